@@ -17,42 +17,55 @@ limitations under the License.
 package access
 
 import (
-	"fmt"
 	"time"
 
-	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/kpango/fastime"
+	"github.com/pkg/errors"
 )
 
+// StandardClaims represents the registered claim names of JWT.
+// https://tools.ietf.org/html/rfc7519#section-4.1
+type StandardClaims struct {
+	Audience  string `json:"aud,omitempty"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+	Id        string `json:"jti,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+	Issuer    string `json:"iss,omitempty"`
+	NotBefore int64  `json:"nbf,omitempty"`
+	Subject   string `json:"sub,omitempty"`
+}
+
 type BaseClaim struct {
-	jwt.StandardClaims
+	StandardClaims
 }
 
-// Valid is copy from source code, and changed c.VerifyExpiresAt parameter.
+// Valid validates the time based claims (exp, iat, nbf). exp is required.
 func (c *BaseClaim) Valid() error {
-	vErr := new(jwt.ValidationError)
-	now := jwt.TimeFunc().Unix()
+	return c.ValidWithLeeway(0)
+}
 
-	if !c.VerifyExpiresAt(now, true) {
-		delta := time.Unix(now, 0).Sub(time.Unix(c.ExpiresAt, 0))
-		vErr.Inner = fmt.Errorf("token is expired by %v", delta)
-		vErr.Errors |= jwt.ValidationErrorExpired
-	}
+// ValidWithLeeway validates the time based claims (exp, iat, nbf), allowing the clock skew of leeway.
+func (c *BaseClaim) ValidWithLeeway(leeway time.Duration) error {
+	now := fastime.Now().Unix()
+	skew := int64(leeway.Seconds())
 
-	if !c.VerifyIssuedAt(now, false) {
-		vErr.Inner = fmt.Errorf("Token used before issued")
-		vErr.Errors |= jwt.ValidationErrorIssuedAt
+	if c.ExpiresAt == 0 {
+		return errors.New("token without expiry")
+	}
+	if now-skew > c.ExpiresAt {
+		delta := time.Unix(now, 0).Sub(time.Unix(c.ExpiresAt, 0))
+		return errors.Errorf("token is expired by %v", delta)
 	}
 
-	if !c.VerifyNotBefore(now, false) {
-		vErr.Inner = fmt.Errorf("token is not valid yet")
-		vErr.Errors |= jwt.ValidationErrorNotValidYet
+	if c.IssuedAt != 0 && now+skew < c.IssuedAt {
+		return errors.New("Token used before issued")
 	}
 
-	if vErr.Errors == 0 {
-		return nil
+	if c.NotBefore != 0 && now+skew < c.NotBefore {
+		return errors.New("token is not valid yet")
 	}
 
-	return vErr
+	return nil
 }
 
 // OAuth2AccessTokenClaim represents access token claim data.
@@ -0,0 +1,80 @@
+/*
+Copyright (C)  2018 Yahoo Japan Corporation Athenz team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package access
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// introspectionResponse represents the token introspection response.
+// https://tools.ietf.org/html/rfc7662#section-2.2
+type introspectionResponse struct {
+	Active bool `json:"active"`
+	// space-separated list of the scopes
+	Scope string `json:"scope,omitempty"`
+	OAuth2AccessTokenClaim
+}
+
+// introspect asks the introspection endpoint whether the token is active, and returns the claims in the response.
+func (a *atp) introspect(ctx context.Context, cred string) (*OAuth2AccessTokenClaim, error) {
+	form := url.Values{}
+	form.Set("token", cred)
+	form.Set("token_type_hint", "access_token")
+
+	req, err := http.NewRequest(http.MethodPost, a.introspectionURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating introspection request")
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	res, err := a.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, errors.Wrap(err, "error making introspection request")
+	}
+	defer func() {
+		// flush the body to reuse the connection
+		_, _ = io.Copy(ioutil.Discard, res.Body)
+		_ = res.Body.Close()
+	}()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("error introspection request returns status code %d", res.StatusCode)
+	}
+
+	ir := new(introspectionResponse)
+	if err := json.NewDecoder(res.Body).Decode(ir); err != nil {
+		return nil, errors.Wrap(err, "error decode introspection response")
+	}
+	if !ir.Active {
+		return nil, errors.New("error access token is not active")
+	}
+
+	claims := ir.OAuth2AccessTokenClaim
+	if len(claims.Scope) == 0 && ir.Scope != "" {
+		claims.Scope = strings.Fields(ir.Scope)
+	}
+	return &claims, nil
+}
@@ -0,0 +1,200 @@
+/*
+Copyright (C)  2018 Yahoo Japan Corporation Athenz team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package access
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_atp_introspect(t *testing.T) {
+	type fields struct {
+		introspectionURL string
+		client           *http.Client
+	}
+	type args struct {
+		ctx  context.Context
+		cred string
+	}
+	type test struct {
+		name    string
+		fields  fields
+		args    args
+		want    *OAuth2AccessTokenClaim
+		wantErr bool
+	}
+	newServer := func(status int, body string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost ||
+				r.Header.Get("Content-Type") != "application/x-www-form-urlencoded" ||
+				r.PostFormValue("token") != "dummyToken" ||
+				r.PostFormValue("token_type_hint") != "access_token" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.WriteHeader(status)
+			_, _ = w.Write([]byte(body))
+		}))
+	}
+	tests := []test{
+		func() test {
+			srv := newServer(http.StatusOK, `{"active":true,"scope":"admin user","client_id":"domain.tenant.service","sub":"domain.tenant.service","exp":9999999999,"cnf":{"x5t#S256":"dummy"}}`)
+			return test{
+				name: "introspect success",
+				fields: fields{
+					introspectionURL: srv.URL,
+					client:           srv.Client(),
+				},
+				args: args{
+					cred: "dummyToken",
+				},
+				want: &OAuth2AccessTokenClaim{
+					BaseClaim: BaseClaim{
+						StandardClaims: StandardClaims{
+							Subject:   "domain.tenant.service",
+							ExpiresAt: 9999999999,
+						},
+					},
+					ClientID: "domain.tenant.service",
+					Scope:    []string{"admin", "user"},
+					Confirm: map[string]string{
+						"x5t#S256": "dummy",
+					},
+				},
+			}
+		}(),
+		func() test {
+			srv := newServer(http.StatusOK, `{"active":true,"scope":"admin","scp":["user"]}`)
+			return test{
+				name: "introspect success, scp takes precedence over scope",
+				fields: fields{
+					introspectionURL: srv.URL,
+					client:           srv.Client(),
+				},
+				args: args{
+					cred: "dummyToken",
+				},
+				want: &OAuth2AccessTokenClaim{
+					Scope: []string{"user"},
+				},
+			}
+		}(),
+		func() test {
+			srv := newServer(http.StatusOK, `{"active":false}`)
+			return test{
+				name: "introspect fail, inactive token",
+				fields: fields{
+					introspectionURL: srv.URL,
+					client:           srv.Client(),
+				},
+				args: args{
+					cred: "dummyToken",
+				},
+				wantErr: true,
+			}
+		}(),
+		func() test {
+			srv := newServer(http.StatusUnauthorized, ``)
+			return test{
+				name: "introspect fail, unexpected status code",
+				fields: fields{
+					introspectionURL: srv.URL,
+					client:           srv.Client(),
+				},
+				args: args{
+					cred: "dummyToken",
+				},
+				wantErr: true,
+			}
+		}(),
+		func() test {
+			srv := newServer(http.StatusOK, `{"active":`)
+			return test{
+				name: "introspect fail, invalid response",
+				fields: fields{
+					introspectionURL: srv.URL,
+					client:           srv.Client(),
+				},
+				args: args{
+					cred: "dummyToken",
+				},
+				wantErr: true,
+			}
+		}(),
+		func() test {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				<-r.Context().Done()
+			}))
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			go func() {
+				<-ctx.Done()
+				cancel()
+			}()
+			return test{
+				name: "introspect fail, context timeout",
+				fields: fields{
+					introspectionURL: srv.URL,
+					client:           srv.Client(),
+				},
+				args: args{
+					ctx:  ctx,
+					cred: "dummyToken",
+				},
+				wantErr: true,
+			}
+		}(),
+		func() test {
+			srv := newServer(http.StatusOK, `{"active":true}`)
+			srv.Close()
+			return test{
+				name: "introspect fail, request error",
+				fields: fields{
+					introspectionURL: srv.URL,
+					client:           srv.Client(),
+				},
+				args: args{
+					cred: "dummyToken",
+				},
+				wantErr: true,
+			}
+		}(),
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &atp{
+				introspectionURL: tt.fields.introspectionURL,
+				client:           tt.fields.client,
+			}
+			ctx := tt.args.ctx
+			if ctx == nil {
+				ctx = context.Background()
+			}
+			got, err := a.introspect(ctx, tt.args.cred)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("atp.introspect() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("atp.introspect() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -17,14 +17,20 @@ limitations under the License.
 package access
 
 import (
+	"net/http"
+	"net/url"
 	"time"
 
 	"github.com/pkg/errors"
+	urlutil "github.com/yahoojapan/athenz-authorizer/v5/internal/url"
 	"github.com/yahoojapan/athenz-authorizer/v5/jwk"
 )
 
 var (
-	defaultOptions = []Option{}
+	defaultOptions = []Option{
+		WithHTTPClient(http.DefaultClient),
+		WithRequestTimeout("3s"),
+	}
 )
 
 // Option represents a functional options pattern interface
@@ -38,6 +44,66 @@ func WithJWKProvider(jwkp jwk.Provider) Option {
 	}
 }
 
+// WithJWKAlgorithmProvider represents set jwk algorithm provider functional option
+func WithJWKAlgorithmProvider(jwkap jwk.AlgorithmProvider) Option {
+	return func(r *atp) error {
+		r.jwkap = jwkap
+		return nil
+	}
+}
+
+// WithJWKRefresher represents set jwk refresher functional option
+func WithJWKRefresher(jwkr jwk.Refresher) Option {
+	return func(r *atp) error {
+		r.jwkr = jwkr
+		return nil
+	}
+}
+
+// WithIntrospectionURL represents set introspectionURL functional option
+func WithIntrospectionURL(u string) Option {
+	return func(r *atp) error {
+		if u == "" {
+			return nil
+		}
+		pu, err := url.ParseRequestURI(u)
+		if err != nil {
+			return errors.Wrap(err, "invalid introspection URL")
+		}
+		if pu.Scheme != "http" && pu.Scheme != "https" {
+			return urlutil.ErrUnsupportedScheme
+		}
+		r.introspectionURL = u
+		return nil
+	}
+}
+
+// WithHTTPClient represents set http client functional option
+func WithHTTPClient(c *http.Client) Option {
+	return func(r *atp) error {
+		if c != nil {
+			r.client = c
+		}
+		return nil
+	}
+}
+
+// WithAllowedAlgorithms represents set allowedAlgorithms functional option
+func WithAllowedAlgorithms(algs []string) Option {
+	return func(r *atp) error {
+		r.allowedAlgorithms = algs
+		return nil
+	}
+}
+
+// WithEnableVerifyTokenType represents set enableVerifyTokenType functional option
+func WithEnableVerifyTokenType(b bool) Option {
+	return func(r *atp) error {
+		r.enableVerifyTokenType = b
+		return nil
+	}
+}
+
 // WithEnableMTLSCertificateBoundAccessToken represents set enableMTLSCertificateBoundAccessToken functional option
 func WithEnableMTLSCertificateBoundAccessToken(b bool) Option {
 	return func(r *atp) error {
@@ -91,3 +157,62 @@ func WithClientCertificateOffsetSeconds(t string) Option {
 		return nil
 	}
 }
+
+// WithAuthorizedIssuers represents set authorizedIssuers functional option
+func WithAuthorizedIssuers(issuers []string) Option {
+	return func(r *atp) error {
+		r.authorizedIssuers = issuers
+		return nil
+	}
+}
+
+// WithJWKSetIssuers represents set jwkSetIssuers functional option.
+// The key of the map is the JWK Set URL (jku), and the value is the issuers accepted for the tokens signed by the keys of that JWK Set.
+func WithJWKSetIssuers(m map[string][]string) Option {
+	return func(r *atp) error {
+		r.jwkSetIssuers = m
+		return nil
+	}
+}
+
+// WithAuthorizedAudiences represents set authorizedAudiences functional option
+func WithAuthorizedAudiences(audiences []string) Option {
+	return func(r *atp) error {
+		r.authorizedAudiences = audiences
+		return nil
+	}
+}
+
+// WithClockSkew represents set clockSkew functional option
+func WithClockSkew(t string) Option {
+	return func(r *atp) error {
+		if t == "" {
+			return nil
+		}
+		cs, err := time.ParseDuration(t)
+		if err != nil {
+			return errors.Wrap(err, "invalid clock skew")
+		}
+		r.clockSkew = cs
+		return nil
+	}
+}
+
+// WithRequestTimeout represents set requestTimeout functional option.
+// It limits the wait for the JWK Set refresh and the introspection request triggered by a token.
+func WithRequestTimeout(t string) Option {
+	return func(r *atp) error {
+		if t == "" {
+			return nil
+		}
+		rt, err := time.ParseDuration(t)
+		if err != nil {
+			return errors.Wrap(err, "invalid request timeout")
+		}
+		if rt <= 0 {
+			return errors.New("invalid request timeout")
+		}
+		r.requestTimeout = rt
+		return nil
+	}
+}
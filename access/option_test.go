@@ -16,10 +16,14 @@ limitations under the License.
 package access
 
 import (
+	"context"
 	"fmt"
+	"net/http"
 	"reflect"
 	"testing"
+	"time"
 
+	urlutil "github.com/yahoojapan/athenz-authorizer/v5/internal/url"
 	"github.com/yahoojapan/athenz-authorizer/v5/jwk"
 )
 
@@ -82,9 +86,496 @@ func TestWithJWKProvider(t *testing.T) {
 	}
 }
 
+func TestWithJWKAlgorithmProvider(t *testing.T) {
+	type args struct {
+		jwkap jwk.AlgorithmProvider
+	}
+	type test struct {
+		name      string
+		args      args
+		checkFunc func(Option) error
+	}
+	tests := []test{
+		func() test {
+			jwkap := jwk.AlgorithmProvider(func(string, string) string {
+				return ""
+			})
+			return test{
+				name: "set success",
+				args: args{
+					jwkap: jwkap,
+				},
+				checkFunc: func(opt Option) error {
+					r := &atp{}
+					if err := opt(r); err != nil {
+						return err
+					}
+					if reflect.ValueOf(r.jwkap) != reflect.ValueOf(jwkap) {
+						return fmt.Errorf("Error")
+					}
+					return nil
+				},
+			}
+		}(),
+		{
+			name: "empty value",
+			args: args{
+				nil,
+			},
+			checkFunc: func(opt Option) error {
+				r := &atp{}
+				if err := opt(r); err != nil {
+					return err
+				}
+				if !reflect.DeepEqual(r, &atp{}) {
+					return fmt.Errorf("expected no changes, but got %v", r)
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithJWKAlgorithmProvider(tt.args.jwkap)
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithJWKAlgorithmProvider() error: %v", err)
+			}
+		})
+	}
+}
+
+func TestWithJWKRefresher(t *testing.T) {
+	type args struct {
+		jwkr jwk.Refresher
+	}
+	type test struct {
+		name      string
+		args      args
+		checkFunc func(Option) error
+	}
+	tests := []test{
+		func() test {
+			jwkr := jwk.Refresher(func(context.Context, string) error {
+				return nil
+			})
+			return test{
+				name: "set success",
+				args: args{
+					jwkr: jwkr,
+				},
+				checkFunc: func(opt Option) error {
+					r := &atp{}
+					if err := opt(r); err != nil {
+						return err
+					}
+					if reflect.ValueOf(r.jwkr) != reflect.ValueOf(jwkr) {
+						return fmt.Errorf("Error")
+					}
+					return nil
+				},
+			}
+		}(),
+		{
+			name: "empty value",
+			args: args{
+				nil,
+			},
+			checkFunc: func(opt Option) error {
+				r := &atp{}
+				if err := opt(r); err != nil {
+					return err
+				}
+				if !reflect.DeepEqual(r, &atp{}) {
+					return fmt.Errorf("expected no changes, but got %v", r)
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithJWKRefresher(tt.args.jwkr)
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithJWKRefresher() error: %v", err)
+			}
+		})
+	}
+}
+
+func TestWithIntrospectionURL(t *testing.T) {
+	type args struct {
+		u string
+	}
+	tests := []struct {
+		name      string
+		args      args
+		checkFunc func(Option) error
+	}{
+		{
+			name: "set success",
+			args: args{
+				u: "https://zts.athenz.io/zts/v1/oauth2/introspect",
+			},
+			checkFunc: func(opt Option) error {
+				r := &atp{}
+				if err := opt(r); err != nil {
+					return err
+				}
+				if r.introspectionURL != "https://zts.athenz.io/zts/v1/oauth2/introspect" {
+					return fmt.Errorf("Error")
+				}
+				return nil
+			},
+		},
+		{
+			name: "empty value",
+			args: args{
+				u: "",
+			},
+			checkFunc: func(opt Option) error {
+				r := &atp{}
+				if err := opt(r); err != nil {
+					return err
+				}
+				if !reflect.DeepEqual(r, &atp{}) {
+					return fmt.Errorf("expected no changes, but got %v", r)
+				}
+				return nil
+			},
+		},
+		{
+			name: "invalid URL",
+			args: args{
+				u: "dummy",
+			},
+			checkFunc: func(opt Option) error {
+				r := &atp{}
+				if err := opt(r); err == nil {
+					return fmt.Errorf("expected error, but not return")
+				}
+				return nil
+			},
+		},
+		{
+			name: "unsupported scheme",
+			args: args{
+				u: "ftp://zts.athenz.io/zts/v1/oauth2/introspect",
+			},
+			checkFunc: func(opt Option) error {
+				r := &atp{}
+				if err := opt(r); err != urlutil.ErrUnsupportedScheme {
+					return fmt.Errorf("expected error: %v, got: %v", urlutil.ErrUnsupportedScheme, err)
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithIntrospectionURL(tt.args.u)
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithIntrospectionURL() error: %v", err)
+			}
+		})
+	}
+}
+
+func TestWithHTTPClient(t *testing.T) {
+	type args struct {
+		c *http.Client
+	}
+	type test struct {
+		name      string
+		args      args
+		checkFunc func(Option) error
+	}
+	tests := []test{
+		func() test {
+			c := &http.Client{}
+			return test{
+				name: "set success",
+				args: args{
+					c: c,
+				},
+				checkFunc: func(opt Option) error {
+					r := &atp{}
+					if err := opt(r); err != nil {
+						return err
+					}
+					if r.client != c {
+						return fmt.Errorf("Error")
+					}
+					return nil
+				},
+			}
+		}(),
+		{
+			name: "empty value",
+			args: args{
+				c: nil,
+			},
+			checkFunc: func(opt Option) error {
+				r := &atp{}
+				if err := opt(r); err != nil {
+					return err
+				}
+				if !reflect.DeepEqual(r, &atp{}) {
+					return fmt.Errorf("expected no changes, but got %v", r)
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithHTTPClient(tt.args.c)
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithHTTPClient() error: %v", err)
+			}
+		})
+	}
+}
+
+func TestWithAllowedAlgorithms(t *testing.T) {
+	type args struct {
+		algs []string
+	}
+	tests := []struct {
+		name      string
+		args      args
+		checkFunc func(Option) error
+	}{
+		{
+			name: "set success",
+			args: args{
+				algs: []string{"RS256", "ES256"},
+			},
+			checkFunc: func(opt Option) error {
+				r := &atp{}
+				if err := opt(r); err != nil {
+					return err
+				}
+				if !reflect.DeepEqual(r.allowedAlgorithms, []string{"RS256", "ES256"}) {
+					return fmt.Errorf("Error")
+				}
+				return nil
+			},
+		},
+		{
+			name: "empty value",
+			args: args{
+				algs: nil,
+			},
+			checkFunc: func(opt Option) error {
+				r := &atp{}
+				if err := opt(r); err != nil {
+					return err
+				}
+				if !reflect.DeepEqual(r, &atp{}) {
+					return fmt.Errorf("expected no changes, but got %v", r)
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithAllowedAlgorithms(tt.args.algs)
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithAllowedAlgorithms() error: %v", err)
+			}
+		})
+	}
+}
+
+func TestWithEnableVerifyTokenType(t *testing.T) {
+	type args struct {
+		b bool
+	}
+	tests := []struct {
+		name      string
+		args      args
+		checkFunc func(Option) error
+	}{
+		{
+			name: "set success",
+			args: args{
+				b: true,
+			},
+			checkFunc: func(opt Option) error {
+				r := &atp{}
+				if err := opt(r); err != nil {
+					return err
+				}
+				if !r.enableVerifyTokenType {
+					return fmt.Errorf("Error")
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithEnableVerifyTokenType(tt.args.b)
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithEnableVerifyTokenType() error: %v", err)
+			}
+		})
+	}
+}
+
 func TestWithEnableMTLSCertificateBoundAccessToken(t *testing.T) {
 	type args struct {
-		b bool
+		b bool
+	}
+	tests := []struct {
+		name      string
+		args      args
+		checkFunc func(Option) error
+	}{
+		{
+			name: "set success",
+			args: args{
+				b: true,
+			},
+			checkFunc: func(opt Option) error {
+				r := &atp{}
+				if err := opt(r); err != nil {
+					return err
+				}
+				if !r.enableMTLSCertificateBoundAccessToken {
+					return fmt.Errorf("Error")
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithEnableMTLSCertificateBoundAccessToken(tt.args.b)
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithEnableMTLSCertificateBoundAccessToken() error: %v", err)
+			}
+		})
+	}
+}
+
+func TestWithEnableVerifyClientID(t *testing.T) {
+	type args struct {
+		b bool
+	}
+	tests := []struct {
+		name      string
+		args      args
+		checkFunc func(Option) error
+	}{
+		{
+			name: "set success",
+			args: args{
+				b: true,
+			},
+			checkFunc: func(opt Option) error {
+				r := &atp{}
+				if err := opt(r); err != nil {
+					return err
+				}
+				if !r.enableVerifyClientID {
+					return fmt.Errorf("Error")
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithEnableVerifyClientID(tt.args.b)
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithEnableVerifyClientID() error: %v", err)
+			}
+		})
+	}
+}
+
+func TestWithAuthorizedClientIDs(t *testing.T) {
+	type args struct {
+		m map[string][]string
+	}
+	type test struct {
+		name      string
+		args      args
+		checkFunc func(Option) error
+	}
+	tests := []test{
+		func() test {
+			normalAuthorizedClientIDs := map[string][]string{
+				"cn1": {"cid1a", "cid2b"},
+			}
+			return test{
+				name: "set success",
+				args: args{
+					m: normalAuthorizedClientIDs,
+				},
+				checkFunc: func(opt Option) error {
+					r := &atp{}
+					if err := opt(r); err != nil {
+						return err
+					}
+					if !reflect.DeepEqual(r.authorizedClientIDs, normalAuthorizedClientIDs) {
+						return fmt.Errorf("Error")
+					}
+					return nil
+				},
+			}
+		}(),
+		func() test {
+			emptyAuthorizedClientIDs := map[string][]string{}
+			return test{
+				name: "empty value",
+				args: args{
+					m: emptyAuthorizedClientIDs,
+				},
+				checkFunc: func(opt Option) error {
+					r := &atp{}
+					if err := opt(r); err != nil {
+						return err
+					}
+					if !reflect.DeepEqual(r.authorizedClientIDs, emptyAuthorizedClientIDs) {
+						return fmt.Errorf("Error")
+					}
+					return nil
+				},
+			}
+		}(),
+		{
+			name: "empty value",
+			args: args{
+				m: nil,
+			},
+			checkFunc: func(opt Option) error {
+				r := &atp{}
+				if err := opt(r); err != nil {
+					return err
+				}
+				if r.authorizedClientIDs != nil {
+					return fmt.Errorf("Error")
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithAuthorizedClientIDs(tt.args.m)
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithAuthorizedClientIDs() error: %v", err)
+			}
+		})
+	}
+}
+
+func TestWithClientCertificateGoBackSeconds(t *testing.T) {
+	type args struct {
+		t string
 	}
 	tests := []struct {
 		name      string
@@ -94,33 +585,62 @@ func TestWithEnableMTLSCertificateBoundAccessToken(t *testing.T) {
 		{
 			name: "set success",
 			args: args{
-				b: true,
+				t: "2h",
 			},
 			checkFunc: func(opt Option) error {
 				r := &atp{}
 				if err := opt(r); err != nil {
 					return err
 				}
-				if !r.enableMTLSCertificateBoundAccessToken {
+				if r.clientCertificateGoBackSeconds != 7200 {
 					return fmt.Errorf("Error")
 				}
 				return nil
 			},
 		},
+		{
+			name: "empty value",
+			args: args{
+				t: "",
+			},
+			checkFunc: func(opt Option) error {
+				r := &atp{}
+				if err := opt(r); err != nil {
+					return err
+				}
+				if !reflect.DeepEqual(r, &atp{}) {
+					return fmt.Errorf("expected no changes, but got %v", r)
+				}
+				return nil
+			},
+		},
+		{
+			name: "invalid format",
+			args: args{
+				t: "invalid",
+			},
+			checkFunc: func(opt Option) error {
+				r := &atp{}
+				if err := opt(r); err == nil {
+					return fmt.Errorf("expected error, but not return")
+				}
+				return nil
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := WithEnableMTLSCertificateBoundAccessToken(tt.args.b)
+			got := WithClientCertificateGoBackSeconds(tt.args.t)
 			if err := tt.checkFunc(got); err != nil {
-				t.Errorf("WithEnableMTLSCertificateBoundAccessToken() error: %v", err)
+				t.Errorf("WithClientCertificateGoBackSeconds() error: %v", err)
 			}
 		})
 	}
 }
 
-func TestWithEnableVerifyClientID(t *testing.T) {
+func TestWithClientCertificateOffsetSeconds(t *testing.T) {
 	type args struct {
-		b bool
+		t string
 	}
 	tests := []struct {
 		name      string
@@ -130,80 +650,141 @@ func TestWithEnableVerifyClientID(t *testing.T) {
 		{
 			name: "set success",
 			args: args{
-				b: true,
+				t: "2h",
 			},
 			checkFunc: func(opt Option) error {
 				r := &atp{}
 				if err := opt(r); err != nil {
 					return err
 				}
-				if !r.enableVerifyClientID {
+				if r.clientCertificateOffsetSeconds != 7200 {
 					return fmt.Errorf("Error")
 				}
 				return nil
 			},
 		},
+		{
+			name: "empty value",
+			args: args{
+				t: "",
+			},
+			checkFunc: func(opt Option) error {
+				r := &atp{}
+				if err := opt(r); err != nil {
+					return err
+				}
+				if !reflect.DeepEqual(r, &atp{}) {
+					return fmt.Errorf("expected no changes, but got %v", r)
+				}
+				return nil
+			},
+		},
+		{
+			name: "invalid format",
+			args: args{
+				t: "invalid",
+			},
+			checkFunc: func(opt Option) error {
+				r := &atp{}
+				if err := opt(r); err == nil {
+					return fmt.Errorf("expected error, but not return")
+				}
+				return nil
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := WithEnableVerifyClientID(tt.args.b)
+			got := WithClientCertificateOffsetSeconds(tt.args.t)
 			if err := tt.checkFunc(got); err != nil {
-				t.Errorf("WithEnableVerifyClientID() error: %v", err)
+				t.Errorf("WithClientCertificateOffsetSeconds() error: %v", err)
 			}
 		})
 	}
 }
 
-func TestWithAuthorizedClientIDs(t *testing.T) {
+func TestWithAuthorizedIssuers(t *testing.T) {
 	type args struct {
-		m map[string][]string
+		issuers []string
 	}
-	type test struct {
+	tests := []struct {
 		name      string
 		args      args
 		checkFunc func(Option) error
+	}{
+		{
+			name: "set success",
+			args: args{
+				issuers: []string{"https://zts.athenz.io"},
+			},
+			checkFunc: func(opt Option) error {
+				r := &atp{}
+				if err := opt(r); err != nil {
+					return err
+				}
+				if !reflect.DeepEqual(r.authorizedIssuers, []string{"https://zts.athenz.io"}) {
+					return fmt.Errorf("Error")
+				}
+				return nil
+			},
+		},
+		{
+			name: "empty value",
+			args: args{
+				issuers: nil,
+			},
+			checkFunc: func(opt Option) error {
+				r := &atp{}
+				if err := opt(r); err != nil {
+					return err
+				}
+				if !reflect.DeepEqual(r, &atp{}) {
+					return fmt.Errorf("expected no changes, but got %v", r)
+				}
+				return nil
+			},
+		},
 	}
-	tests := []test{
-		func() test {
-			normalAuthorizedClientIDs := map[string][]string{
-				"cn1": {"cid1a", "cid2b"},
-			}
-			return test{
-				name: "set success",
-				args: args{
-					m: normalAuthorizedClientIDs,
-				},
-				checkFunc: func(opt Option) error {
-					r := &atp{}
-					if err := opt(r); err != nil {
-						return err
-					}
-					if !reflect.DeepEqual(r.authorizedClientIDs, normalAuthorizedClientIDs) {
-						return fmt.Errorf("Error")
-					}
-					return nil
-				},
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithAuthorizedIssuers(tt.args.issuers)
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithAuthorizedIssuers() error: %v", err)
 			}
-		}(),
-		func() test {
-			emptyAuthorizedClientIDs := map[string][]string{}
-			return test{
-				name: "empty value",
-				args: args{
-					m: emptyAuthorizedClientIDs,
-				},
-				checkFunc: func(opt Option) error {
-					r := &atp{}
-					if err := opt(r); err != nil {
-						return err
-					}
-					if !reflect.DeepEqual(r.authorizedClientIDs, emptyAuthorizedClientIDs) {
-						return fmt.Errorf("Error")
-					}
-					return nil
+		})
+	}
+}
+
+func TestWithJWKSetIssuers(t *testing.T) {
+	type args struct {
+		m map[string][]string
+	}
+	tests := []struct {
+		name      string
+		args      args
+		checkFunc func(Option) error
+	}{
+		{
+			name: "set success",
+			args: args{
+				m: map[string][]string{
+					"https://zts.athenz.io/oauth2/keys": {"https://zts.athenz.io"},
 				},
-			}
-		}(),
+			},
+			checkFunc: func(opt Option) error {
+				r := &atp{}
+				if err := opt(r); err != nil {
+					return err
+				}
+				want := map[string][]string{
+					"https://zts.athenz.io/oauth2/keys": {"https://zts.athenz.io"},
+				}
+				if !reflect.DeepEqual(r.jwkSetIssuers, want) {
+					return fmt.Errorf("Error")
+				}
+				return nil
+			},
+		},
 		{
 			name: "empty value",
 			args: args{
@@ -214,24 +795,76 @@ func TestWithAuthorizedClientIDs(t *testing.T) {
 				if err := opt(r); err != nil {
 					return err
 				}
-				if r.authorizedClientIDs != nil {
+				if !reflect.DeepEqual(r, &atp{}) {
+					return fmt.Errorf("expected no changes, but got %v", r)
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithJWKSetIssuers(tt.args.m)
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithJWKSetIssuers() error: %v", err)
+			}
+		})
+	}
+}
+
+func TestWithAuthorizedAudiences(t *testing.T) {
+	type args struct {
+		audiences []string
+	}
+	tests := []struct {
+		name      string
+		args      args
+		checkFunc func(Option) error
+	}{
+		{
+			name: "set success",
+			args: args{
+				audiences: []string{"domain1", "domain2"},
+			},
+			checkFunc: func(opt Option) error {
+				r := &atp{}
+				if err := opt(r); err != nil {
+					return err
+				}
+				if !reflect.DeepEqual(r.authorizedAudiences, []string{"domain1", "domain2"}) {
 					return fmt.Errorf("Error")
 				}
 				return nil
 			},
 		},
+		{
+			name: "empty value",
+			args: args{
+				audiences: nil,
+			},
+			checkFunc: func(opt Option) error {
+				r := &atp{}
+				if err := opt(r); err != nil {
+					return err
+				}
+				if !reflect.DeepEqual(r, &atp{}) {
+					return fmt.Errorf("expected no changes, but got %v", r)
+				}
+				return nil
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := WithAuthorizedClientIDs(tt.args.m)
+			got := WithAuthorizedAudiences(tt.args.audiences)
 			if err := tt.checkFunc(got); err != nil {
-				t.Errorf("WithAuthorizedClientIDs() error: %v", err)
+				t.Errorf("WithAuthorizedAudiences() error: %v", err)
 			}
 		})
 	}
 }
 
-func TestWithClientCertificateGoBackSeconds(t *testing.T) {
+func TestWithClockSkew(t *testing.T) {
 	type args struct {
 		t string
 	}
@@ -243,14 +876,14 @@ func TestWithClientCertificateGoBackSeconds(t *testing.T) {
 		{
 			name: "set success",
 			args: args{
-				t: "2h",
+				t: "1m",
 			},
 			checkFunc: func(opt Option) error {
 				r := &atp{}
 				if err := opt(r); err != nil {
 					return err
 				}
-				if r.clientCertificateGoBackSeconds != 7200 {
+				if r.clockSkew != time.Minute {
 					return fmt.Errorf("Error")
 				}
 				return nil
@@ -288,15 +921,15 @@ func TestWithClientCertificateGoBackSeconds(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := WithClientCertificateGoBackSeconds(tt.args.t)
+			got := WithClockSkew(tt.args.t)
 			if err := tt.checkFunc(got); err != nil {
-				t.Errorf("WithClientCertificateGoBackSeconds() error: %v", err)
+				t.Errorf("WithClockSkew() error: %v", err)
 			}
 		})
 	}
 }
 
-func TestWithClientCertificateOffsetSeconds(t *testing.T) {
+func TestWithRequestTimeout(t *testing.T) {
 	type args struct {
 		t string
 	}
@@ -308,14 +941,14 @@ func TestWithClientCertificateOffsetSeconds(t *testing.T) {
 		{
 			name: "set success",
 			args: args{
-				t: "2h",
+				t: "1s",
 			},
 			checkFunc: func(opt Option) error {
 				r := &atp{}
 				if err := opt(r); err != nil {
 					return err
 				}
-				if r.clientCertificateOffsetSeconds != 7200 {
+				if r.requestTimeout != time.Second {
 					return fmt.Errorf("Error")
 				}
 				return nil
@@ -350,12 +983,25 @@ func TestWithClientCertificateOffsetSeconds(t *testing.T) {
 				return nil
 			},
 		},
+		{
+			name: "zero timeout",
+			args: args{
+				t: "0",
+			},
+			checkFunc: func(opt Option) error {
+				r := &atp{}
+				if err := opt(r); err == nil {
+					return fmt.Errorf("expected error, but not return")
+				}
+				return nil
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := WithClientCertificateOffsetSeconds(tt.args.t)
+			got := WithRequestTimeout(tt.args.t)
 			if err := tt.checkFunc(got); err != nil {
-				t.Errorf("WithClientCertificateOffsetSeconds() error: %v", err)
+				t.Errorf("WithRequestTimeout() error: %v", err)
 			}
 		})
 	}
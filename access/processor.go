@@ -17,11 +17,20 @@ limitations under the License.
 package access
 
 import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
 	"crypto/sha256"
 	"crypto/x509"
 	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
 
-	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/kpango/glg"
+	"github.com/lestrrat-go/jwx/jwa"
 	"github.com/lestrrat-go/jwx/jws"
 	"github.com/pkg/errors"
 	"github.com/yahoojapan/athenz-authorizer/v5/jwk"
@@ -31,14 +40,7 @@ const (
 	confirmMethodMember = "x5t#S256"
 )
 
-// errNilHeader is "header is nil"
-var errNilHeader = errors.New("header is nil")
-
-// errKeyNotFoundInHeader is "key not written in header"
-var errKeyNotFoundInHeader = errors.New("key not written in header")
-
-// errHeaderValueNotString is "header value not written as string"
-var errHeaderValueNotString = errors.New("header value not written as string")
+var errKeyNotFound = errors.New("key cannot be found")
 
 // Processor represents the access token parser interface.
 type Processor interface {
@@ -46,7 +48,13 @@ type Processor interface {
 }
 
 type atp struct {
-	jwkp                                  jwk.Provider
+	jwkp jwk.Provider
+	// Used for checking the token alg against the alg declared in the JWK. Optional.
+	jwkap jwk.AlgorithmProvider
+	// The signing algorithms accepted for the tokens. Empty means any algorithm consistent with the key type.
+	allowedAlgorithms []string
+	// Verify the typ header is at+jwt. (RFC 9068)
+	enableVerifyTokenType                 bool
 	enableMTLSCertificateBoundAccessToken bool
 	// If you go back to the issue time, set that time. Subtract if necessary (for example, token issuance time).
 	clientCertificateGoBackSeconds int64
@@ -54,6 +62,21 @@ type atp struct {
 	clientCertificateOffsetSeconds int64
 	enableVerifyClientID           bool
 	authorizedClientIDs            map[string][]string
+	// The issuers accepted for the tokens, used when the token's jku has no entry in jwkSetIssuers. Empty means the issuer is not checked.
+	authorizedIssuers []string
+	// The issuers accepted for the tokens signed by the keys of a specific JWK Set URL (jku).
+	jwkSetIssuers map[string][]string
+	// The audiences accepted for the tokens. Empty means the audience is not checked.
+	authorizedAudiences []string
+	// The clock skew allowed when validating exp, iat and nbf.
+	clockSkew time.Duration
+	// Used for refreshing the JWK Set when the key is not found. Optional.
+	jwkr jwk.Refresher
+	// The token introspection endpoint (RFC 7662) used when the token is opaque or the key is still not found. Optional.
+	introspectionURL string
+	client           *http.Client
+	// The maximum wait for the JWK Set refresh and the introspection request triggered by a token.
+	requestTimeout time.Duration
 }
 
 // New returns the Processor instance.
@@ -68,21 +91,118 @@ func New(opts ...Option) (Processor, error) {
 }
 
 func (a *atp) ParseAndValidateOAuth2AccessToken(cred string, cert *x509.Certificate) (*OAuth2AccessTokenClaim, error) {
-
-	tok, err := jwt.ParseWithClaims(cred, &OAuth2AccessTokenClaim{}, a.keyFunc)
+	// only the compact serialization is accepted
+	if strings.HasPrefix(strings.TrimSpace(cred), "{") {
+		return nil, errors.New("error access token is not in compact serialization")
+	}
+	msg, err := jws.ParseString(cred)
 	if err != nil {
+		// the token may be an opaque token
+		if a.introspectionURL != "" {
+			return a.introspectAndValidate(cred, cert)
+		}
 		return nil, err
 	}
-	claims, ok := tok.Claims.(*OAuth2AccessTokenClaim)
-	if !ok || !tok.Valid {
+	sigs := msg.Signatures()
+	if len(sigs) != 1 {
 		return nil, errors.New("error invalid access token")
 	}
+	header := sigs[0].ProtectedHeaders()
+
+	alg := header.Algorithm()
+	if !a.isAllowedAlgorithm(alg) {
+		return nil, errors.Errorf("error signing method %s is invalid", alg)
+	}
+
+	if a.enableVerifyTokenType {
+		if err := validateTokenType(header.Type()); err != nil {
+			return nil, err
+		}
+	}
+
+	key, err := a.keyFunc(header)
+	if errors.Cause(err) == errKeyNotFound {
+		// the key may be rotated after the last refresh of the JWK Set
+		if a.jwkr != nil {
+			ctx, cancel := a.requestContext()
+			if rerr := a.jwkr.Wait(ctx, header.JWKSetURL()); rerr != nil {
+				glg.Debugf("refresh JWK Set failed: %v", rerr)
+			}
+			cancel()
+			key, err = a.keyFunc(header)
+		}
+		if errors.Cause(err) == errKeyNotFound && a.introspectionURL != "" {
+			return a.introspectAndValidate(cred, cert)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	payload, err := jws.Verify([]byte(cred), alg, key)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := new(OAuth2AccessTokenClaim)
+	if err := json.Unmarshal(payload, claims); err != nil {
+		return nil, errors.Wrap(err, "error invalid access token claims")
+	}
+	if err := claims.ValidWithLeeway(a.clockSkew); err != nil {
+		return nil, err
+	}
+
+	if err := a.validateClaims(header.JWKSetURL(), claims, cert); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// requestContext returns the context of the request triggered by a token, canceled after requestTimeout if set.
+func (a *atp) requestContext() (context.Context, context.CancelFunc) {
+	if a.requestTimeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), a.requestTimeout)
+}
+
+// introspectAndValidate validates the token by the introspection endpoint instead of the signature.
+func (a *atp) introspectAndValidate(cred string, cert *x509.Certificate) (*OAuth2AccessTokenClaim, error) {
+	ctx, cancel := a.requestContext()
+	defer cancel()
+	claims, err := a.introspect(ctx, cred)
+	if err != nil {
+		return nil, err
+	}
+	// exp is optional in the introspection response, the endpoint has already checked it if active
+	if claims.ExpiresAt != 0 {
+		if err := claims.ValidWithLeeway(a.clockSkew); err != nil {
+			return nil, err
+		}
+	}
+
+	// the issuers for the JWK Set URL is not applied since the token is not verified by the JWK Set
+	if err := a.validateClaims("", claims, cert); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (a *atp) validateClaims(jwkSetURL string, claims *OAuth2AccessTokenClaim, cert *x509.Certificate) error {
+	// validate iss of AccessToken
+	if err := a.validateIssuer(jwkSetURL, claims); err != nil {
+		return err
+	}
+
+	// validate aud of AccessToken
+	if err := a.validateAudience(claims); err != nil {
+		return err
+	}
 
 	// validate client_id of AccessToken
 	if a.enableVerifyClientID {
 		err := a.validateClientID(cert, claims)
 		if err != nil {
-			return nil, err
+			return err
 		}
 	}
 
@@ -90,11 +210,11 @@ func (a *atp) ParseAndValidateOAuth2AccessToken(cred string, cert *x509.Certific
 	if a.enableMTLSCertificateBoundAccessToken {
 		err := a.validateCertificateBoundAccessToken(cert, claims)
 		if err != nil {
-			return nil, err
+			return err
 		}
 	}
 
-	return claims, nil
+	return nil
 }
 
 func (a *atp) validateClientID(cert *x509.Certificate, claims *OAuth2AccessTokenClaim) error {
@@ -117,6 +237,42 @@ func (a *atp) validateClientID(cert *x509.Certificate, claims *OAuth2AccessToken
 	return errors.Errorf("error %v is not authorized %v", clientID, cn)
 }
 
+func (a *atp) validateIssuer(jwkSetURL string, claims *OAuth2AccessTokenClaim) error {
+	issuers, ok := a.jwkSetIssuers[jwkSetURL]
+	if !ok {
+		issuers = a.authorizedIssuers
+	}
+	if len(issuers) == 0 {
+		return nil
+	}
+	if claims == nil {
+		return errors.New("error claim of access token is nil")
+	}
+
+	for _, v := range issuers {
+		if v == claims.Issuer {
+			return nil
+		}
+	}
+	return errors.Errorf("error issuer %v is not authorized, jwkSetURL: %v", claims.Issuer, jwkSetURL)
+}
+
+func (a *atp) validateAudience(claims *OAuth2AccessTokenClaim) error {
+	if len(a.authorizedAudiences) == 0 {
+		return nil
+	}
+	if claims == nil {
+		return errors.New("error claim of access token is nil")
+	}
+
+	for _, v := range a.authorizedAudiences {
+		if v == claims.Audience {
+			return nil
+		}
+	}
+	return errors.Errorf("error audience %v is not authorized", claims.Audience)
+}
+
 func (a *atp) validateCertificateBoundAccessToken(cert *x509.Certificate, claims *OAuth2AccessTokenClaim) error {
 	if cert == nil {
 		return errors.New("error mTLS client certificate is nil")
@@ -177,48 +333,86 @@ func (a *atp) validateCertPrincipal(cert *x509.Certificate, claims *OAuth2Access
 	return nil
 }
 
-// keyFunc extract the key id from the token, and return corresponding key
-func (a *atp) keyFunc(token *jwt.Token) (interface{}, error) {
-	keyID, err := getAsStringFromHeader(&token.Header, jws.KeyIDKey)
-	// kid is required and will return if an error occurs
-	if err != nil {
-		return nil, errors.New(err.Error() + ":" + jws.KeyIDKey)
+// keyFunc extract the key id from the token header, and return corresponding key
+func (a *atp) keyFunc(header jws.Headers) (interface{}, error) {
+	if header == nil {
+		return nil, errors.New("header is nil")
 	}
+	alg := header.Algorithm()
 
-	jwkSetURL, err := getAsStringFromHeader(&token.Header, jws.JWKSetURLKey)
-	// return not string error or nil header error.
-	// If not found error, assume it is an athenz token and continue.
-	if err == errHeaderValueNotString || err == errNilHeader {
-		return nil, errors.New(err.Error() + ":" + jws.JWKSetURLKey)
+	keyID := header.KeyID()
+	// kid is required
+	if keyID == "" {
+		return nil, errors.New("key not written in header:" + jws.KeyIDKey)
 	}
 
+	// If jku is not found, assume it is an athenz token and continue.
+	jwkSetURL := header.JWKSetURL()
+
 	key := a.jwkp(keyID, jwkSetURL)
 
 	if key == nil {
-		return nil, errors.Errorf("key cannot be found, keyID: %s jwkSetURL: %s", keyID, jwkSetURL)
+		return nil, errors.Wrapf(errKeyNotFound, "keyID: %s jwkSetURL: %s", keyID, jwkSetURL)
+	}
+
+	// reject the token if alg is not consistent with the key, e.g. HS256 signed by the RSA public key
+	if err := validateKeyType(alg, key); err != nil {
+		return nil, errors.Wrapf(err, "keyID: %s jwkSetURL: %s", keyID, jwkSetURL)
+	}
+	if a.jwkap != nil {
+		if jwkAlg := a.jwkap(keyID, jwkSetURL); jwkAlg != "" && jwkAlg != alg.String() {
+			return nil, errors.Errorf("alg mismatch, token: %s, key: %s, keyID: %s jwkSetURL: %s", alg, jwkAlg, keyID, jwkSetURL)
+		}
 	}
 
 	return key, nil
 }
 
-// getAsStringFromHeader return string header value and error.
-// return error is not found or not string cases.
-func getAsStringFromHeader(header *map[string]interface{}, key string) (string, error) {
-	var ok bool
-
-	if header == nil {
-		return "", errNilHeader
+func (a *atp) isAllowedAlgorithm(alg jwa.SignatureAlgorithm) bool {
+	if alg == "" || alg == jwa.NoSignature {
+		return false
 	}
-
-	var v interface{}
-	if v, ok = (*header)[key]; !ok {
-		return "", errKeyNotFoundInHeader
+	if len(a.allowedAlgorithms) == 0 {
+		return true
+	}
+	for _, v := range a.allowedAlgorithms {
+		if v == alg.String() {
+			return true
+		}
 	}
+	return false
+}
 
-	var value string
-	if value, ok = v.(string); !ok {
-		return "", errHeaderValueNotString
+// validateTokenType validates the typ header. https://tools.ietf.org/html/rfc9068#section-2.1
+func validateTokenType(typ string) error {
+	switch strings.ToLower(typ) {
+	case "at+jwt", "application/at+jwt":
+		return nil
 	}
+	return errors.Errorf("error invalid token type: %s", typ)
+}
 
-	return value, nil
+// validateKeyType returns error if the signing algorithm cannot be used with the key type.
+func validateKeyType(alg jwa.SignatureAlgorithm, key interface{}) error {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		switch alg {
+		case jwa.RS256, jwa.RS384, jwa.RS512, jwa.PS256, jwa.PS384, jwa.PS512:
+			return nil
+		}
+	case *ecdsa.PublicKey:
+		var curve elliptic.Curve
+		switch alg {
+		case jwa.ES256:
+			curve = elliptic.P256()
+		case jwa.ES384:
+			curve = elliptic.P384()
+		case jwa.ES512:
+			curve = elliptic.P521()
+		}
+		if curve != nil && k.Curve == curve {
+			return nil
+		}
+	}
+	return errors.Errorf("alg %s cannot be used with key type %T", alg, key)
 }
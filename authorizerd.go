@@ -19,11 +19,11 @@ package authorizerd
 import (
 	"context"
 	"crypto/x509"
+	"net"
 	"net/http"
 	"strings"
 	"time"
 
-	"github.com/dgrijalva/jwt-go/request"
 	"github.com/kpango/gache"
 	"github.com/kpango/glg"
 	"github.com/pkg/errors"
@@ -49,6 +49,7 @@ type Authorizerd interface {
 	VerifyRoleCert(ctx context.Context, peerCerts []*x509.Certificate, act, res string) error
 	AuthorizeRoleCert(ctx context.Context, peerCerts []*x509.Certificate, act, res string) (Principal, error)
 	GetPolicyCache(ctx context.Context) map[string]interface{}
+	GetPolicyStatus(ctx context.Context) map[string]policy.DomainStatus
 }
 
 type authorizer func(r *http.Request, act, res string) (Principal, error)
@@ -66,6 +67,9 @@ type authority struct {
 	athenzURL string
 	client    *http.Client
 
+	// how the results of the authorizers are combined
+	combinationPolicy CombinationPolicy
+
 	// successful result cache
 	cache    gache.Gache
 	cacheExp time.Duration
@@ -80,31 +84,77 @@ type authority struct {
 	pubkeySysAuthDomain   string
 	pubkeyETagExpiry      string
 	pubkeyETagPurgePeriod string
+	pubkeyKeyGracePeriod  string
+	pubkeyKeySources      []pubkey.KeySource
+	pubkeyEnvironments    map[pubkey.AthenzEnv]pubkey.EnvConfig
 
 	// policyd parameters
-	disablePolicyd      bool
-	athenzDomains       []string
-	policyExpiryMargin  string
-	policyRefreshPeriod string
-	policyPurgePeriod   string
-	policyRetryDelay    string
-	policyRetryAttempts int
+	disablePolicyd         bool
+	athenzDomains          []string
+	policyExpiryMargin     string
+	policyRefreshPeriod    string
+	policyPurgePeriod      string
+	policyRetryDelay       string
+	policyRetryAttempts    int
+	policyMaxRetryDelay    string
+	policyMaxStaleness     string
+	policyFetchConcurrency int
+	policyRefreshSpread    string
+	policyBulkSource       policy.BulkSource
+	policyShadowSource     policy.BulkSource
+	policyDryRun           bool
+	policyDryRunDomains    []string
+	policyDecisionHook     policy.DecisionHook
 
 	// jwkd parameters
 	disableJwkd      bool
 	jwkRefreshPeriod string
 	jwkRetryDelay    string
 	jwkURLs          []string
+	// The minimum interval of the JWK Set refresh triggered by an unknown key ID
+	jwkMinRefreshInterval string
+	// Refresh the JWK Set in background when the key ID is not found
+	jwkAsyncRefreshOnMiss bool
+	// The JWK Set URL patterns allowed to be fetched on demand, and the maximum number of the JWK Sets fetched by them
+	jwkURLPatterns    []string
+	jwkMaxDynamicURLs int
+	// The minimum interval between the fetches of the new JWK Set URLs by the patterns
+	jwkDynamicURLFetchInterval string
+	// The maximum number of the JWK Set URLs fetched concurrently
+	jwkFetchConcurrency int
 
 	// accessTokenProcessor parameters
-	accessTokenParam AccessTokenParam
+	accessTokenParam          AccessTokenParam
+	accessTokenExtractors     []CredentialExtractor
+	accessTokenIssuers        []string
+	accessTokenJWKSetIssuers  map[string][]string
+	accessTokenVerifyAudience bool
+	accessTokenClockSkew      string
+	accessTokenAlgorithms     []string
+	accessTokenVerifyType     bool
+	// Refresh the JWK Set when the key ID of the access token is not found
+	accessTokenJWKRefreshOnMiss bool
+	accessTokenIntrospectionURL string
+	// The maximum wait for the JWK Set refresh and the introspection request triggered by the access token
+	accessTokenRequestTimeout string
 
 	// roleTokenProcessor parameters
-	enableRoleToken bool
-	roleAuthHeader  string
+	enableRoleToken     bool
+	roleAuthHeader      string
+	roleTokenExtractors []CredentialExtractor
+	// Accept only the role token issued for the remote address of the request
+	roleTokenVerifyIP             bool
+	roleTokenAuthorizedProxyUsers []string
+	roleTokenClockSkew            string
+	roleTokenMaxLifetime          string
 
 	// roleCertificateProcessor parameters
 	enableRoleCert bool
+	// Trusted CA of the role certificate, the chain is not verified if nil
+	roleCertCAPool       *x509.CertPool
+	roleCertVerifyExpiry bool
+	// Accepted issuer DN of the role certificate, not verified if empty
+	roleCertIssuers []string
 
 	translator Translator
 }
@@ -123,9 +173,11 @@ func New(opts ...Option) (Authorizerd, error) {
 		prov = &authority{
 			cache: gache.New(),
 		}
-		err    error
-		pkPro  pubkey.Provider
-		jwkPro jwk.Provider
+		err     error
+		pkPro   pubkey.Provider
+		jwkPro  jwk.Provider
+		jwkaPro jwk.AlgorithmProvider
+		jwkr    jwk.Refresher
 	)
 
 	for _, opt := range append(defaultOptions, opts...) {
@@ -135,15 +187,21 @@ func New(opts ...Option) (Authorizerd, error) {
 	}
 
 	if !prov.disablePubkeyd {
-		if prov.pubkeyd, err = pubkey.New(
+		pubkeyOpts := []pubkey.Option{
 			pubkey.WithAthenzURL(prov.athenzURL),
 			pubkey.WithSysAuthDomain(prov.pubkeySysAuthDomain),
 			pubkey.WithETagExpiry(prov.pubkeyETagExpiry),
 			pubkey.WithETagPurgePeriod(prov.pubkeyETagPurgePeriod),
 			pubkey.WithRefreshPeriod(prov.pubkeyRefreshPeriod),
 			pubkey.WithRetryDelay(prov.pubkeyRetryDelay),
+			pubkey.WithKeyGracePeriod(prov.pubkeyKeyGracePeriod),
+			pubkey.WithKeySources(prov.pubkeyKeySources...),
 			pubkey.WithHTTPClient(prov.client),
-		); err != nil {
+		}
+		for env, cfg := range prov.pubkeyEnvironments {
+			pubkeyOpts = append(pubkeyOpts, pubkey.WithEnvironment(env, cfg))
+		}
+		if prov.pubkeyd, err = pubkey.New(pubkeyOpts...); err != nil {
 			return nil, err
 		}
 		pkPro = prov.pubkeyd.GetProvider()
@@ -158,6 +216,15 @@ func New(opts ...Option) (Authorizerd, error) {
 			policy.WithPurgePeriod(prov.policyPurgePeriod),
 			policy.WithRetryDelay(prov.policyRetryDelay),
 			policy.WithRetryAttempts(prov.policyRetryAttempts),
+			policy.WithMaxRetryDelay(prov.policyMaxRetryDelay),
+			policy.WithMaxStaleness(prov.policyMaxStaleness),
+			policy.WithFetchConcurrency(prov.policyFetchConcurrency),
+			policy.WithRefreshSpread(prov.policyRefreshSpread),
+			policy.WithBulkSource(prov.policyBulkSource),
+			policy.WithShadowSource(prov.policyShadowSource),
+			policy.WithDryRun(prov.policyDryRun),
+			policy.WithDryRunDomains(prov.policyDryRunDomains...),
+			policy.WithDecisionHook(prov.policyDecisionHook),
 			policy.WithHTTPClient(prov.client),
 			policy.WithPubKeyProvider(pkPro),
 		); err != nil {
@@ -171,29 +238,60 @@ func New(opts ...Option) (Authorizerd, error) {
 			jwk.WithRefreshPeriod(prov.jwkRefreshPeriod),
 			jwk.WithRetryDelay(prov.jwkRetryDelay),
 			jwk.WithURLs(prov.jwkURLs),
+			jwk.WithMinRefreshInterval(prov.jwkMinRefreshInterval),
+			jwk.WithAsyncRefreshOnMiss(prov.jwkAsyncRefreshOnMiss),
+			jwk.WithURLPatterns(prov.jwkURLPatterns),
+			jwk.WithMaxDynamicURLs(prov.jwkMaxDynamicURLs),
+			jwk.WithDynamicURLFetchInterval(prov.jwkDynamicURLFetchInterval),
+			jwk.WithFetchConcurrency(prov.jwkFetchConcurrency),
 			jwk.WithHTTPClient(prov.client),
 		); err != nil {
 			return nil, err
 		}
 		jwkPro = prov.jwkd.GetProvider()
+		jwkaPro = prov.jwkd.GetAlgorithmProvider()
+		if prov.accessTokenJWKRefreshOnMiss {
+			jwkr = prov.jwkd.GetRefresher()
+		}
 	}
 
 	if prov.enableRoleToken {
 		if prov.roleProcessor, err = role.New(
 			role.WithPubkeyProvider(pkPro),
+			role.WithAuthorizedProxyUsers(prov.roleTokenAuthorizedProxyUsers),
+			role.WithClockSkew(prov.roleTokenClockSkew),
+			role.WithMaxLifetime(prov.roleTokenMaxLifetime),
 		); err != nil {
 			return nil, err
 		}
 	}
 
 	if prov.accessTokenParam.enable {
+		var audiences []string
+		if prov.accessTokenVerifyAudience {
+			if len(prov.athenzDomains) == 0 {
+				return nil, errors.New("error create access token processor: audience verification requires athenz domains")
+			}
+			audiences = prov.athenzDomains
+		}
 		if prov.accessProcessor, err = access.New(
 			access.WithJWKProvider(jwkPro),
+			access.WithJWKAlgorithmProvider(jwkaPro),
+			access.WithAllowedAlgorithms(prov.accessTokenAlgorithms),
+			access.WithEnableVerifyTokenType(prov.accessTokenVerifyType),
 			access.WithEnableMTLSCertificateBoundAccessToken(prov.accessTokenParam.verifyCertThumbprint),
 			access.WithEnableVerifyClientID(prov.accessTokenParam.verifyClientID),
 			access.WithAuthorizedClientIDs(prov.accessTokenParam.authorizedClientIDs),
 			access.WithClientCertificateGoBackSeconds(prov.accessTokenParam.certBackdateDur),
 			access.WithClientCertificateOffsetSeconds(prov.accessTokenParam.certOffsetDur),
+			access.WithAuthorizedIssuers(prov.accessTokenIssuers),
+			access.WithJWKSetIssuers(prov.accessTokenJWKSetIssuers),
+			access.WithAuthorizedAudiences(audiences),
+			access.WithClockSkew(prov.accessTokenClockSkew),
+			access.WithJWKRefresher(jwkr),
+			access.WithIntrospectionURL(prov.accessTokenIntrospectionURL),
+			access.WithRequestTimeout(prov.accessTokenRequestTimeout),
+			access.WithHTTPClient(prov.client),
 		); err != nil {
 			return nil, err
 		}
@@ -208,40 +306,61 @@ func New(opts ...Option) (Authorizerd, error) {
 }
 
 func (a *authority) initAuthorizers() error {
-	// TODO: check empty credentials to speed up the checking
 	authorizers := make([]authorizer, 0, 3) // rolecert, access token, roletoken
 
 	if a.enableRoleCert {
 		rcVerifier := func(r *http.Request, act, res string) (Principal, error) {
-			if r.TLS != nil {
-				return a.AuthorizeRoleCert(r.Context(), r.TLS.PeerCertificates, act, res)
+			if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+				return nil, errors.Wrap(ErrEmptyCredential, "role certificate not found")
 			}
-			return a.AuthorizeRoleCert(r.Context(), nil, act, res)
+			// the client certificate without roles, e.g. the service certificate of the certificate bound access token, is not a role certificate
+			if len(a.parseRoleCert(r.TLS.PeerCertificates[:1]).domains) == 0 {
+				return nil, errors.Wrap(ErrEmptyCredential, "role certificate not found, no role in the client certificate")
+			}
+			return a.AuthorizeRoleCert(r.Context(), r.TLS.PeerCertificates, act, res)
 		}
 		glg.Info("initAuthorizers: added role certificate authorizer")
 		authorizers = append(authorizers, rcVerifier)
 	}
 
 	if a.accessTokenParam.enable {
+		extractors := a.accessTokenExtractors
+		if len(extractors) == 0 {
+			extractors = []CredentialExtractor{BearerTokenExtractor()}
+		}
+		if err := validateExtractors(extractors); err != nil {
+			return errors.Wrap(err, "error access token authorizer")
+		}
 		atVerifier := func(r *http.Request, act, res string) (Principal, error) {
-			tokenString, err := request.AuthorizationHeaderExtractor.ExtractToken(r)
-			if err != nil {
-				return nil, err
+			tokenString := extractCredential(r, extractors)
+			if tokenString == "" {
+				return nil, errors.Wrap(ErrEmptyCredential, "access token not found")
 			}
 			if r.TLS != nil && len(r.TLS.PeerCertificates) != 0 {
-				return a.authorize(r.Context(), accessToken, tokenString, act, res, r.URL.RawQuery, r.TLS.PeerCertificates[0])
+				return a.authorize(r.Context(), accessToken, tokenString, act, res, r.URL.RawQuery, r.RemoteAddr, r.TLS.PeerCertificates[0])
 			}
-			return a.authorize(r.Context(), accessToken, tokenString, act, res, r.URL.RawQuery, nil)
+			return a.authorize(r.Context(), accessToken, tokenString, act, res, r.URL.RawQuery, r.RemoteAddr, nil)
 		}
-		glg.Infof("initAuthorizers: added access token authorizer having param: %+v", a.accessTokenParam)
+		glg.Infof("initAuthorizers: added access token authorizer having param: %+v, extractors: %d", a.accessTokenParam, len(extractors))
 		authorizers = append(authorizers, atVerifier)
 	}
 
 	if a.enableRoleToken {
+		extractors := a.roleTokenExtractors
+		if len(extractors) == 0 {
+			extractors = []CredentialExtractor{HeaderExtractor(a.roleAuthHeader)}
+		}
+		if err := validateExtractors(extractors); err != nil {
+			return errors.Wrap(err, "error role token authorizer")
+		}
 		rtVerifier := func(r *http.Request, act, res string) (Principal, error) {
-			return a.authorize(r.Context(), roleToken, r.Header.Get(a.roleAuthHeader), act, res, r.URL.RawQuery, nil)
+			tok := extractCredential(r, extractors)
+			if tok == "" {
+				return nil, errors.Wrap(ErrEmptyCredential, "role token not found")
+			}
+			return a.authorize(r.Context(), roleToken, tok, act, res, r.URL.RawQuery, r.RemoteAddr, nil)
 		}
-		glg.Info("initAuthorizers: added role token authorizer")
+		glg.Infof("initAuthorizers: added role token authorizer, extractors: %d", len(extractors))
 		authorizers = append(authorizers, rtVerifier)
 	}
 
@@ -255,6 +374,15 @@ func (a *authority) initAuthorizers() error {
 	return nil
 }
 
+// remoteAddrHost returns the host part of the remote address in the form of "host:port".
+func remoteAddrHost(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return remoteAddr
+	}
+	return host
+}
+
 // Init initializes child daemons synchronously.
 func (a *authority) Init(ctx context.Context) error {
 	eg, egCtx := errgroup.WithContext(ctx)
@@ -336,31 +464,39 @@ func (a *authority) Start(ctx context.Context) <-chan error {
 }
 
 // VerifyRoleToken verifies the role token for specific resource and return and verification error.
+// The IP address of the role token is not verified by RoleTokenVerifyIP, since the remote address is unknown. Use Verify with the request instead.
 func (a *authority) VerifyRoleToken(ctx context.Context, tok, act, res string) error {
-	_, err := a.authorize(ctx, roleToken, tok, act, res, "", nil)
+	_, err := a.authorize(ctx, roleToken, tok, act, res, "", "", nil)
 	return err
 }
 
 // AuthorizeRoleToken verifies the role token for specific resource and returns the result of verifying or verification error if unauthorized.
+// The IP address of the role token is not verified by RoleTokenVerifyIP, since the remote address is unknown. Use Authorize with the request instead.
 func (a *authority) AuthorizeRoleToken(ctx context.Context, tok, act, res string) (Principal, error) {
-	return a.authorize(ctx, roleToken, tok, act, res, "", nil)
+	return a.authorize(ctx, roleToken, tok, act, res, "", "", nil)
 }
 
 // VerifyAccessToken verifies the access token on the specific (action, resource) pair and returns verification error if unauthorized.
 func (a *authority) VerifyAccessToken(ctx context.Context, tok, act, res string, cert *x509.Certificate) error {
-	_, err := a.authorize(ctx, accessToken, tok, act, res, "", cert)
+	_, err := a.authorize(ctx, accessToken, tok, act, res, "", "", cert)
 	return err
 }
 
 // AuthorizeAccessToken verifies the access token on the specific (action, resource) pair and returns the result of verifying or verification error if unauthorized.
 func (a *authority) AuthorizeAccessToken(ctx context.Context, tok, act, res string, cert *x509.Certificate) (Principal, error) {
-	return a.authorize(ctx, accessToken, tok, act, res, "", cert)
+	return a.authorize(ctx, accessToken, tok, act, res, "", "", cert)
 }
 
-func (a *authority) authorize(ctx context.Context, m mode, tok, act, res, query string, cert *x509.Certificate) (Principal, error) {
+func (a *authority) authorize(ctx context.Context, m mode, tok, act, res, query, remoteAddr string, cert *x509.Certificate) (Principal, error) {
 	var key strings.Builder
 	key.WriteString(tok)
 
+	// the cached result is valid only for the verified remote address
+	if m == roleToken && a.roleTokenVerifyIP {
+		key.WriteRune(cacheKeyDelimiter)
+		key.WriteString(remoteAddrHost(remoteAddr))
+	}
+
 	if cert != nil {
 		key.WriteRune(cacheKeyDelimiter)
 		key.WriteString(cert.Issuer.CommonName)
@@ -402,6 +538,13 @@ func (a *authority) authorize(ctx context.Context, m mode, tok, act, res, query
 			glg.Debugf("error parse and validate role token, err: %v", err)
 			return nil, errors.Wrap(err, "error authorize role token")
 		}
+		// the IP address is not verified without the request, e.g. VerifyRoleToken and AuthorizeRoleToken
+		if a.roleTokenVerifyIP && remoteAddr != "" {
+			if err := rt.ValidateIP(remoteAddr); err != nil {
+				glg.Debugf("error validate role token IP, err: %v", err)
+				return nil, errors.Wrap(err, "error authorize role token")
+			}
+		}
 		domain = rt.Domain
 		roles = rt.Roles
 		p = &principal{
@@ -444,82 +587,78 @@ func (a *authority) authorize(ctx context.Context, m mode, tok, act, res, query
 			return nil, errors.Wrap(err, "token unauthorized")
 		}
 	}
+	if !a.cacheable(domain) {
+		return p, nil
+	}
 	glg.Debugf("set token result. tok: %s, key: %s, act: %s, res: %s", tok, key.String(), act, res)
 	a.cache.SetWithExpire(key.String(), p, a.cacheExp)
 	return p, nil
 }
 
-// Verify returns error of verification. Returns nil if ANY authorizer succeeds (OR logic).
-func (a *authority) Verify(r *http.Request, act, res string) error {
-	for _, verifier := range a.authorizers {
-		// OR logic on multiple credentials
-		_, err := verifier(r, act, res)
-		if err == nil {
-			return nil
+// cacheable returns false if the policy decisions of the domain must be evaluated on every request, i.e. in dry-run mode, or with the decision hook or the shadow policies.
+// Otherwise the cached requests never reach the policy check, and their decisions are not reported.
+func (a *authority) cacheable(domain string) bool {
+	if a.disablePolicyd {
+		return true
+	}
+	if a.policyDryRun || a.policyDecisionHook != nil || a.policyShadowSource != nil {
+		return false
+	}
+	for _, d := range a.policyDryRunDomains {
+		if d == domain {
+			return false
 		}
 	}
+	return true
+}
 
-	return ErrInvalidCredentials
+// Verify returns error of verification. The results of the authorizers are combined by the CombinationPolicy (default: AnyOf, returns nil if ANY authorizer succeeds).
+// The returned error is ErrInvalidCredentials or a *CredentialsError caused by it, use errors.Cause() or errors.Is() to compare.
+func (a *authority) Verify(r *http.Request, act, res string) error {
+	_, err := a.combine(r, act, res)
+	return err
 }
 
-// Authorize returns the principal or an error if unauthorized. Returns the principal with nil error if ANY authorizer succeeds (OR logic).
+// Authorize returns the principal or an error if unauthorized. The results of the authorizers are combined by the CombinationPolicy (default: AnyOf).
+// The returned error is a *CredentialsError aggregating the error of each authorizer, or ErrInvalidCredentials as is if no credential is present.
+// Use errors.Cause() or errors.Is() to compare the error with ErrInvalidCredentials.
 func (a *authority) Authorize(r *http.Request, act, res string) (Principal, error) {
-	for _, verifier := range a.authorizers {
-		// OR logic on multiple credentials
-		verified, err := verifier(r, act, res)
-		if err == nil {
-			return verified, nil
-		}
-	}
-
-	return nil, ErrInvalidCredentials
+	return a.combine(r, act, res)
 }
 
 // VerifyRoleCert verifies the role certificate for specific resource and return and verification error.
 func (a *authority) VerifyRoleCert(ctx context.Context, peerCerts []*x509.Certificate, act, res string) error {
-	if a.disablePolicyd {
-		return nil
-	}
-
-	var dr []string
-	drcheck := make(map[string]struct{})
-	domainRoles := make(map[string][]string)
-	for _, cert := range peerCerts {
-		for _, uri := range cert.URIs {
-			if strings.HasPrefix(uri.String(), a.roleCertURIPrefix) {
-				dr = strings.SplitN(strings.TrimPrefix(uri.String(), a.roleCertURIPrefix), "/", 2) // domain/role
-				if len(dr) != 2 {
-					continue
-				}
-				domain, roleName := dr[0], dr[1]
-				// duplicated role check
-				if _, ok := drcheck[domain+roleName]; !ok {
-					domainRoles[domain] = append(domainRoles[domain], roleName)
-					drcheck[domain+roleName] = struct{}{}
-				}
-			}
+	_, err := a.AuthorizeRoleCert(ctx, peerCerts, act, res)
+	return err
+}
+
+// AuthorizeRoleCert verifies the role certificate for specific resource and returns the result of verifying or verification error if unauthorized.
+// The roles and the principal are extracted only from the validated leaf certificate (peerCerts[0]), the other certificates are used only to build the chain.
+// The returned principal has the roles of the authorized domain, and the name extracted from the principal URI of the leaf certificate if exists.
+func (a *authority) AuthorizeRoleCert(ctx context.Context, peerCerts []*x509.Certificate, act, res string) (Principal, error) {
+	if len(peerCerts) != 0 {
+		if err := a.validateRoleCert(peerCerts); err != nil {
+			return nil, errors.Wrap(err, "invalid role certificate")
 		}
 	}
 
-	if len(domainRoles) == 0 {
-		return errors.New("invalid role certificate")
+	rc := a.parseRoleCert(peerCerts)
+	if a.disablePolicyd {
+		return rc.principal(""), nil
+	}
+
+	if len(rc.domains) == 0 {
+		return nil, errors.New("invalid role certificate")
 	}
 
 	var err error
-	for domain, roles := range domainRoles {
-		// TODO futurework
-		if err = a.policyd.CheckPolicy(ctx, domain, roles, act, res); err == nil {
-			return nil
+	for _, domain := range rc.domains {
+		if err = a.policyd.CheckPolicy(ctx, domain, rc.domainRoles[domain], act, res); err == nil {
+			return rc.principal(domain), nil
 		}
 	}
 
-	return errors.Wrap(err, "role certificates unauthorized")
-}
-
-// AuthorizeRoleCert verifies the role certificate for specific resource and returns the result of verifying or verification error if unauthorized. (unimplemented)
-func (a *authority) AuthorizeRoleCert(ctx context.Context, peerCerts []*x509.Certificate, act, res string) (Principal, error) {
-	// TODO VerifyRoleCert has not yet been implemented to return a Principal
-	return nil, errors.New("AuthorizeRoleCert has not yet been implemented")
+	return nil, errors.Wrap(err, "role certificates unauthorized")
 }
 
 // GetPolicyCache returns the cached policy data
@@ -530,3 +669,11 @@ func (a *authority) GetPolicyCache(ctx context.Context) map[string]interface{} {
 		return make(map[string]interface{})
 	}
 }
+
+// GetPolicyStatus returns the status of the policy enforced for each domain
+func (a *authority) GetPolicyStatus(ctx context.Context) map[string]policy.DomainStatus {
+	if !a.disablePolicyd {
+		return a.policyd.GetDomainStatus(ctx)
+	}
+	return make(map[string]policy.DomainStatus)
+}
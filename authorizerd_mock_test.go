@@ -23,6 +23,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/yahoojapan/athenz-authorizer/v5/access"
 	"github.com/yahoojapan/athenz-authorizer/v5/jwk"
+	"github.com/yahoojapan/athenz-authorizer/v5/policy"
 	"github.com/yahoojapan/athenz-authorizer/v5/pubkey"
 	"github.com/yahoojapan/athenz-authorizer/v5/role"
 )
@@ -72,8 +73,9 @@ type PolicydMock struct {
 	UpdateFunc      func(context.Context) error
 	CheckPolicyFunc func(ctx context.Context, domain string, roles []string, action, resource string) error
 
-	policydExp  time.Duration
-	policyCache map[string]interface{}
+	policydExp   time.Duration
+	policyCache  map[string]interface{}
+	policyStatus map[string]policy.DomainStatus
 }
 
 func (pdm *PolicydMock) Start(context.Context) <-chan error {
@@ -103,6 +105,10 @@ func (pdm *PolicydMock) GetPolicyCache(ctx context.Context) map[string]interface
 	return pdm.policyCache
 }
 
+func (pdm *PolicydMock) GetDomainStatus(ctx context.Context) map[string]policy.DomainStatus {
+	return pdm.policyStatus
+}
+
 type RoleProcessorMock struct {
 	role.Processor
 	wantErr error
@@ -124,9 +130,12 @@ func (apm *AccessProcessorMock) ParseAndValidateOAuth2AccessToken(cred string, c
 }
 
 type JwkdMock struct {
-	StartFunc       func(context.Context) <-chan error
-	UpdateFunc      func(context.Context) error
-	GetProviderFunc func() jwk.Provider
+	StartFunc                func(context.Context) <-chan error
+	UpdateFunc               func(context.Context) error
+	GetProviderFunc          func() jwk.Provider
+	GetAlgorithmProviderFunc func() jwk.AlgorithmProvider
+	GetRefresherFunc         func() jwk.Refresher
+	GetKeyWithRefreshFunc    func(ctx context.Context, keyID string, jwkSetURL string) (interface{}, error)
 }
 
 func (jm *JwkdMock) Start(ctx context.Context) <-chan error {
@@ -149,3 +158,24 @@ func (jm *JwkdMock) GetProvider() jwk.Provider {
 	}
 	return nil
 }
+
+func (jm *JwkdMock) GetAlgorithmProvider() jwk.AlgorithmProvider {
+	if jm.GetAlgorithmProviderFunc != nil {
+		return jm.GetAlgorithmProviderFunc()
+	}
+	return nil
+}
+
+func (jm *JwkdMock) GetRefresher() jwk.Refresher {
+	if jm.GetRefresherFunc != nil {
+		return jm.GetRefresherFunc()
+	}
+	return nil
+}
+
+func (jm *JwkdMock) GetKeyWithRefresh(ctx context.Context, keyID string, jwkSetURL string) (interface{}, error) {
+	if jm.GetKeyWithRefreshFunc != nil {
+		return jm.GetKeyWithRefreshFunc(ctx, keyID, jwkSetURL)
+	}
+	return nil, nil
+}
@@ -26,7 +26,6 @@ import (
 	"testing"
 	"time"
 
-	jwt "github.com/dgrijalva/jwt-go"
 	"github.com/kpango/fastime"
 	"github.com/kpango/gache"
 	"github.com/pkg/errors"
@@ -163,6 +162,41 @@ func TestNew(t *testing.T) {
 				return nil
 			},
 		},
+		{
+			name: "test New error, access token audience verification without athenz domains",
+			args: args{
+				[]Option{
+					WithAccessTokenVerifyAudience(true),
+				},
+			},
+			checkFunc: func(prov Authorizerd, err error) error {
+				wantErr := "error create access token processor: audience verification requires athenz domains"
+				if err == nil || err.Error() != wantErr {
+					return errors.Errorf("Unexpected error: %v, wantErr: %s", err, wantErr)
+				}
+				return nil
+			},
+		},
+		{
+			name: "test New success, access token audience verification",
+			args: args{
+				[]Option{
+					WithAthenzDomains("domain1", "domain2"),
+					WithAccessTokenVerifyAudience(true),
+					WithAccessTokenIssuers("https://zts.athenz.io"),
+					WithAccessTokenClockSkew("1m"),
+				},
+			},
+			checkFunc: func(prov Authorizerd, err error) error {
+				if err != nil {
+					return errors.Wrap(err, "unexpected error")
+				}
+				if prov.(*authority).accessProcessor == nil {
+					return errors.New("cannot new access token processor")
+				}
+				return nil
+			},
+		},
 		{
 			name: "test New error, authorizer",
 			args: args{
@@ -218,6 +252,9 @@ func Test_authorizer_initAuthorizers(t *testing.T) {
 		enableRoleToken       bool
 		roleAuthHeader        string
 		enableRoleCert        bool
+		roleTokenExtractors   []CredentialExtractor
+		accessTokenExtractors []CredentialExtractor
+		roleProcessor         role.Processor
 	}
 	tests := []struct {
 		name      string
@@ -272,6 +309,74 @@ func Test_authorizer_initAuthorizers(t *testing.T) {
 			fields:  fields{},
 			wantErr: true,
 		},
+		{
+			name: "initVerifier fail, nil role token extractor",
+			fields: fields{
+				enableRoleToken:     true,
+				roleTokenExtractors: []CredentialExtractor{nil},
+			},
+			wantErr: true,
+		},
+		{
+			name: "initVerifier fail, nil access token extractor",
+			fields: fields{
+				accessTokenParam:      AccessTokenParam{enable: true},
+				accessTokenExtractors: []CredentialExtractor{BearerTokenExtractor(), nil},
+			},
+			wantErr: true,
+		},
+		{
+			name: "initVerifier success, empty role token is skipped",
+			fields: fields{
+				enableRoleToken: true,
+				roleAuthHeader:  "Athenz-Role-Auth",
+				roleProcessor: &RoleProcessorMock{
+					wantErr: errors.New("role token processor must not be called"),
+				},
+			},
+			wantErr: false,
+			checkFunc: func(a authority) error {
+				r, _ := http.NewRequest("GET", "http://127.0.0.1", nil)
+				_, err := a.authorizers[0](r, "dummyAct", "dummyRes")
+				if errors.Cause(err) != ErrEmptyCredential {
+					return errors.Errorf("unexpected error: %v", err)
+				}
+				return nil
+			},
+		},
+		{
+			name: "initVerifier success, role token extracted by the custom extractors",
+			fields: fields{
+				enableRoleToken: true,
+				disablePolicyd:  true,
+				cache:           gache.New(),
+				roleTokenExtractors: []CredentialExtractor{
+					HeaderExtractor("Athenz-Role-Auth"),
+					CookieExtractor("roleToken"),
+				},
+				roleProcessor: &RoleProcessorMock{
+					rt: &role.Token{
+						Principal: "dummyPrincipal",
+					},
+				},
+			},
+			wantErr: false,
+			checkFunc: func(a authority) error {
+				r, _ := http.NewRequest("GET", "http://127.0.0.1", nil)
+				r.AddCookie(&http.Cookie{
+					Name:  "roleToken",
+					Value: "dummyToken",
+				})
+				p, err := a.authorizers[0](r, "dummyAct", "dummyRes")
+				if err != nil {
+					return err
+				}
+				if p.Name() != "dummyPrincipal" {
+					return errors.Errorf("unexpected principal: %v", p.Name())
+				}
+				return nil
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -301,6 +406,9 @@ func Test_authorizer_initAuthorizers(t *testing.T) {
 				enableRoleToken:       tt.fields.enableRoleToken,
 				roleAuthHeader:        tt.fields.roleAuthHeader,
 				enableRoleCert:        tt.fields.enableRoleCert,
+				roleTokenExtractors:   tt.fields.roleTokenExtractors,
+				accessTokenExtractors: tt.fields.accessTokenExtractors,
+				roleProcessor:         tt.fields.roleProcessor,
 			}
 			if err := a.initAuthorizers(); (err != nil) != tt.wantErr {
 				t.Errorf("authority.initAuthorizers() error = %v, wantErr %v", err, tt.wantErr)
@@ -921,15 +1029,20 @@ func Test_authorizer_authorize(t *testing.T) {
 		policyRefreshPeriod   string
 		disablePolicyd        bool
 		translator            Translator
+		roleTokenVerifyIP     bool
+		policyDryRun          bool
+		policyDryRunDomains   []string
+		policyDecisionHook    policy.DecisionHook
 	}
 	type args struct {
-		ctx   context.Context
-		m     mode
-		tok   string
-		act   string
-		res   string
-		query string
-		cert  *x509.Certificate
+		ctx        context.Context
+		m          mode
+		tok        string
+		act        string
+		res        string
+		query      string
+		remoteAddr string
+		cert       *x509.Certificate
 	}
 	type test struct {
 		name       string
@@ -1238,6 +1351,274 @@ func Test_authorizer_authorize(t *testing.T) {
 				},
 			}
 		}(),
+		func() test {
+			c := gache.New()
+			rt := &role.Token{
+				IP: "172.16.168.25",
+			}
+			rpm := &RoleProcessorMock{
+				rt:      rt,
+				wantErr: nil,
+			}
+			return test{
+				name: "test role token IP match",
+				fields: fields{
+					cache:             c,
+					disablePolicyd:    true,
+					roleProcessor:     rpm,
+					roleTokenVerifyIP: true,
+				},
+				args: args{
+					m:          roleToken,
+					ctx:        context.Background(),
+					tok:        "dummyTok",
+					act:        "dummyAct",
+					res:        "dummyRes",
+					remoteAddr: "172.16.168.25:12345",
+				},
+				wantErr: false,
+				wantResult: &principal{
+					issueTime:  rt.TimeStamp.Unix(),
+					expiryTime: rt.ExpiryTime.Unix(),
+				},
+				checkFunc: func(prov *authority) error {
+					if _, ok := c.Get("dummyTok:172.16.168.25"); !ok {
+						return errors.New("cache key must contain the remote address")
+					}
+					return nil
+				},
+			}
+		}(),
+		func() test {
+			c := gache.New()
+			rpm := &RoleProcessorMock{
+				rt: &role.Token{
+					IP: "172.16.168.25",
+				},
+				wantErr: nil,
+			}
+			return test{
+				name: "test role token IP mismatch",
+				fields: fields{
+					cache:             c,
+					disablePolicyd:    true,
+					roleProcessor:     rpm,
+					roleTokenVerifyIP: true,
+				},
+				args: args{
+					m:          roleToken,
+					ctx:        context.Background(),
+					tok:        "dummyTok",
+					act:        "dummyAct",
+					res:        "dummyRes",
+					remoteAddr: "172.16.168.26:12345",
+				},
+				wantErr: true,
+			}
+		}(),
+		func() test {
+			c := gache.New()
+			rt := &role.Token{
+				IP: "172.16.168.25",
+			}
+			rpm := &RoleProcessorMock{
+				rt:      rt,
+				wantErr: nil,
+			}
+			return test{
+				name: "test role token IP not verified without remote address",
+				fields: fields{
+					cache:             c,
+					disablePolicyd:    true,
+					roleProcessor:     rpm,
+					roleTokenVerifyIP: true,
+				},
+				args: args{
+					m:   roleToken,
+					ctx: context.Background(),
+					tok: "dummyTok",
+					act: "dummyAct",
+					res: "dummyRes",
+				},
+				wantErr: false,
+				wantResult: &principal{
+					issueTime:  rt.TimeStamp.Unix(),
+					expiryTime: rt.ExpiryTime.Unix(),
+				},
+			}
+		}(),
+		func() test {
+			c := gache.New()
+			pdm := &PolicydMock{
+				CheckPolicyFunc: func(ctx context.Context, domain string, roles []string, action, resource string) error {
+					return nil
+				},
+			}
+			rt := &role.Token{
+				Domain: "dummyDom",
+			}
+			rpm := &RoleProcessorMock{
+				rt:      rt,
+				wantErr: nil,
+			}
+			return test{
+				name: "test result not cached in dry-run mode",
+				fields: fields{
+					cache:         c,
+					policyd:       pdm,
+					roleProcessor: rpm,
+					policyDryRun:  true,
+				},
+				args: args{
+					m:   roleToken,
+					ctx: context.Background(),
+					tok: "dummyTok",
+					act: "dummyAct",
+					res: "dummyRes",
+				},
+				wantErr: false,
+				wantResult: &principal{
+					domain:     rt.Domain,
+					issueTime:  rt.TimeStamp.Unix(),
+					expiryTime: rt.ExpiryTime.Unix(),
+				},
+				checkFunc: func(prov *authority) error {
+					if _, ok := c.Get("dummyTok:dummyAct:dummyRes"); ok != false {
+						return errors.Errorf("cached: %v, want: %v", ok, false)
+					}
+					return nil
+				},
+			}
+		}(),
+		func() test {
+			c := gache.New()
+			pdm := &PolicydMock{
+				CheckPolicyFunc: func(ctx context.Context, domain string, roles []string, action, resource string) error {
+					return nil
+				},
+			}
+			rt := &role.Token{
+				Domain: "dummyDom",
+			}
+			rpm := &RoleProcessorMock{
+				rt:      rt,
+				wantErr: nil,
+			}
+			return test{
+				name: "test result not cached in dry-run mode of the domain",
+				fields: fields{
+					cache:               c,
+					policyd:             pdm,
+					roleProcessor:       rpm,
+					policyDryRunDomains: []string{"dummyDom"},
+				},
+				args: args{
+					m:   roleToken,
+					ctx: context.Background(),
+					tok: "dummyTok",
+					act: "dummyAct",
+					res: "dummyRes",
+				},
+				wantErr: false,
+				wantResult: &principal{
+					domain:     rt.Domain,
+					issueTime:  rt.TimeStamp.Unix(),
+					expiryTime: rt.ExpiryTime.Unix(),
+				},
+				checkFunc: func(prov *authority) error {
+					if _, ok := c.Get("dummyTok:dummyAct:dummyRes"); ok != false {
+						return errors.Errorf("cached: %v, want: %v", ok, false)
+					}
+					return nil
+				},
+			}
+		}(),
+		func() test {
+			c := gache.New()
+			pdm := &PolicydMock{
+				CheckPolicyFunc: func(ctx context.Context, domain string, roles []string, action, resource string) error {
+					return nil
+				},
+			}
+			rt := &role.Token{
+				Domain: "dummyDom",
+			}
+			rpm := &RoleProcessorMock{
+				rt:      rt,
+				wantErr: nil,
+			}
+			return test{
+				name: "test result cached in dry-run mode of the other domain",
+				fields: fields{
+					cache:               c,
+					policyd:             pdm,
+					roleProcessor:       rpm,
+					policyDryRunDomains: []string{"otherDom"},
+				},
+				args: args{
+					m:   roleToken,
+					ctx: context.Background(),
+					tok: "dummyTok",
+					act: "dummyAct",
+					res: "dummyRes",
+				},
+				wantErr: false,
+				wantResult: &principal{
+					domain:     rt.Domain,
+					issueTime:  rt.TimeStamp.Unix(),
+					expiryTime: rt.ExpiryTime.Unix(),
+				},
+				checkFunc: func(prov *authority) error {
+					if _, ok := c.Get("dummyTok:dummyAct:dummyRes"); ok != true {
+						return errors.Errorf("cached: %v, want: %v", ok, true)
+					}
+					return nil
+				},
+			}
+		}(),
+		func() test {
+			c := gache.New()
+			pdm := &PolicydMock{
+				CheckPolicyFunc: func(ctx context.Context, domain string, roles []string, action, resource string) error {
+					return nil
+				},
+			}
+			rt := &role.Token{
+				Domain: "dummyDom",
+			}
+			rpm := &RoleProcessorMock{
+				rt:      rt,
+				wantErr: nil,
+			}
+			return test{
+				name: "test result not cached with the decision hook",
+				fields: fields{
+					cache:              c,
+					policyd:            pdm,
+					roleProcessor:      rpm,
+					policyDecisionHook: func(context.Context, policy.Decision) {},
+				},
+				args: args{
+					m:   roleToken,
+					ctx: context.Background(),
+					tok: "dummyTok",
+					act: "dummyAct",
+					res: "dummyRes",
+				},
+				wantErr: false,
+				wantResult: &principal{
+					domain:     rt.Domain,
+					issueTime:  rt.TimeStamp.Unix(),
+					expiryTime: rt.ExpiryTime.Unix(),
+				},
+				checkFunc: func(prov *authority) error {
+					if _, ok := c.Get("dummyTok:dummyAct:dummyRes"); ok != false {
+						return errors.Errorf("cached: %v, want: %v", ok, false)
+					}
+					return nil
+				},
+			}
+		}(),
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -1260,8 +1641,12 @@ func Test_authorizer_authorize(t *testing.T) {
 				policyRefreshPeriod:   tt.fields.policyRefreshPeriod,
 				disablePolicyd:        tt.fields.disablePolicyd,
 				translator:            tt.fields.translator,
+				roleTokenVerifyIP:     tt.fields.roleTokenVerifyIP,
+				policyDryRun:          tt.fields.policyDryRun,
+				policyDryRunDomains:   tt.fields.policyDryRunDomains,
+				policyDecisionHook:    tt.fields.policyDecisionHook,
 			}
-			p, err := a.authorize(tt.args.ctx, tt.args.m, tt.args.tok, tt.args.act, tt.args.res, tt.args.query, tt.args.cert)
+			p, err := a.authorize(tt.args.ctx, tt.args.m, tt.args.tok, tt.args.act, tt.args.res, tt.args.query, tt.args.remoteAddr, tt.args.cert)
 			if err != nil {
 				if !tt.wantErr {
 					t.Errorf("authority.authorize() error = %v, wantErr %v", err, tt.wantErr)
@@ -1301,6 +1686,7 @@ func Test_authorizer_VerifyRoleCert(t *testing.T) {
 		athenzDomains         []string
 		policyRefreshPeriod   string
 		disablePolicyd        bool
+		roleCertIssuers       []string
 	}
 	type args struct {
 		ctx       context.Context
@@ -1369,6 +1755,62 @@ bu80CwTnWhmdBo36Ig==
 				},
 			}
 		}(),
+		func() test {
+			crt := `-----BEGIN CERTIFICATE-----
+MIICGTCCAcOgAwIBAgIJALLML3PdJAZ1MA0GCSqGSIb3DQEBCwUAMFwxCzAJBgNV
+BAYTAlVTMQswCQYDVQQIEwJDQTEPMA0GA1UEChMGQXRoZW56MRcwFQYDVQQLEw5U
+ZXN0aW5nIERvbWFpbjEWMBQGA1UEAxMNYXRoZW56LnN5bmNlcjAeFw0xOTA0Mjcw
+MjQ2MjNaFw0yOTA0MjQwMjQ2MjNaMFwxCzAJBgNVBAYTAlVTMQswCQYDVQQIEwJD
+QTEPMA0GA1UEChMGQXRoZW56MRcwFQYDVQQLEw5UZXN0aW5nIERvbWFpbjEWMBQG
+A1UEAxMNYXRoZW56LnN5bmNlcjBcMA0GCSqGSIb3DQEBAQUAA0sAMEgCQQCvv27a
+SNAnK0vcN8fqqQgMHwb0EhfVWMwoRTBQFrCmA9mH/84QgI/0kR3ZI+DlDNBCgDHd
+rEJZVPyX2V41VOX3AgMBAAGjaDBmMGQGA1UdEQRdMFuGGXNwaWZmZTovL2F0aGVu
+ei9zYS9zeW5jZXKGHmF0aGVuejovL3JvbGUvY29yZXRlY2gvcmVhZGVyc4YeYXRo
+ZW56Oi8vcm9sZS9jb3JldGVjaC93cml0ZXJzMA0GCSqGSIb3DQEBCwUAA0EAa3Ra
+Wo7tEDFBGqSVYSVuoh0GpsWC0VBAYYi9vhAGfp+g5M2oszvRuxOHYsQmYAjYroTJ
+bu80CwTnWhmdBo36Ig==
+-----END CERTIFICATE-----`
+			block, _ := pem.Decode([]byte(crt))
+			cert, _ := x509.ParseCertificate(block.Bytes)
+
+			pm := &PolicydMock{
+				CheckPolicyFunc: func(ctx context.Context, domain string, roles []string, act, res string) error {
+					containRole := func(r string) bool {
+						for _, role := range roles {
+							if role == r {
+								return true
+							}
+						}
+						return false
+					}
+					if domain != "coretech" {
+						return errors.Errorf("invalid domain, got: %s, want: %s", domain, "coretech")
+					}
+					if !containRole("readers") || !containRole("writers") {
+						return errors.Errorf("invalid role, got: %s", roles)
+					}
+					return nil
+				},
+			}
+
+			return test{
+				name: "verify role cert fail, issuer mismatched",
+				fields: fields{
+					roleCertURIPrefix: "athenz://role/",
+					policyd:           pm,
+					roleCertIssuers:   []string{"CN=Athenz CA,O=Athenz"},
+				},
+				args: args{
+					ctx: context.Background(),
+					peerCerts: []*x509.Certificate{
+						cert,
+					},
+					act: "abc",
+					res: "def",
+				},
+				wantErr: true,
+			}
+		}(),
 		func() test {
 			crt := `
 -----BEGIN CERTIFICATE-----
@@ -1505,14 +1947,14 @@ bu80CwTnWhmdBo36Ig==
 				athenzDomains:         tt.fields.athenzDomains,
 				policyRefreshPeriod:   tt.fields.policyRefreshPeriod,
 				disablePolicyd:        tt.fields.disablePolicyd,
+				roleCertIssuers:       tt.fields.roleCertIssuers,
 			}
 			if err := p.VerifyRoleCert(tt.args.ctx, tt.args.peerCerts, tt.args.act, tt.args.res); (err != nil) != tt.wantErr {
 				t.Errorf("authority.VerifyRoleCert() error = %v, wantErr %v", err, tt.wantErr)
 			}
 
-			_, err := p.AuthorizeRoleCert(tt.args.ctx, tt.args.peerCerts, tt.args.act, tt.args.res)
-			if err == nil {
-				t.Errorf("AuthorizeRoleCert has not yet been implemented")
+			if _, err := p.AuthorizeRoleCert(tt.args.ctx, tt.args.peerCerts, tt.args.act, tt.args.res); (err != nil) != tt.wantErr {
+				t.Errorf("authority.AuthorizeRoleCert() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
 	}
@@ -1596,9 +2038,70 @@ func Test_authorizer_GetPolicyCache(t *testing.T) {
 	}
 }
 
+func Test_authorizer_GetPolicyStatus(t *testing.T) {
+	type fields struct {
+		policyd        policy.Daemon
+		disablePolicyd bool
+	}
+	type args struct {
+		ctx context.Context
+	}
+	tests := []struct {
+		name   string
+		fields fields
+		args   args
+		want   map[string]policy.DomainStatus
+	}{
+		{
+			name: "GetPolicyStatus success",
+			fields: fields{
+				policyd: &PolicydMock{
+					policyStatus: map[string]policy.DomainStatus{
+						"dummyDom": {
+							Stale:       true,
+							StaleChecks: 1,
+						},
+					},
+				},
+			},
+			args: args{
+				ctx: context.Background(),
+			},
+			want: map[string]policy.DomainStatus{
+				"dummyDom": {
+					Stale:       true,
+					StaleChecks: 1,
+				},
+			},
+		},
+		{
+			name: "GetPolicyStatus success disable policyd",
+			fields: fields{
+				disablePolicyd: true,
+			},
+			args: args{
+				ctx: context.Background(),
+			},
+			want: make(map[string]policy.DomainStatus),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &authority{
+				policyd:        tt.fields.policyd,
+				disablePolicyd: tt.fields.disablePolicyd,
+			}
+			if got := a.GetPolicyStatus(tt.args.ctx); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("authority.GetPolicyStatus() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func Test_authorizer_Authorize(t *testing.T) {
 	type fields struct {
-		authorizers []authorizer
+		authorizers       []authorizer
+		combinationPolicy CombinationPolicy
 	}
 	type args struct {
 		r   *http.Request
@@ -1610,6 +2113,8 @@ func Test_authorizer_Authorize(t *testing.T) {
 		fields  fields
 		args    args
 		wantErr bool
+		// checkErr checks the error returned by Authorize, nil for no check
+		checkErr func(error) error
 	}
 	tests := []test{
 		{
@@ -1668,18 +2173,189 @@ func Test_authorizer_Authorize(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "Verify fail, multiple authorizer, errors aggregated",
+			fields: fields{
+				authorizers: []authorizer{
+					func(r *http.Request, act, res string) (Principal, error) {
+						return nil, errors.Wrap(ErrEmptyCredential, "role token not found")
+					},
+					func(r *http.Request, act, res string) (Principal, error) {
+						return nil, errors.Wrap(ErrNoMatch, "token unauthorized")
+					},
+				},
+			},
+			wantErr: true,
+			checkErr: func(err error) error {
+				ce, ok := err.(*CredentialsError)
+				if !ok {
+					return fmt.Errorf("error is not *CredentialsError: %T", err)
+				}
+				if len(ce.Errs) != 2 {
+					return fmt.Errorf("got %d errors, want 2", len(ce.Errs))
+				}
+				if errors.Cause(err) != ErrInvalidCredentials {
+					return fmt.Errorf("cause = %v, want %v", errors.Cause(err), ErrInvalidCredentials)
+				}
+				if ce.StatusCode() != http.StatusForbidden {
+					return fmt.Errorf("status code = %d, want %d", ce.StatusCode(), http.StatusForbidden)
+				}
+				return nil
+			},
+		},
+		{
+			name: "Verify fail, no credential, bare error",
+			fields: fields{
+				authorizers: []authorizer{
+					func(r *http.Request, act, res string) (Principal, error) {
+						return nil, errors.Wrap(ErrEmptyCredential, "role token not found")
+					},
+					func(r *http.Request, act, res string) (Principal, error) {
+						return nil, errors.Wrap(ErrEmptyCredential, "access token not found")
+					},
+				},
+			},
+			wantErr: true,
+			checkErr: func(err error) error {
+				if err != ErrInvalidCredentials {
+					return fmt.Errorf("error = %v, want %v", err, ErrInvalidCredentials)
+				}
+				return nil
+			},
+		},
+		{
+			name: "Verify success, AllOf",
+			fields: fields{
+				combinationPolicy: AllOf,
+				authorizers: []authorizer{
+					func(r *http.Request, act, res string) (Principal, error) {
+						return &principal{}, nil
+					},
+					func(r *http.Request, act, res string) (Principal, error) {
+						return &principal{}, nil
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Verify fail, AllOf, 1 authorizer fails",
+			fields: fields{
+				combinationPolicy: AllOf,
+				authorizers: []authorizer{
+					func(r *http.Request, act, res string) (Principal, error) {
+						return &principal{}, nil
+					},
+					func(r *http.Request, act, res string) (Principal, error) {
+						return nil, errors.Wrap(ErrEmptyCredential, "access token not found")
+					},
+				},
+			},
+			wantErr: true,
+			checkErr: func(err error) error {
+				ce, ok := err.(*CredentialsError)
+				if !ok {
+					return fmt.Errorf("error is not *CredentialsError: %T", err)
+				}
+				if len(ce.Errs) != 1 {
+					return fmt.Errorf("got %d errors, want 1", len(ce.Errs))
+				}
+				if ce.StatusCode() != http.StatusUnauthorized {
+					return fmt.Errorf("status code = %d, want %d", ce.StatusCode(), http.StatusUnauthorized)
+				}
+				return nil
+			},
+		},
+		{
+			name: "Verify fail, AllOf, no authorizer",
+			fields: fields{
+				combinationPolicy: AllOf,
+			},
+			wantErr: true,
+		},
+		{
+			name: "Verify success, FirstPresent, empty credential skipped",
+			fields: fields{
+				combinationPolicy: FirstPresent,
+				authorizers: []authorizer{
+					func(r *http.Request, act, res string) (Principal, error) {
+						return nil, errors.Wrap(ErrEmptyCredential, "role token not found")
+					},
+					func(r *http.Request, act, res string) (Principal, error) {
+						return &principal{}, nil
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Verify fail, FirstPresent, only the first present credential checked",
+			fields: fields{
+				combinationPolicy: FirstPresent,
+				authorizers: []authorizer{
+					func(r *http.Request, act, res string) (Principal, error) {
+						return nil, errors.Wrap(ErrEmptyCredential, "role token not found")
+					},
+					func(r *http.Request, act, res string) (Principal, error) {
+						return nil, errors.Wrap(ErrDenyByPolicy, "token unauthorized")
+					},
+					func(r *http.Request, act, res string) (Principal, error) {
+						return &principal{}, nil
+					},
+				},
+			},
+			wantErr: true,
+			checkErr: func(err error) error {
+				ce, ok := err.(*CredentialsError)
+				if !ok {
+					return fmt.Errorf("error is not *CredentialsError: %T", err)
+				}
+				if len(ce.Errs) != 1 || errors.Cause(ce.Errs[0]) != ErrDenyByPolicy {
+					return fmt.Errorf("errors = %v, want [%v]", ce.Errs, ErrDenyByPolicy)
+				}
+				return nil
+			},
+		},
+		{
+			name: "Verify fail, FirstPresent, no credential",
+			fields: fields{
+				combinationPolicy: FirstPresent,
+				authorizers: []authorizer{
+					func(r *http.Request, act, res string) (Principal, error) {
+						return nil, errors.Wrap(ErrEmptyCredential, "role token not found")
+					},
+					func(r *http.Request, act, res string) (Principal, error) {
+						return nil, errors.Wrap(ErrEmptyCredential, "access token not found")
+					},
+				},
+			},
+			wantErr: true,
+			checkErr: func(err error) error {
+				if err != ErrInvalidCredentials {
+					return fmt.Errorf("error = %v, want %v", err, ErrInvalidCredentials)
+				}
+				return nil
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			a := &authority{
-				authorizers: tt.fields.authorizers,
+				authorizers:       tt.fields.authorizers,
+				combinationPolicy: tt.fields.combinationPolicy,
 			}
 			if err := a.Verify(tt.args.r, tt.args.act, tt.args.res); (err != nil) != tt.wantErr {
 				t.Errorf("authority.Verify() error = %v, wantErr %v", err, tt.wantErr)
 			}
-			if _, err := a.Authorize(tt.args.r, tt.args.act, tt.args.res); (err != nil) != tt.wantErr {
+			_, err := a.Authorize(tt.args.r, tt.args.act, tt.args.res)
+			if (err != nil) != tt.wantErr {
 				t.Errorf("authority.Authorize() error = %v, wantErr %v", err, tt.wantErr)
 			}
+			if tt.checkErr != nil {
+				if err := tt.checkErr(err); err != nil {
+					t.Errorf("authority.Authorize() error = %v", err)
+				}
+			}
 		})
 	}
 }
@@ -1713,7 +2389,7 @@ func Test_authorizer_AuthorizeAccessToken(t *testing.T) {
 			at := &access.OAuth2AccessTokenClaim{
 				Scope: []string{"role"},
 				BaseClaim: access.BaseClaim{
-					StandardClaims: jwt.StandardClaims{
+					StandardClaims: access.StandardClaims{
 						Audience: "domain",
 					},
 				},
@@ -1775,7 +2451,7 @@ func Test_authorizer_AuthorizeAccessToken(t *testing.T) {
 			at := &access.OAuth2AccessTokenClaim{
 				Scope: []string{"role"},
 				BaseClaim: access.BaseClaim{
-					StandardClaims: jwt.StandardClaims{
+					StandardClaims: access.StandardClaims{
 						Audience: "domain",
 					},
 				},
@@ -2075,7 +2751,7 @@ func Test_authorizer_AuthorizeAccessToken(t *testing.T) {
 			at := &access.OAuth2AccessTokenClaim{
 				Scope: []string{"role"},
 				BaseClaim: access.BaseClaim{
-					StandardClaims: jwt.StandardClaims{
+					StandardClaims: access.StandardClaims{
 						Audience: "domain",
 					},
 				},
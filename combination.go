@@ -0,0 +1,161 @@
+/*
+Copyright (C)  2018 Yahoo Japan Corporation Athenz team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package authorizerd
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/kpango/glg"
+	"github.com/pkg/errors"
+)
+
+// CombinationPolicy represents how the results of the multiple authorizers are combined in Verify and Authorize.
+type CombinationPolicy int
+
+const (
+	// AnyOf authorizes the request if ANY of the authorizers succeeds (OR logic).
+	AnyOf CombinationPolicy = iota
+	// AllOf authorizes the request only if ALL of the authorizers succeed (AND logic), e.g. both the role certificate and the access token are required.
+	AllOf
+	// FirstPresent checks only the first credential present in the request, and returns its result.
+	// The client certificate without roles is not a role certificate, e.g. the one sent with the certificate bound access token.
+	FirstPresent
+)
+
+// String returns the name of the combination policy.
+func (c CombinationPolicy) String() string {
+	switch c {
+	case AnyOf:
+		return "AnyOf"
+	case AllOf:
+		return "AllOf"
+	case FirstPresent:
+		return "FirstPresent"
+	}
+	return "Unknown"
+}
+
+// CredentialsError represents the aggregated errors of the authorizers. The cause is ErrInvalidCredentials.
+// Compare the error with errors.Cause(err) == ErrInvalidCredentials or errors.Is(err, ErrInvalidCredentials), instead of err == ErrInvalidCredentials.
+type CredentialsError struct {
+	Errs []error
+}
+
+// Error returns the message of ErrInvalidCredentials followed by the error of each authorizer.
+func (e *CredentialsError) Error() string {
+	if len(e.Errs) == 0 {
+		return ErrInvalidCredentials.Error()
+	}
+	msgs := make([]string, 0, len(e.Errs))
+	for _, err := range e.Errs {
+		msgs = append(msgs, err.Error())
+	}
+	return ErrInvalidCredentials.Error() + ": " + strings.Join(msgs, "; ")
+}
+
+// Cause returns ErrInvalidCredentials for errors.Cause().
+func (e *CredentialsError) Cause() error {
+	return ErrInvalidCredentials
+}
+
+// Unwrap returns ErrInvalidCredentials for errors.Is().
+func (e *CredentialsError) Unwrap() error {
+	return ErrInvalidCredentials
+}
+
+// Forbidden returns true if any of the credentials is valid but denied by the policy, i.e. the request should be responded with 403 instead of 401.
+func (e *CredentialsError) Forbidden() bool {
+	for _, err := range e.Errs {
+		switch errors.Cause(err) {
+		case ErrDenyByPolicy, ErrNoMatch, ErrDomainMismatch, ErrDomainNotFound, ErrDomainExpired, ErrInvalidPolicyResource:
+			return true
+		}
+	}
+	return false
+}
+
+// StatusCode returns http.StatusForbidden if Forbidden() is true, otherwise http.StatusUnauthorized.
+func (e *CredentialsError) StatusCode() int {
+	if e.Forbidden() {
+		return http.StatusForbidden
+	}
+	return http.StatusUnauthorized
+}
+
+// combine runs the authorizers and combines the results by the combination policy.
+// It returns ErrInvalidCredentials as is if no credential is present in the request for AnyOf and FirstPresent, otherwise a *CredentialsError.
+func (a *authority) combine(r *http.Request, act, res string) (Principal, error) {
+	errs := make([]error, 0, len(a.authorizers))
+	switch a.combinationPolicy {
+	case AllOf:
+		var p Principal
+		for _, authorize := range a.authorizers {
+			verified, err := authorize(r, act, res)
+			if err != nil {
+				glg.Debugf("authorize failed, err: %v", err)
+				errs = append(errs, err)
+				continue
+			}
+			if p == nil {
+				p = verified
+			}
+		}
+		if len(errs) != 0 || len(a.authorizers) == 0 {
+			return nil, &CredentialsError{Errs: errs}
+		}
+		return p, nil
+	case FirstPresent:
+		for _, authorize := range a.authorizers {
+			verified, err := authorize(r, act, res)
+			if err == nil {
+				return verified, nil
+			}
+			glg.Debugf("authorize failed, err: %v", err)
+			errs = append(errs, err)
+			if errors.Cause(err) != ErrEmptyCredential {
+				// only the first credential present is checked
+				return nil, &CredentialsError{Errs: []error{err}}
+			}
+		}
+		return nil, ErrInvalidCredentials
+	default:
+		for _, authorize := range a.authorizers {
+			// OR logic on multiple credentials
+			verified, err := authorize(r, act, res)
+			if err == nil {
+				return verified, nil
+			}
+			glg.Debugf("authorize failed, err: %v", err)
+			errs = append(errs, err)
+		}
+		if noCredential(errs) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, &CredentialsError{Errs: errs}
+	}
+}
+
+// noCredential returns true if all the errors are ErrEmptyCredential, i.e. no credential is present in the request.
+func noCredential(errs []error) bool {
+	for _, err := range errs {
+		if errors.Cause(err) != ErrEmptyCredential {
+			return false
+		}
+	}
+	return true
+}
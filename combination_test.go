@@ -0,0 +1,93 @@
+/*
+Copyright (C)  2018 Yahoo Japan Corporation Athenz team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package authorizerd
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestCredentialsError_Error(t *testing.T) {
+	tests := []struct {
+		name string
+		errs []error
+		want string
+	}{
+		{
+			name: "no error",
+			want: "Access denied due to invalid credentials",
+		},
+		{
+			name: "multiple errors",
+			errs: []error{
+				errors.New("error 1"),
+				errors.New("error 2"),
+			},
+			want: "Access denied due to invalid credentials: error 1; error 2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &CredentialsError{Errs: tt.errs}
+			if got := e.Error(); got != tt.want {
+				t.Errorf("CredentialsError.Error() = %v, want %v", got, tt.want)
+			}
+			if got := errors.Cause(e); got != ErrInvalidCredentials {
+				t.Errorf("errors.Cause() = %v, want %v", got, ErrInvalidCredentials)
+			}
+		})
+	}
+}
+
+func TestCredentialsError_StatusCode(t *testing.T) {
+	tests := []struct {
+		name string
+		errs []error
+		want int
+	}{
+		{
+			name: "no error",
+			want: http.StatusUnauthorized,
+		},
+		{
+			name: "invalid credentials",
+			errs: []error{
+				errors.Wrap(ErrEmptyCredential, "role token not found"),
+				errors.Wrap(ErrRoleTokenExpired, "error authorize role token"),
+			},
+			want: http.StatusUnauthorized,
+		},
+		{
+			name: "denied by policy",
+			errs: []error{
+				errors.Wrap(ErrEmptyCredential, "role token not found"),
+				errors.Wrap(ErrDenyByPolicy, "token unauthorized"),
+			},
+			want: http.StatusForbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &CredentialsError{Errs: tt.errs}
+			if got := e.StatusCode(); got != tt.want {
+				t.Errorf("CredentialsError.StatusCode() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
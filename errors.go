@@ -28,6 +28,10 @@ var (
 	ErrRoleTokenInvalid = role.ErrRoleTokenInvalid
 	// ErrRoleTokenExpired "Access denied due to expired RoleToken"
 	ErrRoleTokenExpired = role.ErrRoleTokenExpired
+	// ErrRoleTokenNotYetValid "Access denied due to RoleToken issued in the future"
+	ErrRoleTokenNotYetValid = role.ErrRoleTokenNotYetValid
+	// ErrRoleTokenLifetimeExceeded "Access denied due to RoleToken lifetime exceeding the maximum"
+	ErrRoleTokenLifetimeExceeded = role.ErrRoleTokenLifetimeExceeded
 
 	// ErrDomainMismatch "Access denied due to domain mismatch between Resource and RoleToken"
 	ErrDomainMismatch = policy.ErrDomainMismatch
@@ -49,6 +53,18 @@ var (
 
 	// ErrInvalidCredentials "Access denied due to invalid credentials"
 	ErrInvalidCredentials = errors.New("Access denied due to invalid credentials")
+
+	// ErrEmptyCredential "Access denied due to empty credential"
+	ErrEmptyCredential = errors.New("Access denied due to empty credential")
+
+	// ErrCertMismatchIssuer "Access denied due to certificate mismatch in issuer"
+	ErrCertMismatchIssuer = errors.New("Access denied due to certificate mismatch in issuer")
+	// ErrCertExpired "Access denied due to expired certificate"
+	ErrCertExpired = errors.New("Access denied due to expired certificate")
+	// ErrCertNotYetValid "Access denied due to not yet valid certificate"
+	ErrCertNotYetValid = errors.New("Access denied due to not yet valid certificate")
+	// ErrCertUntrusted "Access denied due to certificate not issued by the trusted CA"
+	ErrCertUntrusted = errors.New("Access denied due to certificate not issued by the trusted CA")
 )
 
 /*
@@ -58,8 +74,6 @@ const (
 	// ErrDomainEmpty "Access denied due to no policies in the domain file"
 	ErrDomainEmpty Effect = iota + 1
 
-	// ErrCertMismatchIssuer "Access denied due to certificate mismatch in issuer"
-	ErrCertMismatchIssuer
 	// ErrCertMissingSubject "Access denied due to missing subject in certificate"
 	ErrCertMissingSubject
 	// ErrCertMissingDomain "Access denied due to missing domain name in certificate"
@@ -92,8 +106,6 @@ func (e Effect) String() string {
 		return "Access denied due to no policies in the domain file"
 	case ErrInvalidParameters:
 		return "Access denied due to invalid/empty action/resource values"
-	case ErrCertMismatchIssuer:
-		return "Access denied due to certificate mismatch in issuer"
 	case ErrCertMissingSubject:
 		return "Access denied due to missing subject in certificate"
 	case ErrCertMissingDomain:
@@ -0,0 +1,107 @@
+/*
+Copyright (C)  2018 Yahoo Japan Corporation Athenz team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package authorizerd
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// accessTokenQueryParam is the query parameter name of the access token. https://tools.ietf.org/html/rfc6750#section-2.3
+	accessTokenQueryParam = "access_token"
+	bearerPrefix          = "Bearer "
+)
+
+// CredentialExtractor extracts the credential from the request. It returns an empty string if the credential is not found.
+type CredentialExtractor func(r *http.Request) string
+
+// HeaderExtractor returns a CredentialExtractor that extracts the credential from the first non-empty header of names.
+func HeaderExtractor(names ...string) CredentialExtractor {
+	return func(r *http.Request) string {
+		for _, name := range names {
+			if v := r.Header.Get(name); v != "" {
+				return v
+			}
+		}
+		return ""
+	}
+}
+
+// BearerTokenExtractor returns a CredentialExtractor that extracts the credential from the Authorization header.
+// The "Bearer " prefix is stripped if exists.
+func BearerTokenExtractor() CredentialExtractor {
+	return func(r *http.Request) string {
+		tok := r.Header.Get("Authorization")
+		if len(tok) >= len(bearerPrefix) && strings.EqualFold(tok[:len(bearerPrefix)], bearerPrefix) {
+			return tok[len(bearerPrefix):]
+		}
+		return tok
+	}
+}
+
+// CookieExtractor returns a CredentialExtractor that extracts the credential from the first non-empty cookie of names.
+func CookieExtractor(names ...string) CredentialExtractor {
+	return func(r *http.Request) string {
+		for _, name := range names {
+			if c, err := r.Cookie(name); err == nil && c.Value != "" {
+				return c.Value
+			}
+		}
+		return ""
+	}
+}
+
+// QueryExtractor returns a CredentialExtractor that extracts the credential from the query parameter.
+func QueryExtractor(name string) CredentialExtractor {
+	return func(r *http.Request) string {
+		if r.URL == nil {
+			return ""
+		}
+		return r.URL.Query().Get(name)
+	}
+}
+
+// AccessTokenQueryExtractor returns a CredentialExtractor that extracts the access token from the "access_token" query parameter.
+// It is not used by default since the token in the URL may be leaked via logs. https://tools.ietf.org/html/rfc6750#section-5.3
+func AccessTokenQueryExtractor() CredentialExtractor {
+	return QueryExtractor(accessTokenQueryParam)
+}
+
+// extractCredential returns the first non-empty credential extracted by the extractors.
+func extractCredential(r *http.Request, extractors []CredentialExtractor) string {
+	for _, extract := range extractors {
+		if cred := strings.TrimSpace(extract(r)); cred != "" {
+			return cred
+		}
+	}
+	return ""
+}
+
+func validateExtractors(extractors []CredentialExtractor) error {
+	if len(extractors) == 0 {
+		return errors.New("no credential extractors")
+	}
+	for i, extract := range extractors {
+		if extract == nil {
+			return errors.Errorf("credential extractor[%d] is nil", i)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,300 @@
+/*
+Copyright (C)  2018 Yahoo Japan Corporation Athenz team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package authorizerd
+
+import (
+	"net/http"
+	"testing"
+)
+
+func newExtractorTestRequest(url string, headers map[string]string, cookies ...*http.Cookie) *http.Request {
+	r, _ := http.NewRequest("GET", url, nil)
+	for k, v := range headers {
+		r.Header.Set(k, v)
+	}
+	for _, c := range cookies {
+		r.AddCookie(c)
+	}
+	return r
+}
+
+func TestHeaderExtractor(t *testing.T) {
+	type args struct {
+		names []string
+		r     *http.Request
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{
+			name: "extract success",
+			args: args{
+				names: []string{"Athenz-Role-Auth"},
+				r: newExtractorTestRequest("http://127.0.0.1", map[string]string{
+					"Athenz-Role-Auth": "dummyToken",
+				}),
+			},
+			want: "dummyToken",
+		},
+		{
+			name: "extract from the first non-empty header",
+			args: args{
+				names: []string{"Athenz-Role-Auth", "X-Role-Token"},
+				r: newExtractorTestRequest("http://127.0.0.1", map[string]string{
+					"X-Role-Token": "dummyToken",
+				}),
+			},
+			want: "dummyToken",
+		},
+		{
+			name: "header not found",
+			args: args{
+				names: []string{"Athenz-Role-Auth"},
+				r:     newExtractorTestRequest("http://127.0.0.1", nil),
+			},
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HeaderExtractor(tt.args.names...)(tt.args.r); got != tt.want {
+				t.Errorf("HeaderExtractor() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBearerTokenExtractor(t *testing.T) {
+	type args struct {
+		r *http.Request
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{
+			name: "bearer token",
+			args: args{
+				r: newExtractorTestRequest("http://127.0.0.1", map[string]string{
+					"Authorization": "Bearer dummyToken",
+				}),
+			},
+			want: "dummyToken",
+		},
+		{
+			name: "bearer token, case insensitive",
+			args: args{
+				r: newExtractorTestRequest("http://127.0.0.1", map[string]string{
+					"Authorization": "bearer dummyToken",
+				}),
+			},
+			want: "dummyToken",
+		},
+		{
+			name: "token without prefix",
+			args: args{
+				r: newExtractorTestRequest("http://127.0.0.1", map[string]string{
+					"Authorization": "dummyToken",
+				}),
+			},
+			want: "dummyToken",
+		},
+		{
+			name: "no token",
+			args: args{
+				r: newExtractorTestRequest("http://127.0.0.1", nil),
+			},
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := BearerTokenExtractor()(tt.args.r); got != tt.want {
+				t.Errorf("BearerTokenExtractor() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCookieExtractor(t *testing.T) {
+	type args struct {
+		names []string
+		r     *http.Request
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{
+			name: "extract success",
+			args: args{
+				names: []string{"roleToken"},
+				r: newExtractorTestRequest("http://127.0.0.1", nil, &http.Cookie{
+					Name:  "roleToken",
+					Value: "dummyToken",
+				}),
+			},
+			want: "dummyToken",
+		},
+		{
+			name: "extract from the first non-empty cookie",
+			args: args{
+				names: []string{"roleToken", "rt"},
+				r: newExtractorTestRequest("http://127.0.0.1", nil, &http.Cookie{
+					Name:  "rt",
+					Value: "dummyToken",
+				}),
+			},
+			want: "dummyToken",
+		},
+		{
+			name: "cookie not found",
+			args: args{
+				names: []string{"roleToken"},
+				r:     newExtractorTestRequest("http://127.0.0.1", nil),
+			},
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CookieExtractor(tt.args.names...)(tt.args.r); got != tt.want {
+				t.Errorf("CookieExtractor() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAccessTokenQueryExtractor(t *testing.T) {
+	type args struct {
+		r *http.Request
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{
+			name: "extract success",
+			args: args{
+				r: newExtractorTestRequest("http://127.0.0.1/path?access_token=dummyToken&q=1", nil),
+			},
+			want: "dummyToken",
+		},
+		{
+			name: "query not found",
+			args: args{
+				r: newExtractorTestRequest("http://127.0.0.1/path?q=1", nil),
+			},
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := AccessTokenQueryExtractor()(tt.args.r); got != tt.want {
+				t.Errorf("AccessTokenQueryExtractor() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_extractCredential(t *testing.T) {
+	empty := func(*http.Request) string {
+		return " "
+	}
+	found := func(*http.Request) string {
+		return "dummyToken"
+	}
+	type args struct {
+		extractors []CredentialExtractor
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{
+			name: "first non-empty credential",
+			args: args{
+				extractors: []CredentialExtractor{empty, found},
+			},
+			want: "dummyToken",
+		},
+		{
+			name: "credential not found",
+			args: args{
+				extractors: []CredentialExtractor{empty},
+			},
+			want: "",
+		},
+		{
+			name: "no extractors",
+			args: args{},
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newExtractorTestRequest("http://127.0.0.1", nil)
+			if got := extractCredential(r, tt.args.extractors); got != tt.want {
+				t.Errorf("extractCredential() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_validateExtractors(t *testing.T) {
+	type args struct {
+		extractors []CredentialExtractor
+	}
+	tests := []struct {
+		name    string
+		args    args
+		wantErr bool
+	}{
+		{
+			name: "valid extractors",
+			args: args{
+				extractors: []CredentialExtractor{BearerTokenExtractor()},
+			},
+			wantErr: false,
+		},
+		{
+			name:    "no extractors",
+			args:    args{},
+			wantErr: true,
+		},
+		{
+			name: "nil extractor",
+			args: args{
+				extractors: []CredentialExtractor{BearerTokenExtractor(), nil},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateExtractors(tt.args.extractors); (err != nil) != tt.wantErr {
+				t.Errorf("validateExtractors() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -4,7 +4,6 @@ go 1.14
 
 require (
 	github.com/ardielle/ardielle-go v1.5.2
-	github.com/dgrijalva/jwt-go v3.2.0+incompatible
 	github.com/google/go-cmp v0.5.0
 	github.com/kpango/fastime v1.0.16
 	github.com/kpango/gache v1.2.1
@@ -0,0 +1,106 @@
+/*
+Copyright (C)  2018 Yahoo Japan Corporation Athenz team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package supervisor
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// DefaultJitter is the jitter ratio of the retry backoff used by the daemons.
+const DefaultJitter = 0.1
+
+var (
+	rnd   = rand.New(rand.NewSource(time.Now().UnixNano()))
+	rndMu sync.Mutex
+)
+
+// Backoff represents the exponential backoff of the retries.
+type Backoff struct {
+	// Initial is the delay of the first retry. The zero value disables the retry.
+	Initial time.Duration
+	// Max is the maximum delay of the retries. The zero value means no limit.
+	Max time.Duration
+	// Factor is the multiplier of the delay on each retry, 2 if less than 1.
+	Factor float64
+	// Jitter is the ratio of the random deviation of the delay, e.g. 0.1 randomizes the delay by ±10%.
+	Jitter float64
+}
+
+// Duration returns the delay of the retry attempt, starting from 1.
+func (b Backoff) Duration(attempt int) time.Duration {
+	if b.Initial <= 0 {
+		return 0
+	}
+	if attempt < 1 {
+		attempt = 1
+	}
+	factor := b.Factor
+	if factor < 1 {
+		factor = 2
+	}
+	d := float64(b.Initial) * math.Pow(factor, float64(attempt-1))
+	if b.Max > 0 && d > float64(b.Max) {
+		d = float64(b.Max)
+	}
+	if d > math.MaxInt64 {
+		d = math.MaxInt64
+	}
+	return Jitter(time.Duration(d), b.Jitter)
+}
+
+// Jitter randomizes the duration by ±ratio.
+func Jitter(d time.Duration, ratio float64) time.Duration {
+	if ratio <= 0 || d <= 0 {
+		return d
+	}
+	if ratio > 1 {
+		ratio = 1
+	}
+	rndMu.Lock()
+	r := rnd.Float64()
+	rndMu.Unlock()
+	return time.Duration(float64(d) * (1 + ratio*(2*r-1)))
+}
+
+// Spread returns a random duration in [0, d), to spread the concurrent jobs over the duration.
+func Spread(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	rndMu.Lock()
+	defer rndMu.Unlock()
+	return time.Duration(rnd.Int63n(int64(d)))
+}
+
+// Sleep waits for the duration, or returns ctx.Err() immediately when ctx is done.
+func Sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
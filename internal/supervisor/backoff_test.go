@@ -0,0 +1,148 @@
+/*
+Copyright (C)  2018 Yahoo Japan Corporation Athenz team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package supervisor
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestBackoff_Duration(t *testing.T) {
+	type args struct {
+		attempt int
+	}
+	tests := []struct {
+		name    string
+		backoff Backoff
+		args    args
+		want    time.Duration
+	}{
+		{
+			name: "first attempt",
+			backoff: Backoff{
+				Initial: time.Second,
+			},
+			args: args{
+				attempt: 1,
+			},
+			want: time.Second,
+		},
+		{
+			name: "exponential",
+			backoff: Backoff{
+				Initial: time.Second,
+			},
+			args: args{
+				attempt: 4,
+			},
+			want: 8 * time.Second,
+		},
+		{
+			name: "custom factor",
+			backoff: Backoff{
+				Initial: time.Second,
+				Factor:  3,
+			},
+			args: args{
+				attempt: 3,
+			},
+			want: 9 * time.Second,
+		},
+		{
+			name: "max backoff",
+			backoff: Backoff{
+				Initial: time.Second,
+				Max:     5 * time.Second,
+			},
+			args: args{
+				attempt: 100,
+			},
+			want: 5 * time.Second,
+		},
+		{
+			name:    "retry disabled",
+			backoff: Backoff{},
+			args: args{
+				attempt: 1,
+			},
+			want: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.backoff.Duration(tt.args.attempt); got != tt.want {
+				t.Errorf("Backoff.Duration() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJitter(t *testing.T) {
+	d := time.Second
+	seen := make(map[time.Duration]struct{})
+	for i := 0; i < 100; i++ {
+		got := Jitter(d, 0.1)
+		if got < 900*time.Millisecond || got > 1100*time.Millisecond {
+			t.Fatalf("Jitter() = %v, want between 900ms and 1.1s", got)
+		}
+		seen[got] = struct{}{}
+	}
+	if len(seen) < 2 {
+		t.Errorf("Jitter() is not randomized")
+	}
+	if got := Jitter(d, 0); got != d {
+		t.Errorf("Jitter() = %v, want %v", got, d)
+	}
+}
+
+func TestSpread(t *testing.T) {
+	d := time.Second
+	seen := make(map[time.Duration]struct{})
+	for i := 0; i < 100; i++ {
+		got := Spread(d)
+		if got < 0 || got >= d {
+			t.Fatalf("Spread() = %v, want between 0 and 1s", got)
+		}
+		seen[got] = struct{}{}
+	}
+	if len(seen) < 2 {
+		t.Errorf("Spread() is not randomized")
+	}
+	if got := Spread(0); got != 0 {
+		t.Errorf("Spread() = %v, want 0", got)
+	}
+}
+
+func TestSleep(t *testing.T) {
+	if err := Sleep(context.Background(), time.Millisecond); err != nil {
+		t.Errorf("Sleep() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	start := time.Now()
+	if err := Sleep(ctx, time.Hour); err != context.Canceled {
+		t.Errorf("Sleep() error = %v, want %v", err, context.Canceled)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Sleep() not interrupted, elapsed: %v", elapsed)
+	}
+}
@@ -0,0 +1,101 @@
+/*
+Copyright (C)  2018 Yahoo Japan Corporation Athenz team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package supervisor provides the refresh loop shared by the daemons, with the exponential backoff and jitter on failure
+package supervisor
+
+import (
+	"context"
+	"time"
+
+	"github.com/kpango/glg"
+	"github.com/pkg/errors"
+)
+
+// Config represents the refresh loop of a daemon.
+type Config struct {
+	// Name is the name of the daemon for logging.
+	Name string
+	// Interval returns the delay until the next periodic update. It is called after each successful update.
+	Interval func() time.Duration
+	// Backoff is the retry backoff on the update failure. The zero value disables the retry, i.e. the next update follows the Interval.
+	Backoff Backoff
+}
+
+// Run calls update periodically until ctx is done, and returns the channel of the update errors.
+// The update errors are sent without blocking the loop, and dropped if the channel is full; the number of the dropped errors is reported with ctx.Err() on stop.
+// The channel receives ctx.Err() and is closed when the loop stops.
+func Run(ctx context.Context, cfg Config, update func(context.Context) error) <-chan error {
+	glg.Infof("Starting %s updater", cfg.Name)
+	ech := make(chan error, 100)
+
+	go func() {
+		defer close(ech)
+
+		var dropped int
+		var lastDropped error
+		attempt := 0
+		delay := cfg.Interval()
+		for {
+			if Sleep(ctx, delay) != nil {
+				glg.Infof("Stopping %s updater", cfg.Name)
+				if dropped != 0 {
+					ech <- errors.Wrapf(ctx.Err(), "%d errors dropped, last error: %v", dropped, lastDropped)
+				} else {
+					ech <- ctx.Err()
+				}
+				return
+			}
+
+			err := update(ctx)
+			if err == nil {
+				attempt = 0
+				delay = cfg.Interval()
+				continue
+			}
+			if ctx.Err() != nil {
+				// stopped during the update
+				delay = 0
+				continue
+			}
+
+			select {
+			case ech <- err:
+			default:
+				glg.Warnf("%s error queue already full, error: %v", cfg.Name, err)
+				dropped++
+				lastDropped = err
+			}
+
+			if cfg.Backoff.Initial <= 0 {
+				delay = cfg.Interval()
+				continue
+			}
+			attempt++
+			delay = cfg.Backoff.Duration(attempt)
+			glg.Debugf("%s will retry the update after %s, attempt: %d", cfg.Name, delay, attempt)
+		}
+	}()
+
+	return ech
+}
+
+// Every returns the Interval of the fixed duration.
+func Every(d time.Duration) func() time.Duration {
+	return func() time.Duration {
+		return d
+	}
+}
@@ -0,0 +1,173 @@
+/*
+Copyright (C)  2018 Yahoo Japan Corporation Athenz team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	type args struct {
+		cfg    Config
+		update func(*int32) func(context.Context) error
+	}
+	type test struct {
+		name      string
+		args      args
+		checkFunc func(context.CancelFunc, <-chan error, *int32) error
+	}
+	tests := []test{
+		{
+			name: "update periodically",
+			args: args{
+				cfg: Config{
+					Name:     "test",
+					Interval: Every(10 * time.Millisecond),
+				},
+				update: func(cnt *int32) func(context.Context) error {
+					return func(context.Context) error {
+						atomic.AddInt32(cnt, 1)
+						return nil
+					}
+				},
+			},
+			checkFunc: func(cancel context.CancelFunc, ech <-chan error, cnt *int32) error {
+				time.Sleep(100 * time.Millisecond)
+				cancel()
+				if err := <-ech; err != context.Canceled {
+					return errors.New("unexpected error: " + err.Error())
+				}
+				if got := atomic.LoadInt32(cnt); got < 3 {
+					return errors.New("not updated periodically")
+				}
+				return nil
+			},
+		},
+		{
+			name: "stop immediately",
+			args: args{
+				cfg: Config{
+					Name:     "test",
+					Interval: Every(time.Hour),
+				},
+				update: func(cnt *int32) func(context.Context) error {
+					return func(context.Context) error {
+						atomic.AddInt32(cnt, 1)
+						return nil
+					}
+				},
+			},
+			checkFunc: func(cancel context.CancelFunc, ech <-chan error, cnt *int32) error {
+				start := time.Now()
+				cancel()
+				if err := <-ech; err != context.Canceled {
+					return errors.New("unexpected error: " + err.Error())
+				}
+				if _, ok := <-ech; ok {
+					return errors.New("error channel not closed")
+				}
+				if time.Since(start) > time.Second {
+					return errors.New("not stopped immediately")
+				}
+				if got := atomic.LoadInt32(cnt); got != 0 {
+					return errors.New("updated before the interval")
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cnt int32
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			ech := Run(ctx, tt.args.cfg, tt.args.update(&cnt))
+			if err := tt.checkFunc(cancel, ech, &cnt); err != nil {
+				t.Errorf("Run() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestRun_retry(t *testing.T) {
+	var cnt int32
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ech := Run(ctx, Config{
+		Name:     "test",
+		Interval: Every(time.Millisecond),
+		Backoff: Backoff{
+			Initial: 20 * time.Millisecond,
+			Max:     20 * time.Millisecond,
+		},
+	}, func(context.Context) error {
+		if atomic.AddInt32(&cnt, 1) < 3 {
+			return errors.New("update error")
+		}
+		return nil
+	})
+
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-ech:
+			if err.Error() != "update error" {
+				t.Errorf("Run() error = %v, want update error", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Run() error not reported")
+		}
+	}
+	// the third update succeeds after the backoff
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&cnt) < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Run() not retried")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-ech; err != context.Canceled {
+		t.Errorf("Run() error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestRun_dropped(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var cnt int32
+	ech := Run(ctx, Config{
+		Name:     "test",
+		Interval: Every(0),
+	}, func(context.Context) error {
+		if atomic.AddInt32(&cnt, 1) > 101 {
+			cancel()
+		}
+		return errors.New("update error")
+	})
+	<-ctx.Done()
+	var last error
+	for err := range ech {
+		last = err
+	}
+	if last == nil || !strings.Contains(last.Error(), "errors dropped") || !strings.Contains(last.Error(), context.Canceled.Error()) {
+		t.Errorf("Run() last error = %v, want dropped errors with %v", last, context.Canceled)
+	}
+}
@@ -0,0 +1,208 @@
+/*
+Copyright (C)  2018 Yahoo Japan Corporation Athenz team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwk
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kpango/fastime"
+	"github.com/kpango/glg"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/pkg/errors"
+	"github.com/yahoojapan/athenz-authorizer/v5/internal/supervisor"
+)
+
+// fetchMeta represents the metadata of the last successful fetch of a JWK Set URL.
+type fetchMeta struct {
+	eTag         string
+	lastModified string
+	// the time the last request was sent
+	fetched time.Time
+	// the max-age in the Cache-Control response header, minus the Age header. Negative if not provided.
+	maxAge time.Duration
+	// the number of the consecutive failures since the last successful fetch
+	failures int
+	// the time the JWK Set should be refreshed by Update
+	nextRefresh time.Time
+}
+
+// fetchSet fetches the JWK Set of the target. If the JWK Set is cached, the request is conditional by the ETag and the Last-Modified of the last response,
+// and the cached JWK Set is returned on 304 Not Modified. The fetch metadata of the target is updated on success,
+// and the next refresh of the target is backed off on failure.
+func (j *jwkd) fetchSet(ctx context.Context, target string) (_ *jwk.Set, err error) {
+	now := fastime.Now()
+	m := j.loadMeta(target)
+	defer func() {
+		if err != nil {
+			j.storeFailure(target, m, now)
+			err = errors.Wrapf(err, "error fetch JWK Set from %s", target)
+		}
+	}()
+
+	req, err := http.NewRequest(http.MethodGet, target, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to new request to remote JWK")
+	}
+
+	cached, _ := j.keys.Load(target)
+	if cached != nil && m != nil {
+		if m.eTag != "" {
+			req.Header.Set("If-None-Match", m.eTag)
+		}
+		if m.lastModified != "" {
+			req.Header.Set("If-Modified-Since", m.lastModified)
+		}
+	}
+
+	res, err := j.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch remote JWK")
+	}
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode == http.StatusNotModified && cached != nil && m != nil:
+		glg.Debugf("JWK Set of %s not modified, ETag: %s, Last-Modified: %s", target, m.eTag, m.lastModified)
+		j.storeMeta(target, &fetchMeta{
+			eTag:         m.eTag,
+			lastModified: m.lastModified,
+			fetched:      now,
+			maxAge:       parseMaxAge(res.Header),
+		})
+		return cached.(*jwk.Set), nil
+	case res.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("failed to fetch remote JWK (status = %d)", res.StatusCode)
+	}
+
+	set, err := jwk.Parse(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	j.storeMeta(target, &fetchMeta{
+		eTag:         res.Header.Get("ETag"),
+		lastModified: res.Header.Get("Last-Modified"),
+		fetched:      now,
+		maxAge:       parseMaxAge(res.Header),
+	})
+	return set, nil
+}
+
+// loadMeta returns the fetch metadata of the target, or nil if not fetched yet.
+func (j *jwkd) loadMeta(target string) *fetchMeta {
+	m, ok := j.meta.Load(target)
+	if !ok {
+		return nil
+	}
+	return m.(*fetchMeta)
+}
+
+// storeMeta stores the fetch metadata with the next refresh time.
+// The next refresh follows the max-age if provided, but not later than refreshPeriod and not earlier than minRefreshInterval; otherwise the refreshPeriod is used.
+// The max-age is capped so that the rotated or revoked keys are not trusted longer than refreshPeriod.
+func (j *jwkd) storeMeta(target string, m *fetchMeta) {
+	interval := j.refreshPeriod
+	if m.maxAge >= 0 {
+		interval = m.maxAge
+		if interval > j.refreshPeriod {
+			interval = j.refreshPeriod
+		}
+		if interval < j.minRefreshInterval {
+			interval = j.minRefreshInterval
+		}
+	}
+	m.nextRefresh = m.fetched.Add(interval)
+	j.meta.Store(target, m)
+}
+
+// storeFailure stores the fetch metadata of the failure. The next refresh is backed off exponentially from retryDelay up to refreshPeriod, with jitter.
+// The ETag and the Last-Modified of the last successful fetch are kept.
+func (j *jwkd) storeFailure(target string, last *fetchMeta, now time.Time) {
+	m := &fetchMeta{
+		fetched:  now,
+		maxAge:   -1,
+		failures: 1,
+	}
+	if last != nil {
+		m.eTag = last.eTag
+		m.lastModified = last.lastModified
+		m.failures = last.failures + 1
+	}
+	backoff := supervisor.Backoff{
+		Initial: j.retryDelay,
+		Max:     j.refreshPeriod,
+		Jitter:  supervisor.DefaultJitter,
+	}
+	m.nextRefresh = now.Add(backoff.Duration(m.failures))
+	j.meta.Store(target, m)
+}
+
+// isFresh returns true if the JWK Set of the target need not be refreshed by Update yet, i.e. the cached JWK Set is fresh or the retry is backed off.
+func (j *jwkd) isFresh(target string, now time.Time) bool {
+	m := j.loadMeta(target)
+	return m != nil && now.Before(m.nextRefresh)
+}
+
+// nextUpdateDelay returns the delay until the earliest next refresh of the JWK Sets, at most refreshPeriod and at least minRefreshInterval.
+func (j *jwkd) nextUpdateDelay() time.Duration {
+	now := fastime.Now()
+	d := j.refreshPeriod
+	j.meta.Range(func(_, v interface{}) bool {
+		if nd := v.(*fetchMeta).nextRefresh.Sub(now); nd < d {
+			d = nd
+		}
+		return true
+	})
+	if d < j.minRefreshInterval {
+		d = j.minRefreshInterval
+	}
+	if d <= 0 {
+		d = j.refreshPeriod
+	}
+	return d
+}
+
+// parseMaxAge returns the max-age in the Cache-Control header minus the Age header, or -1 if max-age is not provided.
+// The no-cache and no-store directives are ignored, since the JWK Set is still refreshed periodically.
+func parseMaxAge(h http.Header) time.Duration {
+	maxAge := time.Duration(-1)
+	for _, directive := range strings.Split(h.Get("Cache-Control"), ",") {
+		kv := strings.SplitN(strings.TrimSpace(directive), "=", 2)
+		if len(kv) != 2 || !strings.EqualFold(kv[0], "max-age") {
+			continue
+		}
+		sec, err := strconv.ParseInt(strings.Trim(kv[1], `"`), 10, 64)
+		if err != nil || sec < 0 {
+			return -1
+		}
+		maxAge = time.Duration(sec) * time.Second
+	}
+	if maxAge < 0 {
+		return maxAge
+	}
+	if age, err := strconv.ParseInt(h.Get("Age"), 10, 64); err == nil && age > 0 {
+		maxAge -= time.Duration(age) * time.Second
+		if maxAge < 0 {
+			maxAge = 0
+		}
+	}
+	return maxAge
+}
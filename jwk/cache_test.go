@@ -0,0 +1,292 @@
+/*
+Copyright (C)  2018 Yahoo Japan Corporation Athenz team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwk
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kpango/fastime"
+	"github.com/lestrrat-go/jwx/jwk"
+)
+
+func Test_jwkd_fetchSet(t *testing.T) {
+	var cnt, notModified int32
+	k := `{"keys":[{"e":"AQAB","kty":"RSA","kid":"0","n":"0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw"}]}`
+	lastModified := "Mon, 02 Jan 2006 15:04:05 GMT"
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&cnt, 1)
+		w.Header().Set("Cache-Control", "public, max-age=3600")
+		if r.Header.Get("If-None-Match") == `"v1"` && r.Header.Get("If-Modified-Since") == lastModified {
+			atomic.AddInt32(&notModified, 1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Last-Modified", lastModified)
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(k))
+	}))
+	defer srv.Close()
+
+	j := &jwkd{
+		athenzJwksURL:      srv.URL,
+		refreshPeriod:      24 * time.Hour,
+		minRefreshInterval: time.Minute,
+		client:             srv.Client(),
+		keys:               &sync.Map{},
+		lastRefreshed:      &sync.Map{},
+	}
+
+	// the first fetch is not conditional
+	if err := j.fetch(context.Background(), srv.URL); err != nil {
+		t.Fatalf("jwkd.fetch() error = %v", err)
+	}
+	m := j.loadMeta(srv.URL)
+	if m == nil {
+		t.Fatalf("jwkd.fetch() fetch metadata not stored")
+	}
+	if m.eTag != `"v1"` || m.lastModified != lastModified || m.maxAge != time.Hour {
+		t.Errorf("jwkd.fetch() invalid fetch metadata: %+v", m)
+	}
+	if got := m.nextRefresh.Sub(m.fetched); got != time.Hour {
+		t.Errorf("jwkd.fetch() next refresh after %v, want %v", got, time.Hour)
+	}
+	if !j.isFresh(srv.URL, fastime.Now()) {
+		t.Errorf("jwkd.isFresh() = false, want true")
+	}
+
+	// Update skips the fresh JWK Set
+	if err := j.Update(context.Background()); err != nil {
+		t.Fatalf("jwkd.Update() error = %v", err)
+	}
+	if got := atomic.LoadInt32(&cnt); got != 1 {
+		t.Errorf("jwkd.Update() fetched %d times, want 1", got)
+	}
+
+	// the refresh is conditional, and the cached JWK Set is kept on 304
+	cached, _ := j.keys.Load(srv.URL)
+	if err := j.fetch(context.Background(), srv.URL); err != nil {
+		t.Fatalf("jwkd.fetch() error = %v", err)
+	}
+	if got := atomic.LoadInt32(&notModified); got != 1 {
+		t.Errorf("jwkd.fetch() not modified %d times, want 1", got)
+	}
+	if got, _ := j.keys.Load(srv.URL); got.(*jwk.Set) != cached.(*jwk.Set) {
+		t.Errorf("jwkd.fetch() cached JWK Set replaced on 304")
+	}
+	if j.getKey("0", "") == nil {
+		t.Errorf("jwkd.getKey() key not found")
+	}
+	if m := j.loadMeta(srv.URL); m.eTag != `"v1"` || m.lastModified != lastModified {
+		t.Errorf("jwkd.fetch() fetch metadata not kept on 304: %+v", m)
+	}
+
+	// not conditional without the cached JWK Set
+	j.keys.Delete(srv.URL)
+	if err := j.fetch(context.Background(), srv.URL); err != nil {
+		t.Fatalf("jwkd.fetch() error = %v", err)
+	}
+	if got := atomic.LoadInt32(&notModified); got != 1 {
+		t.Errorf("jwkd.fetch() not modified %d times, want 1", got)
+	}
+	if j.getKey("0", "") == nil {
+		t.Errorf("jwkd.getKey() key not found")
+	}
+}
+
+func Test_jwkd_nextUpdateDelay(t *testing.T) {
+	type fields struct {
+		refreshPeriod      time.Duration
+		minRefreshInterval time.Duration
+		nextRefresh        []time.Duration
+	}
+	tests := []struct {
+		name   string
+		fields fields
+		min    time.Duration
+		max    time.Duration
+	}{
+		{
+			name: "no JWK Set fetched",
+			fields: fields{
+				refreshPeriod:      time.Hour,
+				minRefreshInterval: time.Minute,
+			},
+			min: time.Hour,
+			max: time.Hour,
+		},
+		{
+			name: "earliest next refresh",
+			fields: fields{
+				refreshPeriod:      time.Hour,
+				minRefreshInterval: time.Minute,
+				nextRefresh:        []time.Duration{30 * time.Minute, 10 * time.Minute, 2 * time.Hour},
+			},
+			min: 9 * time.Minute,
+			max: 10 * time.Minute,
+		},
+		{
+			name: "not earlier than min refresh interval",
+			fields: fields{
+				refreshPeriod:      time.Hour,
+				minRefreshInterval: time.Minute,
+				nextRefresh:        []time.Duration{-time.Minute},
+			},
+			min: time.Minute,
+			max: time.Minute,
+		},
+		{
+			name: "refresh period if overdue without min refresh interval",
+			fields: fields{
+				refreshPeriod: time.Hour,
+				nextRefresh:   []time.Duration{-time.Minute},
+			},
+			min: time.Hour,
+			max: time.Hour,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j := &jwkd{
+				refreshPeriod:      tt.fields.refreshPeriod,
+				minRefreshInterval: tt.fields.minRefreshInterval,
+			}
+			for i, nr := range tt.fields.nextRefresh {
+				j.meta.Store(string(rune('a'+i)), &fetchMeta{nextRefresh: fastime.Now().Add(nr)})
+			}
+			if got := j.nextUpdateDelay(); got < tt.min || got > tt.max {
+				t.Errorf("jwkd.nextUpdateDelay() = %v, want between %v and %v", got, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func Test_jwkd_storeMeta(t *testing.T) {
+	tests := []struct {
+		name   string
+		maxAge time.Duration
+		want   time.Duration
+	}{
+		{
+			name:   "max-age",
+			maxAge: time.Hour,
+			want:   time.Hour,
+		},
+		{
+			name:   "max-age capped by refresh period",
+			maxAge: 365 * 24 * time.Hour,
+			want:   24 * time.Hour,
+		},
+		{
+			name:   "max-age not shorter than min refresh interval",
+			maxAge: 0,
+			want:   time.Minute,
+		},
+		{
+			name:   "no max-age",
+			maxAge: -1,
+			want:   24 * time.Hour,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j := &jwkd{
+				refreshPeriod:      24 * time.Hour,
+				minRefreshInterval: time.Minute,
+			}
+			fetched := fastime.Now()
+			j.storeMeta("dummy", &fetchMeta{fetched: fetched, maxAge: tt.maxAge})
+			if got := j.loadMeta("dummy").nextRefresh.Sub(fetched); got != tt.want {
+				t.Errorf("jwkd.storeMeta() next refresh after %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseMaxAge(t *testing.T) {
+	type args struct {
+		cacheControl string
+		age          string
+	}
+	tests := []struct {
+		name string
+		args args
+		want time.Duration
+	}{
+		{
+			name: "max-age",
+			args: args{
+				cacheControl: "public, max-age=3600, must-revalidate",
+			},
+			want: time.Hour,
+		},
+		{
+			name: "max-age minus age",
+			args: args{
+				cacheControl: "max-age=3600",
+				age:          "600",
+			},
+			want: 50 * time.Minute,
+		},
+		{
+			name: "age over max-age",
+			args: args{
+				cacheControl: "max-age=60",
+				age:          "600",
+			},
+			want: 0,
+		},
+		{
+			name: "no max-age",
+			args: args{
+				cacheControl: "no-cache",
+			},
+			want: -1,
+		},
+		{
+			name: "invalid max-age",
+			args: args{
+				cacheControl: "max-age=abc",
+			},
+			want: -1,
+		},
+		{
+			name: "no Cache-Control",
+			want: -1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := http.Header{}
+			if tt.args.cacheControl != "" {
+				h.Set("Cache-Control", tt.args.cacheControl)
+			}
+			if tt.args.age != "" {
+				h.Set("Age", tt.args.age)
+			}
+			if got := parseMaxAge(h); got != tt.want {
+				t.Errorf("parseMaxAge() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -19,12 +19,16 @@ package jwk
 import (
 	"context"
 	"net/http"
+	"net/url"
 	"sync"
 	"time"
 
+	"github.com/kpango/fastime"
 	"github.com/kpango/glg"
 	"github.com/lestrrat-go/jwx/jwk"
 	"github.com/pkg/errors"
+	"github.com/yahoojapan/athenz-authorizer/v5/internal/supervisor"
+	"golang.org/x/sync/singleflight"
 )
 
 // Daemon represents the daemon to retrieve jwk from Athenz.
@@ -32,27 +36,78 @@ type Daemon interface {
 	Start(ctx context.Context) <-chan error
 	Update(context.Context) error
 	GetProvider() Provider
+	GetAlgorithmProvider() AlgorithmProvider
+	GetRefresher() Refresher
+	GetKeyWithRefresh(ctx context.Context, keyID string, jwkSetURL string) (interface{}, error)
 }
 
 type jwkd struct {
 	athenzJwksURL string
 	urls          []string
+	// The JWK Set URLs (jku) fetched lazily on the first lookup, in addition to the urls.
+	urlPatterns []*urlPattern
+	// The maximum number of the JWK Sets fetched by the urlPatterns.
+	maxDynamicURLs int
+	// The minimum interval between the fetches of the new JWK Set URLs by the urlPatterns, across all the URLs.
+	dynamicFetchInterval time.Duration
+	// The maximum number of the JWK Set URLs fetched concurrently by Update.
+	fetchConcurrency int
 
 	refreshPeriod time.Duration
 	retryDelay    time.Duration
+	// The minimum interval between the on-demand refreshes of the same JWK Set URL.
+	minRefreshInterval time.Duration
+	// Refresh the JWK Set asynchronously when the key ID is not found by the Provider.
+	asyncRefreshOnMiss bool
 
 	client *http.Client
 
 	keys *sync.Map
+	// The fetch metadata (ETag, Last-Modified, max-age) of each JWK Set URL.
+	meta sync.Map
+	// The last on-demand refresh time of each JWK Set URL.
+	lastRefreshed *sync.Map
+	group         singleflight.Group
+
+	// The last used time of the JWK Sets fetched by the urlPatterns, guarded by dynamicMu.
+	dynamicURLs map[string]time.Time
+	// The last fetch time of the new JWK Set URL by the urlPatterns, guarded by dynamicMu.
+	lastDynamicFetch time.Time
+	dynamicMu        sync.Mutex
 }
 
 // Provider represent the jwk provider to retrieve the json web key.
 type Provider func(keyID string, jwkSetURL string) interface{}
 
+// AlgorithmProvider represent the provider to retrieve the algorithm ("alg") declared in the json web key.
+// It returns an empty string if the key is not found or the key does not declare the algorithm.
+type AlgorithmProvider func(keyID string, jwkSetURL string) string
+
+// Refresher represent the function to refresh the JWK Set of the JWK Set URL on demand, e.g. when the key ID is not found after the key rotation.
+// The empty jwkSetURL means the Athenz JWK Set URL.
+type Refresher func(ctx context.Context, jwkSetURL string) error
+
+// Wait refreshes the JWK Set of the jwkSetURL and waits for the refresh until ctx is done.
+// The refresh is not canceled by ctx, since it may be shared with the other callers.
+func (r Refresher) Wait(ctx context.Context, jwkSetURL string) error {
+	ech := make(chan error, 1)
+	go func() {
+		ech <- r(context.Background(), jwkSetURL)
+	}()
+	select {
+	case <-ctx.Done():
+		return errors.Wrap(ctx.Err(), "error waiting for JWK Set refresh")
+	case err := <-ech:
+		return err
+	}
+}
+
 // New represent the constructor of Policyd
 func New(opts ...Option) (Daemon, error) {
 	j := &jwkd{
-		keys: &sync.Map{},
+		keys:          &sync.Map{},
+		lastRefreshed: &sync.Map{},
+		dynamicURLs:   make(map[string]time.Time),
 	}
 	for _, opt := range append(defaultOptions, opts...) {
 		err := opt(j)
@@ -65,58 +120,20 @@ func New(opts ...Option) (Daemon, error) {
 }
 
 func (j *jwkd) Start(ctx context.Context) <-chan error {
-	glg.Info("Starting jwk updater")
-	ech := make(chan error, 100)
-	fch := make(chan struct{}, 1)
-
-	go func() {
-		defer close(fch)
-		defer close(ech)
-		ticker := time.NewTicker(j.refreshPeriod)
-		ebuf := errors.New("")
-
-		update := func() {
-			if err := j.Update(ctx); err != nil {
-				err = errors.Wrap(err, "error update athenz json web key")
-				time.Sleep(j.retryDelay)
-
-				select {
-				case ech <- errors.Wrap(ebuf, err.Error()):
-					ebuf = errors.New("")
-				default:
-					ebuf = errors.Wrap(ebuf, err.Error())
-				}
-
-				select {
-				case fch <- struct{}{}:
-				default:
-					glg.Warn("failure queue already full")
-				}
-			}
-		}
-
-		for {
-			select {
-			case <-ctx.Done():
-				glg.Info("Stopping jwkd")
-				ticker.Stop()
-				if ebuf.Error() != "" {
-					ech <- errors.Wrap(ctx.Err(), ebuf.Error())
-				} else {
-					ech <- ctx.Err()
-				}
-				return
-			case <-fch:
-				update()
-			case <-ticker.C:
-				update()
-			}
+	// the next update follows the earliest refresh time of the JWK Sets, and the failed URLs are retried by their own backoff, see storeFailure
+	return supervisor.Run(ctx, supervisor.Config{
+		Name:     "jwk",
+		Interval: j.nextUpdateDelay,
+	}, func(ctx context.Context) error {
+		if err := j.Update(ctx); err != nil {
+			return errors.Wrap(err, "error update athenz json web key")
 		}
-	}()
-
-	return ech
+		return nil
+	})
 }
 
+// Update fetches the JWK Sets due for refresh concurrently. Each JWK Set URL has its own refresh schedule and backoff,
+// so that the failure of a URL does not cause refetching the others.
 func (j *jwkd) Update(ctx context.Context) (err error) {
 	glg.Info("Fetching JWK Set")
 
@@ -127,19 +144,60 @@ func (j *jwkd) Update(ctx context.Context) (err error) {
 		targets = j.urls
 	}
 
+	now := fastime.Now()
+	// the dynamic JWK Sets are refreshed only while in use, the failures are not reported since the URLs come from the tokens
+	dynamicTargets := j.sweepDynamicURLs(now.Add(-j.refreshPeriod))
+	errs := make([]error, len(targets)+len(dynamicTargets))
+
+	all := make([]string, 0, len(errs))
+	all = append(append(all, targets...), dynamicTargets...)
+	concurrency := j.fetchConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, target := range all {
+		if j.isFresh(target, now) {
+			glg.Debugf("JWK Set of %s is fresh, skip fetching", target)
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, target string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if i < len(targets) {
+				errs[i] = j.fetch(ctx, target)
+			} else {
+				errs[i] = j.fetchDynamic(ctx, target, false)
+			}
+		}(i, target)
+	}
+	wg.Wait()
+
 	var failedTargets []string
-	for _, target := range targets {
-		glg.Debugf("Fetching JWK Set from %s", target)
-		keys, err := jwk.FetchHTTP(target, jwk.WithHTTPClient(j.client))
-		if err != nil {
-			glg.Errorf("Fetch JWK Set error: %v", err)
-			failedTargets = append(failedTargets, target)
+	for i, err := range errs {
+		if err == nil {
 			continue
 		}
-		j.keys.Store(target, keys)
-		glg.Debugf("Fetch JWK Set from %s success", target)
+		if i < len(targets) {
+			glg.Errorf("Fetch JWK Set error: %v", err)
+			failedTargets = append(failedTargets, targets[i])
+		} else {
+			glg.Warnf("Fetch dynamic JWK Set error: %v", err)
+		}
 	}
 
+	j.lastRefreshed.Range(func(k, v interface{}) bool {
+		if fastime.Now().Sub(v.(time.Time)) >= j.minRefreshInterval {
+			j.lastRefreshed.Delete(k)
+		}
+		return true
+	})
+
 	if len(failedTargets) > 0 {
 		return errors.Errorf("Failed to fetch the JWK Set from these URLs: %s", failedTargets)
 	}
@@ -148,29 +206,215 @@ func (j *jwkd) Update(ctx context.Context) (err error) {
 	return nil
 }
 
+func (j *jwkd) fetch(ctx context.Context, target string) error {
+	glg.Debugf("Fetching JWK Set from %s", target)
+	keys, err := j.fetchSet(ctx, target)
+	if err != nil {
+		return err
+	}
+	j.keys.Store(target, keys)
+	glg.Debugf("Fetch JWK Set from %s success", target)
+	return nil
+}
+
+// fetchDynamic fetches the JWK Set of the URL allowed by the urlPatterns. If add is false, the JWK Set is stored only if it is not evicted yet.
+// The least recently used JWK Set is evicted if the number of the dynamic JWK Sets exceeds maxDynamicURLs.
+func (j *jwkd) fetchDynamic(ctx context.Context, target string, add bool) error {
+	glg.Debugf("Fetching dynamic JWK Set from %s", target)
+	keys, err := j.fetchSet(ctx, target)
+
+	j.dynamicMu.Lock()
+	defer j.dynamicMu.Unlock()
+	if err != nil {
+		// the failure of the URL not added yet is not kept, it is never swept and would shorten the next update delay forever
+		if _, ok := j.dynamicURLs[target]; !ok {
+			j.meta.Delete(target)
+		}
+		return err
+	}
+	if _, ok := j.dynamicURLs[target]; !ok {
+		if !add {
+			j.meta.Delete(target)
+			return nil
+		}
+		if len(j.dynamicURLs) >= j.maxDynamicURLs {
+			j.evictDynamicURL()
+		}
+		j.dynamicURLs[target] = fastime.Now()
+	}
+	j.keys.Store(target, keys)
+	glg.Debugf("Fetch dynamic JWK Set from %s success", target)
+	return nil
+}
+
+// evictDynamicURL removes the least recently used dynamic JWK Set. It must be called with dynamicMu held.
+func (j *jwkd) evictDynamicURL() {
+	var lru string
+	var lruTime time.Time
+	for u, t := range j.dynamicURLs {
+		if lru == "" || t.Before(lruTime) {
+			lru, lruTime = u, t
+		}
+	}
+	if lru == "" {
+		return
+	}
+	glg.Debugf("Evicting dynamic JWK Set of %s", lru)
+	delete(j.dynamicURLs, lru)
+	j.keys.Delete(lru)
+	j.meta.Delete(lru)
+}
+
+// sweepDynamicURLs removes the dynamic JWK Sets not used since the deadline, and returns the URLs of the remaining ones.
+func (j *jwkd) sweepDynamicURLs(deadline time.Time) []string {
+	j.dynamicMu.Lock()
+	defer j.dynamicMu.Unlock()
+	targets := make([]string, 0, len(j.dynamicURLs))
+	for u, t := range j.dynamicURLs {
+		if t.Before(deadline) {
+			glg.Debugf("Evicting unused dynamic JWK Set of %s", u)
+			delete(j.dynamicURLs, u)
+			j.keys.Delete(u)
+			j.meta.Delete(u)
+			continue
+		}
+		targets = append(targets, u)
+	}
+	return targets
+}
+
+// touchDynamicURL updates the last used time of the dynamic JWK Set.
+func (j *jwkd) touchDynamicURL(target string) {
+	j.dynamicMu.Lock()
+	if _, ok := j.dynamicURLs[target]; ok {
+		j.dynamicURLs[target] = fastime.Now()
+	}
+	j.dynamicMu.Unlock()
+}
+
+// reserveDynamicFetch returns true if the JWK Set URL can be fetched by the urlPatterns.
+// The new URLs are fetched at most once per dynamicFetchInterval in total, so that the tokens with the various jku cannot trigger unlimited fetches and evict the JWK Sets in use.
+func (j *jwkd) reserveDynamicFetch(target string, now time.Time) bool {
+	j.dynamicMu.Lock()
+	defer j.dynamicMu.Unlock()
+	if _, ok := j.dynamicURLs[target]; ok {
+		return true
+	}
+	if !j.lastDynamicFetch.IsZero() && now.Sub(j.lastDynamicFetch) < j.dynamicFetchInterval {
+		return false
+	}
+	j.lastDynamicFetch = now
+	return true
+}
+
+// isStaticURL returns true if the JWK Set URL is the Athenz JWK Set URL or one of the urls.
+func (j *jwkd) isStaticURL(target string) bool {
+	return target == j.athenzJwksURL || isContain(j.urls, target)
+}
+
+// isAllowedURL returns true if the JWK Set URL matches any of the urlPatterns.
+func (j *jwkd) isAllowedURL(target string) bool {
+	if len(j.urlPatterns) == 0 || j.maxDynamicURLs <= 0 {
+		return false
+	}
+	u, err := url.Parse(target)
+	// the non-canonical URL, e.g. with the empty query "?" or fragment "#", is a distinct URL of the same JWK Set
+	if err != nil || u.String() != target {
+		return false
+	}
+	for _, p := range j.urlPatterns {
+		if p.match(u) {
+			return true
+		}
+	}
+	return false
+}
+
 func (j *jwkd) GetProvider() Provider {
 	return j.getKey
 }
 
+func (j *jwkd) GetAlgorithmProvider() AlgorithmProvider {
+	return j.getAlgorithm
+}
+
+func (j *jwkd) GetRefresher() Refresher {
+	return j.refresh
+}
+
+// refresh fetches the JWK Set of the jwkSetURL. The concurrent refreshes of the same URL are deduplicated,
+// and the URL is not fetched again within minRefreshInterval.
+func (j *jwkd) refresh(ctx context.Context, jwkSetURL string) error {
+	target := jwkSetURL
+	if target == "" {
+		target = j.athenzJwksURL
+	}
+	// only the configured URLs are fetched, the jku in the token should not trigger a request to arbitrary URL
+	static := j.isStaticURL(target)
+	if !static && !j.isAllowedURL(target) {
+		return errors.Wrap(ErrUnknownJWKSetURL, target)
+	}
+
+	_, err, _ := j.group.Do(target, func() (interface{}, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		now := fastime.Now()
+		if last, ok := j.lastRefreshed.Load(target); ok && now.Sub(last.(time.Time)) < j.minRefreshInterval {
+			return nil, errors.Wrap(ErrRefreshRateLimited, target)
+		}
+		if !static && !j.reserveDynamicFetch(target, now) {
+			return nil, errors.Wrapf(ErrRefreshRateLimited, "new JWK Set URL fetched recently, jku: %s", target)
+		}
+		j.lastRefreshed.Store(target, now)
+		if !static {
+			return nil, j.fetchDynamic(ctx, target, true)
+		}
+		return nil, j.fetch(ctx, target)
+	})
+	return err
+}
+
 func (j *jwkd) getKey(keyID string, jwkSetURL string) interface{} {
+	if key := j.lookupRawKey(keyID, jwkSetURL); key != nil {
+		return key
+	}
+	if j.asyncRefreshOnMiss && keyID != "" {
+		// the refresh is rate-limited and deduplicated, the lookup does not wait for it
+		go func() {
+			if err := j.refresh(context.Background(), jwkSetURL); err != nil {
+				glg.Debugf("error refresh JWK Set on unknown key ID, kid: %s, jku: %s, err: %v", keyID, jwkSetURL, err)
+			}
+		}()
+	}
+	return nil
+}
+
+// GetKeyWithRefresh returns the key of the key ID. If the key ID is not found, it refreshes the JWK Set and waits for the refresh until ctx is done.
+func (j *jwkd) GetKeyWithRefresh(ctx context.Context, keyID string, jwkSetURL string) (interface{}, error) {
+	if key := j.lookupRawKey(keyID, jwkSetURL); key != nil {
+		return key, nil
+	}
 	if keyID == "" {
-		return nil
+		return nil, errors.Wrap(ErrKeyNotFound, "empty key ID")
 	}
 
-	var keys interface{}
-	var ok bool
-	if jwkSetURL == "" {
-		keys, ok = j.keys.Load(j.athenzJwksURL)
-	} else {
-		keys, ok = j.keys.Load(jwkSetURL)
+	if err := Refresher(j.refresh).Wait(ctx, jwkSetURL); err != nil {
+		// the JWK Set was refreshed recently, the key may be found in the current one
+		if errors.Cause(err) != ErrRefreshRateLimited {
+			return nil, errors.Wrap(err, "error refresh JWK Set")
+		}
 	}
 
-	// Either jku specified in the token is not set in jwkd.urls or key cache is failing.
-	if !ok {
-		return nil
+	if key := j.lookupRawKey(keyID, jwkSetURL); key != nil {
+		return key, nil
 	}
+	return nil, errors.Wrapf(ErrKeyNotFound, "kid: %s", keyID)
+}
 
-	for _, key := range keys.(*jwk.Set).LookupKeyID(keyID) {
+// lookupRawKey returns the first valid key of the key ID, or nil if not found.
+func (j *jwkd) lookupRawKey(keyID string, jwkSetURL string) interface{} {
+	for _, key := range j.lookupKeys(keyID, jwkSetURL) {
 		var raw interface{}
 		if err := key.Raw(&raw); err != nil {
 			glg.Warnf("jwkd.getKey: %s", err.Error())
@@ -182,6 +426,41 @@ func (j *jwkd) getKey(keyID string, jwkSetURL string) interface{} {
 	return nil
 }
 
+func (j *jwkd) getAlgorithm(keyID string, jwkSetURL string) string {
+	for _, key := range j.lookupKeys(keyID, jwkSetURL) {
+		var raw interface{}
+		// same key as getKey() returns
+		if err := key.Raw(&raw); err == nil {
+			return key.Algorithm()
+		}
+	}
+	return ""
+}
+
+func (j *jwkd) lookupKeys(keyID string, jwkSetURL string) []jwk.Key {
+	if keyID == "" {
+		return nil
+	}
+
+	var keys interface{}
+	var ok bool
+	if jwkSetURL == "" {
+		keys, ok = j.keys.Load(j.athenzJwksURL)
+	} else {
+		keys, ok = j.keys.Load(jwkSetURL)
+	}
+
+	// Either jku specified in the token is not set in jwkd.urls, not fetched yet by the urlPatterns, or key cache is failing.
+	if !ok {
+		return nil
+	}
+	if jwkSetURL != "" && len(j.urlPatterns) != 0 && !j.isStaticURL(jwkSetURL) {
+		j.touchDynamicURL(jwkSetURL)
+	}
+
+	return keys.(*jwk.Set).LookupKeyID(keyID)
+}
+
 func isContain(targets []string, key string) bool {
 	for _, target := range targets {
 		if target == key {
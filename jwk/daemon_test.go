@@ -26,9 +26,11 @@ import (
 	"net/http/httptest"
 	"reflect"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/kpango/fastime"
 	"github.com/lestrrat-go/jwx/jwa"
 	"github.com/lestrrat-go/jwx/jwk"
 	"github.com/pkg/errors"
@@ -52,11 +54,18 @@ func TestNew(t *testing.T) {
 				},
 			},
 			want: &jwkd{
-				athenzJwksURL: "https://www.dummy.com/oauth2/keys",
-				refreshPeriod: time.Hour * 24,
-				retryDelay:    time.Minute,
-				client:        http.DefaultClient,
-				keys:          &sync.Map{},
+				athenzJwksURL:        "https://www.dummy.com/oauth2/keys",
+				refreshPeriod:        time.Hour * 24,
+				retryDelay:           time.Minute,
+				minRefreshInterval:   time.Minute,
+				asyncRefreshOnMiss:   true,
+				maxDynamicURLs:       100,
+				dynamicFetchInterval: time.Second,
+				fetchConcurrency:     4,
+				client:               http.DefaultClient,
+				keys:                 &sync.Map{},
+				lastRefreshed:        &sync.Map{},
+				dynamicURLs:          map[string]time.Time{},
 			},
 		},
 		{
@@ -301,6 +310,7 @@ func Test_jwkd_Start(t *testing.T) {
 				retryDelay:    tt.fields.retryDelay,
 				client:        tt.fields.client,
 				keys:          tt.fields.keys,
+				lastRefreshed: &sync.Map{},
 			}
 			got := j.Start(tt.args.ctx)
 			if tt.checkFunc != nil {
@@ -714,6 +724,7 @@ func Test_jwkd_Update(t *testing.T) {
 				retryDelay:    tt.fields.retryDelay,
 				client:        tt.fields.client,
 				keys:          tt.fields.keys,
+				lastRefreshed: &sync.Map{},
 			}
 			err := j.Update(tt.args.ctx)
 			if tt.wantErr {
@@ -770,6 +781,723 @@ func Test_jwkd_GetProvider(t *testing.T) {
 	}
 }
 
+func Test_jwkd_GetRefresher(t *testing.T) {
+	tests := []struct {
+		name      string
+		checkFunc func(Refresher) error
+	}{
+		{
+			name: "get success",
+			checkFunc: func(r Refresher) error {
+				if r == nil {
+					return errors.New("GetRefresher return nil")
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j := &jwkd{}
+			got := j.GetRefresher()
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("jwkd.GetRefresher() err %v", err)
+			}
+		})
+	}
+}
+
+func Test_jwkd_refresh(t *testing.T) {
+	type fields struct {
+		athenzJwksURL      string
+		urls               []string
+		minRefreshInterval time.Duration
+		client             *http.Client
+		keys               *sync.Map
+		lastRefreshed      *sync.Map
+	}
+	type args struct {
+		ctx       context.Context
+		jwkSetURL string
+	}
+	type test struct {
+		name      string
+		fields    fields
+		args      args
+		checkFunc func(*jwkd) error
+		wantErr   error
+	}
+	k := `{
+"e":"AQAB",
+"kty":"RSA",
+"kid":"0",
+"n":"0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw"
+}`
+	newServer := func() *httptest.Server {
+		return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write([]byte(k))
+		}))
+	}
+	tests := []test{
+		func() test {
+			srv := newServer()
+			return test{
+				name: "refresh athenz JWK Set success",
+				fields: fields{
+					athenzJwksURL: srv.URL,
+					client:        srv.Client(),
+					keys:          &sync.Map{},
+					lastRefreshed: &sync.Map{},
+				},
+				args: args{
+					ctx:       context.Background(),
+					jwkSetURL: "",
+				},
+				checkFunc: func(j *jwkd) error {
+					if j.getKey("0", "") == nil {
+						return errors.New("key not refreshed")
+					}
+					if _, ok := j.lastRefreshed.Load(srv.URL); !ok {
+						return errors.New("last refreshed time not stored")
+					}
+					return nil
+				},
+			}
+		}(),
+		func() test {
+			srv := newServer()
+			return test{
+				name: "refresh JWK Set of urls success",
+				fields: fields{
+					athenzJwksURL: "https://dummy.athenz.io/oauth2/keys",
+					urls:          []string{srv.URL + "/jwks"},
+					client:        srv.Client(),
+					keys:          &sync.Map{},
+					lastRefreshed: &sync.Map{},
+				},
+				args: args{
+					ctx:       context.Background(),
+					jwkSetURL: srv.URL + "/jwks",
+				},
+				checkFunc: func(j *jwkd) error {
+					if j.getKey("0", srv.URL+"/jwks") == nil {
+						return errors.New("key not refreshed")
+					}
+					return nil
+				},
+			}
+		}(),
+		{
+			name: "refresh fail, unknown JWK Set URL",
+			fields: fields{
+				athenzJwksURL: "https://dummy.athenz.io/oauth2/keys",
+				urls:          []string{"https://dummy.example.com/jwks"},
+				keys:          &sync.Map{},
+				lastRefreshed: &sync.Map{},
+			},
+			args: args{
+				ctx:       context.Background(),
+				jwkSetURL: "https://attacker.example.com/jwks",
+			},
+			wantErr: ErrUnknownJWKSetURL,
+		},
+		func() test {
+			srv := newServer()
+			lastRefreshed := &sync.Map{}
+			lastRefreshed.Store(srv.URL, fastime.Now())
+			return test{
+				name: "refresh fail, rate limited",
+				fields: fields{
+					athenzJwksURL:      srv.URL,
+					minRefreshInterval: time.Hour,
+					client:             srv.Client(),
+					keys:               &sync.Map{},
+					lastRefreshed:      lastRefreshed,
+				},
+				args: args{
+					ctx: context.Background(),
+				},
+				checkFunc: func(j *jwkd) error {
+					if j.getKey("0", "") != nil {
+						return errors.New("key refreshed within min refresh interval")
+					}
+					return nil
+				},
+				wantErr: ErrRefreshRateLimited,
+			}
+		}(),
+		func() test {
+			srv := newServer()
+			lastRefreshed := &sync.Map{}
+			lastRefreshed.Store(srv.URL, fastime.Now().Add(-2*time.Hour))
+			return test{
+				name: "refresh success, min refresh interval passed",
+				fields: fields{
+					athenzJwksURL:      srv.URL,
+					minRefreshInterval: time.Hour,
+					client:             srv.Client(),
+					keys:               &sync.Map{},
+					lastRefreshed:      lastRefreshed,
+				},
+				args: args{
+					ctx: context.Background(),
+				},
+				checkFunc: func(j *jwkd) error {
+					if j.getKey("0", "") == nil {
+						return errors.New("key not refreshed")
+					}
+					return nil
+				},
+			}
+		}(),
+		func() test {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			return test{
+				name: "refresh fail, context canceled",
+				fields: fields{
+					athenzJwksURL: "https://dummy.athenz.io/oauth2/keys",
+					keys:          &sync.Map{},
+					lastRefreshed: &sync.Map{},
+				},
+				args: args{
+					ctx: ctx,
+				},
+				wantErr: context.Canceled,
+			}
+		}(),
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j := &jwkd{
+				athenzJwksURL:      tt.fields.athenzJwksURL,
+				urls:               tt.fields.urls,
+				minRefreshInterval: tt.fields.minRefreshInterval,
+				client:             tt.fields.client,
+				keys:               tt.fields.keys,
+				lastRefreshed:      tt.fields.lastRefreshed,
+			}
+			err := j.refresh(tt.args.ctx, tt.args.jwkSetURL)
+			if errors.Cause(err) != tt.wantErr {
+				t.Errorf("jwkd.refresh() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.checkFunc != nil {
+				if err := tt.checkFunc(j); err != nil {
+					t.Errorf("jwkd.refresh() error = %v", err)
+				}
+			}
+		})
+	}
+}
+
+func Test_jwkd_refresh_concurrent(t *testing.T) {
+	var cnt int32
+	k := `{"keys":[{"e":"AQAB","kty":"RSA","kid":"0","n":"0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw"}]}`
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&cnt, 1)
+		time.Sleep(100 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(k))
+	}))
+	defer srv.Close()
+
+	j := &jwkd{
+		athenzJwksURL:      srv.URL,
+		minRefreshInterval: time.Hour,
+		client:             srv.Client(),
+		keys:               &sync.Map{},
+		lastRefreshed:      &sync.Map{},
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = j.refresh(context.Background(), "")
+		}()
+	}
+	wg.Wait()
+	if got := atomic.LoadInt32(&cnt); got != 1 {
+		t.Errorf("jwkd.refresh() fetched %d times, want 1", got)
+	}
+	if j.getKey("0", "") == nil {
+		t.Errorf("jwkd.refresh() key not refreshed")
+	}
+}
+
+func Test_jwkd_refresh_urlPatterns(t *testing.T) {
+	var cnt int32
+	k := `{"keys":[{"e":"AQAB","kty":"RSA","kid":"0","n":"0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw"}]}`
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&cnt, 1)
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(k))
+	}))
+	defer srv.Close()
+
+	p, err := parseURLPattern(srv.URL + "/regions/")
+	if err != nil {
+		t.Fatalf("parseURLPattern() error = %v", err)
+	}
+	j := &jwkd{
+		athenzJwksURL:      "https://dummy.athenz.io/oauth2/keys",
+		urlPatterns:        []*urlPattern{p},
+		maxDynamicURLs:     2,
+		minRefreshInterval: time.Hour,
+		client:             srv.Client(),
+		keys:               &sync.Map{},
+		lastRefreshed:      &sync.Map{},
+		dynamicURLs:        map[string]time.Time{},
+	}
+	east, west, north := srv.URL+"/regions/east/jwks", srv.URL+"/regions/west/jwks", srv.URL+"/regions/north/jwks"
+
+	// not allowed by the patterns
+	for _, u := range []string{srv.URL + "/other/jwks", srv.URL + "/regions/../other/jwks", "https://attacker.example.com/regions/jwks", srv.URL + "/regions/east/jwks?x=1", srv.URL + "/regions/east/jwks?", srv.URL + "/regions/east/jwks#"} {
+		if err := j.refresh(context.Background(), u); errors.Cause(err) != ErrUnknownJWKSetURL {
+			t.Errorf("jwkd.refresh() error = %v, want %v, jku: %s", err, ErrUnknownJWKSetURL, u)
+		}
+	}
+	if got := atomic.LoadInt32(&cnt); got != 0 {
+		t.Errorf("jwkd.refresh() fetched %d times, want 0", got)
+	}
+
+	// fetched lazily and cached
+	if j.getKey("0", east) != nil {
+		t.Errorf("jwkd.getKey() key found before refresh")
+	}
+	if err := j.refresh(context.Background(), east); err != nil {
+		t.Fatalf("jwkd.refresh() error = %v", err)
+	}
+	if j.getKey("0", east) == nil {
+		t.Errorf("jwkd.getKey() key not refreshed")
+	}
+	if err := j.refresh(context.Background(), east); errors.Cause(err) != ErrRefreshRateLimited {
+		t.Errorf("jwkd.refresh() error = %v, want %v", err, ErrRefreshRateLimited)
+	}
+
+	// the least recently used one is evicted
+	if err := j.refresh(context.Background(), west); err != nil {
+		t.Fatalf("jwkd.refresh() error = %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	_ = j.getKey("0", east)
+	if err := j.refresh(context.Background(), north); err != nil {
+		t.Fatalf("jwkd.refresh() error = %v", err)
+	}
+	if j.getKey("0", west) != nil {
+		t.Errorf("jwkd.getKey() least recently used JWK Set not evicted")
+	}
+	if j.getKey("0", east) == nil || j.getKey("0", north) == nil {
+		t.Errorf("jwkd.getKey() recently used JWK Set evicted")
+	}
+	if got := len(j.dynamicURLs); got != 2 {
+		t.Errorf("jwkd.dynamicURLs has %d entries, want 2", got)
+	}
+}
+
+func Test_jwkd_refresh_dynamicFetchInterval(t *testing.T) {
+	var cnt int32
+	k := `{"keys":[{"e":"AQAB","kty":"RSA","kid":"0","n":"0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw"}]}`
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&cnt, 1)
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(k))
+	}))
+	defer srv.Close()
+
+	p, err := parseURLPattern(srv.URL + "/regions/")
+	if err != nil {
+		t.Fatalf("parseURLPattern() error = %v", err)
+	}
+	j := &jwkd{
+		athenzJwksURL:        "https://dummy.athenz.io/oauth2/keys",
+		urlPatterns:          []*urlPattern{p},
+		maxDynamicURLs:       10,
+		dynamicFetchInterval: time.Hour,
+		client:               srv.Client(),
+		keys:                 &sync.Map{},
+		lastRefreshed:        &sync.Map{},
+		dynamicURLs:          map[string]time.Time{},
+	}
+	east, west := srv.URL+"/regions/east/jwks", srv.URL+"/regions/west/jwks"
+
+	if err := j.refresh(context.Background(), east); err != nil {
+		t.Fatalf("jwkd.refresh() error = %v", err)
+	}
+	// the other new URL is not fetched within the interval
+	if err := j.refresh(context.Background(), west); errors.Cause(err) != ErrRefreshRateLimited {
+		t.Errorf("jwkd.refresh() error = %v, want %v", err, ErrRefreshRateLimited)
+	}
+	if j.getKey("0", west) != nil {
+		t.Errorf("jwkd.getKey() rate limited JWK Set refreshed")
+	}
+	// the known URL is still refreshed
+	if err := j.refresh(context.Background(), east); err != nil {
+		t.Errorf("jwkd.refresh() error = %v", err)
+	}
+	if got := atomic.LoadInt32(&cnt); got != 2 {
+		t.Errorf("jwkd.refresh() fetched %d times, want 2", got)
+	}
+}
+
+func Test_jwkd_refresh_dynamicURLFailure(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	p, err := parseURLPattern(srv.URL + "/regions/")
+	if err != nil {
+		t.Fatalf("parseURLPattern() error = %v", err)
+	}
+	j := &jwkd{
+		athenzJwksURL:      "https://dummy.athenz.io/oauth2/keys",
+		urlPatterns:        []*urlPattern{p},
+		maxDynamicURLs:     10,
+		refreshPeriod:      24 * time.Hour,
+		retryDelay:         time.Minute,
+		minRefreshInterval: time.Minute,
+		client:             srv.Client(),
+		keys:               &sync.Map{},
+		lastRefreshed:      &sync.Map{},
+		dynamicURLs:        map[string]time.Time{},
+	}
+	target := srv.URL + "/regions/east/jwks"
+	if err := j.refresh(context.Background(), target); err == nil {
+		t.Fatalf("jwkd.refresh() error = nil")
+	}
+	if j.loadMeta(target) != nil {
+		t.Errorf("jwkd.refresh() failure of the dynamic JWK Set URL not added is kept")
+	}
+	if got := j.nextUpdateDelay(); got != j.refreshPeriod {
+		t.Errorf("jwkd.nextUpdateDelay() = %v, want %v", got, j.refreshPeriod)
+	}
+}
+
+func Test_jwkd_Update_dynamicURLs(t *testing.T) {
+	k := `{"keys":[{"e":"AQAB","kty":"RSA","kid":"0","n":"0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw"}]}`
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(k))
+	}))
+	defer srv.Close()
+
+	p, err := parseURLPattern(srv.URL + "/regions/")
+	if err != nil {
+		t.Fatalf("parseURLPattern() error = %v", err)
+	}
+	used, unused := srv.URL+"/regions/used/jwks", srv.URL+"/regions/unused/jwks"
+	keys := &sync.Map{}
+	keys.Store(used, &jwk.Set{})
+	keys.Store(unused, &jwk.Set{})
+	lastRefreshed := &sync.Map{}
+	lastRefreshed.Store(unused, fastime.Now().Add(-2*time.Hour))
+	j := &jwkd{
+		athenzJwksURL:      srv.URL,
+		urlPatterns:        []*urlPattern{p},
+		maxDynamicURLs:     10,
+		refreshPeriod:      time.Hour,
+		minRefreshInterval: time.Minute,
+		client:             srv.Client(),
+		keys:               keys,
+		lastRefreshed:      lastRefreshed,
+		dynamicURLs: map[string]time.Time{
+			used:   fastime.Now(),
+			unused: fastime.Now().Add(-2 * time.Hour),
+		},
+	}
+	if err := j.Update(context.Background()); err != nil {
+		t.Fatalf("jwkd.Update() error = %v", err)
+	}
+	if j.getKey("0", used) == nil {
+		t.Errorf("jwkd.Update() used dynamic JWK Set not refreshed")
+	}
+	if _, ok := j.keys.Load(unused); ok {
+		t.Errorf("jwkd.Update() unused dynamic JWK Set not evicted")
+	}
+	if _, ok := j.dynamicURLs[unused]; ok {
+		t.Errorf("jwkd.Update() unused dynamic JWK Set URL not evicted")
+	}
+	if _, ok := j.lastRefreshed.Load(unused); ok {
+		t.Errorf("jwkd.Update() expired last refreshed time not purged")
+	}
+}
+
+func Test_jwkd_Update_failureIsolation(t *testing.T) {
+	var athenzCnt, brokenCnt, running, maxRunning int32
+	k := `{"keys":[{"e":"AQAB","kty":"RSA","kid":"0","n":"0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw"}]}`
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			m := atomic.LoadInt32(&maxRunning)
+			if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+				break
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+		if r.URL.Path == "/broken" {
+			atomic.AddInt32(&brokenCnt, 1)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if r.URL.Path == "/athenz" {
+			atomic.AddInt32(&athenzCnt, 1)
+		}
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(k))
+	}))
+	defer srv.Close()
+
+	j := &jwkd{
+		athenzJwksURL:    srv.URL + "/athenz",
+		urls:             []string{srv.URL + "/broken", srv.URL + "/1", srv.URL + "/2", srv.URL + "/3"},
+		refreshPeriod:    time.Hour,
+		retryDelay:       time.Minute,
+		fetchConcurrency: 2,
+		client:           srv.Client(),
+		keys:             &sync.Map{},
+		lastRefreshed:    &sync.Map{},
+	}
+
+	err := j.Update(context.Background())
+	want := fmt.Sprintf("Failed to fetch the JWK Set from these URLs: %s", []string{srv.URL + "/broken"})
+	if err == nil || err.Error() != want {
+		t.Fatalf("jwkd.Update() error = %v, want %v", err, want)
+	}
+	if got := atomic.LoadInt32(&maxRunning); got != 2 {
+		t.Errorf("jwkd.Update() fetched %d URLs concurrently, want 2", got)
+	}
+	for _, u := range append([]string{j.athenzJwksURL}, j.urls[1:]...) {
+		if j.getKey("0", u) == nil {
+			t.Errorf("jwkd.Update() key of %s not updated", u)
+		}
+	}
+	m := j.loadMeta(srv.URL + "/broken")
+	if m == nil || m.failures != 1 || !withinJitter(m.nextRefresh.Sub(m.fetched), time.Minute) {
+		t.Errorf("jwkd.Update() invalid backoff of the failed URL: %+v", m)
+	}
+
+	// neither the healthy URLs nor the backed off URL are fetched again
+	if err := j.Update(context.Background()); err != nil {
+		t.Errorf("jwkd.Update() error = %v", err)
+	}
+	if got := atomic.LoadInt32(&athenzCnt); got != 1 {
+		t.Errorf("jwkd.Update() fetched Athenz JWK Set %d times, want 1", got)
+	}
+	if got := atomic.LoadInt32(&brokenCnt); got != 1 {
+		t.Errorf("jwkd.Update() fetched broken JWK Set %d times, want 1", got)
+	}
+
+	// the backoff is doubled on the consecutive failure
+	m.nextRefresh = fastime.Now().Add(-time.Second)
+	if err := j.Update(context.Background()); err == nil {
+		t.Errorf("jwkd.Update() error = nil, want error")
+	}
+	if m := j.loadMeta(srv.URL + "/broken"); m.failures != 2 || !withinJitter(m.nextRefresh.Sub(m.fetched), 2*time.Minute) {
+		t.Errorf("jwkd.Update() invalid backoff of the failed URL: %+v", m)
+	}
+	if got := atomic.LoadInt32(&athenzCnt); got != 1 {
+		t.Errorf("jwkd.Update() fetched Athenz JWK Set %d times, want 1", got)
+	}
+}
+
+// withinJitter returns true if the backoff is within the default jitter of the delay.
+func withinJitter(got, want time.Duration) bool {
+	return got >= want-want/10 && got <= want+want/10
+}
+
+func Test_jwkd_getKey_asyncRefreshOnMiss(t *testing.T) {
+	var cnt int32
+	k := `{"keys":[{"e":"AQAB","kty":"RSA","kid":"0","n":"0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw"}]}`
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&cnt, 1)
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(k))
+	}))
+	defer srv.Close()
+
+	j := &jwkd{
+		athenzJwksURL:      srv.URL,
+		minRefreshInterval: time.Hour,
+		asyncRefreshOnMiss: true,
+		client:             srv.Client(),
+		keys:               &sync.Map{},
+		lastRefreshed:      &sync.Map{},
+	}
+	if j.getKey("0", "") != nil {
+		t.Fatalf("jwkd.getKey() key found before refresh")
+	}
+	deadline := time.Now().Add(time.Second)
+	for j.getKey("0", "") == nil {
+		if time.Now().After(deadline) {
+			t.Fatalf("jwkd.getKey() key not refreshed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	// the other misses are rate-limited
+	for i := 0; i < 10; i++ {
+		_ = j.getKey("1", "")
+	}
+	time.Sleep(100 * time.Millisecond)
+	if got := atomic.LoadInt32(&cnt); got != 1 {
+		t.Errorf("jwkd.getKey() fetched %d times, want 1", got)
+	}
+}
+
+func TestRefresher_Wait(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	tests := []struct {
+		name       string
+		jwkr       Refresher
+		wantErr    error
+		maxElapsed time.Duration
+	}{
+		{
+			name: "refresh done",
+			jwkr: func(ctx context.Context, jwkSetURL string) error {
+				return ErrRefreshRateLimited
+			},
+			wantErr:    ErrRefreshRateLimited,
+			maxElapsed: time.Second,
+		},
+		{
+			name: "refresh timeout",
+			jwkr: func(ctx context.Context, jwkSetURL string) error {
+				<-release
+				return nil
+			},
+			wantErr:    context.DeadlineExceeded,
+			maxElapsed: time.Second,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			start := time.Now()
+			err := tt.jwkr.Wait(ctx, "https://dummy.athenz.io/oauth2/keys")
+			if errors.Cause(err) != tt.wantErr {
+				t.Errorf("Refresher.Wait() error = %v, want %v", err, tt.wantErr)
+			}
+			if elapsed := time.Since(start); elapsed > tt.maxElapsed {
+				t.Errorf("Refresher.Wait() elapsed = %v, want less than %v", elapsed, tt.maxElapsed)
+			}
+		})
+	}
+}
+
+func Test_jwkd_GetKeyWithRefresh(t *testing.T) {
+	k := `{"keys":[{"e":"AQAB","kty":"RSA","kid":"0","n":"0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw"}]}`
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(k))
+	}))
+	defer srv.Close()
+
+	newJwkd := func() *jwkd {
+		return &jwkd{
+			athenzJwksURL:      srv.URL,
+			urls:               []string{srv.URL + "/slow"},
+			minRefreshInterval: time.Hour,
+			client:             srv.Client(),
+			keys:               &sync.Map{},
+			lastRefreshed:      &sync.Map{},
+		}
+	}
+	type args struct {
+		timeout   time.Duration
+		keyID     string
+		jwkSetURL string
+	}
+	tests := []struct {
+		name    string
+		jwkd    *jwkd
+		args    args
+		wantKey bool
+		wantErr error
+	}{
+		{
+			name: "key found after refresh",
+			jwkd: newJwkd(),
+			args: args{
+				timeout: time.Second,
+				keyID:   "0",
+			},
+			wantKey: true,
+		},
+		{
+			name: "key not found after refresh",
+			jwkd: newJwkd(),
+			args: args{
+				timeout: time.Second,
+				keyID:   "1",
+			},
+			wantErr: ErrKeyNotFound,
+		},
+		{
+			name: "key not found, refresh rate-limited",
+			jwkd: func() *jwkd {
+				j := newJwkd()
+				j.lastRefreshed.Store(srv.URL, time.Now())
+				return j
+			}(),
+			args: args{
+				timeout: time.Second,
+				keyID:   "0",
+			},
+			wantErr: ErrKeyNotFound,
+		},
+		{
+			name: "empty key ID",
+			jwkd: newJwkd(),
+			args: args{
+				timeout: time.Second,
+			},
+			wantErr: ErrKeyNotFound,
+		},
+		{
+			name: "unknown JWK Set URL",
+			jwkd: newJwkd(),
+			args: args{
+				timeout:   time.Second,
+				keyID:     "0",
+				jwkSetURL: "https://unknown.athenz.io/oauth2/keys",
+			},
+			wantErr: ErrUnknownJWKSetURL,
+		},
+		{
+			name: "timeout waiting for refresh",
+			jwkd: newJwkd(),
+			args: args{
+				timeout:   50 * time.Millisecond,
+				keyID:     "0",
+				jwkSetURL: srv.URL + "/slow",
+			},
+			wantErr: context.DeadlineExceeded,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), tt.args.timeout)
+			defer cancel()
+			got, err := tt.jwkd.GetKeyWithRefresh(ctx, tt.args.keyID, tt.args.jwkSetURL)
+			if errors.Cause(err) != tt.wantErr {
+				t.Errorf("jwkd.GetKeyWithRefresh() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if (got != nil) != tt.wantKey {
+				t.Errorf("jwkd.GetKeyWithRefresh() = %v, wantKey %v", got, tt.wantKey)
+			}
+		})
+	}
+}
+
 func Test_jwkd_getKey(t *testing.T) {
 	type fields struct {
 		athenzJwksURL string
@@ -1107,6 +1835,144 @@ func Test_jwkd_getKey(t *testing.T) {
 	}
 }
 
+func Test_jwkd_GetAlgorithmProvider(t *testing.T) {
+	tests := []struct {
+		name      string
+		checkFunc func(AlgorithmProvider) error
+	}{
+		{
+			name: "get success",
+			checkFunc: func(p AlgorithmProvider) error {
+				if p == nil {
+					return errors.New("GetAlgorithmProvider return nil")
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j := &jwkd{}
+			got := j.GetAlgorithmProvider()
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("jwkd.GetAlgorithmProvider() err %v", err)
+			}
+		})
+	}
+}
+
+func Test_jwkd_getAlgorithm(t *testing.T) {
+	type fields struct {
+		athenzJwksURL string
+		keys          *sync.Map
+	}
+	type args struct {
+		keyID     string
+		jwkSetURL string
+	}
+	type test struct {
+		name   string
+		fields fields
+		args   args
+		want   string
+	}
+	newKey := func(keyID, alg string) jwk.Key {
+		k, _ := rsa.GenerateKey(rand.Reader, 2048)
+		jwkKey, _ := jwk.New(&k.PublicKey)
+		if err := jwkKey.Set(jwk.KeyIDKey, keyID); err != nil {
+			t.Errorf("jwkd.getAlgorithm() setup error = %v", err)
+		}
+		if alg != "" {
+			if err := jwkKey.Set(jwk.AlgorithmKey, alg); err != nil {
+				t.Errorf("jwkd.getAlgorithm() setup error = %v", err)
+			}
+		}
+		return jwkKey
+	}
+	tests := []test{
+		func() test {
+			key := sync.Map{}
+			key.Store("dummy.com", &jwk.Set{
+				Keys: []jwk.Key{newKey("dummyID", "RS256")},
+			})
+			return test{
+				name: "get algorithm success",
+				fields: fields{
+					keys:          &key,
+					athenzJwksURL: "dummy.com",
+				},
+				args: args{
+					keyID: "dummyID",
+				},
+				want: "RS256",
+			}
+		}(),
+		func() test {
+			key := sync.Map{}
+			key.Store("dummy2.com", &jwk.Set{
+				Keys: []jwk.Key{newKey("dummyID", "PS256")},
+			})
+			return test{
+				name: "get algorithm success jwkSetURL",
+				fields: fields{
+					keys:          &key,
+					athenzJwksURL: "dummy.com",
+				},
+				args: args{
+					keyID:     "dummyID",
+					jwkSetURL: "dummy2.com",
+				},
+				want: "PS256",
+			}
+		}(),
+		func() test {
+			key := sync.Map{}
+			key.Store("dummy.com", &jwk.Set{
+				Keys: []jwk.Key{newKey("dummyID", "")},
+			})
+			return test{
+				name: "algorithm not declared",
+				fields: fields{
+					keys:          &key,
+					athenzJwksURL: "dummy.com",
+				},
+				args: args{
+					keyID: "dummyID",
+				},
+				want: "",
+			}
+		}(),
+		func() test {
+			key := sync.Map{}
+			key.Store("dummy.com", &jwk.Set{
+				Keys: []jwk.Key{newKey("dummyID", "RS256")},
+			})
+			return test{
+				name: "key not found",
+				fields: fields{
+					keys:          &key,
+					athenzJwksURL: "dummy.com",
+				},
+				args: args{
+					keyID: "not exists",
+				},
+				want: "",
+			}
+		}(),
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j := &jwkd{
+				athenzJwksURL: tt.fields.athenzJwksURL,
+				keys:          tt.fields.keys,
+			}
+			if got := j.getAlgorithm(tt.args.keyID, tt.args.jwkSetURL); got != tt.want {
+				t.Errorf("jwkd.getAlgorithm() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func Test_jwkd_isContain(t *testing.T) {
 	type args struct {
 		targets []string
@@ -21,4 +21,13 @@ import "github.com/pkg/errors"
 var (
 	// ErrFetchAthenzJWK "Fetch athenz json web key error"
 	ErrFetchAthenzJWK = errors.New("Fetch athenz json web key error")
+
+	// ErrUnknownJWKSetURL "JWK Set URL is not configured"
+	ErrUnknownJWKSetURL = errors.New("JWK Set URL is not configured")
+
+	// ErrRefreshRateLimited "JWK Set refreshed recently"
+	ErrRefreshRateLimited = errors.New("JWK Set refreshed recently")
+
+	// ErrKeyNotFound "JWK not found"
+	ErrKeyNotFound = errors.New("JWK not found")
 )
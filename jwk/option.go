@@ -30,6 +30,11 @@ var (
 	defaultOptions = []Option{
 		WithRefreshPeriod("24h"),
 		WithRetryDelay("1m"),
+		WithMinRefreshInterval("1m"),
+		WithAsyncRefreshOnMiss(true),
+		WithMaxDynamicURLs(100),
+		WithDynamicURLFetchInterval("1s"),
+		WithFetchConcurrency(4),
 		WithHTTPClient(http.DefaultClient),
 	}
 )
@@ -82,6 +87,31 @@ func WithRetryDelay(i string) Option {
 	}
 }
 
+// WithMinRefreshInterval returns a MinRefreshInterval functional option
+func WithMinRefreshInterval(t string) Option {
+	return func(j *jwkd) error {
+		if t == "" {
+			return nil
+		}
+		ri, err := time.ParseDuration(t)
+		if err != nil {
+			return errors.Wrap(err, "invalid min refresh interval")
+		}
+		j.minRefreshInterval = ri
+		return nil
+	}
+}
+
+// WithAsyncRefreshOnMiss returns an AsyncRefreshOnMiss functional option.
+// If enabled, the Provider triggers the refresh of the JWK Set in background when the key ID is not found.
+// The refresh is rate-limited by MinRefreshInterval.
+func WithAsyncRefreshOnMiss(b bool) Option {
+	return func(j *jwkd) error {
+		j.asyncRefreshOnMiss = b
+		return nil
+	}
+}
+
 // WithURLs returns an JwkUrls functional option
 func WithURLs(urls []string) Option {
 	return func(j *jwkd) error {
@@ -99,6 +129,67 @@ func WithURLs(urls []string) Option {
 	}
 }
 
+// WithURLPatterns returns an URLPatterns functional option.
+// The JWK Set URL (jku) in the token matching any of the patterns is fetched on the first lookup, and refreshed while in use.
+// The pattern is matched by the scheme, the host and the path prefix, e.g. "https://*.athenz.io/zts/v1/". The host "*.athenz.io" matches any subdomain.
+func WithURLPatterns(patterns []string) Option {
+	return func(j *jwkd) error {
+		ps := make([]*urlPattern, 0, len(patterns))
+		for _, pattern := range patterns {
+			p, err := parseURLPattern(pattern)
+			if err != nil {
+				return err
+			}
+			ps = append(ps, p)
+		}
+		j.urlPatterns = ps
+		return nil
+	}
+}
+
+// WithMaxDynamicURLs returns a MaxDynamicURLs functional option.
+// The least recently used JWK Set fetched by the URLPatterns is evicted when the number exceeds the maximum.
+func WithMaxDynamicURLs(n int) Option {
+	return func(j *jwkd) error {
+		if n < 0 {
+			return errors.New("invalid max dynamic URLs")
+		}
+		j.maxDynamicURLs = n
+		return nil
+	}
+}
+
+// WithDynamicURLFetchInterval returns a DynamicURLFetchInterval functional option.
+// The new JWK Set URLs allowed by the URLPatterns are fetched at most once per interval in total.
+func WithDynamicURLFetchInterval(t string) Option {
+	return func(j *jwkd) error {
+		if t == "" {
+			return nil
+		}
+		fi, err := time.ParseDuration(t)
+		if err != nil {
+			return errors.Wrap(err, "invalid dynamic URL fetch interval")
+		}
+		if fi < 0 {
+			return errors.New("invalid dynamic URL fetch interval")
+		}
+		j.dynamicFetchInterval = fi
+		return nil
+	}
+}
+
+// WithFetchConcurrency returns a FetchConcurrency functional option.
+// It limits the number of the JWK Set URLs fetched concurrently by Update.
+func WithFetchConcurrency(n int) Option {
+	return func(j *jwkd) error {
+		if n < 1 {
+			return errors.New("invalid fetch concurrency")
+		}
+		j.fetchConcurrency = n
+		return nil
+	}
+}
+
 // WithHTTPClient returns a HTTPClient functional option
 func WithHTTPClient(cl *http.Client) Option {
 	return func(j *jwkd) error {
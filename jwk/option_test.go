@@ -5,7 +5,7 @@ Licensed under the Apache License, Version 2.0 (the "License");
 you may not use this file except in compliance with the License.
 You may obtain a copy of the License at
 
-    http://www.apache.org/licenses/LICENSE-2.0
+	http://www.apache.org/licenses/LICENSE-2.0
 
 Unless required by applicable law or agreed to in writing, software
 distributed under the License is distributed on an "AS IS" BASIS,
@@ -225,6 +225,335 @@ func TestWithRetryDelay(t *testing.T) {
 	}
 }
 
+func TestWithMinRefreshInterval(t *testing.T) {
+	type args struct {
+		i string
+	}
+	tests := []struct {
+		name      string
+		args      args
+		checkFunc func(Option) error
+	}{
+		{
+			name: "set success",
+			args: args{
+				"1h",
+			},
+			checkFunc: func(opt Option) error {
+				pol := &jwkd{}
+				if err := opt(pol); err != nil {
+					return err
+				}
+				if pol.minRefreshInterval != time.Hour {
+					return fmt.Errorf("Error")
+				}
+
+				return nil
+			},
+		},
+		{
+			name: "invalid format",
+			args: args{
+				"dummy",
+			},
+			checkFunc: func(opt Option) error {
+				pol := &jwkd{}
+				if err := opt(pol); err == nil {
+					return fmt.Errorf("expected error, but not return")
+				}
+
+				return nil
+			},
+		},
+		{
+			name: "empty value",
+			args: args{
+				"",
+			},
+			checkFunc: func(opt Option) error {
+				pol := &jwkd{}
+				if err := opt(pol); err != nil {
+					return err
+				}
+				if !reflect.DeepEqual(pol, &jwkd{}) {
+					return fmt.Errorf("expected no changes, but got %v", pol)
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithMinRefreshInterval(tt.args.i)
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithMinRefreshInterval() error= %v", err)
+			}
+		})
+	}
+}
+
+func TestWithAsyncRefreshOnMiss(t *testing.T) {
+	type args struct {
+		b bool
+	}
+	tests := []struct {
+		name      string
+		args      args
+		checkFunc func(Option) error
+	}{
+		{
+			name: "set success",
+			args: args{
+				b: true,
+			},
+			checkFunc: func(opt Option) error {
+				j := &jwkd{}
+				if err := opt(j); err != nil {
+					return err
+				}
+				if !j.asyncRefreshOnMiss {
+					return fmt.Errorf("invalid param was set")
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithAsyncRefreshOnMiss(tt.args.b)
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithAsyncRefreshOnMiss() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestWithURLPatterns(t *testing.T) {
+	type args struct {
+		patterns []string
+	}
+	tests := []struct {
+		name      string
+		args      args
+		checkFunc func(Option) error
+	}{
+		{
+			name: "set success",
+			args: args{
+				patterns: []string{"https://*.athenz.io/zts/v1/"},
+			},
+			checkFunc: func(opt Option) error {
+				j := &jwkd{}
+				if err := opt(j); err != nil {
+					return err
+				}
+				want := []*urlPattern{{scheme: "https", host: "*.athenz.io", pathPrefix: "/zts/v1"}}
+				if !reflect.DeepEqual(j.urlPatterns, want) {
+					return fmt.Errorf("invalid param was set")
+				}
+				return nil
+			},
+		},
+		{
+			name: "invalid pattern",
+			args: args{
+				patterns: []string{"ftp://athenz.io/"},
+			},
+			checkFunc: func(opt Option) error {
+				j := &jwkd{}
+				if err := opt(j); err == nil {
+					return fmt.Errorf("expected error, but not occurred")
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithURLPatterns(tt.args.patterns)
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithURLPatterns() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestWithMaxDynamicURLs(t *testing.T) {
+	type args struct {
+		n int
+	}
+	tests := []struct {
+		name      string
+		args      args
+		checkFunc func(Option) error
+	}{
+		{
+			name: "set success",
+			args: args{
+				n: 10,
+			},
+			checkFunc: func(opt Option) error {
+				j := &jwkd{}
+				if err := opt(j); err != nil {
+					return err
+				}
+				if j.maxDynamicURLs != 10 {
+					return fmt.Errorf("invalid param was set")
+				}
+				return nil
+			},
+		},
+		{
+			name: "invalid max",
+			args: args{
+				n: -1,
+			},
+			checkFunc: func(opt Option) error {
+				j := &jwkd{}
+				if err := opt(j); err == nil {
+					return fmt.Errorf("expected error, but not occurred")
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithMaxDynamicURLs(tt.args.n)
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithMaxDynamicURLs() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestWithDynamicURLFetchInterval(t *testing.T) {
+	type args struct {
+		i string
+	}
+	tests := []struct {
+		name      string
+		args      args
+		checkFunc func(Option) error
+	}{
+		{
+			name: "set success",
+			args: args{
+				"1m",
+			},
+			checkFunc: func(opt Option) error {
+				j := &jwkd{}
+				if err := opt(j); err != nil {
+					return err
+				}
+				if j.dynamicFetchInterval != time.Minute {
+					return fmt.Errorf("invalid param was set")
+				}
+				return nil
+			},
+		},
+		{
+			name: "invalid format",
+			args: args{
+				"dummy",
+			},
+			checkFunc: func(opt Option) error {
+				j := &jwkd{}
+				if err := opt(j); err == nil {
+					return fmt.Errorf("expected error, but not occurred")
+				}
+				return nil
+			},
+		},
+		{
+			name: "negative interval",
+			args: args{
+				"-1s",
+			},
+			checkFunc: func(opt Option) error {
+				j := &jwkd{}
+				if err := opt(j); err == nil {
+					return fmt.Errorf("expected error, but not occurred")
+				}
+				return nil
+			},
+		},
+		{
+			name: "empty value",
+			args: args{
+				"",
+			},
+			checkFunc: func(opt Option) error {
+				j := &jwkd{}
+				if err := opt(j); err != nil {
+					return err
+				}
+				if !reflect.DeepEqual(j, &jwkd{}) {
+					return fmt.Errorf("expected no changes, but got %v", j)
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithDynamicURLFetchInterval(tt.args.i)
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithDynamicURLFetchInterval() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestWithFetchConcurrency(t *testing.T) {
+	type args struct {
+		n int
+	}
+	tests := []struct {
+		name      string
+		args      args
+		checkFunc func(Option) error
+	}{
+		{
+			name: "set success",
+			args: args{
+				n: 8,
+			},
+			checkFunc: func(opt Option) error {
+				j := &jwkd{}
+				if err := opt(j); err != nil {
+					return err
+				}
+				if j.fetchConcurrency != 8 {
+					return fmt.Errorf("invalid param was set")
+				}
+				return nil
+			},
+		},
+		{
+			name: "invalid concurrency",
+			args: args{
+				n: 0,
+			},
+			checkFunc: func(opt Option) error {
+				j := &jwkd{}
+				if err := opt(j); err == nil {
+					return fmt.Errorf("expected error, but not occurred")
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithFetchConcurrency(tt.args.n)
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithFetchConcurrency() error = %v", err)
+			}
+		})
+	}
+}
+
 func TestWithHTTPClient(t *testing.T) {
 	type args struct {
 		cl *http.Client
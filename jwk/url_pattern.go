@@ -0,0 +1,82 @@
+/*
+Copyright (C)  2018 Yahoo Japan Corporation Athenz team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwk
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+	urlutil "github.com/yahoojapan/athenz-authorizer/v5/internal/url"
+)
+
+// urlPattern represents the JWK Set URLs (jku) allowed to be fetched on demand, matched by the scheme, the host and the path prefix.
+type urlPattern struct {
+	scheme string
+	// the host with the optional port, e.g. "zts.athenz.io:4443". The leading "*." matches any subdomain, e.g. "*.athenz.io".
+	host string
+	// the path prefix matched on the path segment boundary, e.g. "/zts/v1" matches "/zts/v1/oauth2/keys" but not "/zts/v10"
+	pathPrefix string
+}
+
+// parseURLPattern parses the pattern, e.g. "https://*.athenz.io/zts/v1/".
+func parseURLPattern(pattern string) (*urlPattern, error) {
+	u, err := url.Parse(pattern)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid URL pattern %s", pattern)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, errors.Wrapf(urlutil.ErrUnsupportedScheme, "URL pattern %s", pattern)
+	}
+	host := strings.ToLower(u.Host)
+	if host == "" || host == "*." || strings.Contains(strings.TrimPrefix(host, "*."), "*") {
+		return nil, errors.Errorf("invalid host of URL pattern %s", pattern)
+	}
+	if u.User != nil || u.RawQuery != "" || u.Fragment != "" {
+		return nil, errors.Errorf("URL pattern %s must not contain user info, query or fragment", pattern)
+	}
+	return &urlPattern{
+		scheme:     u.Scheme,
+		host:       host,
+		pathPrefix: strings.TrimSuffix(u.Path, "/"),
+	}, nil
+}
+
+// match returns true if the JWK Set URL matches the pattern.
+// The URL with the user info, the query or the fragment never matches, since the caller may vary them to make unlimited distinct URLs.
+// Such URLs, e.g. "https://zts.athenz.io/zts/v1/oauth2/keys?rfc=true", should be configured as the static URLs.
+func (p *urlPattern) match(u *url.URL) bool {
+	if u.Scheme != p.scheme || u.User != nil || u.Opaque != "" {
+		return false
+	}
+	if u.RawQuery != "" || u.ForceQuery || u.Fragment != "" {
+		return false
+	}
+	host := strings.ToLower(u.Host)
+	if strings.HasPrefix(p.host, "*.") {
+		if !strings.HasSuffix(host, p.host[1:]) {
+			return false
+		}
+	} else if host != p.host {
+		return false
+	}
+	// the dot segments may escape from the path prefix
+	if strings.Contains(u.Path+"/", "/../") {
+		return false
+	}
+	return p.pathPrefix == "" || u.Path == p.pathPrefix || strings.HasPrefix(u.Path, p.pathPrefix+"/")
+}
@@ -0,0 +1,221 @@
+/*
+Copyright (C)  2018 Yahoo Japan Corporation Athenz team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwk
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func Test_parseURLPattern(t *testing.T) {
+	type args struct {
+		pattern string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    *urlPattern
+		wantErr bool
+	}{
+		{
+			name: "parse success",
+			args: args{
+				pattern: "https://zts.athenz.io/zts/v1/",
+			},
+			want: &urlPattern{
+				scheme:     "https",
+				host:       "zts.athenz.io",
+				pathPrefix: "/zts/v1",
+			},
+		},
+		{
+			name: "parse success, wildcard host with port",
+			args: args{
+				pattern: "https://*.Athenz.io:4443",
+			},
+			want: &urlPattern{
+				scheme: "https",
+				host:   "*.athenz.io:4443",
+			},
+		},
+		{
+			name: "parse fail, unsupported scheme",
+			args: args{
+				pattern: "ftp://zts.athenz.io/",
+			},
+			wantErr: true,
+		},
+		{
+			name: "parse fail, empty host",
+			args: args{
+				pattern: "https:///zts/v1/",
+			},
+			wantErr: true,
+		},
+		{
+			name: "parse fail, wildcard in the middle of host",
+			args: args{
+				pattern: "https://zts.*.athenz.io/",
+			},
+			wantErr: true,
+		},
+		{
+			name: "parse fail, query",
+			args: args{
+				pattern: "https://zts.athenz.io/zts/v1/?rfc=true",
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseURLPattern(tt.args.pattern)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseURLPattern() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseURLPattern() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_urlPattern_match(t *testing.T) {
+	type args struct {
+		jwkSetURL string
+	}
+	tests := []struct {
+		name    string
+		pattern string
+		args    args
+		want    bool
+	}{
+		{
+			name:    "match path prefix",
+			pattern: "https://zts.athenz.io/zts/v1/",
+			args: args{
+				jwkSetURL: "https://zts.athenz.io/zts/v1/oauth2/keys",
+			},
+			want: true,
+		},
+		{
+			name:    "match any path",
+			pattern: "https://zts.athenz.io",
+			args: args{
+				jwkSetURL: "https://zts.athenz.io/oauth2/keys",
+			},
+			want: true,
+		},
+		{
+			name:    "match wildcard host",
+			pattern: "https://*.athenz.io/zts/v1",
+			args: args{
+				jwkSetURL: "https://zts.east.athenz.io/zts/v1/oauth2/keys",
+			},
+			want: true,
+		},
+		{
+			name:    "not match, path prefix is not on the segment boundary",
+			pattern: "https://zts.athenz.io/zts/v1/",
+			args: args{
+				jwkSetURL: "https://zts.athenz.io/zts/v10/oauth2/keys",
+			},
+			want: false,
+		},
+		{
+			name:    "not match, dot segments",
+			pattern: "https://zts.athenz.io/zts/v1/",
+			args: args{
+				jwkSetURL: "https://zts.athenz.io/zts/v1/../../other/keys",
+			},
+			want: false,
+		},
+		{
+			name:    "not match, scheme",
+			pattern: "https://zts.athenz.io/",
+			args: args{
+				jwkSetURL: "http://zts.athenz.io/oauth2/keys",
+			},
+			want: false,
+		},
+		{
+			name:    "not match, host",
+			pattern: "https://zts.athenz.io/",
+			args: args{
+				jwkSetURL: "https://zts.athenz.io.attacker.com/oauth2/keys",
+			},
+			want: false,
+		},
+		{
+			name:    "not match, wildcard does not match the parent domain",
+			pattern: "https://*.athenz.io/",
+			args: args{
+				jwkSetURL: "https://athenz.io/oauth2/keys",
+			},
+			want: false,
+		},
+		{
+			name:    "not match, query",
+			pattern: "https://zts.athenz.io/zts/v1/",
+			args: args{
+				jwkSetURL: "https://zts.athenz.io/zts/v1/oauth2/keys?rfc=true",
+			},
+			want: false,
+		},
+		{
+			name:    "not match, empty query",
+			pattern: "https://zts.athenz.io/zts/v1/",
+			args: args{
+				jwkSetURL: "https://zts.athenz.io/zts/v1/oauth2/keys?",
+			},
+			want: false,
+		},
+		{
+			name:    "not match, fragment",
+			pattern: "https://zts.athenz.io/zts/v1/",
+			args: args{
+				jwkSetURL: "https://zts.athenz.io/zts/v1/oauth2/keys#x=1",
+			},
+			want: false,
+		},
+		{
+			name:    "not match, user info",
+			pattern: "https://zts.athenz.io/",
+			args: args{
+				jwkSetURL: "https://user@zts.athenz.io/oauth2/keys",
+			},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := parseURLPattern(tt.pattern)
+			if err != nil {
+				t.Fatalf("parseURLPattern() error = %v", err)
+			}
+			u, err := url.Parse(tt.args.jwkSetURL)
+			if err != nil {
+				t.Fatalf("url.Parse() error = %v", err)
+			}
+			if got := p.match(u); got != tt.want {
+				t.Errorf("urlPattern.match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
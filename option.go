@@ -17,10 +17,15 @@ limitations under the License.
 package authorizerd
 
 import (
+	"crypto/x509"
+	"io/ioutil"
 	"net/http"
 	"time"
 
+	"github.com/pkg/errors"
 	urlutil "github.com/yahoojapan/athenz-authorizer/v5/internal/url"
+	"github.com/yahoojapan/athenz-authorizer/v5/policy"
+	"github.com/yahoojapan/athenz-authorizer/v5/pubkey"
 )
 
 const (
@@ -34,7 +39,11 @@ var (
 		WithCacheExp(time.Minute),
 		WithEnablePubkeyd(),
 		WithEnablePolicyd(),
+		WithPolicyFetchConcurrency(8),
 		WithEnableJwkd(),
+		WithJwkAsyncRefreshOnMiss(true),
+		WithJwkMaxDynamicURLs(100),
+		WithJwkFetchConcurrency(4),
 		WithAccessTokenParam(NewAccessTokenParam(true, true, "1h", "1h", false, nil)),
 		WithEnableRoleToken(),
 		WithRoleAuthHeader("Athenz-Role-Auth"),
@@ -98,6 +107,19 @@ func WithCacheExp(exp time.Duration) Option {
 	}
 }
 
+// WithCombinationPolicy returns a CombinationPolicy functional option.
+// It decides how the results of the role token, access token and role certificate authorizers are combined in Verify and Authorize.
+func WithCombinationPolicy(p CombinationPolicy) Option {
+	return func(authz *authority) error {
+		switch p {
+		case AnyOf, AllOf, FirstPresent:
+			authz.combinationPolicy = p
+			return nil
+		}
+		return errors.Errorf("invalid combination policy: %d", p)
+	}
+}
+
 /*
 	pubkeyd parameters
 */
@@ -158,6 +180,38 @@ func WithPubkeyETagPurgePeriod(t string) Option {
 	}
 }
 
+// WithPubkeyKeyGracePeriod returns a PubkeyKeyGracePeriod functional option.
+// The public key removed from the Athenz server is still accepted during the grace period.
+func WithPubkeyKeyGracePeriod(t string) Option {
+	return func(authz *authority) error {
+		authz.pubkeyKeyGracePeriod = t
+		return nil
+	}
+}
+
+// WithPubkeyKeySources returns a PubkeyKeySources functional option.
+// The Athenz public keys are loaded from the key sources instead of the sys.auth domain,
+// e.g. WithPubkeyKeySources(pubkey.NewJWKSKeySource(pubkey.EnvZTS, "https://athenz.io/zts/v1/oauth2/keys?rfc=true", nil), pubkey.NewDirKeySource("/etc/athenz/keys"))
+func WithPubkeyKeySources(srcs ...pubkey.KeySource) Option {
+	return func(authz *authority) error {
+		authz.pubkeyKeySources = srcs
+		return nil
+	}
+}
+
+// WithPubkeyEnvironment returns a PubkeyEnvironment functional option.
+// It adds the Athenz environment of the public keys, or overrides the configuration of pubkey.EnvZMS and pubkey.EnvZTS,
+// e.g. WithPubkeyEnvironment(pubkey.EnvZMS, pubkey.EnvConfig{AthenzURL: "zms.athenz.io/zms/v1"})
+func WithPubkeyEnvironment(env pubkey.AthenzEnv, cfg pubkey.EnvConfig) Option {
+	return func(authz *authority) error {
+		if authz.pubkeyEnvironments == nil {
+			authz.pubkeyEnvironments = make(map[pubkey.AthenzEnv]pubkey.EnvConfig)
+		}
+		authz.pubkeyEnvironments[env] = cfg
+		return nil
+	}
+}
+
 /*
 	policyd parameters
 */
@@ -210,6 +264,15 @@ func WithPolicyRetryDelay(i string) Option {
 	}
 }
 
+// WithPolicyMaxRetryDelay returns a PolicyMaxRetryDelay functional option.
+// The retry delay is capped by it, and the retry is given up if the Retry-After from the server exceeds it.
+func WithPolicyMaxRetryDelay(d string) Option {
+	return func(authz *authority) error {
+		authz.policyMaxRetryDelay = d
+		return nil
+	}
+}
+
 // WithPolicyRetryAttempts returns a PolicyRetryAttempts functional option
 func WithPolicyRetryAttempts(c int) Option {
 	return func(authz *authority) error {
@@ -218,6 +281,77 @@ func WithPolicyRetryAttempts(c int) Option {
 	}
 }
 
+// WithPolicyMaxStaleness returns a PolicyMaxStaleness functional option
+func WithPolicyMaxStaleness(t string) Option {
+	return func(authz *authority) error {
+		authz.policyMaxStaleness = t
+		return nil
+	}
+}
+
+// WithPolicyFetchConcurrency returns a PolicyFetchConcurrency functional option.
+// It limits the number of the domains fetched concurrently.
+func WithPolicyFetchConcurrency(n int) Option {
+	return func(authz *authority) error {
+		authz.policyFetchConcurrency = n
+		return nil
+	}
+}
+
+// WithPolicyRefreshSpread returns a PolicyRefreshSpread functional option.
+// The periodic fetches of the domains are delayed randomly within the duration.
+func WithPolicyRefreshSpread(t string) Option {
+	return func(authz *authority) error {
+		authz.policyRefreshSpread = t
+		return nil
+	}
+}
+
+// WithPolicyBulkSource returns a PolicyBulkSource functional option.
+// The policies of the domains are fetched from the source at once, e.g. WithPolicyBulkSource(policy.NewFileBulkSource("/var/athenz/policies.json"))
+func WithPolicyBulkSource(s policy.BulkSource) Option {
+	return func(authz *authority) error {
+		authz.policyBulkSource = s
+		return nil
+	}
+}
+
+// WithPolicyShadowSource returns a PolicyShadowSource functional option.
+// The shadow policies are evaluated with the enforced policies and the disagreements are logged, e.g. to verify the new policies before rolling out. The results are not cached while the source is set
+func WithPolicyShadowSource(s policy.BulkSource) Option {
+	return func(authz *authority) error {
+		authz.policyShadowSource = s
+		return nil
+	}
+}
+
+// WithPolicyDryRun returns a PolicyDryRun functional option.
+// The requests denied by the policy are allowed and only logged. The results are not cached, so that every decision is reported
+func WithPolicyDryRun(b bool) Option {
+	return func(authz *authority) error {
+		authz.policyDryRun = b
+		return nil
+	}
+}
+
+// WithPolicyDryRunDomains returns a PolicyDryRunDomains functional option.
+// The requests denied by the policy of the domains are allowed and only logged. The results of the domains are not cached, so that every decision is reported
+func WithPolicyDryRunDomains(domains ...string) Option {
+	return func(authz *authority) error {
+		authz.policyDryRunDomains = domains
+		return nil
+	}
+}
+
+// WithPolicyDecisionHook returns a PolicyDecisionHook functional option.
+// The hook is called with the result of each policy check, e.g. to export the audit logs or the metrics. The results are not cached while the hook is set
+func WithPolicyDecisionHook(h policy.DecisionHook) Option {
+	return func(authz *authority) error {
+		authz.policyDecisionHook = h
+		return nil
+	}
+}
+
 /*
 	jwkd parameters
 */
@@ -254,6 +388,24 @@ func WithJwkRetryDelay(i string) Option {
 	}
 }
 
+// WithJwkMinRefreshInterval returns a JwkMinRefreshInterval functional option.
+// The JWK Set is not refreshed on demand again within the interval.
+func WithJwkMinRefreshInterval(t string) Option {
+	return func(authz *authority) error {
+		authz.jwkMinRefreshInterval = t
+		return nil
+	}
+}
+
+// WithJwkAsyncRefreshOnMiss returns a JwkAsyncRefreshOnMiss functional option.
+// The JWK Set is refreshed in background when the key ID is not found, the refresh is rate-limited by JwkMinRefreshInterval.
+func WithJwkAsyncRefreshOnMiss(b bool) Option {
+	return func(authz *authority) error {
+		authz.jwkAsyncRefreshOnMiss = b
+		return nil
+	}
+}
+
 // WithJwkURLs returns a JwkURLs functional option
 func WithJwkURLs(urls []string) Option {
 	return func(authz *authority) error {
@@ -262,6 +414,43 @@ func WithJwkURLs(urls []string) Option {
 	}
 }
 
+// WithJwkURLPatterns returns a JwkURLPatterns functional option.
+// The JWK Set URL (jku) in the access token matching any of the patterns is fetched on the first use, e.g. "https://*.athenz.io/zts/v1/".
+// The jku with the user info, the query or the fragment never matches.
+func WithJwkURLPatterns(patterns []string) Option {
+	return func(authz *authority) error {
+		authz.jwkURLPatterns = patterns
+		return nil
+	}
+}
+
+// WithJwkMaxDynamicURLs returns a JwkMaxDynamicURLs functional option.
+// The least recently used JWK Set fetched by the JwkURLPatterns is evicted when the number exceeds the maximum.
+func WithJwkMaxDynamicURLs(n int) Option {
+	return func(authz *authority) error {
+		authz.jwkMaxDynamicURLs = n
+		return nil
+	}
+}
+
+// WithJwkDynamicURLFetchInterval returns a JwkDynamicURLFetchInterval functional option.
+// The new JWK Set URLs matching the JwkURLPatterns are fetched at most once per interval in total.
+func WithJwkDynamicURLFetchInterval(t string) Option {
+	return func(authz *authority) error {
+		authz.jwkDynamicURLFetchInterval = t
+		return nil
+	}
+}
+
+// WithJwkFetchConcurrency returns a JwkFetchConcurrency functional option.
+// It limits the number of the JWK Set URLs fetched concurrently.
+func WithJwkFetchConcurrency(n int) Option {
+	return func(authz *authority) error {
+		authz.jwkFetchConcurrency = n
+		return nil
+	}
+}
+
 /*
 	access token parameters
 */
@@ -292,6 +481,96 @@ func WithAccessTokenParam(accessTokenParam AccessTokenParam) Option {
 	}
 }
 
+// WithAccessTokenExtractors returns an AccessTokenExtractors functional option.
+// The access token is extracted by the first extractor returning non-empty credential. If not set, the Authorization header is used.
+// e.g. WithAccessTokenExtractors(BearerTokenExtractor(), AccessTokenQueryExtractor())
+func WithAccessTokenExtractors(extractors ...CredentialExtractor) Option {
+	return func(authz *authority) error {
+		authz.accessTokenExtractors = extractors
+		return nil
+	}
+}
+
+// WithAccessTokenIssuers returns an AccessTokenIssuers functional option.
+// The access tokens with the other issuers are rejected. If not set, the issuer is not verified.
+func WithAccessTokenIssuers(issuers ...string) Option {
+	return func(authz *authority) error {
+		authz.accessTokenIssuers = issuers
+		return nil
+	}
+}
+
+// WithAccessTokenJWKSetIssuers returns an AccessTokenJWKSetIssuers functional option.
+// The map key is the JWK Set URL (jku), and the value is the issuers accepted for the access tokens signed by the keys of that JWK Set.
+func WithAccessTokenJWKSetIssuers(m map[string][]string) Option {
+	return func(authz *authority) error {
+		authz.accessTokenJWKSetIssuers = m
+		return nil
+	}
+}
+
+// WithAccessTokenVerifyAudience returns an AccessTokenVerifyAudience functional option.
+// If true, only the access tokens having an audience in AthenzDomains are accepted.
+func WithAccessTokenVerifyAudience(b bool) Option {
+	return func(authz *authority) error {
+		authz.accessTokenVerifyAudience = b
+		return nil
+	}
+}
+
+// WithAccessTokenClockSkew returns an AccessTokenClockSkew functional option
+func WithAccessTokenClockSkew(t string) Option {
+	return func(authz *authority) error {
+		authz.accessTokenClockSkew = t
+		return nil
+	}
+}
+
+// WithAccessTokenAllowedAlgorithms returns an AccessTokenAllowedAlgorithms functional option.
+// The access tokens signed with the other algorithms are rejected. If not set, any algorithm consistent with the key type is accepted.
+func WithAccessTokenAllowedAlgorithms(algs ...string) Option {
+	return func(authz *authority) error {
+		authz.accessTokenAlgorithms = algs
+		return nil
+	}
+}
+
+// WithAccessTokenJWKRefreshOnMiss returns an AccessTokenJWKRefreshOnMiss functional option.
+// If true, the JWK Set is refreshed when the key ID of the access token is not found, e.g. after the key rotation.
+func WithAccessTokenJWKRefreshOnMiss(b bool) Option {
+	return func(authz *authority) error {
+		authz.accessTokenJWKRefreshOnMiss = b
+		return nil
+	}
+}
+
+// WithAccessTokenIntrospectionURL returns an AccessTokenIntrospectionURL functional option.
+// The token introspection endpoint (RFC 7662) is used for the opaque access token, and the access token whose key is still not found.
+func WithAccessTokenIntrospectionURL(u string) Option {
+	return func(authz *authority) error {
+		authz.accessTokenIntrospectionURL = u
+		return nil
+	}
+}
+
+// WithAccessTokenRequestTimeout returns an AccessTokenRequestTimeout functional option.
+// It limits the wait for the JWK Set refresh and the introspection request triggered by the access token.
+func WithAccessTokenRequestTimeout(t string) Option {
+	return func(authz *authority) error {
+		authz.accessTokenRequestTimeout = t
+		return nil
+	}
+}
+
+// WithAccessTokenVerifyType returns an AccessTokenVerifyType functional option.
+// If true, only the access token having the typ header "at+jwt" is accepted. (RFC 9068)
+func WithAccessTokenVerifyType(b bool) Option {
+	return func(authz *authority) error {
+		authz.accessTokenVerifyType = b
+		return nil
+	}
+}
+
 /*
 	role token parameters
 */
@@ -320,6 +599,52 @@ func WithRoleAuthHeader(h string) Option {
 	}
 }
 
+// WithRoleTokenExtractors returns a RoleTokenExtractors functional option.
+// The role token is extracted by the first extractor returning non-empty credential. If not set, the RoleAuthHeader is used.
+func WithRoleTokenExtractors(extractors ...CredentialExtractor) Option {
+	return func(authz *authority) error {
+		authz.roleTokenExtractors = extractors
+		return nil
+	}
+}
+
+// WithRoleTokenVerifyIP returns a RoleTokenVerifyIP functional option.
+// If true, the role token issued for the IP address other than the remote address of the request is rejected.
+// It applies only to Verify and Authorize with the request, VerifyRoleToken and AuthorizeRoleToken do not verify the IP address since the remote address is unknown.
+func WithRoleTokenVerifyIP(b bool) Option {
+	return func(authz *authority) error {
+		authz.roleTokenVerifyIP = b
+		return nil
+	}
+}
+
+// WithRoleTokenAuthorizedProxyUsers returns a RoleTokenAuthorizedProxyUsers functional option.
+// The role token having the proxy user not in the list is rejected. If not set, the proxy user is not checked.
+func WithRoleTokenAuthorizedProxyUsers(users ...string) Option {
+	return func(authz *authority) error {
+		authz.roleTokenAuthorizedProxyUsers = users
+		return nil
+	}
+}
+
+// WithRoleTokenClockSkew returns a RoleTokenClockSkew functional option.
+// The role token issued in the future beyond the clock skew is rejected.
+func WithRoleTokenClockSkew(t string) Option {
+	return func(authz *authority) error {
+		authz.roleTokenClockSkew = t
+		return nil
+	}
+}
+
+// WithRoleTokenMaxLifetime returns a RoleTokenMaxLifetime functional option.
+// The role token whose lifetime (expiry time - issue time) exceeds the maximum is rejected. If not set, the lifetime is not checked.
+func WithRoleTokenMaxLifetime(t string) Option {
+	return func(authz *authority) error {
+		authz.roleTokenMaxLifetime = t
+		return nil
+	}
+}
+
 /*
 	role certificate parameters
 */
@@ -348,6 +673,54 @@ func WithRoleCertURIPrefix(t string) Option {
 	}
 }
 
+// WithRoleCertCAPool returns a RoleCertCAPool functional option.
+// The chain of the role certificate is verified against the CA pool. If nil, the chain is not verified.
+func WithRoleCertCAPool(pool *x509.CertPool) Option {
+	return func(authz *authority) error {
+		authz.roleCertCAPool = pool
+		return nil
+	}
+}
+
+// WithRoleCertCAPath returns a RoleCertCAPath functional option.
+// The PEM encoded CA certificates in the file are added to the CA pool of the role certificate.
+func WithRoleCertCAPath(path string) Option {
+	return func(authz *authority) error {
+		if path == "" {
+			return nil
+		}
+		pem, err := ioutil.ReadFile(path)
+		if err != nil {
+			return errors.Wrap(err, "error reading role certificate CA")
+		}
+		if authz.roleCertCAPool == nil {
+			authz.roleCertCAPool = x509.NewCertPool()
+		}
+		if !authz.roleCertCAPool.AppendCertsFromPEM(pem) {
+			return errors.Errorf("no valid certificate in role certificate CA: %s", path)
+		}
+		return nil
+	}
+}
+
+// WithRoleCertVerifyExpiry returns a RoleCertVerifyExpiry functional option.
+// The role certificate outside of its validity period is rejected.
+func WithRoleCertVerifyExpiry(b bool) Option {
+	return func(authz *authority) error {
+		authz.roleCertVerifyExpiry = b
+		return nil
+	}
+}
+
+// WithRoleCertIssuers returns a RoleCertIssuers functional option.
+// Only the role certificate whose issuer DN (e.g. "CN=Athenz CA,O=Athenz") matches one of the issuers is accepted. If empty, the issuer is not verified.
+func WithRoleCertIssuers(issuers ...string) Option {
+	return func(authz *authority) error {
+		authz.roleCertIssuers = issuers
+		return nil
+	}
+}
+
 // WithTranslator returns a Translator functional option
 func WithTranslator(t Translator) Option {
 	return func(authz *authority) error {
@@ -16,6 +16,8 @@ limitations under the License.
 package authorizerd
 
 import (
+	"context"
+	"crypto/x509"
 	"fmt"
 	"net/http"
 	"reflect"
@@ -24,6 +26,8 @@ import (
 
 	"github.com/kpango/gache"
 	urlutil "github.com/yahoojapan/athenz-authorizer/v5/internal/url"
+	"github.com/yahoojapan/athenz-authorizer/v5/policy"
+	"github.com/yahoojapan/athenz-authorizer/v5/pubkey"
 )
 
 func TestWithEnablePubkeyd(t *testing.T) {
@@ -258,6 +262,55 @@ func TestWithAthenzDomains(t *testing.T) {
 	}
 }
 
+func TestWithCombinationPolicy(t *testing.T) {
+	type args struct {
+		p CombinationPolicy
+	}
+	tests := []struct {
+		name      string
+		args      args
+		checkFunc func(Option) error
+	}{
+		{
+			name: "set success",
+			args: args{
+				p: AllOf,
+			},
+			checkFunc: func(opt Option) error {
+				authz := &authority{}
+				if err := opt(authz); err != nil {
+					return err
+				}
+				if authz.combinationPolicy != AllOf {
+					return fmt.Errorf("invalid param was set")
+				}
+				return nil
+			},
+		},
+		{
+			name: "invalid combination policy",
+			args: args{
+				p: CombinationPolicy(-1),
+			},
+			checkFunc: func(opt Option) error {
+				authz := &authority{}
+				if err := opt(authz); err == nil {
+					return fmt.Errorf("invalid combination policy was accepted")
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithCombinationPolicy(tt.args.p)
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithCombinationPolicy() error = %v", err)
+			}
+		})
+	}
+}
+
 func TestWithPubkeySysAuthDomain(t *testing.T) {
 	type args struct {
 		t string
@@ -366,6 +419,125 @@ func TestWithPubkeyETagPurgePeriod(t *testing.T) {
 	}
 }
 
+func TestWithPubkeyKeyGracePeriod(t *testing.T) {
+	type args struct {
+		t string
+	}
+	tests := []struct {
+		name      string
+		args      args
+		checkFunc func(Option) error
+	}{
+		{
+			name: "set success",
+			args: args{
+				t: "dummy",
+			},
+			checkFunc: func(opt Option) error {
+				authz := &authority{}
+				if err := opt(authz); err != nil {
+					return err
+				}
+				if authz.pubkeyKeyGracePeriod != "dummy" {
+					return fmt.Errorf("invalid param was set")
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithPubkeyKeyGracePeriod(tt.args.t)
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithPubkeyKeyGracePeriod() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestWithPubkeyKeySources(t *testing.T) {
+	type args struct {
+		srcs []pubkey.KeySource
+	}
+	tests := []struct {
+		name      string
+		args      args
+		checkFunc func(Option) error
+	}{
+		{
+			name: "set success",
+			args: args{
+				srcs: []pubkey.KeySource{
+					pubkey.NewDirKeySource("dummy"),
+				},
+			},
+			checkFunc: func(opt Option) error {
+				authz := &authority{}
+				if err := opt(authz); err != nil {
+					return err
+				}
+				if len(authz.pubkeyKeySources) != 1 {
+					return fmt.Errorf("invalid param was set")
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithPubkeyKeySources(tt.args.srcs...)
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithPubkeyKeySources() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestWithPubkeyEnvironment(t *testing.T) {
+	type args struct {
+		env pubkey.AthenzEnv
+		cfg pubkey.EnvConfig
+	}
+	tests := []struct {
+		name      string
+		args      args
+		checkFunc func(Option) error
+	}{
+		{
+			name: "set success",
+			args: args{
+				env: "zts-east",
+				cfg: pubkey.EnvConfig{
+					AthenzURL: "zts-east.athenz.io/zts/v1",
+					Usage:     pubkey.EnvZTS,
+				},
+			},
+			checkFunc: func(opt Option) error {
+				authz := &authority{}
+				if err := opt(authz); err != nil {
+					return err
+				}
+				want := pubkey.EnvConfig{
+					AthenzURL: "zts-east.athenz.io/zts/v1",
+					Usage:     pubkey.EnvZTS,
+				}
+				if !reflect.DeepEqual(authz.pubkeyEnvironments["zts-east"], want) {
+					return fmt.Errorf("invalid param was set")
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithPubkeyEnvironment(tt.args.env, tt.args.cfg)
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithPubkeyEnvironment() error = %v", err)
+			}
+		})
+	}
+}
+
 func TestWithEnablePolicyd(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -568,9 +740,9 @@ func TestWithPolicyRetryDelay(t *testing.T) {
 	}
 }
 
-func TestWithPolicyRetryAttempts(t *testing.T) {
+func TestWithPolicyMaxRetryDelay(t *testing.T) {
 	type args struct {
-		c int
+		d string
 	}
 	tests := []struct {
 		name      string
@@ -580,14 +752,14 @@ func TestWithPolicyRetryAttempts(t *testing.T) {
 		{
 			name: "set success",
 			args: args{
-				c: 2,
+				d: "5m",
 			},
 			checkFunc: func(opt Option) error {
 				authz := &authority{}
 				if err := opt(authz); err != nil {
 					return err
 				}
-				if authz.policyRetryAttempts != 2 {
+				if authz.policyMaxRetryDelay != "5m" {
 					return fmt.Errorf("invalid param was set")
 				}
 				return nil
@@ -596,17 +768,17 @@ func TestWithPolicyRetryAttempts(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := WithPolicyRetryAttempts(tt.args.c)
+			got := WithPolicyMaxRetryDelay(tt.args.d)
 			if err := tt.checkFunc(got); err != nil {
-				t.Errorf("WithPolicyRetryAttempts() error = %v", err)
+				t.Errorf("WithPolicyMaxRetryDelay() error = %v", err)
 			}
 		})
 	}
 }
 
-func TestWithCacheExp(t *testing.T) {
+func TestWithPolicyRetryAttempts(t *testing.T) {
 	type args struct {
-		d time.Duration
+		c int
 	}
 	tests := []struct {
 		name      string
@@ -616,16 +788,14 @@ func TestWithCacheExp(t *testing.T) {
 		{
 			name: "set success",
 			args: args{
-				d: time.Duration(time.Hour * 2),
+				c: 2,
 			},
 			checkFunc: func(opt Option) error {
-				authz := &authority{
-					cache: gache.New(),
-				}
+				authz := &authority{}
 				if err := opt(authz); err != nil {
 					return err
 				}
-				if authz.cacheExp != time.Duration(time.Hour*2) {
+				if authz.policyRetryAttempts != 2 {
 					return fmt.Errorf("invalid param was set")
 				}
 				return nil
@@ -634,17 +804,17 @@ func TestWithCacheExp(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := WithCacheExp(tt.args.d)
+			got := WithPolicyRetryAttempts(tt.args.c)
 			if err := tt.checkFunc(got); err != nil {
-				t.Errorf("WithCacheExp() error = %v", err)
+				t.Errorf("WithPolicyRetryAttempts() error = %v", err)
 			}
 		})
 	}
 }
 
-func TestWithRoleCertURIPrefix(t *testing.T) {
+func TestWithPolicyMaxStaleness(t *testing.T) {
 	type args struct {
-		p string
+		t string
 	}
 	tests := []struct {
 		name      string
@@ -654,14 +824,14 @@ func TestWithRoleCertURIPrefix(t *testing.T) {
 		{
 			name: "set success",
 			args: args{
-				p: "dummy",
+				t: "6h",
 			},
 			checkFunc: func(opt Option) error {
 				authz := &authority{}
 				if err := opt(authz); err != nil {
 					return err
 				}
-				if authz.roleCertURIPrefix != "dummy" {
+				if authz.policyMaxStaleness != "6h" {
 					return fmt.Errorf("invalid param was set")
 				}
 				return nil
@@ -670,17 +840,17 @@ func TestWithRoleCertURIPrefix(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := WithRoleCertURIPrefix(tt.args.p)
+			got := WithPolicyMaxStaleness(tt.args.t)
 			if err := tt.checkFunc(got); err != nil {
-				t.Errorf("WithRoleCertURIPrefix() error = %v", err)
+				t.Errorf("WithPolicyMaxStaleness() error = %v", err)
 			}
 		})
 	}
 }
 
-func TestWithHTTPClient(t *testing.T) {
+func TestWithPolicyFetchConcurrency(t *testing.T) {
 	type args struct {
-		c *http.Client
+		n int
 	}
 	tests := []struct {
 		name      string
@@ -690,33 +860,50 @@ func TestWithHTTPClient(t *testing.T) {
 		{
 			name: "set success",
 			args: args{
-				c: http.DefaultClient,
+				n: 16,
 			},
 			checkFunc: func(opt Option) error {
 				authz := &authority{}
 				if err := opt(authz); err != nil {
 					return err
 				}
-				if !reflect.DeepEqual(authz.client, http.DefaultClient) {
+				if authz.policyFetchConcurrency != 16 {
 					return fmt.Errorf("invalid param was set")
 				}
 				return nil
 			},
 		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithPolicyFetchConcurrency(tt.args.n)
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithPolicyFetchConcurrency() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestWithPolicyRefreshSpread(t *testing.T) {
+	type args struct {
+		t string
+	}
+	tests := []struct {
+		name      string
+		args      args
+		checkFunc func(Option) error
+	}{
 		{
-			name: "set nil",
+			name: "set success",
 			args: args{
-				c: nil,
+				t: "5m",
 			},
 			checkFunc: func(opt Option) error {
 				authz := &authority{}
 				if err := opt(authz); err != nil {
 					return err
 				}
-				want := &http.Client{
-					Timeout: 30 * time.Second,
-				}
-				if !reflect.DeepEqual(authz.client, want) {
+				if authz.policyRefreshSpread != "5m" {
 					return fmt.Errorf("invalid param was set")
 				}
 				return nil
@@ -725,27 +912,36 @@ func TestWithHTTPClient(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := WithHTTPClient(tt.args.c)
+			got := WithPolicyRefreshSpread(tt.args.t)
 			if err := tt.checkFunc(got); err != nil {
-				t.Errorf("WithHTTPClient() error = %v", err)
+				t.Errorf("WithPolicyRefreshSpread() error = %v", err)
 			}
 		})
 	}
 }
 
-func TestWithEnableJwkd(t *testing.T) {
+func TestWithPolicyBulkSource(t *testing.T) {
+	type args struct {
+		s policy.BulkSource
+	}
 	tests := []struct {
 		name      string
+		args      args
 		checkFunc func(Option) error
 	}{
 		{
 			name: "set success",
+			args: args{
+				s: policy.BulkSourceFunc(func(context.Context, []string) ([]*policy.SignedPolicy, error) {
+					return nil, nil
+				}),
+			},
 			checkFunc: func(opt Option) error {
 				authz := &authority{}
 				if err := opt(authz); err != nil {
 					return err
 				}
-				if authz.disableJwkd != false {
+				if authz.policyBulkSource == nil {
 					return fmt.Errorf("invalid param was set")
 				}
 				return nil
@@ -754,27 +950,36 @@ func TestWithEnableJwkd(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := WithEnableJwkd()
+			got := WithPolicyBulkSource(tt.args.s)
 			if err := tt.checkFunc(got); err != nil {
-				t.Errorf("WithEnableJwkd() error = %v", err)
+				t.Errorf("WithPolicyBulkSource() error = %v", err)
 			}
 		})
 	}
 }
 
-func TestWithDisableJwkd(t *testing.T) {
+func TestWithPolicyShadowSource(t *testing.T) {
+	type args struct {
+		v policy.BulkSource
+	}
 	tests := []struct {
 		name      string
+		args      args
 		checkFunc func(Option) error
 	}{
 		{
 			name: "set success",
+			args: args{
+				v: policy.BulkSourceFunc(func(context.Context, []string) ([]*policy.SignedPolicy, error) {
+					return nil, nil
+				}),
+			},
 			checkFunc: func(opt Option) error {
 				authz := &authority{}
 				if err := opt(authz); err != nil {
 					return err
 				}
-				if authz.disableJwkd != true {
+				if authz.policyShadowSource == nil {
 					return fmt.Errorf("invalid param was set")
 				}
 				return nil
@@ -783,17 +988,17 @@ func TestWithDisableJwkd(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := WithDisableJwkd()
+			got := WithPolicyShadowSource(tt.args.v)
 			if err := tt.checkFunc(got); err != nil {
-				t.Errorf("WithDisableJwkd() error = %v", err)
+				t.Errorf("WithPolicyShadowSource() error = %v", err)
 			}
 		})
 	}
 }
 
-func TestWithJwkRefreshPeriod(t *testing.T) {
+func TestWithPolicyDryRun(t *testing.T) {
 	type args struct {
-		t string
+		v bool
 	}
 	tests := []struct {
 		name      string
@@ -803,14 +1008,14 @@ func TestWithJwkRefreshPeriod(t *testing.T) {
 		{
 			name: "set success",
 			args: args{
-				t: "dummy",
+				v: true,
 			},
 			checkFunc: func(opt Option) error {
 				authz := &authority{}
 				if err := opt(authz); err != nil {
 					return err
 				}
-				if authz.jwkRefreshPeriod != "dummy" {
+				if !authz.policyDryRun {
 					return fmt.Errorf("invalid param was set")
 				}
 				return nil
@@ -819,16 +1024,17 @@ func TestWithJwkRefreshPeriod(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := WithJwkRefreshPeriod(tt.args.t)
+			got := WithPolicyDryRun(tt.args.v)
 			if err := tt.checkFunc(got); err != nil {
-				t.Errorf("WithJwkRefreshPeriod() error = %v", err)
+				t.Errorf("WithPolicyDryRun() error = %v", err)
 			}
 		})
 	}
 }
-func TestWithJwkRetryDelay(t *testing.T) {
+
+func TestWithPolicyDryRunDomains(t *testing.T) {
 	type args struct {
-		t string
+		v []string
 	}
 	tests := []struct {
 		name      string
@@ -838,15 +1044,14 @@ func TestWithJwkRetryDelay(t *testing.T) {
 		{
 			name: "set success",
 			args: args{
-				t: "dummy",
+				v: []string{"dom1", "dom2"},
 			},
 			checkFunc: func(opt Option) error {
 				authz := &authority{}
 				if err := opt(authz); err != nil {
 					return err
 				}
-				if authz.jwkRetryDelay != "dummy" {
-
+				if !reflect.DeepEqual(authz.policyDryRunDomains, []string{"dom1", "dom2"}) {
 					return fmt.Errorf("invalid param was set")
 				}
 				return nil
@@ -855,45 +1060,556 @@ func TestWithJwkRetryDelay(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := WithJwkRetryDelay(tt.args.t)
+			got := WithPolicyDryRunDomains(tt.args.v...)
 			if err := tt.checkFunc(got); err != nil {
-				t.Errorf("WithJwkRetryDelay() error = %v", err)
+				t.Errorf("WithPolicyDryRunDomains() error = %v", err)
 			}
 		})
 	}
 }
 
-func TestNewAccessTokenParam(t *testing.T) {
+func TestWithPolicyDecisionHook(t *testing.T) {
 	type args struct {
-		enable               bool
-		verifyCertThumbprint bool
-		verifyClientID       bool
-		authorizedClientIDs  map[string][]string
-		certBackdateDur      string
-		certOffsetDur        string
+		v policy.DecisionHook
 	}
 	tests := []struct {
-		name string
-		args args
-		want AccessTokenParam
+		name      string
+		args      args
+		checkFunc func(Option) error
 	}{
 		{
-			name: "create success",
+			name: "set success",
 			args: args{
-				verifyCertThumbprint: true,
-				certBackdateDur:      "2h",
-				certOffsetDur:        "2h",
+				v: func(context.Context, policy.Decision) {},
 			},
-			want: AccessTokenParam{
-				verifyCertThumbprint: true,
-				certBackdateDur:      "2h",
-				certOffsetDur:        "2h",
+			checkFunc: func(opt Option) error {
+				authz := &authority{}
+				if err := opt(authz); err != nil {
+					return err
+				}
+				if authz.policyDecisionHook == nil {
+					return fmt.Errorf("invalid param was set")
+				}
+				return nil
 			},
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := NewAccessTokenParam(
+			got := WithPolicyDecisionHook(tt.args.v)
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithPolicyDecisionHook() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestWithCacheExp(t *testing.T) {
+	type args struct {
+		d time.Duration
+	}
+	tests := []struct {
+		name      string
+		args      args
+		checkFunc func(Option) error
+	}{
+		{
+			name: "set success",
+			args: args{
+				d: time.Duration(time.Hour * 2),
+			},
+			checkFunc: func(opt Option) error {
+				authz := &authority{
+					cache: gache.New(),
+				}
+				if err := opt(authz); err != nil {
+					return err
+				}
+				if authz.cacheExp != time.Duration(time.Hour*2) {
+					return fmt.Errorf("invalid param was set")
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithCacheExp(tt.args.d)
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithCacheExp() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestWithRoleCertURIPrefix(t *testing.T) {
+	type args struct {
+		p string
+	}
+	tests := []struct {
+		name      string
+		args      args
+		checkFunc func(Option) error
+	}{
+		{
+			name: "set success",
+			args: args{
+				p: "dummy",
+			},
+			checkFunc: func(opt Option) error {
+				authz := &authority{}
+				if err := opt(authz); err != nil {
+					return err
+				}
+				if authz.roleCertURIPrefix != "dummy" {
+					return fmt.Errorf("invalid param was set")
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithRoleCertURIPrefix(tt.args.p)
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithRoleCertURIPrefix() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestWithHTTPClient(t *testing.T) {
+	type args struct {
+		c *http.Client
+	}
+	tests := []struct {
+		name      string
+		args      args
+		checkFunc func(Option) error
+	}{
+		{
+			name: "set success",
+			args: args{
+				c: http.DefaultClient,
+			},
+			checkFunc: func(opt Option) error {
+				authz := &authority{}
+				if err := opt(authz); err != nil {
+					return err
+				}
+				if !reflect.DeepEqual(authz.client, http.DefaultClient) {
+					return fmt.Errorf("invalid param was set")
+				}
+				return nil
+			},
+		},
+		{
+			name: "set nil",
+			args: args{
+				c: nil,
+			},
+			checkFunc: func(opt Option) error {
+				authz := &authority{}
+				if err := opt(authz); err != nil {
+					return err
+				}
+				want := &http.Client{
+					Timeout: 30 * time.Second,
+				}
+				if !reflect.DeepEqual(authz.client, want) {
+					return fmt.Errorf("invalid param was set")
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithHTTPClient(tt.args.c)
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithHTTPClient() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestWithEnableJwkd(t *testing.T) {
+	tests := []struct {
+		name      string
+		checkFunc func(Option) error
+	}{
+		{
+			name: "set success",
+			checkFunc: func(opt Option) error {
+				authz := &authority{}
+				if err := opt(authz); err != nil {
+					return err
+				}
+				if authz.disableJwkd != false {
+					return fmt.Errorf("invalid param was set")
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithEnableJwkd()
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithEnableJwkd() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestWithDisableJwkd(t *testing.T) {
+	tests := []struct {
+		name      string
+		checkFunc func(Option) error
+	}{
+		{
+			name: "set success",
+			checkFunc: func(opt Option) error {
+				authz := &authority{}
+				if err := opt(authz); err != nil {
+					return err
+				}
+				if authz.disableJwkd != true {
+					return fmt.Errorf("invalid param was set")
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithDisableJwkd()
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithDisableJwkd() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestWithJwkRefreshPeriod(t *testing.T) {
+	type args struct {
+		t string
+	}
+	tests := []struct {
+		name      string
+		args      args
+		checkFunc func(Option) error
+	}{
+		{
+			name: "set success",
+			args: args{
+				t: "dummy",
+			},
+			checkFunc: func(opt Option) error {
+				authz := &authority{}
+				if err := opt(authz); err != nil {
+					return err
+				}
+				if authz.jwkRefreshPeriod != "dummy" {
+					return fmt.Errorf("invalid param was set")
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithJwkRefreshPeriod(tt.args.t)
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithJwkRefreshPeriod() error = %v", err)
+			}
+		})
+	}
+}
+func TestWithJwkRetryDelay(t *testing.T) {
+	type args struct {
+		t string
+	}
+	tests := []struct {
+		name      string
+		args      args
+		checkFunc func(Option) error
+	}{
+		{
+			name: "set success",
+			args: args{
+				t: "dummy",
+			},
+			checkFunc: func(opt Option) error {
+				authz := &authority{}
+				if err := opt(authz); err != nil {
+					return err
+				}
+				if authz.jwkRetryDelay != "dummy" {
+
+					return fmt.Errorf("invalid param was set")
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithJwkRetryDelay(tt.args.t)
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithJwkRetryDelay() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestWithJwkMinRefreshInterval(t *testing.T) {
+	type args struct {
+		t string
+	}
+	tests := []struct {
+		name      string
+		args      args
+		checkFunc func(Option) error
+	}{
+		{
+			name: "set success",
+			args: args{
+				t: "1m",
+			},
+			checkFunc: func(opt Option) error {
+				authz := &authority{}
+				if err := opt(authz); err != nil {
+					return err
+				}
+				if authz.jwkMinRefreshInterval != "1m" {
+					return fmt.Errorf("invalid param was set")
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithJwkMinRefreshInterval(tt.args.t)
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithJwkMinRefreshInterval() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestWithJwkAsyncRefreshOnMiss(t *testing.T) {
+	type args struct {
+		b bool
+	}
+	tests := []struct {
+		name      string
+		args      args
+		checkFunc func(Option) error
+	}{
+		{
+			name: "set success",
+			args: args{
+				b: true,
+			},
+			checkFunc: func(opt Option) error {
+				authz := &authority{}
+				if err := opt(authz); err != nil {
+					return err
+				}
+				if !authz.jwkAsyncRefreshOnMiss {
+					return fmt.Errorf("invalid param was set")
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithJwkAsyncRefreshOnMiss(tt.args.b)
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithJwkAsyncRefreshOnMiss() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestWithJwkURLPatterns(t *testing.T) {
+	type args struct {
+		patterns []string
+	}
+	tests := []struct {
+		name      string
+		args      args
+		checkFunc func(Option) error
+	}{
+		{
+			name: "set success",
+			args: args{
+				patterns: []string{"https://*.athenz.io/zts/v1/"},
+			},
+			checkFunc: func(opt Option) error {
+				authz := &authority{}
+				if err := opt(authz); err != nil {
+					return err
+				}
+				if !reflect.DeepEqual(authz.jwkURLPatterns, []string{"https://*.athenz.io/zts/v1/"}) {
+					return fmt.Errorf("invalid param was set")
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithJwkURLPatterns(tt.args.patterns)
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithJwkURLPatterns() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestWithJwkMaxDynamicURLs(t *testing.T) {
+	type args struct {
+		n int
+	}
+	tests := []struct {
+		name      string
+		args      args
+		checkFunc func(Option) error
+	}{
+		{
+			name: "set success",
+			args: args{
+				n: 10,
+			},
+			checkFunc: func(opt Option) error {
+				authz := &authority{}
+				if err := opt(authz); err != nil {
+					return err
+				}
+				if authz.jwkMaxDynamicURLs != 10 {
+					return fmt.Errorf("invalid param was set")
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithJwkMaxDynamicURLs(tt.args.n)
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithJwkMaxDynamicURLs() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestWithJwkDynamicURLFetchInterval(t *testing.T) {
+	type args struct {
+		t string
+	}
+	tests := []struct {
+		name      string
+		args      args
+		checkFunc func(Option) error
+	}{
+		{
+			name: "set success",
+			args: args{
+				t: "1m",
+			},
+			checkFunc: func(opt Option) error {
+				authz := &authority{}
+				if err := opt(authz); err != nil {
+					return err
+				}
+				if authz.jwkDynamicURLFetchInterval != "1m" {
+					return fmt.Errorf("invalid param was set")
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithJwkDynamicURLFetchInterval(tt.args.t)
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithJwkDynamicURLFetchInterval() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestWithJwkFetchConcurrency(t *testing.T) {
+	type args struct {
+		n int
+	}
+	tests := []struct {
+		name      string
+		args      args
+		checkFunc func(Option) error
+	}{
+		{
+			name: "set success",
+			args: args{
+				n: 8,
+			},
+			checkFunc: func(opt Option) error {
+				authz := &authority{}
+				if err := opt(authz); err != nil {
+					return err
+				}
+				if authz.jwkFetchConcurrency != 8 {
+					return fmt.Errorf("invalid param was set")
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithJwkFetchConcurrency(tt.args.n)
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithJwkFetchConcurrency() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestNewAccessTokenParam(t *testing.T) {
+	type args struct {
+		enable               bool
+		verifyCertThumbprint bool
+		verifyClientID       bool
+		authorizedClientIDs  map[string][]string
+		certBackdateDur      string
+		certOffsetDur        string
+	}
+	tests := []struct {
+		name string
+		args args
+		want AccessTokenParam
+	}{
+		{
+			name: "create success",
+			args: args{
+				verifyCertThumbprint: true,
+				certBackdateDur:      "2h",
+				certOffsetDur:        "2h",
+			},
+			want: AccessTokenParam{
+				verifyCertThumbprint: true,
+				certBackdateDur:      "2h",
+				certOffsetDur:        "2h",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewAccessTokenParam(
 				tt.args.enable,
 				tt.args.verifyCertThumbprint,
 				tt.args.certBackdateDur,
@@ -907,52 +1623,707 @@ func TestNewAccessTokenParam(t *testing.T) {
 	}
 }
 
-func TestWithAccessTokenParams(t *testing.T) {
+func TestWithAccessTokenParams(t *testing.T) {
+	type args struct {
+		accessTokenParam AccessTokenParam
+	}
+	type test struct {
+		name      string
+		args      args
+		checkFunc func(Option) error
+	}
+	tests := []test{
+		func() test {
+			accessTokenParam :=
+				NewAccessTokenParam(true, true, "1h", "1h", true, map[string][]string{
+					"common_name1": {"client_id1", "client_id2"},
+					"common_name2": {"client_id1", "client_id2"},
+				})
+
+			return test{
+				name: "set success",
+				args: args{
+					accessTokenParam: accessTokenParam,
+				},
+				checkFunc: func(opt Option) error {
+					authz := &authority{}
+					if err := opt(authz); err != nil {
+						return err
+					}
+					if !reflect.DeepEqual(authz.accessTokenParam, accessTokenParam) {
+						return fmt.Errorf("invalid param was set")
+					}
+					return nil
+				},
+			}
+		}(),
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithAccessTokenParam(tt.args.accessTokenParam)
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithAccessTokenParam() = %v error: %v", got, err)
+			}
+		})
+	}
+}
+
+func TestWithAccessTokenExtractors(t *testing.T) {
+	type args struct {
+		extractors []CredentialExtractor
+	}
+	tests := []struct {
+		name      string
+		args      args
+		checkFunc func(Option) error
+	}{
+		{
+			name: "set success",
+			args: args{
+				extractors: []CredentialExtractor{
+					HeaderExtractor("dummy"),
+					CookieExtractor("dummy"),
+				},
+			},
+			checkFunc: func(opt Option) error {
+				authz := &authority{}
+				if err := opt(authz); err != nil {
+					return err
+				}
+				if len(authz.accessTokenExtractors) != 2 {
+					return fmt.Errorf("invalid param was set")
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithAccessTokenExtractors(tt.args.extractors...)
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithAccessTokenExtractors() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestWithAccessTokenIssuers(t *testing.T) {
+	type args struct {
+		issuers []string
+	}
+	tests := []struct {
+		name      string
+		args      args
+		checkFunc func(Option) error
+	}{
+		{
+			name: "set success",
+			args: args{
+				issuers: []string{"https://zts.athenz.io"},
+			},
+			checkFunc: func(opt Option) error {
+				authz := &authority{}
+				if err := opt(authz); err != nil {
+					return err
+				}
+				if !reflect.DeepEqual(authz.accessTokenIssuers, []string{"https://zts.athenz.io"}) {
+					return fmt.Errorf("invalid param was set")
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithAccessTokenIssuers(tt.args.issuers...)
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithAccessTokenIssuers() = %v, error %v", got, err)
+			}
+		})
+	}
+}
+
+func TestWithAccessTokenJWKSetIssuers(t *testing.T) {
+	type args struct {
+		m map[string][]string
+	}
+	tests := []struct {
+		name      string
+		args      args
+		checkFunc func(Option) error
+	}{
+		{
+			name: "set success",
+			args: args{
+				m: map[string][]string{
+					"https://zts.athenz.io/oauth2/keys": {"https://zts.athenz.io"},
+				},
+			},
+			checkFunc: func(opt Option) error {
+				authz := &authority{}
+				if err := opt(authz); err != nil {
+					return err
+				}
+				want := map[string][]string{
+					"https://zts.athenz.io/oauth2/keys": {"https://zts.athenz.io"},
+				}
+				if !reflect.DeepEqual(authz.accessTokenJWKSetIssuers, want) {
+					return fmt.Errorf("invalid param was set")
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithAccessTokenJWKSetIssuers(tt.args.m)
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithAccessTokenJWKSetIssuers() = %v, error %v", got, err)
+			}
+		})
+	}
+}
+
+func TestWithAccessTokenVerifyAudience(t *testing.T) {
+	type args struct {
+		b bool
+	}
+	tests := []struct {
+		name      string
+		args      args
+		checkFunc func(Option) error
+	}{
+		{
+			name: "set success",
+			args: args{
+				b: true,
+			},
+			checkFunc: func(opt Option) error {
+				authz := &authority{}
+				if err := opt(authz); err != nil {
+					return err
+				}
+				if !authz.accessTokenVerifyAudience {
+					return fmt.Errorf("invalid param was set")
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithAccessTokenVerifyAudience(tt.args.b)
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithAccessTokenVerifyAudience() = %v, error %v", got, err)
+			}
+		})
+	}
+}
+
+func TestWithAccessTokenClockSkew(t *testing.T) {
+	type args struct {
+		t string
+	}
+	tests := []struct {
+		name      string
+		args      args
+		checkFunc func(Option) error
+	}{
+		{
+			name: "set success",
+			args: args{
+				t: "1m",
+			},
+			checkFunc: func(opt Option) error {
+				authz := &authority{}
+				if err := opt(authz); err != nil {
+					return err
+				}
+				if authz.accessTokenClockSkew != "1m" {
+					return fmt.Errorf("invalid param was set")
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithAccessTokenClockSkew(tt.args.t)
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithAccessTokenClockSkew() = %v, error %v", got, err)
+			}
+		})
+	}
+}
+
+func TestWithAccessTokenAllowedAlgorithms(t *testing.T) {
+	type args struct {
+		algs []string
+	}
+	tests := []struct {
+		name      string
+		args      args
+		checkFunc func(Option) error
+	}{
+		{
+			name: "set success",
+			args: args{
+				algs: []string{"RS256", "ES256"},
+			},
+			checkFunc: func(opt Option) error {
+				authz := &authority{}
+				if err := opt(authz); err != nil {
+					return err
+				}
+				if !reflect.DeepEqual(authz.accessTokenAlgorithms, []string{"RS256", "ES256"}) {
+					return fmt.Errorf("invalid param was set")
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithAccessTokenAllowedAlgorithms(tt.args.algs...)
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithAccessTokenAllowedAlgorithms() = %v, error %v", got, err)
+			}
+		})
+	}
+}
+
+func TestWithAccessTokenJWKRefreshOnMiss(t *testing.T) {
+	type args struct {
+		b bool
+	}
+	tests := []struct {
+		name      string
+		args      args
+		checkFunc func(Option) error
+	}{
+		{
+			name: "set success",
+			args: args{
+				b: true,
+			},
+			checkFunc: func(opt Option) error {
+				authz := &authority{}
+				if err := opt(authz); err != nil {
+					return err
+				}
+				if !authz.accessTokenJWKRefreshOnMiss {
+					return fmt.Errorf("invalid param was set")
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithAccessTokenJWKRefreshOnMiss(tt.args.b)
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithAccessTokenJWKRefreshOnMiss() = %v, error %v", got, err)
+			}
+		})
+	}
+}
+
+func TestWithAccessTokenIntrospectionURL(t *testing.T) {
+	type args struct {
+		t string
+	}
+	tests := []struct {
+		name      string
+		args      args
+		checkFunc func(Option) error
+	}{
+		{
+			name: "set success",
+			args: args{
+				t: "https://zts.athenz.io/oauth2/introspect",
+			},
+			checkFunc: func(opt Option) error {
+				authz := &authority{}
+				if err := opt(authz); err != nil {
+					return err
+				}
+				if authz.accessTokenIntrospectionURL != "https://zts.athenz.io/oauth2/introspect" {
+					return fmt.Errorf("invalid param was set")
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithAccessTokenIntrospectionURL(tt.args.t)
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithAccessTokenIntrospectionURL() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestWithAccessTokenRequestTimeout(t *testing.T) {
+	type args struct {
+		t string
+	}
+	tests := []struct {
+		name      string
+		args      args
+		checkFunc func(Option) error
+	}{
+		{
+			name: "set success",
+			args: args{
+				t: "1s",
+			},
+			checkFunc: func(opt Option) error {
+				authz := &authority{}
+				if err := opt(authz); err != nil {
+					return err
+				}
+				if authz.accessTokenRequestTimeout != "1s" {
+					return fmt.Errorf("invalid param was set")
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithAccessTokenRequestTimeout(tt.args.t)
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithAccessTokenRequestTimeout() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestWithAccessTokenVerifyType(t *testing.T) {
+	type args struct {
+		b bool
+	}
+	tests := []struct {
+		name      string
+		args      args
+		checkFunc func(Option) error
+	}{
+		{
+			name: "set success",
+			args: args{
+				b: true,
+			},
+			checkFunc: func(opt Option) error {
+				authz := &authority{}
+				if err := opt(authz); err != nil {
+					return err
+				}
+				if !authz.accessTokenVerifyType {
+					return fmt.Errorf("invalid param was set")
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithAccessTokenVerifyType(tt.args.b)
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithAccessTokenVerifyType() = %v, error %v", got, err)
+			}
+		})
+	}
+}
+
+func TestWithEnableRoleToken(t *testing.T) {
+	type test struct {
+		name      string
+		checkFunc func(Option) error
+	}
+	tests := []test{
+		func() test {
+			return test{
+				name: "set success",
+				checkFunc: func(opt Option) error {
+					authz := &authority{}
+					if err := opt(authz); err != nil {
+						return err
+					}
+					if authz.enableRoleToken != true {
+						return fmt.Errorf("invalid param was set")
+					}
+					return nil
+				},
+			}
+		}(),
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithEnableRoleToken()
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithEnableRoleToken() = %v error: %v", got, err)
+			}
+		})
+	}
+}
+
+func TestWithDisableRoleToken(t *testing.T) {
+	type test struct {
+		name      string
+		checkFunc func(Option) error
+	}
+	tests := []test{
+		func() test {
+			return test{
+				name: "set success",
+				checkFunc: func(opt Option) error {
+					authz := &authority{}
+					if err := opt(authz); err != nil {
+						return err
+					}
+					if authz.enableRoleToken != false {
+						return fmt.Errorf("invalid param was set")
+					}
+					return nil
+				},
+			}
+		}(),
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithDisableRoleToken()
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithDisableRoleToken() = %v error: %v", got, err)
+			}
+		})
+	}
+}
+
+func TestWithRoleAuthHeader(t *testing.T) {
+	type args struct {
+		h string
+	}
+	type test struct {
+		name      string
+		args      args
+		checkFunc func(Option) error
+	}
+	tests := []test{
+		func() test {
+			header := "TEST-HEADER"
+			return test{
+				name: "set success",
+				args: args{
+					h: header,
+				},
+				checkFunc: func(opt Option) error {
+					authz := &authority{}
+					if err := opt(authz); err != nil {
+						return err
+					}
+					if !reflect.DeepEqual(authz.roleAuthHeader, header) {
+						return fmt.Errorf("invalid param was set")
+					}
+					return nil
+				},
+			}
+		}(),
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithRoleAuthHeader(tt.args.h)
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithRoleAuthHeader() = %v error: %v", got, err)
+			}
+		})
+	}
+}
+
+func TestWithRoleTokenExtractors(t *testing.T) {
+	type args struct {
+		extractors []CredentialExtractor
+	}
+	tests := []struct {
+		name      string
+		args      args
+		checkFunc func(Option) error
+	}{
+		{
+			name: "set success",
+			args: args{
+				extractors: []CredentialExtractor{
+					HeaderExtractor("dummy"),
+					CookieExtractor("dummy"),
+				},
+			},
+			checkFunc: func(opt Option) error {
+				authz := &authority{}
+				if err := opt(authz); err != nil {
+					return err
+				}
+				if len(authz.roleTokenExtractors) != 2 {
+					return fmt.Errorf("invalid param was set")
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithRoleTokenExtractors(tt.args.extractors...)
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithRoleTokenExtractors() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestWithRoleTokenVerifyIP(t *testing.T) {
+	type args struct {
+		b bool
+	}
+	tests := []struct {
+		name      string
+		args      args
+		checkFunc func(Option) error
+	}{
+		{
+			name: "set success",
+			args: args{
+				b: true,
+			},
+			checkFunc: func(opt Option) error {
+				authz := &authority{}
+				if err := opt(authz); err != nil {
+					return err
+				}
+				if !authz.roleTokenVerifyIP {
+					return fmt.Errorf("invalid param was set")
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithRoleTokenVerifyIP(tt.args.b)
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithRoleTokenVerifyIP() = %v, error %v", got, err)
+			}
+		})
+	}
+}
+
+func TestWithRoleTokenAuthorizedProxyUsers(t *testing.T) {
+	type args struct {
+		users []string
+	}
+	tests := []struct {
+		name      string
+		args      args
+		checkFunc func(Option) error
+	}{
+		{
+			name: "set success",
+			args: args{
+				users: []string{"proxy.service"},
+			},
+			checkFunc: func(opt Option) error {
+				authz := &authority{}
+				if err := opt(authz); err != nil {
+					return err
+				}
+				if !reflect.DeepEqual(authz.roleTokenAuthorizedProxyUsers, []string{"proxy.service"}) {
+					return fmt.Errorf("invalid param was set")
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithRoleTokenAuthorizedProxyUsers(tt.args.users...)
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithRoleTokenAuthorizedProxyUsers() = %v, error %v", got, err)
+			}
+		})
+	}
+}
+
+func TestWithRoleTokenClockSkew(t *testing.T) {
+	type args struct {
+		t string
+	}
+	tests := []struct {
+		name      string
+		args      args
+		checkFunc func(Option) error
+	}{
+		{
+			name: "set success",
+			args: args{
+				t: "1m",
+			},
+			checkFunc: func(opt Option) error {
+				authz := &authority{}
+				if err := opt(authz); err != nil {
+					return err
+				}
+				if authz.roleTokenClockSkew != "1m" {
+					return fmt.Errorf("invalid param was set")
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithRoleTokenClockSkew(tt.args.t)
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithRoleTokenClockSkew() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestWithRoleTokenMaxLifetime(t *testing.T) {
 	type args struct {
-		accessTokenParam AccessTokenParam
+		t string
 	}
-	type test struct {
+	tests := []struct {
 		name      string
 		args      args
 		checkFunc func(Option) error
-	}
-	tests := []test{
-		func() test {
-			accessTokenParam :=
-				NewAccessTokenParam(true, true, "1h", "1h", true, map[string][]string{
-					"common_name1": {"client_id1", "client_id2"},
-					"common_name2": {"client_id1", "client_id2"},
-				})
-
-			return test{
-				name: "set success",
-				args: args{
-					accessTokenParam: accessTokenParam,
-				},
-				checkFunc: func(opt Option) error {
-					authz := &authority{}
-					if err := opt(authz); err != nil {
-						return err
-					}
-					if !reflect.DeepEqual(authz.accessTokenParam, accessTokenParam) {
-						return fmt.Errorf("invalid param was set")
-					}
-					return nil
-				},
-			}
-		}(),
+	}{
+		{
+			name: "set success",
+			args: args{
+				t: "720h",
+			},
+			checkFunc: func(opt Option) error {
+				authz := &authority{}
+				if err := opt(authz); err != nil {
+					return err
+				}
+				if authz.roleTokenMaxLifetime != "720h" {
+					return fmt.Errorf("invalid param was set")
+				}
+				return nil
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := WithAccessTokenParam(tt.args.accessTokenParam)
+			got := WithRoleTokenMaxLifetime(tt.args.t)
 			if err := tt.checkFunc(got); err != nil {
-				t.Errorf("WithAccessTokenParam() = %v error: %v", got, err)
+				t.Errorf("WithRoleTokenMaxLifetime() error = %v", err)
 			}
 		})
 	}
 }
 
-func TestWithEnableRoleToken(t *testing.T) {
+func TestWithEnableRoleCert(t *testing.T) {
 	type test struct {
 		name      string
 		checkFunc func(Option) error
@@ -966,7 +2337,7 @@ func TestWithEnableRoleToken(t *testing.T) {
 					if err := opt(authz); err != nil {
 						return err
 					}
-					if authz.enableRoleToken != true {
+					if authz.enableRoleCert != true {
 						return fmt.Errorf("invalid param was set")
 					}
 					return nil
@@ -976,15 +2347,15 @@ func TestWithEnableRoleToken(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := WithEnableRoleToken()
+			got := WithEnableRoleCert()
 			if err := tt.checkFunc(got); err != nil {
-				t.Errorf("WithEnableRoleToken() = %v error: %v", got, err)
+				t.Errorf("WithEnableRoleCert() = %v error: %v", got, err)
 			}
 		})
 	}
 }
 
-func TestWithDisableRoleToken(t *testing.T) {
+func TestWithDisableRoleCert(t *testing.T) {
 	type test struct {
 		name      string
 		checkFunc func(Option) error
@@ -998,7 +2369,7 @@ func TestWithDisableRoleToken(t *testing.T) {
 					if err := opt(authz); err != nil {
 						return err
 					}
-					if authz.enableRoleToken != false {
+					if authz.enableRoleCert != false {
 						return fmt.Errorf("invalid param was set")
 					}
 					return nil
@@ -1008,121 +2379,203 @@ func TestWithDisableRoleToken(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := WithDisableRoleToken()
+			got := WithDisableRoleCert()
 			if err := tt.checkFunc(got); err != nil {
-				t.Errorf("WithDisableRoleToken() = %v error: %v", got, err)
+				t.Errorf("WithDisableRoleCert() = %v error: %v", got, err)
 			}
 		})
 	}
 }
 
-func TestWithRoleAuthHeader(t *testing.T) {
+func TestWithJwkURLs(t *testing.T) {
 	type args struct {
-		h string
+		urls []string
 	}
-	type test struct {
+	tests := []struct {
 		name      string
 		args      args
 		checkFunc func(Option) error
-	}
-	tests := []test{
-		func() test {
-			header := "TEST-HEADER"
-			return test{
-				name: "set success",
-				args: args{
-					h: header,
-				},
-				checkFunc: func(opt Option) error {
-					authz := &authority{}
-					if err := opt(authz); err != nil {
-						return err
-					}
-					if !reflect.DeepEqual(authz.roleAuthHeader, header) {
-						return fmt.Errorf("invalid param was set")
-					}
-					return nil
-				},
-			}
-		}(),
+	}{
+		{
+			name: "set success",
+			args: args{
+				urls: []string{"url1", "url2"},
+			},
+			checkFunc: func(opt Option) error {
+				authz := &authority{}
+				if err := opt(authz); err != nil {
+					return err
+				}
+				if !reflect.DeepEqual(authz.jwkURLs, []string{"url1", "url2"}) {
+					return fmt.Errorf("invalid param was set")
+				}
+				return nil
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := WithRoleAuthHeader(tt.args.h)
+			got := WithJwkURLs(tt.args.urls)
 			if err := tt.checkFunc(got); err != nil {
-				t.Errorf("WithRoleAuthHeader() = %v error: %v", got, err)
+				t.Errorf("WithJwkURLs() = %v, error %v", got, err)
 			}
 		})
 	}
 }
 
-func TestWithEnableRoleCert(t *testing.T) {
-	type test struct {
+func TestWithRoleCertCAPool(t *testing.T) {
+	type args struct {
+		v *x509.CertPool
+	}
+	tests := []struct {
 		name      string
+		args      args
 		checkFunc func(Option) error
-	}
-	tests := []test{
-		func() test {
-			return test{
-				name: "set success",
-				checkFunc: func(opt Option) error {
-					authz := &authority{}
-					if err := opt(authz); err != nil {
-						return err
-					}
-					if authz.enableRoleCert != true {
-						return fmt.Errorf("invalid param was set")
-					}
-					return nil
-				},
-			}
-		}(),
+	}{
+		{
+			name: "set success",
+			args: args{
+				v: x509.NewCertPool(),
+			},
+			checkFunc: func(opt Option) error {
+				authz := &authority{}
+				if err := opt(authz); err != nil {
+					return err
+				}
+				if authz.roleCertCAPool == nil {
+					return fmt.Errorf("invalid param was set")
+				}
+				return nil
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := WithEnableRoleCert()
+			got := WithRoleCertCAPool(tt.args.v)
 			if err := tt.checkFunc(got); err != nil {
-				t.Errorf("WithEnableRoleCert() = %v error: %v", got, err)
+				t.Errorf("WithRoleCertCAPool() error = %v", err)
 			}
 		})
 	}
 }
 
-func TestWithDisableRoleCert(t *testing.T) {
-	type test struct {
+func TestWithRoleCertCAPath(t *testing.T) {
+	type args struct {
+		path string
+	}
+	tests := []struct {
 		name      string
+		args      args
 		checkFunc func(Option) error
+	}{
+		{
+			name: "set success",
+			args: args{
+				path: "./test/data/dummy_CA.pem",
+			},
+			checkFunc: func(opt Option) error {
+				authz := &authority{}
+				if err := opt(authz); err != nil {
+					return err
+				}
+				if authz.roleCertCAPool == nil {
+					return fmt.Errorf("invalid param was set")
+				}
+				return nil
+			},
+		},
+		{
+			name: "empty path",
+			args: args{
+				path: "",
+			},
+			checkFunc: func(opt Option) error {
+				authz := &authority{}
+				if err := opt(authz); err != nil {
+					return err
+				}
+				if authz.roleCertCAPool != nil {
+					return fmt.Errorf("invalid param was set")
+				}
+				return nil
+			},
+		},
+		{
+			name: "file not found",
+			args: args{
+				path: "./test/data/not_exist.pem",
+			},
+			checkFunc: func(opt Option) error {
+				authz := &authority{}
+				if err := opt(authz); err == nil {
+					return fmt.Errorf("no error returned")
+				}
+				return nil
+			},
+		},
+		{
+			name: "invalid certificate",
+			args: args{
+				path: "./test/data/invalid_dummy_CA.pem",
+			},
+			checkFunc: func(opt Option) error {
+				authz := &authority{}
+				if err := opt(authz); err == nil {
+					return fmt.Errorf("no error returned")
+				}
+				return nil
+			},
+		},
 	}
-	tests := []test{
-		func() test {
-			return test{
-				name: "set success",
-				checkFunc: func(opt Option) error {
-					authz := &authority{}
-					if err := opt(authz); err != nil {
-						return err
-					}
-					if authz.enableRoleCert != false {
-						return fmt.Errorf("invalid param was set")
-					}
-					return nil
-				},
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithRoleCertCAPath(tt.args.path)
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithRoleCertCAPath() error = %v", err)
 			}
-		}(),
+		})
+	}
+}
+
+func TestWithRoleCertVerifyExpiry(t *testing.T) {
+	type args struct {
+		v bool
+	}
+	tests := []struct {
+		name      string
+		args      args
+		checkFunc func(Option) error
+	}{
+		{
+			name: "set success",
+			args: args{
+				v: true,
+			},
+			checkFunc: func(opt Option) error {
+				authz := &authority{}
+				if err := opt(authz); err != nil {
+					return err
+				}
+				if !authz.roleCertVerifyExpiry {
+					return fmt.Errorf("invalid param was set")
+				}
+				return nil
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := WithDisableRoleCert()
+			got := WithRoleCertVerifyExpiry(tt.args.v)
 			if err := tt.checkFunc(got); err != nil {
-				t.Errorf("WithDisableRoleCert() = %v error: %v", got, err)
+				t.Errorf("WithRoleCertVerifyExpiry() error = %v", err)
 			}
 		})
 	}
 }
 
-func TestWithJwkURLs(t *testing.T) {
+func TestWithRoleCertIssuers(t *testing.T) {
 	type args struct {
-		urls []string
+		v []string
 	}
 	tests := []struct {
 		name      string
@@ -1132,14 +2585,14 @@ func TestWithJwkURLs(t *testing.T) {
 		{
 			name: "set success",
 			args: args{
-				urls: []string{"url1", "url2"},
+				v: []string{"CN=dummy"},
 			},
 			checkFunc: func(opt Option) error {
 				authz := &authority{}
 				if err := opt(authz); err != nil {
 					return err
 				}
-				if !reflect.DeepEqual(authz.jwkURLs, []string{"url1", "url2"}) {
+				if !reflect.DeepEqual(authz.roleCertIssuers, []string{"CN=dummy"}) {
 					return fmt.Errorf("invalid param was set")
 				}
 				return nil
@@ -1148,9 +2601,9 @@ func TestWithJwkURLs(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := WithJwkURLs(tt.args.urls)
+			got := WithRoleCertIssuers(tt.args.v...)
 			if err := tt.checkFunc(got); err != nil {
-				t.Errorf("WithJwkURLs() = %v, error %v", got, err)
+				t.Errorf("WithRoleCertIssuers() error = %v", err)
 			}
 		})
 	}
@@ -0,0 +1,146 @@
+/*
+Copyright (C)  2018 Yahoo Japan Corporation Athenz team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/kpango/glg"
+	"github.com/pkg/errors"
+)
+
+// BulkSource represents a source of the signed policies of multiple domains, used instead of fetching each domain from ZTS.
+// The bundle is a JSON array of the signed policies, in the same format as the "signed_policy_data" API of ZTS.
+type BulkSource interface {
+	// Fetch returns the signed policies of the domains. The policies are not verified yet.
+	// The domains not in the source are omitted, and fetched from ZTS instead.
+	Fetch(ctx context.Context, domains []string) ([]*SignedPolicy, error)
+}
+
+// BulkSourceFunc is an adapter to use the function as a BulkSource.
+type BulkSourceFunc func(ctx context.Context, domains []string) ([]*SignedPolicy, error)
+
+// Fetch calls f(ctx, domains).
+func (f BulkSourceFunc) Fetch(ctx context.Context, domains []string) ([]*SignedPolicy, error) {
+	return f(ctx, domains)
+}
+
+// NewFileBulkSource returns a BulkSource of the local bundle file, e.g. the file written by the policy sync job.
+// The file is read on each fetch.
+func NewFileBulkSource(path string) BulkSource {
+	return BulkSourceFunc(func(ctx context.Context, domains []string) ([]*SignedPolicy, error) {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, errors.Wrap(err, "error opening policy bundle file")
+		}
+		defer f.Close()
+		return decodeBundle(f)
+	})
+}
+
+// NewHTTPBulkSource returns a BulkSource of the HTTP endpoint returning the bundle of the domains.
+// The domains are sent as the repeated "domain" query parameters, e.g. "https://policy.example.com/bundle?domain=dom1&domain=dom2".
+func NewHTTPBulkSource(url string, client *http.Client) BulkSource {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return BulkSourceFunc(func(ctx context.Context, domains []string) ([]*SignedPolicy, error) {
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			return nil, errors.Wrap(err, "create fetch policy bundle request fail")
+		}
+		q := req.URL.Query()
+		for _, d := range domains {
+			q.Add("domain", d)
+		}
+		req.URL.RawQuery = q.Encode()
+
+		glg.Debugf("will fetch policy bundle from url: %s", req.URL)
+		res, err := client.Do(req.WithContext(ctx))
+		if err != nil {
+			return nil, errors.Wrap(err, "fetch policy bundle HTTP request fail")
+		}
+		defer func() {
+			if err := flushAndClose(res.Body); err != nil {
+				glg.Warn(errors.Wrap(err, "close Response.Body fail"))
+			}
+		}()
+		if res.StatusCode != http.StatusOK {
+			return nil, errors.Wrapf(ErrFetchPolicy, "fetch policy bundle HTTP response != 200 OK, status: %d", res.StatusCode)
+		}
+		return decodeBundle(res.Body)
+	})
+}
+
+// decodeBundle decodes the JSON array of the signed policies.
+func decodeBundle(r io.Reader) ([]*SignedPolicy, error) {
+	var sps []*SignedPolicy
+	if err := json.NewDecoder(r).Decode(&sps); err != nil {
+		return nil, errors.Wrap(err, "policy bundle decode fail")
+	}
+	return sps, nil
+}
+
+// fetchBulk fetches the policies of the domains from the bulk source, and verifies each policy independently.
+// The invalid policies and the policies of the other domains are dropped, so that the domains are fetched from ZTS instead.
+func (p *policyd) fetchBulk(ctx context.Context, domains []string) map[string]*SignedPolicy {
+	return p.fetchFrom(ctx, p.bulkSource, domains)
+}
+
+// fetchFrom fetches the policies of the domains from the source, and returns the verified policies keyed by the domain.
+func (p *policyd) fetchFrom(ctx context.Context, s BulkSource, domains []string) map[string]*SignedPolicy {
+	if s == nil {
+		return nil
+	}
+	sps, err := s.Fetch(ctx, domains)
+	if err != nil {
+		glg.Errorf("fetch policy bundle fail, will fetch each domain instead, error: %v", err)
+		return nil
+	}
+
+	want := make(map[string]struct{}, len(domains))
+	for _, d := range domains {
+		want[d] = struct{}{}
+	}
+	bulk := make(map[string]*SignedPolicy, len(domains))
+	for _, sp := range sps {
+		if sp == nil {
+			continue
+		}
+		if err := sp.Verify(p.pkp); err != nil {
+			glg.Errorf("invalid policy in bundle, error: %v", err)
+			continue
+		}
+		if sp.SignedPolicyData.PolicyData == nil {
+			glg.Errorf("invalid policy in bundle, error: no policy data")
+			continue
+		}
+		// the domain is signed by ZMS, so it can be trusted after the verification
+		domain := sp.SignedPolicyData.PolicyData.Domain
+		if _, ok := want[domain]; !ok {
+			glg.Debugf("skip policy in bundle of the untracked domain: %s", domain)
+			continue
+		}
+		bulk[domain] = sp
+	}
+	glg.Debugf("fetched policy bundle, domains: %d/%d", len(bulk), len(domains))
+	return bulk
+}
@@ -0,0 +1,322 @@
+/*
+Copyright (C)  2018 Yahoo Japan Corporation Athenz team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ardielle/ardielle-go/rdl"
+	"github.com/kpango/fastime"
+	"github.com/kpango/gache"
+	authcore "github.com/yahoo/athenz/libs/go/zmssvctoken"
+	"github.com/yahoo/athenz/utils/zpe-updater/util"
+	"github.com/yahoojapan/athenz-authorizer/v5/pubkey"
+)
+
+func createBulkSignedPolicy(domain, signature string) *SignedPolicy {
+	return &SignedPolicy{
+		util.DomainSignedPolicyData{
+			SignedPolicyData: &util.SignedPolicyData{
+				Expires: &rdl.Timestamp{Time: fastime.Now().Add(time.Hour).UTC()},
+				PolicyData: &util.PolicyData{
+					Domain: domain,
+					Policies: []*util.Policy{
+						{
+							Assertions: []*util.Assertion{
+								{
+									Role:     domain + ":role.dummyRole",
+									Effect:   "ALLOW",
+									Action:   "dummyAct",
+									Resource: domain + ":dummyRes",
+								},
+							},
+						},
+					},
+				},
+				ZmsSignature: signature,
+			},
+			Signature: signature,
+		},
+	}
+}
+
+func bulkDomains(sps []*SignedPolicy) []string {
+	ds := make([]string, 0, len(sps))
+	for _, sp := range sps {
+		ds = append(ds, sp.SignedPolicyData.PolicyData.Domain)
+	}
+	return ds
+}
+
+func TestNewFileBulkSource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bulk_source")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	bundle, err := json.Marshal([]*SignedPolicy{
+		createBulkSignedPolicy("dummyDom1", "sig"),
+		createBulkSignedPolicy("dummyDom2", "sig"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid := filepath.Join(dir, "valid.json")
+	if err := ioutil.WriteFile(valid, bundle, 0600); err != nil {
+		t.Fatal(err)
+	}
+	invalid := filepath.Join(dir, "invalid.json")
+	if err := ioutil.WriteFile(invalid, []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "read bundle file success",
+			path: valid,
+			want: []string{"dummyDom1", "dummyDom2"},
+		},
+		{
+			name:    "bundle file not exists",
+			path:    filepath.Join(dir, "notexists.json"),
+			wantErr: true,
+		},
+		{
+			name:    "invalid bundle file",
+			path:    invalid,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewFileBulkSource(tt.path).Fetch(context.Background(), []string{"dummyDom1"})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Fetch() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if ds := bulkDomains(got); !reflect.DeepEqual(ds, tt.want) {
+				t.Errorf("Fetch() domains = %v, want %v", ds, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewHTTPBulkSource(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		domains []string
+		want    []string
+		wantErr string
+	}{
+		{
+			name: "fetch bundle success",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				sps := make([]*SignedPolicy, 0)
+				for _, d := range r.URL.Query()["domain"] {
+					sps = append(sps, createBulkSignedPolicy(d, "sig"))
+				}
+				w.WriteHeader(http.StatusOK)
+				json.NewEncoder(w).Encode(sps)
+			},
+			domains: []string{"dummyDom1", "dummyDom2"},
+			want:    []string{"dummyDom1", "dummyDom2"},
+		},
+		{
+			name: "fetch bundle fail, status not OK",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusInternalServerError)
+			},
+			domains: []string{"dummyDom1"},
+			wantErr: "fetch policy bundle HTTP response != 200 OK, status: 500: Error fetching athenz policy",
+		},
+		{
+			name: "fetch bundle fail, invalid body",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte("{"))
+			},
+			domains: []string{"dummyDom1"},
+			wantErr: "policy bundle decode fail: unexpected EOF",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(tt.handler)
+			defer srv.Close()
+
+			got, err := NewHTTPBulkSource(srv.URL, srv.Client()).Fetch(context.Background(), tt.domains)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("Fetch() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("Fetch() unexpected error = %v", err)
+				return
+			}
+			if ds := bulkDomains(got); !reflect.DeepEqual(ds, tt.want) {
+				t.Errorf("Fetch() domains = %v, want %v", ds, tt.want)
+			}
+		})
+	}
+}
+
+func Test_policyd_fetchBulk(t *testing.T) {
+	pkp := func(pubkey.AthenzEnv, string) authcore.Verifier {
+		return VerifierMock{
+			VerifyFunc: func(d, s string) error {
+				if s != "sig" {
+					return fmt.Errorf("invalid signature")
+				}
+				return nil
+			},
+		}
+	}
+	tests := []struct {
+		name       string
+		bulkSource BulkSource
+		domains    []string
+		want       []string
+	}{
+		{
+			name:    "no bulk source",
+			domains: []string{"dummyDom1"},
+			want:    nil,
+		},
+		{
+			name: "fetch bulk success",
+			bulkSource: BulkSourceFunc(func(context.Context, []string) ([]*SignedPolicy, error) {
+				return []*SignedPolicy{
+					createBulkSignedPolicy("dummyDom1", "sig"),
+					createBulkSignedPolicy("dummyDom2", "sig"),
+				}, nil
+			}),
+			domains: []string{"dummyDom1", "dummyDom2"},
+			want:    []string{"dummyDom1", "dummyDom2"},
+		},
+		{
+			name: "drop the policy with invalid signature and the untracked domain",
+			bulkSource: BulkSourceFunc(func(context.Context, []string) ([]*SignedPolicy, error) {
+				return []*SignedPolicy{
+					createBulkSignedPolicy("dummyDom1", "sig"),
+					createBulkSignedPolicy("dummyDom2", "invalid"),
+					createBulkSignedPolicy("dummyDom3", "sig"),
+					nil,
+				}, nil
+			}),
+			domains: []string{"dummyDom1", "dummyDom2"},
+			want:    []string{"dummyDom1"},
+		},
+		{
+			name: "bulk source error",
+			bulkSource: BulkSourceFunc(func(context.Context, []string) ([]*SignedPolicy, error) {
+				return nil, fmt.Errorf("dummy error")
+			}),
+			domains: []string{"dummyDom1"},
+			want:    nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &policyd{
+				pkp:        pkp,
+				bulkSource: tt.bulkSource,
+			}
+			got := p.fetchBulk(context.Background(), tt.domains)
+			var ds []string
+			for d := range got {
+				ds = append(ds, d)
+			}
+			sort.Strings(ds)
+			if !reflect.DeepEqual(ds, tt.want) {
+				t.Errorf("policyd.fetchBulk() domains = %v, want %v", ds, tt.want)
+			}
+		})
+	}
+}
+
+func Test_policyd_Update_bulkSource(t *testing.T) {
+	var fetched int32
+	newFetcher := func(d string) Fetcher {
+		return &fetcherMock{
+			domainMock: func() string { return d },
+			fetchWithRetryMock: func(context.Context) (*SignedPolicy, error) {
+				atomic.AddInt32(&fetched, 1)
+				return createBulkSignedPolicy(d, "sig"), nil
+			},
+		}
+	}
+	p := &policyd{
+		athenzDomains:    []string{"dummyDom1", "dummyDom2"},
+		domainPolicies:   gache.New(),
+		domainStatus:     gache.New(),
+		refreshTriggers:  gache.New(),
+		purgePeriod:      time.Hour,
+		fetchConcurrency: 1,
+		fetchers: map[string]Fetcher{
+			"dummyDom1": newFetcher("dummyDom1"),
+			"dummyDom2": newFetcher("dummyDom2"),
+		},
+		pkp: func(pubkey.AthenzEnv, string) authcore.Verifier {
+			return VerifierMock{
+				VerifyFunc: func(d, s string) error { return nil },
+			}
+		},
+		bulkSource: BulkSourceFunc(func(context.Context, []string) ([]*SignedPolicy, error) {
+			// dummyDom2 is not in the bundle, and fetched from ZTS instead
+			return []*SignedPolicy{createBulkSignedPolicy("dummyDom1", "sig")}, nil
+		}),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := p.Update(ctx); err != nil {
+		t.Errorf("policyd.Update() error = %v", err)
+		return
+	}
+	if got := atomic.LoadInt32(&fetched); got != 1 {
+		t.Errorf("policyd.Update() fetched from ZTS = %d, want 1", got)
+	}
+	for _, d := range p.athenzDomains {
+		if err := p.CheckPolicy(ctx, d, []string{"dummyRole"}, "dummyAct", "dummyRes"); err != nil {
+			t.Errorf("policyd.CheckPolicy() domain: %s, error = %v", d, err)
+		}
+	}
+}
@@ -30,31 +30,63 @@ import (
 	"github.com/kpango/glg"
 	"github.com/pkg/errors"
 	"github.com/yahoo/athenz/utils/zpe-updater/util"
+	"github.com/yahoojapan/athenz-authorizer/v5/internal/supervisor"
 	"github.com/yahoojapan/athenz-authorizer/v5/pubkey"
 	"golang.org/x/sync/errgroup"
 )
 
+// domainPolicy represents the simplified policy of a domain. It must not be modified after cached.
+type domainPolicy struct {
+	// The rolePolicies map has the format of  map[<domain>:role.<role>][]*Assertion
+	// The []*Assertion contains deny policies first, and following the allow policies
+	// When CheckPolicy function called, the []*Assertion is check by order, in current implementation the deny policy is prioritize,
+	// so we need to put the deny policies in lower index.
+	rolePolicies map[string][]*Assertion
+	expires      time.Time
+}
+
 // Daemon represents the daemon to retrieve policy data from Athenz.
 type Daemon interface {
 	Start(context.Context) <-chan error
 	Update(context.Context) error
 	CheckPolicy(ctx context.Context, domain string, roles []string, action, resource string) error
 	GetPolicyCache(context.Context) map[string]interface{}
+	GetDomainStatus(context.Context) map[string]DomainStatus
 }
 
 type policyd struct {
 
-	// The rolePolicies map has the format of  map[<domain>:role.<role>][]*Assertion
-	// The []*Assertion contains deny policies first, and following the allow policies
-	// When CheckPolicy function called, the []*Assertion is check by order, in current implementation the deny policy is prioritize,
-	// so we need to put the deny policies in lower index.
-	rolePolicies gache.Gache
+	// The domainPolicies map has the format of map[<domain>]*domainPolicy
+	// The cache is never replaced. Each domainPolicy is replaced as a whole on refresh, so that CheckPolicy never sees a mix of the old and new policy of a domain.
+	domainPolicies gache.Gache
+
+	// The domainStatus map has the format of map[<domain>]*domainState
+	domainStatus gache.Gache
+
+	// The refreshTriggers map has the format of map[<domain>]struct{}
+	// Each entry expires at the policy expiry, and the expired hook refetches the policy of the domain.
+	refreshTriggers gache.Gache
 
 	expiryMargin  time.Duration // force update policy before actual expiry by margin duration
+	maxStaleness  time.Duration // keep enforcing the expired policy by the duration if it cannot be refreshed
 	refreshPeriod time.Duration
 	purgePeriod   time.Duration
 	retryDelay    time.Duration
 	retryAttempts int
+	maxRetryDelay time.Duration // the retry delay is capped by it, and the retry is given up if Retry-After exceeds it
+
+	fetchConcurrency int           // maximum number of the domains fetched concurrently
+	refreshSpread    time.Duration // the periodic fetches of the domains are spread randomly over the duration
+	bulkSource       BulkSource    // fetch the policies of the domains at once, instead of each domain from ZTS
+
+	// The shadowPolicies map has the format of map[<domain>]*domainPolicy
+	// The shadow policies are evaluated with the enforced policies, and the disagreements are logged.
+	shadowPolicies gache.Gache
+	shadowSource   BulkSource
+
+	dryRun        bool                // allow the requests denied by the policy of any domain, and only log them
+	dryRunDomains map[string]struct{} // allow the requests denied by the policy of the domains, and only log them
+	decisionHook  DecisionHook
 
 	athenzURL     string
 	athenzDomains []string
@@ -67,7 +99,10 @@ type policyd struct {
 // New represent the constructor of Policyd
 func New(opts ...Option) (Daemon, error) {
 	p := &policyd{
-		rolePolicies: gache.New(),
+		domainPolicies:  gache.New(),
+		domainStatus:    gache.New(),
+		refreshTriggers: gache.New(),
+		shadowPolicies:  gache.New(),
 	}
 
 	for _, opt := range append(defaultOptions, opts...) {
@@ -84,13 +119,14 @@ func New(opts ...Option) (Daemon, error) {
 			expiryMargin:  p.expiryMargin,
 			retryDelay:    p.retryDelay,
 			retryAttempts: p.retryAttempts,
+			maxRetryDelay: p.maxRetryDelay,
 			athenzURL:     p.athenzURL,
 			spVerifier: func(sp *SignedPolicy) error {
 				return sp.Verify(p.pkp)
 			},
 			client: p.client,
 		}
-		p.fetchers[domain] = &f
+		p.fetchers[domain] = &singleflightFetcher{Fetcher: &f}
 	}
 
 	return p, nil
@@ -98,102 +134,154 @@ func New(opts ...Option) (Daemon, error) {
 
 // Start starts the Policy daemon to retrive the policy data periodically
 func (p *policyd) Start(ctx context.Context) <-chan error {
-	glg.Info("Starting policyd updater")
-	ech := make(chan error, 100)
-	fch := make(chan struct{}, 1)
-
-	go func() {
-		defer close(fch)
-		defer close(ech)
-
-		ticker := time.NewTicker(p.refreshPeriod)
-		for {
-			select {
-			case <-ctx.Done():
-				glg.Info("Stopping policyd updater")
-				ticker.Stop()
-				ech <- ctx.Err()
-				return
-			case <-fch:
-				if err := p.Update(ctx); err != nil {
-					ech <- errors.Wrap(err, "error update policy")
-
-					time.Sleep(p.retryDelay)
-
-					select {
-					case fch <- struct{}{}:
-					default:
-						glg.Warn("failure queue already full")
-					}
-				}
-			case <-ticker.C:
-				if err := p.Update(ctx); err != nil {
-					ech <- errors.Wrap(err, "error update policy")
-
-					select {
-					case fch <- struct{}{}:
-					default:
-						glg.Warn("failure queue already full")
-					}
-				}
-			}
+	p.domainPolicies.StartExpired(ctx, p.purgePeriod)
+	p.refreshTriggers.SetExpiredHook(p.refreshExpired).
+		EnableExpiredHook().
+		StartExpired(ctx, p.purgePeriod)
+
+	return supervisor.Run(ctx, supervisor.Config{
+		Name:     "policyd",
+		Interval: supervisor.Every(p.refreshPeriod),
+		Backoff: supervisor.Backoff{
+			Initial: p.retryDelay,
+			Max:     p.refreshPeriod,
+			Jitter:  supervisor.DefaultJitter,
+		},
+	}, func(ctx context.Context) error {
+		if err := p.update(ctx, p.refreshSpread); err != nil {
+			return errors.Wrap(err, "error update policy")
 		}
-	}()
-
-	return ech
+		return nil
+	})
 }
 
 // Update updates and cache policy data
 func (p *policyd) Update(ctx context.Context) error {
+	return p.update(ctx, 0)
+}
+
+// update fetches the policies of all the domains, at most fetchConcurrency domains at a time.
+// Each fetch is delayed randomly within spread, so that the periodic refresh does not burst the requests to Athenz.
+// Each domain policy is replaced as a whole in the cache as soon as it is fetched, and the failed domains keep the previous policies.
+func (p *policyd) update(ctx context.Context, spread time.Duration) error {
 	jobID := fastime.Now().Unix()
 	glg.Infof("[%d] will update policy", jobID)
-	eg := errgroup.Group{}
-	rp := gache.New()
+	errs := new(sync.Map) // map[<domain>]error
+	wg := new(sync.WaitGroup)
+	concurrency := p.fetchConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	sem := make(chan struct{}, concurrency)
+	bulk := p.fetchBulk(ctx, p.athenzDomains)
+	p.updateShadow(ctx)
 
 	for _, fetcher := range p.fetchers {
 		f := fetcher // for closure
 		select {
 		case <-ctx.Done():
 			glg.Info("Update policy interrupted")
+			wg.Wait()
 			return ctx.Err()
 		default:
-			eg.Go(func() error {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if supervisor.Sleep(ctx, supervisor.Spread(spread)) != nil {
+					return
+				}
 				select {
 				case <-ctx.Done():
-					glg.Info("Update policy interrupted")
-					return ctx.Err()
-				default:
-					return fetchAndCachePolicy(ctx, rp, f)
+					return
+				case sem <- struct{}{}:
+				}
+				defer func() {
+					<-sem
+				}()
+				sp, err := p.refreshDomain(ctx, p.domainPolicies, f, bulk)
+				if err != nil {
+					errs.Store(f.Domain(), err)
+					return
 				}
-			})
+				p.setDomainExpiry(f.Domain(), sp.SignedPolicyData.Expires.Time)
+				p.scheduleRefresh(f.Domain(), sp.SignedPolicyData.Expires.Time)
+			}()
 		}
 	}
+	wg.Wait()
 
-	if err := eg.Wait(); err != nil {
-		glg.Errorf("[%d] update policy fail", jobID)
+	if err := ctx.Err(); err != nil {
+		glg.Info("Update policy interrupted")
 		return err
 	}
 
-	rp.StartExpired(ctx, p.purgePeriod).
-		EnableExpiredHook().
-		SetExpiredHook(func(ctx context.Context, key string) {
-			// key = <domain>:role.<role>
-			fetchAndCachePolicy(ctx, p.rolePolicies, p.fetchers[strings.Split(key, ":role.")[0]])
-		})
-
-	p.rolePolicies, rp = rp, p.rolePolicies
-	glg.Debugf("tmp cache becomes effective")
-	rp.Stop()
-	rp.Clear()
-
+	uerr := &UpdateError{Errs: make(map[string]error)}
+	errs.Range(func(k, v interface{}) bool {
+		uerr.Errs[k.(string)] = v.(error)
+		return true
+	})
+	if len(uerr.Errs) != 0 {
+		glg.Errorf("[%d] update policy fail, domains: %v", jobID, uerr.Domains())
+		return uerr
+	}
 	glg.Infof("[%d] update policy done", jobID)
 	return nil
 }
 
+// refreshExpired is the expired hook of the refresh triggers, which refetches the policy of the domain.
+// The refetch is retried after the retry delay while it fails, e.g. while the stale policy is enforced.
+func (p *policyd) refreshExpired(ctx context.Context, domain string) {
+	f, ok := p.fetchers[domain]
+	if !ok {
+		return
+	}
+	sp, err := p.refreshDomain(ctx, p.domainPolicies, f, p.fetchBulk(ctx, []string{domain}))
+	if err != nil {
+		glg.Errorf("refresh expired policy fail, domain: %s, error: %v", domain, err)
+		p.scheduleRefresh(domain, time.Time{})
+		return
+	}
+	p.setDomainExpiry(domain, sp.SignedPolicyData.Expires.Time)
+	p.scheduleRefresh(domain, sp.SignedPolicyData.Expires.Time)
+}
+
+// scheduleRefresh triggers the refetch of the domain policy at the expiry. If the policy is already expired, the refetch is triggered after the retry delay.
+func (p *policyd) scheduleRefresh(domain string, expires time.Time) {
+	d := expires.Sub(fastime.Now())
+	if d <= 0 {
+		d = p.retryDelay
+	}
+	if d <= 0 {
+		// gache never expires the entry with non-positive expiry
+		d = time.Second
+	}
+	p.refreshTriggers.SetWithExpire(domain, struct{}{}, d)
+}
+
 // CheckPolicy checks the specified request has privilege to access the resources or not.
 // If return is nil then the request is allowed, otherwise the request is rejected.
 // Only action and resource is supporting wildcard, domain and role is not supporting wildcard.
+// In dry-run mode, the request denied by the policy is allowed, and the result is only reported to the DecisionHook and logged.
 func (p *policyd) CheckPolicy(ctx context.Context, domain string, roles []string, action, resource string) error {
+	err := p.checkDomainExpiry(domain)
+	if err != nil {
+		glg.Debugf("check policy domain: %s, role: %v, action: %s, resource: %s, result: %v", domain, roles, action, resource, err)
+	} else {
+		err = checkPolicy(ctx, p.domainPolicies, domain, roles, action, resource)
+	}
+	return p.decide(ctx, domain, roles, action, resource, err)
+}
+
+// checkPolicy checks the request by the domain policy in g.
+func checkPolicy(ctx context.Context, g gache.Gache, domain string, roles []string, action, resource string) error {
+	dp, ok := g.Get(domain)
+	if !ok {
+		err := errors.Wrap(ErrNoMatch, "no match")
+		glg.Debugf("check policy domain: %s, role: %v, action: %s, resource: %s, result: %v", domain, roles, action, resource, err)
+		return err
+	}
+	rp := dp.(*domainPolicy).rolePolicies
+
 	ech := make(chan error, len(roles))
 	cctx, cancel := context.WithCancel(ctx)
 	defer cancel()
@@ -203,7 +291,6 @@ func (p *policyd) CheckPolicy(ctx context.Context, domain string, roles []string
 
 		wg := new(sync.WaitGroup)
 		wg.Add(len(roles))
-		rp := p.rolePolicies
 
 		for _, role := range roles {
 			dr := fmt.Sprintf("%s:role.%s", domain, role)
@@ -214,12 +301,12 @@ func (p *policyd) CheckPolicy(ctx context.Context, domain string, roles []string
 					ch <- cctx.Err()
 					return
 				default:
-					asss, ok := rp.Get(dr)
+					asss, ok := rp[dr]
 					if !ok {
 						return
 					}
 
-					for _, ass := range asss.([]*Assertion) {
+					for _, ass := range asss {
 						glg.Debugf("Checking policy domain: %s, role: %v, action: %s, resource: %s, assertion: %v", domain, roles, action, resource, ass)
 						select {
 						case <-cctx.Done():
@@ -258,36 +345,77 @@ func (p *policyd) CheckPolicy(ctx context.Context, domain string, roles []string
 	return err
 }
 
-// GetPolicyCache returns the cached role policy data
+// GetPolicyCache returns the cached role policy data, in the format of map[<domain>:role.<role>][]*Assertion
 func (p *policyd) GetPolicyCache(ctx context.Context) map[string]interface{} {
-	return p.rolePolicies.ToRawMap(ctx)
+	m := make(map[string]interface{})
+	for _, dp := range p.domainPolicies.ToRawMap(ctx) {
+		for r, asss := range dp.(*domainPolicy).rolePolicies {
+			m[r] = asss
+		}
+	}
+	return m
 }
 
-func fetchAndCachePolicy(ctx context.Context, g gache.Gache, f Fetcher) error {
+// fetchAndCachePolicy fetches the policy of the domain and replaces the domain policy in g as a whole. It returns the cached policy.
+// If the fetch fails, the policy cache of the fetcher is used. The expired policy is still cached within the max staleness.
+func fetchAndCachePolicy(ctx context.Context, g gache.Gache, f Fetcher, maxStaleness time.Duration) (*SignedPolicy, error) {
 	sp, err := f.FetchWithRetry(ctx)
 	if err != nil {
 		errMsg := "fetch policy fail"
 		glg.Errorf("%s, error: %v", errMsg, err)
 		if sp == nil {
-			return errors.Wrap(err, errMsg)
+			return nil, errors.Wrap(err, errMsg)
+		}
+	}
+
+	if err := cachePolicy(ctx, g, f.Domain(), sp, maxStaleness); err != nil {
+		return nil, err
+	}
+	return sp, nil
+}
+
+// refreshDomain caches the policy of the domain in bulk into g, or fetches and caches it by the fetcher if not in bulk.
+func (p *policyd) refreshDomain(ctx context.Context, g gache.Gache, f Fetcher, bulk map[string]*SignedPolicy) (*SignedPolicy, error) {
+	sp, ok := bulk[f.Domain()]
+	if !ok {
+		return fetchAndCachePolicy(ctx, g, f, p.maxStaleness)
+	}
+	if err := cachePolicy(ctx, g, f.Domain(), sp, p.maxStaleness); err != nil {
+		return nil, err
+	}
+	return sp, nil
+}
+
+// cachePolicy replaces the domain policy in g with the verified signed policy as a whole.
+func cachePolicy(ctx context.Context, g gache.Gache, domain string, sp *SignedPolicy, maxStaleness time.Duration) error {
+	if exp := sp.SignedPolicyData.Expires.Time; !fastime.Now().Before(exp) {
+		deadline := exp.Add(maxStaleness)
+		if !fastime.Now().Before(deadline) {
+			glg.Errorf("policy expired beyond the max staleness, domain: %s, expires: %s", domain, exp)
+			return errors.Wrapf(ErrDomainExpired, "policy expired at %s", exp)
 		}
+		glg.Warnf("policy expired, will keep enforcing the stale policy until %s, domain: %s", deadline, domain)
 	}
 
 	glg.DebugFunc(func() string {
 		rawpol, _ := json.Marshal(sp)
-		return fmt.Sprintf("will merge policy, domain: %s, body: %s", f.Domain(), (string)(rawpol))
+		return fmt.Sprintf("will merge policy, domain: %s, body: %s", domain, (string)(rawpol))
 	})
 
-	if err := simplifyAndCachePolicy(ctx, g, sp); err != nil {
+	dp, err := simplifyPolicy(ctx, sp)
+	if err != nil {
 		errMsg := "simplify and cache policy fail"
 		glg.Debugf("%s, error: %v", errMsg, err)
 		return errors.Wrap(err, errMsg)
 	}
+	// the cache expires after the policy expiry and the max staleness, while the refetch is triggered at the policy expiry by the refresh triggers
+	g.SetWithExpire(domain, dp, dp.expires.Add(maxStaleness).Sub(fastime.Now()))
 
 	return nil
 }
 
-func simplifyAndCachePolicy(ctx context.Context, rp gache.Gache, sp *SignedPolicy) error {
+// simplifyPolicy builds the domain policy from the signed policy. The duplicated assertions are removed, and the deny assertion overrides the allow assertion.
+func simplifyPolicy(ctx context.Context, sp *SignedPolicy) (*domainPolicy, error) {
 	eg := errgroup.Group{}
 	assm := new(sync.Map) // assertion map
 
@@ -317,12 +445,16 @@ func simplifyAndCachePolicy(ctx context.Context, rp gache.Gache, sp *SignedPolic
 	}
 
 	if err := eg.Wait(); err != nil {
-		return errors.Wrap(err, "error simplify and cache policy")
+		return nil, errors.Wrap(err, "error simplify and cache policy")
 	}
 
-	// cache
+	dp := &domainPolicy{
+		rolePolicies: make(map[string][]*Assertion),
+	}
+	if exp := sp.DomainSignedPolicyData.SignedPolicyData.Expires; exp != nil {
+		dp.expires = exp.Time
+	}
 	var retErr error
-	now := fastime.Now()
 	assm.Range(func(k interface{}, val interface{}) bool {
 		ass := val.(*util.Assertion)
 		a, err := NewAssertion(ass.Action, ass.Resource, ass.Effect)
@@ -332,25 +464,18 @@ func simplifyAndCachePolicy(ctx context.Context, rp gache.Gache, sp *SignedPolic
 			return false
 		}
 
-		var asss []*Assertion
-		if p, ok := rp.Get(ass.Role); ok {
-			asss = p.([]*Assertion)
-			if a.Effect == nil {
-				asss = append(asss, a) // append allowed policies to the end of the slice
-			} else {
-				asss = append([]*Assertion{a}, asss...) // append denied policies to the head
-			}
+		if a.Effect == nil {
+			dp.rolePolicies[ass.Role] = append(dp.rolePolicies[ass.Role], a) // append allowed policies to the end of the slice
 		} else {
-			asss = []*Assertion{a}
+			dp.rolePolicies[ass.Role] = append([]*Assertion{a}, dp.rolePolicies[ass.Role]...) // append denied policies to the head
 		}
-		rp.SetWithExpire(ass.Role, asss, sp.DomainSignedPolicyData.SignedPolicyData.Expires.Sub(now))
 
-		glg.Debugf("added assertion to the tmp cache: %+v", ass)
+		glg.Debugf("added assertion to the domain policy: %+v", ass)
 		return true
 	})
 	if retErr != nil {
-		return retErr
+		return nil, retErr
 	}
 
-	return nil
+	return dp, nil
 }
@@ -24,6 +24,7 @@ import (
 	"reflect"
 	"runtime"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -35,6 +36,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/yahoo/athenz/utils/zpe-updater/util"
 	"github.com/yahoojapan/athenz-authorizer/v5/pubkey"
+	"golang.org/x/sync/errgroup"
 )
 
 func TestNew(t *testing.T) {
@@ -60,13 +62,18 @@ func TestNew(t *testing.T) {
 				opts: []Option{},
 			},
 			want: &policyd{
-				rolePolicies:  gache.New(),
-				expiryMargin:  3 * time.Hour,
-				purgePeriod:   1 * time.Hour,
-				refreshPeriod: 30 * time.Minute,
-				retryDelay:    1 * time.Minute,
-				retryAttempts: 2,
-				client:        http.DefaultClient,
+				domainPolicies:   gache.New(),
+				domainStatus:     gache.New(),
+				refreshTriggers:  gache.New(),
+				shadowPolicies:   gache.New(),
+				expiryMargin:     3 * time.Hour,
+				purgePeriod:      1 * time.Hour,
+				refreshPeriod:    30 * time.Minute,
+				retryDelay:       1 * time.Minute,
+				retryAttempts:    2,
+				maxRetryDelay:    5 * time.Minute,
+				fetchConcurrency: 8,
+				client:           http.DefaultClient,
 			},
 			wantErr: "",
 		},
@@ -76,13 +83,18 @@ func TestNew(t *testing.T) {
 				opts: []Option{WithExpiryMargin("5s")},
 			},
 			want: &policyd{
-				rolePolicies:  gache.New(),
-				expiryMargin:  5 * time.Second,
-				purgePeriod:   1 * time.Hour,
-				refreshPeriod: 30 * time.Minute,
-				retryDelay:    1 * time.Minute,
-				retryAttempts: 2,
-				client:        http.DefaultClient,
+				domainPolicies:   gache.New(),
+				domainStatus:     gache.New(),
+				refreshTriggers:  gache.New(),
+				shadowPolicies:   gache.New(),
+				expiryMargin:     5 * time.Second,
+				purgePeriod:      1 * time.Hour,
+				refreshPeriod:    30 * time.Minute,
+				retryDelay:       1 * time.Minute,
+				retryAttempts:    2,
+				maxRetryDelay:    5 * time.Minute,
+				fetchConcurrency: 8,
+				client:           http.DefaultClient,
 			},
 			wantErr: "",
 		},
@@ -92,14 +104,19 @@ func TestNew(t *testing.T) {
 				opts: []Option{WithAthenzDomains("dom1", "dom2")},
 			},
 			want: &policyd{
-				rolePolicies:  gache.New(),
-				expiryMargin:  3 * time.Hour,
-				purgePeriod:   1 * time.Hour,
-				refreshPeriod: 30 * time.Minute,
-				retryDelay:    1 * time.Minute,
-				retryAttempts: 2,
-				client:        http.DefaultClient,
-				athenzDomains: []string{"dom1", "dom2"},
+				domainPolicies:   gache.New(),
+				domainStatus:     gache.New(),
+				refreshTriggers:  gache.New(),
+				shadowPolicies:   gache.New(),
+				expiryMargin:     3 * time.Hour,
+				purgePeriod:      1 * time.Hour,
+				refreshPeriod:    30 * time.Minute,
+				retryDelay:       1 * time.Minute,
+				retryAttempts:    2,
+				maxRetryDelay:    5 * time.Minute,
+				fetchConcurrency: 8,
+				client:           http.DefaultClient,
+				athenzDomains:    []string{"dom1", "dom2"},
 				fetchers: map[string]Fetcher{
 					"dom1": &fetcher{domain: "dom1"},
 					"dom2": &fetcher{domain: "dom2"},
@@ -135,15 +152,15 @@ func TestNew(t *testing.T) {
 
 func Test_policyd_Start(t *testing.T) {
 	type fields struct {
-		expiryMargin  time.Duration
-		rolePolicies  gache.Gache
-		purgePeriod   time.Duration
-		refreshPeriod time.Duration
-		retryDelay    time.Duration
-		pkp           pubkey.Provider
-		athenzURL     string
-		athenzDomains []string
-		fetchers      map[string]Fetcher
+		expiryMargin   time.Duration
+		domainPolicies gache.Gache
+		purgePeriod    time.Duration
+		refreshPeriod  time.Duration
+		retryDelay     time.Duration
+		pkp            pubkey.Provider
+		athenzURL      string
+		athenzDomains  []string
+		fetchers       map[string]Fetcher
 	}
 	type args struct {
 		ctx context.Context
@@ -200,12 +217,12 @@ func Test_policyd_Start(t *testing.T) {
 			return test{
 				name: "Start success",
 				fields: fields{
-					rolePolicies:  gache.New(),
-					purgePeriod:   time.Minute * 30,
-					refreshPeriod: time.Millisecond * 30,
-					expiryMargin:  time.Hour,
-					athenzDomains: []string{domain},
-					fetchers:      fetchers,
+					domainPolicies: gache.New(),
+					purgePeriod:    time.Minute * 30,
+					refreshPeriod:  time.Millisecond * 30,
+					expiryMargin:   time.Hour,
+					athenzDomains:  []string{domain},
+					fetchers:       fetchers,
 				},
 				args: args{
 					ctx: ctx,
@@ -213,9 +230,9 @@ func Test_policyd_Start(t *testing.T) {
 				checkFunc: func(p *policyd, ch <-chan error) error {
 					time.Sleep(time.Millisecond * 100)
 					cancel()
-					asss, ok := p.rolePolicies.Get("dummyDom:role.dummyRole")
+					asss, ok := p.GetPolicyCache(context.Background())["dummyDom:role.dummyRole"]
 					if !ok {
-						return errors.New("rolePolicies is empty")
+						return errors.New("policy cache is empty")
 					}
 					if len(asss.([]*Assertion)) != 1 {
 						return errors.Errorf("invalid length assertions. want: 1, result: %d", len(asss.([]*Assertion)))
@@ -276,12 +293,12 @@ func Test_policyd_Start(t *testing.T) {
 			return test{
 				name: "Start can update cache",
 				fields: fields{
-					rolePolicies:  gache.New(),
-					purgePeriod:   time.Minute * 30,
-					refreshPeriod: time.Millisecond * 30,
-					expiryMargin:  time.Hour,
-					athenzDomains: []string{domain},
-					fetchers:      fetchers,
+					domainPolicies: gache.New(),
+					purgePeriod:    time.Minute * 30,
+					refreshPeriod:  time.Millisecond * 30,
+					expiryMargin:   time.Hour,
+					athenzDomains:  []string{domain},
+					fetchers:       fetchers,
 				},
 				args: args{
 					ctx: ctx,
@@ -290,9 +307,9 @@ func Test_policyd_Start(t *testing.T) {
 					time.Sleep(time.Millisecond * 100)
 					cancel()
 					time.Sleep(time.Millisecond * 50)
-					asss, ok := p.rolePolicies.Get("dummyDom:role.dummyRole")
+					asss, ok := p.GetPolicyCache(context.Background())["dummyDom:role.dummyRole"]
 					if !ok {
-						return errors.New("rolePolicies is empty")
+						return errors.New("policy cache is empty")
 					}
 
 					if len(asss.([]*Assertion)) != 1 {
@@ -365,13 +382,13 @@ func Test_policyd_Start(t *testing.T) {
 			return test{
 				name: "Start retry update",
 				fields: fields{
-					rolePolicies:  gache.New(),
-					purgePeriod:   time.Minute * 30,
-					refreshPeriod: time.Millisecond * 30,
-					retryDelay:    time.Millisecond * 5,
-					expiryMargin:  time.Hour,
-					athenzDomains: []string{domain},
-					fetchers:      fetchers,
+					domainPolicies: gache.New(),
+					purgePeriod:    time.Minute * 30,
+					refreshPeriod:  time.Millisecond * 30,
+					retryDelay:     time.Millisecond * 5,
+					expiryMargin:   time.Hour,
+					athenzDomains:  []string{domain},
+					fetchers:       fetchers,
 				},
 				args: args{
 					ctx: ctx,
@@ -380,9 +397,9 @@ func Test_policyd_Start(t *testing.T) {
 					time.Sleep(time.Millisecond * 120)
 					cancel()
 					time.Sleep(time.Millisecond * 30)
-					asss, ok := p.rolePolicies.Get("dummyDom:role.dummyRole")
+					asss, ok := p.GetPolicyCache(context.Background())["dummyDom:role.dummyRole"]
 					if !ok {
-						return errors.New("rolePolicies is empty")
+						return errors.New("policy cache is empty")
 					}
 
 					if len(asss.([]*Assertion)) != 1 {
@@ -411,15 +428,17 @@ func Test_policyd_Start(t *testing.T) {
 				defer tt.afterFunc()
 			}
 			p := &policyd{
-				expiryMargin:  tt.fields.expiryMargin,
-				rolePolicies:  tt.fields.rolePolicies,
-				purgePeriod:   tt.fields.purgePeriod,
-				refreshPeriod: tt.fields.refreshPeriod,
-				retryDelay:    tt.fields.retryDelay,
-				pkp:           tt.fields.pkp,
-				athenzURL:     tt.fields.athenzURL,
-				athenzDomains: tt.fields.athenzDomains,
-				fetchers:      tt.fields.fetchers,
+				expiryMargin:    tt.fields.expiryMargin,
+				domainPolicies:  tt.fields.domainPolicies,
+				domainStatus:    gache.New(),
+				refreshTriggers: gache.New(),
+				purgePeriod:     tt.fields.purgePeriod,
+				refreshPeriod:   tt.fields.refreshPeriod,
+				retryDelay:      tt.fields.retryDelay,
+				pkp:             tt.fields.pkp,
+				athenzURL:       tt.fields.athenzURL,
+				athenzDomains:   tt.fields.athenzDomains,
+				fetchers:        tt.fields.fetchers,
 			}
 			ch := p.Start(tt.args.ctx)
 			if tt.checkFunc != nil {
@@ -433,16 +452,18 @@ func Test_policyd_Start(t *testing.T) {
 
 func Test_policyd_Update(t *testing.T) {
 	type fields struct {
-		expiryMargin  time.Duration
-		rolePolicies  gache.Gache
-		purgePeriod   time.Duration
-		refreshPeriod time.Duration
-		retryDelay    time.Duration
-		pkp           pubkey.Provider
-		athenzURL     string
-		athenzDomains []string
-		client        *http.Client
-		fetchers      map[string]Fetcher
+		expiryMargin   time.Duration
+		domainPolicies gache.Gache
+		purgePeriod    time.Duration
+		refreshPeriod  time.Duration
+		retryDelay     time.Duration
+		pkp            pubkey.Provider
+		athenzURL      string
+		athenzDomains  []string
+		client         *http.Client
+		fetchers       map[string]Fetcher
+
+		fetchConcurrency int
 	}
 	type args struct {
 		ctx context.Context
@@ -472,9 +493,9 @@ func Test_policyd_Update(t *testing.T) {
 			// prepare test
 			cancel()
 			t.fields = fields{
-				rolePolicies:  gache.New(),
-				athenzDomains: []string{domain},
-				fetchers:      fetchers,
+				domainPolicies: gache.New(),
+				athenzDomains:  []string{domain},
+				fetchers:       fetchers,
 			}
 			t.args = args{
 				ctx: ctx,
@@ -530,10 +551,10 @@ func Test_policyd_Update(t *testing.T) {
 
 			// prepare test
 			t.fields = fields{
-				rolePolicies:  gache.New(),
-				purgePeriod:   time.Hour,
-				athenzDomains: []string{domain},
-				fetchers:      fetchers,
+				domainPolicies: gache.New(),
+				purgePeriod:    time.Hour,
+				athenzDomains:  []string{domain},
+				fetchers:       fetchers,
 			}
 			t.args = args{
 				ctx: ctx,
@@ -597,10 +618,10 @@ func Test_policyd_Update(t *testing.T) {
 
 			// prepare test
 			t.fields = fields{
-				rolePolicies:  gache.New(),
-				purgePeriod:   time.Hour,
-				athenzDomains: domains,
-				fetchers:      fetchers,
+				domainPolicies: gache.New(),
+				purgePeriod:    time.Hour,
+				athenzDomains:  domains,
+				fetchers:       fetchers,
 			}
 			t.args = args{
 				ctx: ctx,
@@ -618,7 +639,7 @@ func Test_policyd_Update(t *testing.T) {
 			return t
 		}(),
 		func() (t test) {
-			t.name = "Update error, context timeout, no partial update"
+			t.name = "Update error, context timeout, the fetched domains are updated"
 
 			// dummy values
 			createSp := func(domain string) *SignedPolicy {
@@ -681,34 +702,116 @@ func Test_policyd_Update(t *testing.T) {
 
 			// prepare test
 			t.fields = fields{
-				rolePolicies:  gache.New(),
-				purgePeriod:   time.Hour,
-				athenzDomains: domains,
-				fetchers:      fetchers,
+				domainPolicies:   gache.New(),
+				purgePeriod:      time.Hour,
+				athenzDomains:    domains,
+				fetchers:         fetchers,
+				fetchConcurrency: len(domains),
 			}
 			t.args = args{
 				ctx: ctx,
 			}
 
 			// want
-			t.wantErr = "fetch policy fail: " + context.DeadlineExceeded.Error()
+			t.wantErr = context.DeadlineExceeded.Error()
 			t.wantRps = make(map[string]interface{})
+			for _, d := range domains[1:] {
+				wantAssertion, _ := NewAssertion("dummyAct", d+":dummyRes", "ALLOW")
+				t.wantRps[d+":role.dummyRole"] = []*Assertion{wantAssertion}
+			}
+			return t
+		}(),
+		func() (t test) {
+			t.name = "Update partial success, failed domains keep the previous policies"
+
+			// dummy values
+			createSp := func(domain string) *SignedPolicy {
+				return &SignedPolicy{
+					util.DomainSignedPolicyData{
+						SignedPolicyData: &util.SignedPolicyData{
+							Expires: &rdl.Timestamp{Time: fastime.Now().Add(time.Hour)},
+							PolicyData: &util.PolicyData{
+								Domain: domain,
+								Policies: []*util.Policy{
+									{
+										Assertions: []*util.Assertion{
+											{
+												Role:     fmt.Sprintf("%s:role.dummyRole", domain),
+												Effect:   "ALLOW",
+												Action:   "dummyAct",
+												Resource: fmt.Sprintf("%s:dummyRes", domain),
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				}
+			}
+			fetchers := map[string]Fetcher{
+				"dummyDom1": &fetcherMock{
+					domainMock: func() string { return "dummyDom1" },
+					fetchWithRetryMock: func(context.Context) (*SignedPolicy, error) {
+						return createSp("dummyDom1"), nil
+					},
+				},
+				"dummyDom2": &fetcherMock{
+					domainMock: func() string { return "dummyDom2" },
+					fetchWithRetryMock: func(context.Context) (*SignedPolicy, error) {
+						return nil, errors.New("dummy error 2")
+					},
+				},
+				"dummyDom3": &fetcherMock{
+					domainMock: func() string { return "dummyDom3" },
+					fetchWithRetryMock: func(context.Context) (*SignedPolicy, error) {
+						return nil, errors.New("dummy error 3")
+					},
+				},
+			}
+			oldAssertion, _ := NewAssertion("oldAct", "dummyDom:oldRes", "ALLOW")
+
+			// prepare test
+			t.fields = fields{
+				domainPolicies: newDomainPolicies(map[string][]*Assertion{
+					"dummyDom1:role.oldRole": {oldAssertion},
+					"dummyDom2:role.oldRole": {oldAssertion},
+				}),
+				purgePeriod:   time.Hour,
+				athenzDomains: []string{"dummyDom1", "dummyDom2", "dummyDom3"},
+				fetchers:      fetchers,
+			}
+			t.args = args{
+				ctx: context.Background(),
+			}
+
+			// want
+			wantAssertion, _ := NewAssertion("dummyAct", "dummyDom1:dummyRes", "ALLOW")
+			t.wantErr = "update policy fail, domains: dummyDom2: fetch policy fail: dummy error 2; dummyDom3: fetch policy fail: dummy error 3"
+			t.wantRps = map[string]interface{}{
+				"dummyDom1:role.dummyRole": []*Assertion{wantAssertion},
+				"dummyDom2:role.oldRole":   []*Assertion{oldAssertion},
+			}
 			return t
 		}(),
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			p := &policyd{
-				expiryMargin:  tt.fields.expiryMargin,
-				rolePolicies:  tt.fields.rolePolicies,
-				purgePeriod:   tt.fields.purgePeriod,
-				refreshPeriod: tt.fields.refreshPeriod,
-				retryDelay:    tt.fields.retryDelay,
-				pkp:           tt.fields.pkp,
-				athenzURL:     tt.fields.athenzURL,
-				athenzDomains: tt.fields.athenzDomains,
-				client:        tt.fields.client,
-				fetchers:      tt.fields.fetchers,
+				expiryMargin:    tt.fields.expiryMargin,
+				domainPolicies:  tt.fields.domainPolicies,
+				domainStatus:    gache.New(),
+				refreshTriggers: gache.New(),
+				purgePeriod:     tt.fields.purgePeriod,
+				refreshPeriod:   tt.fields.refreshPeriod,
+				retryDelay:      tt.fields.retryDelay,
+				pkp:             tt.fields.pkp,
+				athenzURL:       tt.fields.athenzURL,
+				athenzDomains:   tt.fields.athenzDomains,
+				client:          tt.fields.client,
+				fetchers:        tt.fields.fetchers,
+
+				fetchConcurrency: tt.fields.fetchConcurrency,
 			}
 			err := p.Update(tt.args.ctx)
 			if (err == nil && tt.wantErr != "") || (err != nil && err.Error() != tt.wantErr) {
@@ -724,16 +827,103 @@ func Test_policyd_Update(t *testing.T) {
 	}
 }
 
+func Test_policyd_update_concurrency(t *testing.T) {
+	createSp := func(domain string) *SignedPolicy {
+		return &SignedPolicy{
+			util.DomainSignedPolicyData{
+				SignedPolicyData: &util.SignedPolicyData{
+					Expires: &rdl.Timestamp{Time: fastime.Now().Add(time.Hour)},
+					PolicyData: &util.PolicyData{
+						Domain: domain,
+						Policies: []*util.Policy{
+							{
+								Assertions: []*util.Assertion{
+									{
+										Role:     fmt.Sprintf("%s:role.dummyRole", domain),
+										Effect:   "ALLOW",
+										Action:   "dummyAct",
+										Resource: fmt.Sprintf("%s:dummyRes", domain),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+	tests := []struct {
+		name        string
+		concurrency int
+		spread      time.Duration
+	}{
+		{
+			name:        "fetch concurrency is limited",
+			concurrency: 3,
+		},
+		{
+			name:        "fetch concurrency is limited with spread",
+			concurrency: 3,
+			spread:      50 * time.Millisecond,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var running, maxRunning int32
+			fetchers := make(map[string]Fetcher, 20)
+			for i := 0; i < 20; i++ {
+				d := fmt.Sprintf("dummyDom%d", i)
+				fetchers[d] = &fetcherMock{
+					domainMock: func() string { return d },
+					fetchWithRetryMock: func(context.Context) (*SignedPolicy, error) {
+						n := atomic.AddInt32(&running, 1)
+						defer atomic.AddInt32(&running, -1)
+						for {
+							m := atomic.LoadInt32(&maxRunning)
+							if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+								break
+							}
+						}
+						time.Sleep(10 * time.Millisecond)
+						return createSp(d), nil
+					},
+				}
+			}
+			p := &policyd{
+				domainPolicies:   gache.New(),
+				domainStatus:     gache.New(),
+				refreshTriggers:  gache.New(),
+				purgePeriod:      time.Hour,
+				fetchConcurrency: tt.concurrency,
+				fetchers:         fetchers,
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			if err := p.update(ctx, tt.spread); err != nil {
+				t.Errorf("policyd.update() error = %v", err)
+				return
+			}
+			if got := atomic.LoadInt32(&maxRunning); got > int32(tt.concurrency) {
+				t.Errorf("policyd.update() concurrent fetches = %d, want <= %d", got, tt.concurrency)
+			}
+			if got := len(p.GetPolicyCache(ctx)); got != len(fetchers) {
+				t.Errorf("policyd.update() cached domains = %d, want %d", got, len(fetchers))
+			}
+		})
+	}
+}
+
 func Test_policyd_CheckPolicy(t *testing.T) {
 	type fields struct {
-		expiryMargin  time.Duration
-		rolePolicies  gache.Gache
-		refreshPeriod time.Duration
-		retryDelay    time.Duration
-		pkp           pubkey.Provider
-		athenzURL     string
-		athenzDomains []string
-		client        *http.Client
+		expiryMargin   time.Duration
+		domainPolicies gache.Gache
+		refreshPeriod  time.Duration
+		retryDelay     time.Duration
+		pkp            pubkey.Provider
+		athenzURL      string
+		athenzDomains  []string
+		client         *http.Client
 	}
 	type args struct {
 		ctx      context.Context
@@ -752,9 +942,9 @@ func Test_policyd_CheckPolicy(t *testing.T) {
 		{
 			name: "check policy allow success",
 			fields: fields{
-				rolePolicies: func() gache.Gache {
-					g := gache.New()
-					g.Set("dummyDom:role.dummyRole", []*Assertion{
+				domainPolicies: func() gache.Gache {
+					rps := make(map[string][]*Assertion)
+					rps["dummyDom:role.dummyRole"] = []*Assertion{
 						func() *Assertion {
 							a, _ := NewAssertion("dummyAct1", "dummyDom1:dummyRes1", "deny")
 							return a
@@ -767,8 +957,8 @@ func Test_policyd_CheckPolicy(t *testing.T) {
 							a, _ := NewAssertion("dummyAct", "dummyDom:dummyRes", "allow")
 							return a
 						}(),
-					})
-					return g
+					}
+					return newDomainPolicies(rps)
 				}(),
 			},
 			args: args{
@@ -783,15 +973,15 @@ func Test_policyd_CheckPolicy(t *testing.T) {
 		{
 			name: "check policy deny",
 			fields: fields{
-				rolePolicies: func() gache.Gache {
-					g := gache.New()
-					g.Set("dummyDom:role.dummyRole", []*Assertion{
+				domainPolicies: func() gache.Gache {
+					rps := make(map[string][]*Assertion)
+					rps["dummyDom:role.dummyRole"] = []*Assertion{
 						func() *Assertion {
 							a, _ := NewAssertion("dummyAct", "dummyDom:dummyRes", "deny")
 							return a
 						}(),
-					})
-					return g
+					}
+					return newDomainPolicies(rps)
 				}(),
 			},
 			args: args{
@@ -806,7 +996,7 @@ func Test_policyd_CheckPolicy(t *testing.T) {
 		{
 			name: "check policy not found",
 			fields: fields{
-				rolePolicies: gache.New(),
+				domainPolicies: gache.New(),
 			},
 			args: args{
 				ctx:      context.Background(),
@@ -820,9 +1010,9 @@ func Test_policyd_CheckPolicy(t *testing.T) {
 		{
 			name: "check policy allow success with multiple roles",
 			fields: fields{
-				rolePolicies: func() gache.Gache {
-					g := gache.New()
-					g.Set("dummyDom:role.dummyRole", []*Assertion{
+				domainPolicies: func() gache.Gache {
+					rps := make(map[string][]*Assertion)
+					rps["dummyDom:role.dummyRole"] = []*Assertion{
 						func() *Assertion {
 							a, _ := NewAssertion("dummyAct1", "dummyDom1:dummyRes1", "deny")
 							return a
@@ -835,8 +1025,8 @@ func Test_policyd_CheckPolicy(t *testing.T) {
 							a, _ := NewAssertion("dummyAct", "dummyDom:dummyRes", "allow")
 							return a
 						}(),
-					})
-					return g
+					}
+					return newDomainPolicies(rps)
 				}(),
 			},
 			args: args{
@@ -851,15 +1041,15 @@ func Test_policyd_CheckPolicy(t *testing.T) {
 		{
 			name: "check policy no match with assertion resource domain mismatch",
 			fields: fields{
-				rolePolicies: func() gache.Gache {
-					g := gache.New()
-					g.Set("dummyDom:role.dummyRole", []*Assertion{
+				domainPolicies: func() gache.Gache {
+					rps := make(map[string][]*Assertion)
+					rps["dummyDom:role.dummyRole"] = []*Assertion{
 						func() *Assertion {
 							a, _ := NewAssertion("dummyAct", "dummyDom3:dummyRes", "allow")
 							return a
 						}(),
-					})
-					return g
+					}
+					return newDomainPolicies(rps)
 				}(),
 			},
 			args: args{
@@ -874,7 +1064,9 @@ func Test_policyd_CheckPolicy(t *testing.T) {
 		{
 			name: "check policy, canceled context",
 			fields: fields{
-				rolePolicies: gache.New(),
+				domainPolicies: newDomainPolicies(map[string][]*Assertion{
+					"dummyDom:role.dummyRole": {},
+				}),
 			},
 			args: args{
 				ctx: func() context.Context {
@@ -892,21 +1084,21 @@ func Test_policyd_CheckPolicy(t *testing.T) {
 		{
 			name: "check policy deny with multiple roles with allow and deny",
 			fields: fields{
-				rolePolicies: func() gache.Gache {
-					g := gache.New()
+				domainPolicies: func() gache.Gache {
+					rps := make(map[string][]*Assertion)
 					asss := make([]*Assertion, 0, 200)
 					a, _ := NewAssertion("dummyAct", "dummyDom:dummyRes", "allow")
 					for i := 0; i < 200; i++ {
 						asss = append(asss, a)
 					}
-					g.Set("dummyDom:role.dummyRole", asss)
-					g.Set("dummyDom:role.dummyRole1", []*Assertion{
+					rps["dummyDom:role.dummyRole"] = asss
+					rps["dummyDom:role.dummyRole1"] = []*Assertion{
 						func() *Assertion {
 							a, _ := NewAssertion("dummyAct", "dummyDom:dummyRes", "deny")
 							return a
 						}(),
-					})
-					return g
+					}
+					return newDomainPolicies(rps)
 				}(),
 			},
 			args: args{
@@ -921,8 +1113,8 @@ func Test_policyd_CheckPolicy(t *testing.T) {
 		{
 			name: "check policy deny with single role with allow and deny",
 			fields: fields{
-				rolePolicies: func() gache.Gache {
-					g := gache.New()
+				domainPolicies: func() gache.Gache {
+					rps := make(map[string][]*Assertion)
 					asss := make([]*Assertion, 0, 200)
 					da, _ := NewAssertion("dummyAct", "dummyDom:dummyRes", "deny")
 					a, _ := NewAssertion("dummyAct", "dummyDom:dummyRes", "allow")
@@ -931,8 +1123,8 @@ func Test_policyd_CheckPolicy(t *testing.T) {
 					for i := 0; i < 199; i++ {
 						asss = append(asss, a)
 					}
-					g.Set("dummyDom:role.dummyRole", asss)
-					return g
+					rps["dummyDom:role.dummyRole"] = asss
+					return newDomainPolicies(rps)
 				}(),
 			},
 			args: args{
@@ -947,15 +1139,15 @@ func Test_policyd_CheckPolicy(t *testing.T) {
 		{
 			name: "check that action and resource do not affect each other",
 			fields: fields{
-				rolePolicies: func() gache.Gache {
-					g := gache.New()
-					g.Set("dummyDom:role.dummyRole", []*Assertion{
+				domainPolicies: func() gache.Gache {
+					rps := make(map[string][]*Assertion)
+					rps["dummyDom:role.dummyRole"] = []*Assertion{
 						func() *Assertion {
 							a, _ := NewAssertion("dummyAct?", "dummyDom:dummyRes", "allow")
 							return a
 						}(),
-					})
-					return g
+					}
+					return newDomainPolicies(rps)
 				}(),
 			},
 			args: args{
@@ -970,16 +1162,16 @@ func Test_policyd_CheckPolicy(t *testing.T) {
 		{
 			name: "check can't use regexp on action",
 			fields: fields{
-				rolePolicies: func() gache.Gache {
-					g := gache.New()
-					g.Set("dummyDom:role.dummyRole", []*Assertion{
+				domainPolicies: func() gache.Gache {
+					rps := make(map[string][]*Assertion)
+					rps["dummyDom:role.dummyRole"] = []*Assertion{
 						func() *Assertion {
 							// Regexp that allow dummyAct1 or dummyAct2 for action.
 							a, _ := NewAssertion("dummyAct1|dummyAct2", "dummyDom:dummyRes", "allow")
 							return a
 						}(),
-					})
-					return g
+					}
+					return newDomainPolicies(rps)
 				}(),
 			},
 			args: args{
@@ -994,16 +1186,16 @@ func Test_policyd_CheckPolicy(t *testing.T) {
 		{
 			name: "check can't use regexp on resource",
 			fields: fields{
-				rolePolicies: func() gache.Gache {
-					g := gache.New()
-					g.Set("dummyDom:role.dummyRole", []*Assertion{
+				domainPolicies: func() gache.Gache {
+					rps := make(map[string][]*Assertion)
+					rps["dummyDom:role.dummyRole"] = []*Assertion{
 						func() *Assertion {
 							// Regexp that allow dummyResX for resource.
 							a, _ := NewAssertion("dummyAct", "dummyDom:dummyRes.*", "allow")
 							return a
 						}(),
-					})
-					return g
+					}
+					return newDomainPolicies(rps)
 				}(),
 			},
 			args: args{
@@ -1018,16 +1210,16 @@ func Test_policyd_CheckPolicy(t *testing.T) {
 		{
 			name: "check can't use regexp on action and resource",
 			fields: fields{
-				rolePolicies: func() gache.Gache {
-					g := gache.New()
-					g.Set("dummyDom:role.dummyRole", []*Assertion{
+				domainPolicies: func() gache.Gache {
+					rps := make(map[string][]*Assertion)
+					rps["dummyDom:role.dummyRole"] = []*Assertion{
 						func() *Assertion {
 							// Regexp that allow dummyResX for resource.
 							a, _ := NewAssertion("dummyAct1|dummyAct2", "dummyDom:.*Res", "allow")
 							return a
 						}(),
-					})
-					return g
+					}
+					return newDomainPolicies(rps)
 				}(),
 			},
 			args: args{
@@ -1042,15 +1234,15 @@ func Test_policyd_CheckPolicy(t *testing.T) {
 		{
 			name: "check can use wildcard on action",
 			fields: fields{
-				rolePolicies: func() gache.Gache {
-					g := gache.New()
-					g.Set("dummyDom:role.dummyRole", []*Assertion{
+				domainPolicies: func() gache.Gache {
+					rps := make(map[string][]*Assertion)
+					rps["dummyDom:role.dummyRole"] = []*Assertion{
 						func() *Assertion {
 							a, _ := NewAssertion("*Act?", "dummyDom:dummyRes", "allow")
 							return a
 						}(),
-					})
-					return g
+					}
+					return newDomainPolicies(rps)
 				}(),
 			},
 			args: args{
@@ -1065,15 +1257,15 @@ func Test_policyd_CheckPolicy(t *testing.T) {
 		{
 			name: "check can use wildcard on action, deny",
 			fields: fields{
-				rolePolicies: func() gache.Gache {
-					g := gache.New()
-					g.Set("dummyDom:role.dummyRole", []*Assertion{
+				domainPolicies: func() gache.Gache {
+					rps := make(map[string][]*Assertion)
+					rps["dummyDom:role.dummyRole"] = []*Assertion{
 						func() *Assertion {
 							a, _ := NewAssertion("*Act?", "dummyDom:dummyRes", "allow")
 							return a
 						}(),
-					})
-					return g
+					}
+					return newDomainPolicies(rps)
 				}(),
 			},
 			args: args{
@@ -1089,15 +1281,15 @@ func Test_policyd_CheckPolicy(t *testing.T) {
 		{
 			name: "check can use wildcard on resource",
 			fields: fields{
-				rolePolicies: func() gache.Gache {
-					g := gache.New()
-					g.Set("dummyDom:role.dummyRole", []*Assertion{
+				domainPolicies: func() gache.Gache {
+					rps := make(map[string][]*Assertion)
+					rps["dummyDom:role.dummyRole"] = []*Assertion{
 						func() *Assertion {
 							a, _ := NewAssertion("dummyAct", "dummyDom:*Res?", "allow")
 							return a
 						}(),
-					})
-					return g
+					}
+					return newDomainPolicies(rps)
 				}(),
 			},
 			args: args{
@@ -1112,15 +1304,15 @@ func Test_policyd_CheckPolicy(t *testing.T) {
 		{
 			name: "check can use wildcard on resource, deny",
 			fields: fields{
-				rolePolicies: func() gache.Gache {
-					g := gache.New()
-					g.Set("dummyDom:role.dummyRole", []*Assertion{
+				domainPolicies: func() gache.Gache {
+					rps := make(map[string][]*Assertion)
+					rps["dummyDom:role.dummyRole"] = []*Assertion{
 						func() *Assertion {
 							a, _ := NewAssertion("dummyAct", "dummyDom:*Res?", "allow")
 							return a
 						}(),
-					})
-					return g
+					}
+					return newDomainPolicies(rps)
 				}(),
 			},
 			args: args{
@@ -1136,15 +1328,15 @@ func Test_policyd_CheckPolicy(t *testing.T) {
 		{
 			name: "check can use wildcard on action and resource",
 			fields: fields{
-				rolePolicies: func() gache.Gache {
-					g := gache.New()
-					g.Set("dummyDom:role.dummyRole", []*Assertion{
+				domainPolicies: func() gache.Gache {
+					rps := make(map[string][]*Assertion)
+					rps["dummyDom:role.dummyRole"] = []*Assertion{
 						func() *Assertion {
 							a, _ := NewAssertion("*Act?", "dummyDom:*Res?", "allow")
 							return a
 						}(),
-					})
-					return g
+					}
+					return newDomainPolicies(rps)
 				}(),
 			},
 			args: args{
@@ -1159,15 +1351,15 @@ func Test_policyd_CheckPolicy(t *testing.T) {
 		{
 			name: "check can use wildcard on action and resource, deny",
 			fields: fields{
-				rolePolicies: func() gache.Gache {
-					g := gache.New()
-					g.Set("dummyDom:role.dummyRole", []*Assertion{
+				domainPolicies: func() gache.Gache {
+					rps := make(map[string][]*Assertion)
+					rps["dummyDom:role.dummyRole"] = []*Assertion{
 						func() *Assertion {
 							a, _ := NewAssertion("*Act?", "dummyDom:*Res?", "allow")
 							return a
 						}(),
-					})
-					return g
+					}
+					return newDomainPolicies(rps)
 				}(),
 			},
 			args: args{
@@ -1183,15 +1375,15 @@ func Test_policyd_CheckPolicy(t *testing.T) {
 		{
 			name: "check can not use wildcard escaping, allow",
 			fields: fields{
-				rolePolicies: func() gache.Gache {
-					g := gache.New()
-					g.Set("dummyDom:role.dummyRole", []*Assertion{
+				domainPolicies: func() gache.Gache {
+					rps := make(map[string][]*Assertion)
+					rps["dummyDom:role.dummyRole"] = []*Assertion{
 						func() *Assertion {
 							a, _ := NewAssertion("\\*Act\\?", "dummyDom:\\*Res\\?", "allow")
 							return a
 						}(),
-					})
-					return g
+					}
+					return newDomainPolicies(rps)
 				}(),
 			},
 			args: args{
@@ -1206,15 +1398,15 @@ func Test_policyd_CheckPolicy(t *testing.T) {
 		{
 			name: "check can not use wildcard escaping, deny",
 			fields: fields{
-				rolePolicies: func() gache.Gache {
-					g := gache.New()
-					g.Set("dummyDom:role.dummyRole", []*Assertion{
+				domainPolicies: func() gache.Gache {
+					rps := make(map[string][]*Assertion)
+					rps["dummyDom:role.dummyRole"] = []*Assertion{
 						func() *Assertion {
 							a, _ := NewAssertion("\\*Act\\?", "dummyDom:\\*Res\\?", "allow")
 							return a
 						}(),
-					})
-					return g
+					}
+					return newDomainPolicies(rps)
 				}(),
 			},
 			args: args{
@@ -1230,14 +1422,16 @@ func Test_policyd_CheckPolicy(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			p := &policyd{
-				expiryMargin:  tt.fields.expiryMargin,
-				rolePolicies:  tt.fields.rolePolicies,
-				refreshPeriod: tt.fields.refreshPeriod,
-				retryDelay:    tt.fields.retryDelay,
-				pkp:           tt.fields.pkp,
-				athenzURL:     tt.fields.athenzURL,
-				athenzDomains: tt.fields.athenzDomains,
-				client:        tt.fields.client,
+				expiryMargin:    tt.fields.expiryMargin,
+				domainPolicies:  tt.fields.domainPolicies,
+				domainStatus:    gache.New(),
+				refreshTriggers: gache.New(),
+				refreshPeriod:   tt.fields.refreshPeriod,
+				retryDelay:      tt.fields.retryDelay,
+				pkp:             tt.fields.pkp,
+				athenzURL:       tt.fields.athenzURL,
+				athenzDomains:   tt.fields.athenzDomains,
+				client:          tt.fields.client,
 			}
 			err := p.CheckPolicy(tt.args.ctx, tt.args.domain, tt.args.roles, tt.args.action, tt.args.resource)
 			if err == nil {
@@ -1257,14 +1451,14 @@ func Test_policyd_CheckPolicy(t *testing.T) {
 
 func Test_policyd_CheckPolicy_goroutine(t *testing.T) {
 	type fields struct {
-		expiryMargin  time.Duration
-		rolePolicies  gache.Gache
-		refreshPeriod time.Duration
-		retryDelay    time.Duration
-		pkp           pubkey.Provider
-		athenzURL     string
-		athenzDomains []string
-		client        *http.Client
+		expiryMargin   time.Duration
+		domainPolicies gache.Gache
+		refreshPeriod  time.Duration
+		retryDelay     time.Duration
+		pkp            pubkey.Provider
+		athenzURL      string
+		athenzDomains  []string
+		client         *http.Client
 	}
 	type args struct {
 		ctx      context.Context
@@ -1283,15 +1477,15 @@ func Test_policyd_CheckPolicy_goroutine(t *testing.T) {
 		{
 			name: "check policy: control test",
 			fields: fields{
-				rolePolicies: func() gache.Gache {
-					g := gache.New()
-					g.Set("domain:role.role1", []*Assertion{
+				domainPolicies: func() gache.Gache {
+					rps := make(map[string][]*Assertion)
+					rps["domain:role.role1"] = []*Assertion{
 						func() *Assertion {
 							a, _ := NewAssertion("action", "domain:resource", "deny")
 							return a
 						}(),
-					})
-					return g
+					}
+					return newDomainPolicies(rps)
 				}(),
 			},
 			args: args{
@@ -1306,33 +1500,33 @@ func Test_policyd_CheckPolicy_goroutine(t *testing.T) {
 		{
 			name: "check policy multiple deny deadlock",
 			fields: fields{
-				rolePolicies: func() gache.Gache {
-					g := gache.New()
-					g.Set("domain:role.role1", []*Assertion{
+				domainPolicies: func() gache.Gache {
+					rps := make(map[string][]*Assertion)
+					rps["domain:role.role1"] = []*Assertion{
 						func() *Assertion {
 							a, _ := NewAssertion("action", "domain:resource", "deny")
 							return a
 						}(),
-					})
-					g.Set("domain:role.role2", []*Assertion{
+					}
+					rps["domain:role.role2"] = []*Assertion{
 						func() *Assertion {
 							a, _ := NewAssertion("action", "domain:resource", "deny")
 							return a
 						}(),
-					})
-					g.Set("domain:role.role3", []*Assertion{
+					}
+					rps["domain:role.role3"] = []*Assertion{
 						func() *Assertion {
 							a, _ := NewAssertion("action", "domain:resource", "deny")
 							return a
 						}(),
-					})
-					g.Set("domain:role.role4", []*Assertion{
+					}
+					rps["domain:role.role4"] = []*Assertion{
 						func() *Assertion {
 							a, _ := NewAssertion("action", "domain:resource", "deny")
 							return a
 						}(),
-					})
-					return g
+					}
+					return newDomainPolicies(rps)
 				}(),
 			},
 			args: args{
@@ -1348,14 +1542,16 @@ func Test_policyd_CheckPolicy_goroutine(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			p := &policyd{
-				expiryMargin:  tt.fields.expiryMargin,
-				rolePolicies:  tt.fields.rolePolicies,
-				refreshPeriod: tt.fields.refreshPeriod,
-				retryDelay:    tt.fields.retryDelay,
-				pkp:           tt.fields.pkp,
-				athenzURL:     tt.fields.athenzURL,
-				athenzDomains: tt.fields.athenzDomains,
-				client:        tt.fields.client,
+				expiryMargin:    tt.fields.expiryMargin,
+				domainPolicies:  tt.fields.domainPolicies,
+				domainStatus:    gache.New(),
+				refreshTriggers: gache.New(),
+				refreshPeriod:   tt.fields.refreshPeriod,
+				retryDelay:      tt.fields.retryDelay,
+				pkp:             tt.fields.pkp,
+				athenzURL:       tt.fields.athenzURL,
+				athenzDomains:   tt.fields.athenzDomains,
+				client:          tt.fields.client,
 			}
 
 			b := make([]byte, 10240)
@@ -1387,11 +1583,103 @@ func Test_policyd_CheckPolicy_goroutine(t *testing.T) {
 	}
 }
 
+func Test_policyd_CheckPolicy_concurrentRefresh(t *testing.T) {
+	newSp := func(role, effect string) *SignedPolicy {
+		return &SignedPolicy{
+			util.DomainSignedPolicyData{
+				SignedPolicyData: &util.SignedPolicyData{
+					Expires: &rdl.Timestamp{Time: fastime.Now().Add(time.Hour)},
+					PolicyData: &util.PolicyData{
+						Domain: "dummyDom",
+						Policies: []*util.Policy{
+							{
+								Assertions: []*util.Assertion{
+									{
+										Role:     "dummyDom:role." + role,
+										Action:   "dummyAct",
+										Resource: "dummyDom:dummyRes",
+										Effect:   effect,
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+	// the old policy allows dummyRole1, and the new policy denies dummyRole2.
+	// A mix of the two policies results in ErrNoMatch (neither) or ErrDenyByPolicy with the allow of dummyRole1 (both).
+	sps := []*SignedPolicy{newSp("dummyRole1", "allow"), newSp("dummyRole2", "deny")}
+	var n int64
+	f := &fetcherMock{
+		domainMock: func() string { return "dummyDom" },
+		fetchWithRetryMock: func(context.Context) (*SignedPolicy, error) {
+			return sps[atomic.AddInt64(&n, 1)%2], nil
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p := &policyd{
+		domainPolicies:   gache.New(),
+		domainStatus:     gache.New(),
+		refreshTriggers:  gache.New(),
+		purgePeriod:      time.Hour,
+		fetchConcurrency: 1,
+		athenzDomains:    []string{"dummyDom"},
+		fetchers:         map[string]Fetcher{"dummyDom": f},
+	}
+	if err := p.Update(ctx); err != nil {
+		t.Fatalf("policyd.Update() error = %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 500; i++ {
+			if err := p.Update(ctx); err != nil {
+				t.Errorf("policyd.Update() error = %v", err)
+				return
+			}
+		}
+	}()
+
+	eg := errgroup.Group{}
+	for i := 0; i < 8; i++ {
+		eg.Go(func() error {
+			for {
+				select {
+				case <-done:
+					return nil
+				default:
+				}
+				// ErrNoMatch if the domain policy is mixed or missing during the update
+				err := p.CheckPolicy(ctx, "dummyDom", []string{"dummyRole1", "dummyRole2"}, "dummyAct", "dummyRes")
+				if err != nil && errors.Cause(err) != ErrDenyByPolicy {
+					return errors.Wrap(err, "mixed or missing domain policy")
+				}
+			}
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		t.Errorf("policyd.CheckPolicy() error = %v", err)
+	}
+
+	// no duplicated assertion after the refreshes
+	for r, asss := range p.GetPolicyCache(ctx) {
+		if len(asss.([]*Assertion)) != 1 {
+			t.Errorf("policyd.GetPolicyCache() role: %s, assertions: %v", r, asss)
+		}
+	}
+}
+
 func Test_fetchAndCachePolicy(t *testing.T) {
 	type args struct {
-		ctx context.Context
-		g   gache.Gache
-		f   Fetcher
+		ctx          context.Context
+		g            gache.Gache
+		f            Fetcher
+		maxStaleness time.Duration
 	}
 	type test struct {
 		name    string
@@ -1512,6 +1800,40 @@ func Test_fetchAndCachePolicy(t *testing.T) {
 			t.wantRps["dummyDom:role.dummyRole"] = []*Assertion{wantAssertion}
 			return t
 		}(),
+		func() (t test) {
+			t.name = "fetch success, replace the domain policy as a whole"
+
+			// dummy values
+			domain := "dummyDom"
+			sp := createDummySp()
+			fetcher := &fetcherMock{
+				domainMock: func() string { return domain },
+				fetchWithRetryMock: func(context.Context) (*SignedPolicy, error) {
+					return sp, nil
+				},
+			}
+			ctx := context.Background()
+			oldAssertion, _ := NewAssertion("dummyAct", "dummyDom:dummyRes", "ALLOW")
+
+			// prepare test
+			t.args = args{
+				ctx: ctx,
+				g: newDomainPolicies(map[string][]*Assertion{
+					"dummyDom:role.dummyRole":   {oldAssertion},
+					"dummyDom:role.removedRole": {oldAssertion},
+					"dummyDom2:role.dummyRole2": {oldAssertion},
+				}),
+				f: fetcher,
+			}
+
+			// want
+			wantAssertion, _ := NewAssertion("dummyAct", "dummyDom:dummyRes", "ALLOW")
+			t.wantErr = ""
+			t.wantRps = make(map[string]interface{})
+			t.wantRps["dummyDom:role.dummyRole"] = []*Assertion{wantAssertion}
+			t.wantRps["dummyDom2:role.dummyRole2"] = []*Assertion{oldAssertion}
+			return t
+		}(),
 		func() (t test) {
 			t.name = "simplifyAndCache failed, error"
 
@@ -1539,15 +1861,74 @@ func Test_fetchAndCachePolicy(t *testing.T) {
 			t.wantRps = make(map[string]interface{})
 			return t
 		}(),
+		func() (t test) {
+			t.name = "fetch failed, cached policy expired within max staleness, update cache"
+
+			// dummy values
+			domain := "dummyDom"
+			sp := createDummySp()
+			sp.SignedPolicyData.Expires = &rdl.Timestamp{Time: fastime.Now().Add(-time.Minute)}
+			fetcher := &fetcherMock{
+				domainMock: func() string { return domain },
+				fetchWithRetryMock: func(context.Context) (*SignedPolicy, error) {
+					return sp, errors.New("fetch error")
+				},
+			}
+			ctx := context.Background()
+
+			// prepare test
+			t.args = args{
+				ctx:          ctx,
+				g:            gache.New(),
+				f:            fetcher,
+				maxStaleness: time.Hour,
+			}
+
+			// want
+			wantAssertion, _ := NewAssertion("dummyAct", "dummyDom:dummyRes", "ALLOW")
+			t.wantErr = ""
+			t.wantRps = make(map[string]interface{})
+			t.wantRps["dummyDom:role.dummyRole"] = []*Assertion{wantAssertion}
+			return t
+		}(),
+		func() (t test) {
+			t.name = "fetch failed, cached policy expired beyond max staleness, error"
+
+			// dummy values
+			domain := "dummyDom"
+			sp := createDummySp()
+			expires := fastime.Now().Add(-2 * time.Hour).UTC()
+			sp.SignedPolicyData.Expires = &rdl.Timestamp{Time: expires}
+			fetcher := &fetcherMock{
+				domainMock: func() string { return domain },
+				fetchWithRetryMock: func(context.Context) (*SignedPolicy, error) {
+					return sp, errors.New("fetch error")
+				},
+			}
+			ctx := context.Background()
+
+			// prepare test
+			t.args = args{
+				ctx:          ctx,
+				g:            gache.New(),
+				f:            fetcher,
+				maxStaleness: time.Hour,
+			}
+
+			// want
+			t.wantErr = fmt.Sprintf("policy expired at %s: %s", expires, ErrDomainExpired)
+			t.wantRps = make(map[string]interface{})
+			return t
+		}(),
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := fetchAndCachePolicy(tt.args.ctx, tt.args.g, tt.args.f)
+			_, err := fetchAndCachePolicy(tt.args.ctx, tt.args.g, tt.args.f, tt.args.maxStaleness)
 			if (err == nil && tt.wantErr != "") || (err != nil && err.Error() != tt.wantErr) {
 				t.Errorf("fetchAndCachePolicy() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
-			gotRps := tt.args.g.ToRawMap(context.Background())
+			gotRps := (&policyd{domainPolicies: tt.args.g}).GetPolicyCache(context.Background())
 			if !cmp.Equal(gotRps, tt.wantRps, cmpopts.IgnoreFields(Assertion{}, "ActionRegexp", "ResourceRegexp")) {
 				t.Errorf("fetchAndCachePolicy() g = %v, want %v", gotRps, tt.wantRps)
 				t.Errorf("fetchAndCachePolicy() g diff = %s", cmp.Diff(gotRps, tt.wantRps, cmpopts.IgnoreFields(Assertion{}, "ActionRegexp", "ResourceRegexp")))
@@ -1556,16 +1937,15 @@ func Test_fetchAndCachePolicy(t *testing.T) {
 	}
 }
 
-func Test_simplifyAndCachePolicy(t *testing.T) {
+func Test_simplifyPolicy(t *testing.T) {
 	type args struct {
 		ctx context.Context
-		rp  gache.Gache
 		sp  *SignedPolicy
 	}
 	type test struct {
 		name      string
 		args      args
-		checkFunc func() error
+		checkFunc func(*domainPolicy) error
 		wantErr   bool
 	}
 
@@ -1578,13 +1958,11 @@ func Test_simplifyAndCachePolicy(t *testing.T) {
 	}
 	tests := []test{
 		func() test {
-			rp := gache.New()
 			expires := fastime.Now().Add(time.Hour).UTC()
 			return test{
 				name: "cache success with data",
 				args: args{
 					ctx: context.Background(),
-					rp:  rp,
 					sp: &SignedPolicy{
 						util.DomainSignedPolicyData{
 							SignedPolicyData: &util.SignedPolicyData{
@@ -1629,19 +2007,18 @@ func Test_simplifyAndCachePolicy(t *testing.T) {
 						},
 					},
 				},
-				checkFunc: func() error {
-					if len(rp.ToRawMap(context.Background())) != 2 {
-						return errors.Errorf("invalid length role policies 2, role policies: %v", rp.ToRawMap(context.Background()))
+				checkFunc: func(dp *domainPolicy) error {
+					if len(dp.rolePolicies) != 2 {
+						return errors.Errorf("invalid length role policies 2, role policies: %v", dp.rolePolicies)
 					}
 
-					gotRp1, gotExpire1, ok := rp.GetWithExpire("dummyDom:role.dummyRole")
+					gotAsss1, ok := dp.rolePolicies["dummyDom:role.dummyRole"]
 					if !ok {
 						return errors.New("cannot simplify and cache data")
 					}
-					if math.Abs(time.Unix(0, gotExpire1).Sub(expires).Seconds()) > time.Second.Seconds()*3 {
+					if math.Abs(dp.expires.Sub(expires).Seconds()) > time.Second.Seconds()*3 {
 						return errors.New("cache expiry not match with policy expires")
 					}
-					gotAsss1 := gotRp1.([]*Assertion)
 					if len(gotAsss1) != 2 {
 						return errors.Errorf("invalid length asss 1, got: %v", gotAsss1)
 					}
@@ -1659,14 +2036,13 @@ func Test_simplifyAndCachePolicy(t *testing.T) {
 						return errors.Errorf("hv1: %v, hv2: %v", hv1, hv2)
 					}
 
-					gotRp2, gotExpire2, ok := rp.GetWithExpire("dummyDom2:role.dummyRole2")
+					gotAsss2, ok := dp.rolePolicies["dummyDom2:role.dummyRole2"]
 					if !ok {
 						return errors.New("cannot simplify and cache data")
 					}
-					if math.Abs(time.Unix(0, gotExpire2).Sub(expires).Seconds()) > time.Second.Seconds()*3 {
+					if math.Abs(dp.expires.Sub(expires).Seconds()) > time.Second.Seconds()*3 {
 						return errors.New("cache expiry not match with policy expires")
 					}
-					gotAsss2 := gotRp2.([]*Assertion)
 					if len(gotAsss2) != 1 {
 						return errors.New("dummyDom2:role.dummyRole2 invalid length")
 					}
@@ -1677,13 +2053,11 @@ func Test_simplifyAndCachePolicy(t *testing.T) {
 			}
 		}(),
 		func() test {
-			rp := gache.New()
 			ctx, cancel := context.WithDeadline(context.Background(), fastime.Now().Add(time.Nanosecond*5))
 			return test{
 				name: "test context done",
 				args: args{
 					ctx: ctx,
-					rp:  rp,
 					sp: &SignedPolicy{
 						util.DomainSignedPolicyData{
 							SignedPolicyData: &util.SignedPolicyData{
@@ -1728,7 +2102,7 @@ func Test_simplifyAndCachePolicy(t *testing.T) {
 						},
 					},
 				},
-				checkFunc: func() error {
+				checkFunc: func(*domainPolicy) error {
 					cancel()
 					return nil
 				},
@@ -1736,12 +2110,10 @@ func Test_simplifyAndCachePolicy(t *testing.T) {
 			}
 		}(),
 		func() test {
-			rp := gache.New()
 			return test{
 				name: "cache deny overwrite allow",
 				args: args{
 					ctx: context.Background(),
-					rp:  rp,
 					sp: &SignedPolicy{
 						util.DomainSignedPolicyData{
 							SignedPolicyData: &util.SignedPolicyData{
@@ -1798,16 +2170,15 @@ func Test_simplifyAndCachePolicy(t *testing.T) {
 						},
 					},
 				},
-				checkFunc: func() error {
-					if len(rp.ToRawMap(context.Background())) != 1 {
-						return errors.Errorf("invalid length role policies 1, role policies: %v", rp.ToRawMap(context.Background()))
+				checkFunc: func(dp *domainPolicy) error {
+					if len(dp.rolePolicies) != 1 {
+						return errors.Errorf("invalid length role policies 1, role policies: %v", dp.rolePolicies)
 					}
 
-					gotRp1, ok := rp.Get("dummyDom:role.dummyRole")
+					gotAsss1, ok := dp.rolePolicies["dummyDom:role.dummyRole"]
 					if !ok {
 						return errors.New("cannot simplify and cache data")
 					}
-					gotAsss1 := gotRp1.([]*Assertion)
 					if len(gotAsss1) != 1 {
 						return errors.Errorf("invalid length asss 1, got: %v", gotAsss1)
 					}
@@ -1821,12 +2192,10 @@ func Test_simplifyAndCachePolicy(t *testing.T) {
 			}
 		}(),
 		func() test {
-			rp := gache.New()
 			return test{
 				name: "cache success with no data",
 				args: args{
 					ctx: context.Background(),
-					rp:  rp,
 					sp: &SignedPolicy{
 						util.DomainSignedPolicyData{
 							SignedPolicyData: &util.SignedPolicyData{
@@ -1837,9 +2206,9 @@ func Test_simplifyAndCachePolicy(t *testing.T) {
 						},
 					},
 				},
-				checkFunc: func() error {
-					if len(rp.ToRawMap(context.Background())) != 0 {
-						return errors.Errorf("invalid length role policies 0, role policies: %v", rp.ToRawMap(context.Background()))
+				checkFunc: func(dp *domainPolicy) error {
+					if len(dp.rolePolicies) != 0 {
+						return errors.Errorf("invalid length role policies 0, role policies: %v", dp.rolePolicies)
 					}
 					return nil
 				},
@@ -1878,68 +2247,6 @@ func Test_simplifyAndCachePolicy(t *testing.T) {
 				wantErr: true,
 			}
 		}(),
-		func() test {
-			rp := gache.New()
-
-			rp.Set("dummyDom:role.dummyRole", []*Assertion{
-				func() *Assertion {
-					a, _ := NewAssertion("dummyAct", "dummyDom:dummyRes", "dummyEff")
-					return a
-				}(),
-			})
-			return test{
-				name: "cache can append new assertion",
-				args: args{
-					ctx: context.Background(),
-					rp:  rp,
-					sp: &SignedPolicy{
-						util.DomainSignedPolicyData{
-							SignedPolicyData: &util.SignedPolicyData{
-								Expires: &rdl.Timestamp{
-									Time: fastime.Now().Add(time.Hour).UTC(),
-								},
-								PolicyData: &util.PolicyData{
-									Policies: []*util.Policy{
-										{
-											Assertions: []*util.Assertion{
-												{
-													Role:     "dummyDom:role.dummyRole",
-													Action:   "dummyAct1",
-													Resource: "dummyDom1:dummyRes1",
-													Effect:   "allow1",
-												},
-											},
-										},
-									},
-								},
-							},
-						},
-					},
-				},
-				checkFunc: func() error {
-					if len(rp.ToRawMap(context.Background())) != 1 {
-						return errors.Errorf("invalid role policies length")
-					}
-
-					gotAsss, ok := rp.Get("dummyDom:role.dummyRole")
-					if !ok {
-						return errors.New("cannot find dummyDom:role.dummyRole")
-					}
-
-					asss := gotAsss.([]*Assertion)
-					if len(asss) != 2 {
-						return errors.New("Invalid asss length")
-					}
-
-					if err := checkAssertion(asss[0], "dummyAct", "dummyDom:dummyRes", "dummyEff1"); err != nil {
-						return err
-					}
-
-					return checkAssertion(asss[1], "dummyAct1", "dummyDom1:dummyRes1", "allow1")
-				},
-				wantErr: false,
-			}
-		}(),
 		/*
 			func() test {
 				rp := gache.New()
@@ -2013,12 +2320,10 @@ func Test_simplifyAndCachePolicy(t *testing.T) {
 			}(),
 		*/
 		func() test {
-			rp := gache.New()
 			return test{
 				name: "cache success with 100x100 data",
 				args: args{
 					ctx: context.Background(),
-					rp:  rp,
 					sp: &SignedPolicy{
 						util.DomainSignedPolicyData{
 							SignedPolicyData: &util.SignedPolicyData{
@@ -2052,19 +2357,17 @@ func Test_simplifyAndCachePolicy(t *testing.T) {
 						},
 					},
 				},
-				checkFunc: func() error {
-					if len(rp.ToRawMap(context.Background())) != 100 {
+				checkFunc: func(dp *domainPolicy) error {
+					if len(dp.rolePolicies) != 100 {
 						return errors.New("invalid length role policies 100")
 					}
 
 					var err error
-					rp.Foreach(context.Background(), func(k string, val interface{}, exp int64) bool {
-						//glg.Debugf("key: %s, val: %v", k, val)
-						if len(val.([]*Assertion)) != 100 {
+					for k, asss := range dp.rolePolicies {
+						if len(asss) != 100 {
 							err = errors.Errorf("invalid length asss 100, error: %v", k)
 						}
-						return true
-					})
+					}
 
 					return err
 				},
@@ -2072,12 +2375,10 @@ func Test_simplifyAndCachePolicy(t *testing.T) {
 			}
 		}(),
 		func() test {
-			rp := gache.New()
 			return test{
 				name: "cache success with no race condition with 100x100 data",
 				args: args{
 					ctx: context.Background(),
-					rp:  rp,
 					sp: &SignedPolicy{
 						util.DomainSignedPolicyData{
 							SignedPolicyData: &util.SignedPolicyData{
@@ -2111,19 +2412,17 @@ func Test_simplifyAndCachePolicy(t *testing.T) {
 						},
 					},
 				},
-				checkFunc: func() error {
-					if len(rp.ToRawMap(context.Background())) != 1 {
+				checkFunc: func(dp *domainPolicy) error {
+					if len(dp.rolePolicies) != 1 {
 						return errors.New("invalid length role policies 1")
 					}
 
 					var err error
-					rp.Foreach(context.Background(), func(k string, val interface{}, exp int64) bool {
-						//glg.Debugf("key: %s, val: %v", k, val)
-						if len(val.([]*Assertion)) != 10000 {
+					for k, asss := range dp.rolePolicies {
+						if len(asss) != 10000 {
 							err = errors.Errorf("invalid length asss 100, error: %v", k)
 						}
-						return true
-					})
+					}
 
 					return err
 				},
@@ -2131,12 +2430,10 @@ func Test_simplifyAndCachePolicy(t *testing.T) {
 			}
 		}(),
 		func() test {
-			rp := gache.New()
 			return test{
 				name: "cache deny policies sorted first",
 				args: args{
 					ctx: context.Background(),
-					rp:  rp,
 					sp: &SignedPolicy{
 						util.DomainSignedPolicyData{
 							SignedPolicyData: &util.SignedPolicyData{
@@ -2193,16 +2490,15 @@ func Test_simplifyAndCachePolicy(t *testing.T) {
 						},
 					},
 				},
-				checkFunc: func() error {
-					if len(rp.ToRawMap(context.Background())) != 1 {
-						return errors.Errorf("invalid length role policies 1, role policies: %v", rp.ToRawMap(context.Background()))
+				checkFunc: func(dp *domainPolicy) error {
+					if len(dp.rolePolicies) != 1 {
+						return errors.Errorf("invalid length role policies 1, role policies: %v", dp.rolePolicies)
 					}
 
-					gotRp, ok := rp.Get("dummyDom:role.dummyRole")
+					gotAsss, ok := dp.rolePolicies["dummyDom:role.dummyRole"]
 					if !ok {
 						return errors.New("cannot simplify and cache data")
 					}
-					gotAsss := gotRp.([]*Assertion)
 					if len(gotAsss) == 0 {
 						return errors.Errorf("invalid length asss, got: %v", gotAsss)
 					}
@@ -2220,12 +2516,13 @@ func Test_simplifyAndCachePolicy(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if err := simplifyAndCachePolicy(tt.args.ctx, tt.args.rp, tt.args.sp); (err != nil) != tt.wantErr {
-				t.Errorf("simplifyAndCachePolicy() error = %v, wantErr %v", err, tt.wantErr)
+			got, err := simplifyPolicy(tt.args.ctx, tt.args.sp)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("simplifyPolicy() error = %v, wantErr %v", err, tt.wantErr)
 			}
 			if tt.checkFunc != nil {
-				if err := tt.checkFunc(); err != nil {
-					t.Errorf("simplifyAndCachePolicy() error = %v", err)
+				if err := tt.checkFunc(got); err != nil {
+					t.Errorf("simplifyPolicy() error = %v", err)
 				}
 			}
 		})
@@ -2234,19 +2531,20 @@ func Test_simplifyAndCachePolicy(t *testing.T) {
 
 func Test_policyd_GetPolicyCache(t *testing.T) {
 	type fields struct {
-		expiryMargin  time.Duration
-		rolePolicies  gache.Gache
-		purgePeriod   time.Duration
-		refreshPeriod time.Duration
-		retryDelay    time.Duration
-		pkp           pubkey.Provider
-		athenzURL     string
-		athenzDomains []string
-		client        *http.Client
+		expiryMargin   time.Duration
+		domainPolicies gache.Gache
+		purgePeriod    time.Duration
+		refreshPeriod  time.Duration
+		retryDelay     time.Duration
+		pkp            pubkey.Provider
+		athenzURL      string
+		athenzDomains  []string
+		client         *http.Client
 	}
 	type args struct {
 		ctx context.Context
 	}
+	ass, _ := NewAssertion("dummyAct", "dummyDom:dummyRes", "allow")
 	tests := []struct {
 		name   string
 		fields fields
@@ -2256,10 +2554,7 @@ func Test_policyd_GetPolicyCache(t *testing.T) {
 		{
 			name: "get empty policy cache success",
 			fields: fields{
-				rolePolicies: func() gache.Gache {
-					g := gache.New()
-					return g
-				}(),
+				domainPolicies: gache.New(),
 			},
 			args: args{
 				ctx: context.Background(),
@@ -2269,25 +2564,29 @@ func Test_policyd_GetPolicyCache(t *testing.T) {
 		{
 			name: "get policy cache success",
 			fields: fields{
-				rolePolicies: func() gache.Gache {
-					g := gache.New()
-					g.Set("key", "value")
-					return g
-				}(),
+				domainPolicies: newDomainPolicies(map[string][]*Assertion{
+					"dummyDom:role.dummyRole":   {ass},
+					"dummyDom2:role.dummyRole2": {ass},
+				}),
 			},
 			args: args{
 				ctx: context.Background(),
 			},
 			want: map[string]interface{}{
-				"key": "value",
+				"dummyDom:role.dummyRole":   []*Assertion{ass},
+				"dummyDom2:role.dummyRole2": []*Assertion{ass},
 			},
 		},
 		{
 			name: "get policy cache without expired success",
 			fields: fields{
-				rolePolicies: func() gache.Gache {
+				domainPolicies: func() gache.Gache {
 					g := gache.New()
-					g.SetWithExpire("key", "value", 1*time.Nanosecond)
+					g.SetWithExpire("dummyDom", &domainPolicy{
+						rolePolicies: map[string][]*Assertion{
+							"dummyDom:role.dummyRole": {ass},
+						},
+					}, 1*time.Nanosecond)
 					time.Sleep(5 * time.Millisecond)
 					g.DeleteExpired(context.Background())
 					return g
@@ -2302,15 +2601,17 @@ func Test_policyd_GetPolicyCache(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			p := &policyd{
-				expiryMargin:  tt.fields.expiryMargin,
-				rolePolicies:  tt.fields.rolePolicies,
-				purgePeriod:   tt.fields.purgePeriod,
-				refreshPeriod: tt.fields.refreshPeriod,
-				retryDelay:    tt.fields.retryDelay,
-				pkp:           tt.fields.pkp,
-				athenzURL:     tt.fields.athenzURL,
-				athenzDomains: tt.fields.athenzDomains,
-				client:        tt.fields.client,
+				expiryMargin:    tt.fields.expiryMargin,
+				domainPolicies:  tt.fields.domainPolicies,
+				domainStatus:    gache.New(),
+				refreshTriggers: gache.New(),
+				purgePeriod:     tt.fields.purgePeriod,
+				refreshPeriod:   tt.fields.refreshPeriod,
+				retryDelay:      tt.fields.retryDelay,
+				pkp:             tt.fields.pkp,
+				athenzURL:       tt.fields.athenzURL,
+				athenzDomains:   tt.fields.athenzDomains,
+				client:          tt.fields.client,
 			}
 			if got := p.GetPolicyCache(tt.args.ctx); !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("policyd.GetPolicyCache() = %+v, want %v", got, tt.want)
@@ -2318,3 +2619,146 @@ func Test_policyd_GetPolicyCache(t *testing.T) {
 		})
 	}
 }
+
+// newDomainPolicies returns the domain policies cache of the role policies, grouped by the domain of the role.
+func newDomainPolicies(rps map[string][]*Assertion) gache.Gache {
+	g := gache.New()
+	dps := make(map[string]*domainPolicy)
+	for r, asss := range rps {
+		domain := strings.SplitN(r, ":role.", 2)[0]
+		if _, ok := dps[domain]; !ok {
+			dps[domain] = &domainPolicy{
+				rolePolicies: make(map[string][]*Assertion),
+			}
+			g.Set(domain, dps[domain])
+		}
+		dps[domain].rolePolicies[r] = asss
+	}
+	return g
+}
+
+func Test_policyd_scheduleRefresh(t *testing.T) {
+	tests := []struct {
+		name       string
+		retryDelay time.Duration
+		expires    time.Time
+		want       time.Duration
+	}{
+		{
+			name:       "trigger at the policy expiry",
+			retryDelay: time.Minute,
+			expires:    fastime.Now().Add(time.Hour),
+			want:       time.Hour,
+		},
+		{
+			name:       "trigger after the retry delay if already expired",
+			retryDelay: time.Minute,
+			expires:    fastime.Now().Add(-time.Hour),
+			want:       time.Minute,
+		},
+		{
+			name:    "trigger after a second if already expired and no retry delay",
+			expires: fastime.Now().Add(-time.Hour),
+			want:    time.Second,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &policyd{
+				refreshTriggers: gache.New(),
+				retryDelay:      tt.retryDelay,
+			}
+			p.scheduleRefresh("dummyDom", tt.expires)
+			_, exp, ok := p.refreshTriggers.GetWithExpire("dummyDom")
+			if !ok {
+				t.Errorf("policyd.scheduleRefresh() trigger not found")
+				return
+			}
+			got := time.Unix(0, exp).Sub(fastime.Now())
+			if got > tt.want || got < tt.want-10*time.Second {
+				t.Errorf("policyd.scheduleRefresh() expiry = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_policyd_refreshExpired(t *testing.T) {
+	createSp := func(expires time.Time) *SignedPolicy {
+		return &SignedPolicy{
+			util.DomainSignedPolicyData{
+				SignedPolicyData: &util.SignedPolicyData{
+					Expires: &rdl.Timestamp{Time: expires},
+					PolicyData: &util.PolicyData{
+						Domain: "dummyDom",
+						Policies: []*util.Policy{
+							{
+								Assertions: []*util.Assertion{
+									{
+										Role:     "dummyDom:role.dummyRole",
+										Effect:   "ALLOW",
+										Action:   "dummyAct",
+										Resource: "dummyDom:dummyRes",
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var fetched int32
+	fetchErr := errors.New("dummy error")
+	p := &policyd{
+		domainPolicies:  gache.New(),
+		domainStatus:    gache.New(),
+		refreshTriggers: gache.New(),
+		maxStaleness:    time.Hour,
+		retryDelay:      20 * time.Millisecond,
+		purgePeriod:     5 * time.Millisecond,
+		fetchers: map[string]Fetcher{
+			"dummyDom": &fetcherMock{
+				domainMock: func() string { return "dummyDom" },
+				fetchWithRetryMock: func(context.Context) (*SignedPolicy, error) {
+					// ZTS is down on the first refetch after the policy expiry
+					if atomic.AddInt32(&fetched, 1) == 1 {
+						return nil, fetchErr
+					}
+					return createSp(fastime.Now().Add(time.Hour)), nil
+				},
+			},
+		},
+	}
+
+	// the policy expires soon, and the stale policy is enforced within the max staleness
+	expires := fastime.Now().Add(20 * time.Millisecond)
+	if err := cachePolicy(ctx, p.domainPolicies, "dummyDom", createSp(expires), p.maxStaleness); err != nil {
+		t.Fatalf("cachePolicy() error = %v", err)
+	}
+	p.setDomainExpiry("dummyDom", expires)
+	p.scheduleRefresh("dummyDom", expires)
+	p.refreshTriggers.SetExpiredHook(p.refreshExpired).
+		EnableExpiredHook().
+		StartExpired(ctx, p.purgePeriod)
+
+	deadline := time.After(3 * time.Second)
+	for {
+		if ds := p.GetDomainStatus(ctx)["dummyDom"]; ds.Expires.After(fastime.Now().Add(time.Minute)) {
+			break
+		}
+		select {
+		case <-deadline:
+			t.Fatalf("policyd.refreshExpired() policy not refreshed, fetched: %d", atomic.LoadInt32(&fetched))
+		case <-time.After(5 * time.Millisecond):
+		}
+		if err := p.CheckPolicy(ctx, "dummyDom", []string{"dummyRole"}, "dummyAct", "dummyRes"); err != nil {
+			t.Fatalf("policyd.CheckPolicy() on the stale policy error = %v", err)
+		}
+	}
+	if got := atomic.LoadInt32(&fetched); got != 2 {
+		t.Errorf("policyd.refreshExpired() fetched = %d, want 2", got)
+	}
+}
@@ -0,0 +1,127 @@
+/*
+Copyright (C)  2018 Yahoo Japan Corporation Athenz team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"context"
+
+	"github.com/kpango/fastime"
+	"github.com/kpango/glg"
+	"github.com/pkg/errors"
+)
+
+// Decision represents the result of a policy check, reported to the DecisionHook.
+type Decision struct {
+	Domain   string
+	Roles    []string
+	Action   string
+	Resource string
+	// Err is the result of the enforced policy, nil if allowed
+	Err error
+	// DryRun is true if the denied request is allowed anyway, i.e. the policy of the domain is not enforced
+	DryRun bool
+	// Shadowed is true if the shadow policy of the domain is evaluated
+	Shadowed bool
+	// ShadowErr is the result of the shadow policy, nil if allowed
+	ShadowErr error
+}
+
+// Disagreed returns true if the enforced policy and the shadow policy made different decisions.
+func (d *Decision) Disagreed() bool {
+	return d.Shadowed && (d.Err == nil) != (d.ShadowErr == nil)
+}
+
+// DecisionHook is called with the result of each policy check, e.g. to export the audit logs or the metrics.
+// It is called synchronously in CheckPolicy, so it should not block.
+type DecisionHook func(ctx context.Context, d Decision)
+
+// decide reports the result of the enforced policy, evaluates the shadow policy, and returns the error to enforce.
+// In dry-run mode, the request denied by the policy is allowed and only logged.
+func (p *policyd) decide(ctx context.Context, domain string, roles []string, action, resource string, err error) error {
+	if !p.dryRun && len(p.dryRunDomains) == 0 && p.shadowSource == nil && p.decisionHook == nil {
+		return err
+	}
+
+	d := Decision{
+		Domain:   domain,
+		Roles:    roles,
+		Action:   action,
+		Resource: resource,
+		Err:      err,
+	}
+	if p.shadowSource != nil {
+		if _, ok := p.shadowPolicies.Get(domain); ok {
+			d.Shadowed = true
+			d.ShadowErr = checkPolicy(ctx, p.shadowPolicies, domain, roles, action, resource)
+			if d.Disagreed() {
+				glg.Warnf("shadow policy disagreed, domain: %s, role: %v, action: %s, resource: %s, enforced: %v, shadow: %v", domain, roles, action, resource, err, d.ShadowErr)
+			}
+		}
+	}
+	if err != nil && p.isDryRun(domain) && isPolicyDenial(err) {
+		d.DryRun = true
+	}
+	if p.decisionHook != nil {
+		p.decisionHook(ctx, d)
+	}
+
+	if d.DryRun {
+		glg.Infof("dry-run, allow the request denied by policy, domain: %s, role: %v, action: %s, resource: %s, error: %v", domain, roles, action, resource, err)
+		return nil
+	}
+	return err
+}
+
+// isDryRun returns true if the policy of the domain is not enforced.
+func (p *policyd) isDryRun(domain string) bool {
+	if p.dryRun {
+		return true
+	}
+	_, ok := p.dryRunDomains[domain]
+	return ok
+}
+
+// isPolicyDenial returns true if the request is denied by the policy, not by the other errors, e.g. the context canceled.
+func isPolicyDenial(err error) bool {
+	switch errors.Cause(err) {
+	case ErrDenyByPolicy, ErrNoMatch, ErrDomainExpired:
+		return true
+	}
+	return false
+}
+
+// updateShadow fetches the shadow policies of the domains, and replaces the shadow policy of each domain.
+// The shadow policies never affect the enforced policies, so the errors are only logged.
+func (p *policyd) updateShadow(ctx context.Context) {
+	if p.shadowSource == nil {
+		return
+	}
+	for domain, sp := range p.fetchFrom(ctx, p.shadowSource, p.athenzDomains) {
+		dp, err := simplifyPolicy(ctx, sp)
+		if err != nil {
+			glg.Errorf("simplify shadow policy fail, domain: %s, error: %v", domain, err)
+			continue
+		}
+		// gache never expires the entry with non-positive expiry
+		exp := dp.expires.Sub(fastime.Now())
+		if exp <= 0 {
+			glg.Warnf("skip expired shadow policy, domain: %s, expires: %s", domain, dp.expires)
+			continue
+		}
+		p.shadowPolicies.SetWithExpire(domain, dp, exp)
+	}
+}
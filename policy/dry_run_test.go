@@ -0,0 +1,229 @@
+/*
+Copyright (C)  2018 Yahoo Japan Corporation Athenz team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kpango/fastime"
+	"github.com/kpango/gache"
+	"github.com/pkg/errors"
+	authcore "github.com/yahoo/athenz/libs/go/zmssvctoken"
+	"github.com/yahoojapan/athenz-authorizer/v5/pubkey"
+)
+
+func Test_policyd_decide(t *testing.T) {
+	allow, _ := NewAssertion("act", "dummyDom:res", "allow")
+	shadowPolicies := newDomainPolicies(map[string][]*Assertion{
+		"dummyDom:role.role1": {allow},
+	})
+	shadowSource := BulkSourceFunc(func(context.Context, []string) ([]*SignedPolicy, error) {
+		return nil, nil
+	})
+	type fields struct {
+		shadowPolicies gache.Gache
+		shadowSource   BulkSource
+		dryRun         bool
+		dryRunDomains  map[string]struct{}
+	}
+	type args struct {
+		domain string
+		err    error
+	}
+	tests := []struct {
+		name         string
+		fields       fields
+		args         args
+		wantErr      error
+		wantDecision *Decision
+	}{
+		{
+			name: "enforce the denied request",
+			args: args{
+				domain: "dummyDom",
+				err:    errors.Wrap(ErrNoMatch, "no match"),
+			},
+			wantErr: ErrNoMatch,
+			wantDecision: &Decision{
+				Domain: "dummyDom",
+				Err:    ErrNoMatch,
+			},
+		},
+		{
+			name: "dry-run allows the request denied by policy",
+			fields: fields{
+				dryRun: true,
+			},
+			args: args{
+				domain: "dummyDom",
+				err:    errors.Wrap(ErrDenyByPolicy, "policy deny"),
+			},
+			wantErr: nil,
+			wantDecision: &Decision{
+				Domain: "dummyDom",
+				Err:    ErrDenyByPolicy,
+				DryRun: true,
+			},
+		},
+		{
+			name: "dry-run of the domain allows the request denied by policy",
+			fields: fields{
+				dryRunDomains: map[string]struct{}{"dummyDom": {}},
+			},
+			args: args{
+				domain: "dummyDom",
+				err:    errors.Wrap(ErrDomainExpired, "policy expired"),
+			},
+			wantErr: nil,
+			wantDecision: &Decision{
+				Domain: "dummyDom",
+				Err:    ErrDomainExpired,
+				DryRun: true,
+			},
+		},
+		{
+			name: "dry-run of the other domain enforces the denied request",
+			fields: fields{
+				dryRunDomains: map[string]struct{}{"otherDom": {}},
+			},
+			args: args{
+				domain: "dummyDom",
+				err:    errors.Wrap(ErrNoMatch, "no match"),
+			},
+			wantErr: ErrNoMatch,
+			wantDecision: &Decision{
+				Domain: "dummyDom",
+				Err:    ErrNoMatch,
+			},
+		},
+		{
+			name: "dry-run does not allow the request failed by the other error",
+			fields: fields{
+				dryRun: true,
+			},
+			args: args{
+				domain: "dummyDom",
+				err:    context.Canceled,
+			},
+			wantErr: context.Canceled,
+			wantDecision: &Decision{
+				Domain: "dummyDom",
+				Err:    context.Canceled,
+			},
+		},
+		{
+			name: "shadow policy disagreed",
+			fields: fields{
+				shadowPolicies: shadowPolicies,
+				shadowSource:   shadowSource,
+			},
+			args: args{
+				domain: "dummyDom",
+				err:    errors.Wrap(ErrNoMatch, "no match"),
+			},
+			wantErr: ErrNoMatch,
+			wantDecision: &Decision{
+				Domain:   "dummyDom",
+				Err:      ErrNoMatch,
+				Shadowed: true,
+			},
+		},
+		{
+			name: "shadow policy of the domain not found",
+			fields: fields{
+				shadowPolicies: shadowPolicies,
+				shadowSource:   shadowSource,
+			},
+			args: args{
+				domain: "otherDom",
+				err:    nil,
+			},
+			wantErr: nil,
+			wantDecision: &Decision{
+				Domain: "otherDom",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got *Decision
+			p := &policyd{
+				shadowPolicies: tt.fields.shadowPolicies,
+				shadowSource:   tt.fields.shadowSource,
+				dryRun:         tt.fields.dryRun,
+				dryRunDomains:  tt.fields.dryRunDomains,
+				decisionHook: func(ctx context.Context, d Decision) {
+					got = &d
+				},
+			}
+			err := p.decide(context.Background(), tt.args.domain, []string{"role1"}, "act", "res", tt.args.err)
+			if errors.Cause(err) != tt.wantErr {
+				t.Errorf("policyd.decide() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got == nil {
+				t.Errorf("policyd.decide() decision not reported")
+				return
+			}
+			if got.Domain != tt.wantDecision.Domain ||
+				errors.Cause(got.Err) != tt.wantDecision.Err ||
+				got.DryRun != tt.wantDecision.DryRun ||
+				got.Shadowed != tt.wantDecision.Shadowed ||
+				(got.ShadowErr != nil) != (tt.wantDecision.ShadowErr != nil) {
+				t.Errorf("policyd.decide() decision = %+v, want %+v", got, tt.wantDecision)
+			}
+			if got.Disagreed() != (tt.wantDecision.Shadowed && (tt.wantDecision.Err == nil) != (tt.wantDecision.ShadowErr == nil)) {
+				t.Errorf("Decision.Disagreed() = %v", got.Disagreed())
+			}
+		})
+	}
+}
+
+func Test_policyd_updateShadow(t *testing.T) {
+	p := &policyd{
+		athenzDomains:  []string{"dummyDom1", "dummyDom2"},
+		domainPolicies: gache.New(),
+		shadowPolicies: gache.New(),
+		pkp: func(pubkey.AthenzEnv, string) authcore.Verifier {
+			return VerifierMock{
+				VerifyFunc: func(d, s string) error { return nil },
+			}
+		},
+		shadowSource: BulkSourceFunc(func(context.Context, []string) ([]*SignedPolicy, error) {
+			expired := createBulkSignedPolicy("dummyDom2", "sig")
+			expired.SignedPolicyData.Expires.Time = fastime.Now().Add(-time.Hour)
+			return []*SignedPolicy{createBulkSignedPolicy("dummyDom1", "sig"), expired}, nil
+		}),
+	}
+	ctx := context.Background()
+	p.updateShadow(ctx)
+
+	if _, ok := p.shadowPolicies.Get("dummyDom1"); !ok {
+		t.Errorf("policyd.updateShadow() shadow policy of dummyDom1 not cached")
+	}
+	if _, ok := p.shadowPolicies.Get("dummyDom2"); ok {
+		t.Errorf("policyd.updateShadow() expired shadow policy of dummyDom2 cached")
+	}
+	if got := len(p.GetPolicyCache(ctx)); got != 0 {
+		t.Errorf("policyd.updateShadow() enforced policies = %d, want 0", got)
+	}
+	if err := p.decide(ctx, "dummyDom1", []string{"dummyRole"}, "dummyAct", "dummyRes", errors.Wrap(ErrNoMatch, "no match")); errors.Cause(err) != ErrNoMatch {
+		t.Errorf("policyd.decide() error = %v, want %v", err, ErrNoMatch)
+	}
+}
@@ -16,7 +16,13 @@ limitations under the License.
 
 package policy
 
-import "github.com/pkg/errors"
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
 
 var (
 	// ErrDomainMismatch "Access denied due to domain mismatch between Resource and RoleToken"
@@ -40,3 +46,29 @@ var (
 	// ErrFetchPolicy "Error fetching athenz policy"
 	ErrFetchPolicy = errors.New("Error fetching athenz policy")
 )
+
+// UpdateError represents the errors of the domains failed to update. The failed domains keep the previous policies, and the other domains are updated.
+type UpdateError struct {
+	// Errs has the format of map[<domain>]error
+	Errs map[string]error
+}
+
+// Error returns the error of each failed domain, sorted by the domain.
+func (e *UpdateError) Error() string {
+	domains := e.Domains()
+	msgs := make([]string, 0, len(domains))
+	for _, d := range domains {
+		msgs = append(msgs, fmt.Sprintf("%s: %v", d, e.Errs[d]))
+	}
+	return "update policy fail, domains: " + strings.Join(msgs, "; ")
+}
+
+// Domains returns the failed domains in sorted order.
+func (e *UpdateError) Domains() []string {
+	domains := make([]string, 0, len(e.Errs))
+	for d := range e.Errs {
+		domains = append(domains, d)
+	}
+	sort.Strings(domains)
+	return domains
+}
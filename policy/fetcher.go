@@ -30,6 +30,8 @@ import (
 	"github.com/kpango/fastime"
 	"github.com/kpango/glg"
 	"github.com/pkg/errors"
+	"github.com/yahoojapan/athenz-authorizer/v5/internal/supervisor"
+	"golang.org/x/sync/singleflight"
 )
 
 // SignedPolicyVerifier type defines the function signature to verify a signed policy.
@@ -50,6 +52,7 @@ type fetcher struct {
 	// retry related
 	retryDelay    time.Duration
 	retryAttempts int
+	maxRetryDelay time.Duration // give up the retry if Retry-After exceeds it, no limit if zero
 
 	// athenz related
 	domain     string
@@ -100,7 +103,10 @@ func (f *fetcher) Fetch(ctx context.Context) (*SignedPolicy, error) {
 	if err != nil {
 		errMsg := "fetch policy HTTP request fail"
 		glg.Errorf("%s, domain: %s, error: %v", errMsg, f.domain, err)
-		return nil, errors.Wrap(err, errMsg)
+		if ctx.Err() != nil {
+			return nil, errors.Wrap(err, errMsg)
+		}
+		return nil, retryable(errors.Wrap(err, errMsg), 0)
 	}
 	defer func() {
 		if err := flushAndClose(res.Body); err != nil {
@@ -117,6 +123,9 @@ func (f *fetcher) Fetch(ctx context.Context) (*SignedPolicy, error) {
 	if res.StatusCode != http.StatusOK {
 		errMsg := "fetch policy HTTP response != 200 OK"
 		glg.Errorf("%s, domain: %s, status: %d", errMsg, f.domain, res.StatusCode)
+		if isRetryableStatus(res.StatusCode) {
+			return nil, retryable(errors.Wrap(ErrFetchPolicy, errMsg), parseRetryAfter(res))
+		}
 		return nil, errors.Wrap(ErrFetchPolicy, errMsg)
 	}
 
@@ -125,7 +134,8 @@ func (f *fetcher) Fetch(ctx context.Context) (*SignedPolicy, error) {
 	if err = json.NewDecoder(res.Body).Decode(&sp); err != nil {
 		errMsg := "policy decode fail"
 		glg.Errorf("%s, domain: %s, error: %v", errMsg, f.domain, err)
-		return nil, errors.Wrap(err, errMsg)
+		// the response may be truncated
+		return nil, retryable(errors.Wrap(err, errMsg), 0)
 	}
 
 	// verify policy data
@@ -151,20 +161,50 @@ func (f *fetcher) Fetch(ctx context.Context) (*SignedPolicy, error) {
 }
 
 // FetchWithRetry fetches policy with retry. Returns cached policy if all retries failed too.
+// Only the retryable errors are retried, with the exponential backoff from retryDelay and jitter, up to maxRetryDelay. The Retry-After on 429 and 503 is honored.
+// The retry is given up if the Retry-After exceeds maxRetryDelay, the policy is refetched on the next refresh instead.
+// The retry is canceled when ctx is done.
 func (f *fetcher) FetchWithRetry(ctx context.Context) (*SignedPolicy, error) {
+	backoff := supervisor.Backoff{
+		Initial: f.retryDelay,
+		Jitter:  supervisor.DefaultJitter,
+	}
 	var lastErr error
-	for i := -1; i < f.retryAttempts; i++ {
+	errMsg := "max. retry count excess"
+	for i := 0; i <= f.retryAttempts; i++ {
 		sp, err := f.Fetch(ctx)
 		if err == nil {
 			return sp, nil
 		}
-
 		lastErr = err
-		time.Sleep(f.retryDelay)
+
+		ok, retryAfter := retryInfo(err)
+		if !ok {
+			errMsg = "fetch policy fail without retry"
+			break
+		}
+		if i == f.retryAttempts {
+			break
+		}
+		if f.maxRetryDelay > 0 && retryAfter > f.maxRetryDelay {
+			errMsg = "Retry-After exceeds max. retry delay"
+			break
+		}
+		delay := backoff.Duration(i + 1)
+		if retryAfter > delay {
+			delay = retryAfter
+		}
+		if f.maxRetryDelay > 0 && delay > f.maxRetryDelay {
+			delay = f.maxRetryDelay
+		}
+		glg.Debugf("will retry fetching policy after %s, domain: %s, error: %v", delay, f.domain, err)
+		if err := supervisor.Sleep(ctx, delay); err != nil {
+			errMsg = "fetch policy retry canceled"
+			break
+		}
 	}
 
-	errMsg := "max. retry count excess"
-	glg.Info("Will use policy cache, since: %s, domain: %s, error: %v", errMsg, f.domain, lastErr)
+	glg.Infof("Will use policy cache, since: %s, domain: %s, error: %v", errMsg, f.domain, lastErr)
 	if lastErr == nil {
 		lastErr = fmt.Errorf("retryAttempts %v", f.retryAttempts)
 	}
@@ -174,6 +214,25 @@ func (f *fetcher) FetchWithRetry(ctx context.Context) (*SignedPolicy, error) {
 	return (*taggedPolicy)(atomic.LoadPointer(&f.policyCache)).sp, errors.Wrap(lastErr, errMsg)
 }
 
+// singleflightFetcher coalesces the concurrent fetches of the same domain, e.g. by the expired hook and the periodic update.
+// The waiting callers share the result of the first caller.
+type singleflightFetcher struct {
+	Fetcher
+	group singleflight.Group
+}
+
+// FetchWithRetry calls FetchWithRetry of the underlying fetcher, or waits for the result of the ongoing call.
+func (f *singleflightFetcher) FetchWithRetry(ctx context.Context) (*SignedPolicy, error) {
+	v, err, shared := f.group.Do(f.Domain(), func() (interface{}, error) {
+		return f.Fetcher.FetchWithRetry(ctx)
+	})
+	if shared {
+		glg.Debugf("shared the policy fetch, domain: %s", f.Domain())
+	}
+	sp, _ := v.(*SignedPolicy)
+	return sp, err
+}
+
 func (t *taggedPolicy) String() string {
 	var policyDomain string
 	if t.sp != nil && t.sp.SignedPolicyData != nil && t.sp.SignedPolicyData.PolicyData != nil {
@@ -1125,9 +1125,10 @@ func Test_fetcher_FetchWithRetry(t *testing.T) {
 					return err
 				}
 
-				// check retry interval
+				// check retry interval, backed off exponentially (100ms + 200ms) with jitter
 				diff := a.ctime.Sub(b.ctime)
-				if diff < retryDelay*time.Duration(retryAttempts) || diff > retryDelay*time.Duration(retryAttempts+1) {
+				backoff := retryDelay * time.Duration(1<<uint(retryAttempts)-1)
+				if diff < backoff-backoff/10 || diff > backoff+retryDelay {
 					return errors.New("retry interval not working")
 				}
 				return nil
@@ -1317,6 +1318,225 @@ func Test_fetcher_FetchWithRetry(t *testing.T) {
 
 }
 
+func Test_fetcher_FetchWithRetry_retryable(t *testing.T) {
+	type test struct {
+		name          string
+		handler       func(*uint32) http.HandlerFunc
+		spVerifier    SignedPolicyVerifier
+		retryDelay    time.Duration
+		retryAttempts int
+		maxRetryDelay time.Duration
+		ctx           func() context.Context
+		wantRequests  uint32
+		minElapsed    time.Duration
+		maxElapsed    time.Duration
+		wantErrStr    string
+	}
+	body := `{"keyId":"keyId","signedPolicyData":{"expires":""}}`
+	tests := []test{
+		{
+			name: "signature failure, not retried",
+			handler: func(cnt *uint32) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					atomic.AddUint32(cnt, 1)
+					_, _ = w.Write([]byte(body))
+				}
+			},
+			spVerifier:    func(sp *SignedPolicy) error { return errors.New("invalid signature") },
+			retryDelay:    time.Minute,
+			retryAttempts: 2,
+			ctx:           context.Background,
+			wantRequests:  1,
+			maxElapsed:    time.Second,
+			wantErrStr:    "no policy cache: fetch policy fail without retry: invalid policy: invalid signature",
+		},
+		{
+			name: "4xx, not retried",
+			handler: func(cnt *uint32) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					atomic.AddUint32(cnt, 1)
+					w.WriteHeader(http.StatusNotFound)
+				}
+			},
+			spVerifier:    func(sp *SignedPolicy) error { return nil },
+			retryDelay:    time.Minute,
+			retryAttempts: 2,
+			ctx:           context.Background,
+			wantRequests:  1,
+			maxElapsed:    time.Second,
+			wantErrStr:    "no policy cache: fetch policy fail without retry: fetch policy HTTP response != 200 OK: Error fetching athenz policy",
+		},
+		{
+			name: "Retry-After on 503 honored",
+			handler: func(cnt *uint32) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					if atomic.AddUint32(cnt, 1) == 1 {
+						w.Header().Set("Retry-After", "1")
+						w.WriteHeader(http.StatusServiceUnavailable)
+						return
+					}
+					_, _ = w.Write([]byte(body))
+				}
+			},
+			spVerifier:    func(sp *SignedPolicy) error { return nil },
+			retryDelay:    time.Millisecond,
+			retryAttempts: 2,
+			ctx:           context.Background,
+			wantRequests:  2,
+			minElapsed:    time.Second,
+			maxElapsed:    2 * time.Second,
+		},
+		{
+			name: "Retry-After exceeding max retry delay, given up",
+			handler: func(cnt *uint32) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					atomic.AddUint32(cnt, 1)
+					w.Header().Set("Retry-After", "3600")
+					w.WriteHeader(http.StatusServiceUnavailable)
+				}
+			},
+			spVerifier:    func(sp *SignedPolicy) error { return nil },
+			retryDelay:    time.Millisecond,
+			retryAttempts: 2,
+			maxRetryDelay: time.Second,
+			ctx:           context.Background,
+			wantRequests:  1,
+			maxElapsed:    time.Second,
+			wantErrStr:    "no policy cache: Retry-After exceeds max. retry delay: fetch policy HTTP response != 200 OK: Error fetching athenz policy",
+		},
+		{
+			name: "backoff capped by max retry delay",
+			handler: func(cnt *uint32) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					atomic.AddUint32(cnt, 1)
+					w.WriteHeader(http.StatusInternalServerError)
+				}
+			},
+			spVerifier:    func(sp *SignedPolicy) error { return nil },
+			retryDelay:    time.Hour,
+			retryAttempts: 1,
+			maxRetryDelay: 100 * time.Millisecond,
+			ctx:           context.Background,
+			wantRequests:  2,
+			minElapsed:    90 * time.Millisecond,
+			maxElapsed:    time.Second,
+			wantErrStr:    "no policy cache: max. retry count excess: fetch policy HTTP response != 200 OK: Error fetching athenz policy",
+		},
+		{
+			name: "no sleep after the final attempt",
+			handler: func(cnt *uint32) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					atomic.AddUint32(cnt, 1)
+					w.WriteHeader(http.StatusInternalServerError)
+				}
+			},
+			spVerifier:    func(sp *SignedPolicy) error { return nil },
+			retryDelay:    100 * time.Millisecond,
+			retryAttempts: 1,
+			ctx:           context.Background,
+			wantRequests:  2,
+			minElapsed:    90 * time.Millisecond,
+			maxElapsed:    190 * time.Millisecond,
+			wantErrStr:    "no policy cache: max. retry count excess: fetch policy HTTP response != 200 OK: Error fetching athenz policy",
+		},
+		{
+			name: "retry canceled by context",
+			handler: func(cnt *uint32) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					atomic.AddUint32(cnt, 1)
+					w.WriteHeader(http.StatusInternalServerError)
+				}
+			},
+			spVerifier:    func(sp *SignedPolicy) error { return nil },
+			retryDelay:    time.Hour,
+			retryAttempts: 2,
+			ctx: func() context.Context {
+				ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+				go func() {
+					<-ctx.Done()
+					cancel()
+				}()
+				return ctx
+			},
+			wantRequests: 1,
+			maxElapsed:   time.Second,
+			wantErrStr:   "no policy cache: fetch policy retry canceled: fetch policy HTTP response != 200 OK: Error fetching athenz policy",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cnt uint32
+			srv := httptest.NewTLSServer(tt.handler(&cnt))
+			defer srv.Close()
+			f := &fetcher{
+				retryDelay:    tt.retryDelay,
+				retryAttempts: tt.retryAttempts,
+				maxRetryDelay: tt.maxRetryDelay,
+				domain:        "dummyDomain",
+				athenzURL:     strings.Replace(srv.URL, "https://", "", 1),
+				spVerifier:    tt.spVerifier,
+				client:        srv.Client(),
+			}
+			start := time.Now()
+			_, err := f.FetchWithRetry(tt.ctx())
+			elapsed := time.Since(start)
+			if (err == nil && tt.wantErrStr != "") || (err != nil && err.Error() != tt.wantErrStr) {
+				t.Errorf("fetcher.FetchWithRetry() error = %v, wantErr %v", err, tt.wantErrStr)
+			}
+			if got := atomic.LoadUint32(&cnt); got != tt.wantRequests {
+				t.Errorf("fetcher.FetchWithRetry() requests = %d, want %d", got, tt.wantRequests)
+			}
+			if elapsed < tt.minElapsed || elapsed > tt.maxElapsed {
+				t.Errorf("fetcher.FetchWithRetry() elapsed = %v, want between %v and %v", elapsed, tt.minElapsed, tt.maxElapsed)
+			}
+		})
+	}
+}
+
+func Test_singleflightFetcher_FetchWithRetry(t *testing.T) {
+	sp := &SignedPolicy{}
+	var calls int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	f := &singleflightFetcher{
+		Fetcher: &fetcherMock{
+			domainMock: func() string { return "dummyDom" },
+			fetchWithRetryMock: func(context.Context) (*SignedPolicy, error) {
+				if atomic.AddInt32(&calls, 1) == 1 {
+					close(started)
+				}
+				<-release
+				return sp, errors.New("dummy error")
+			},
+		},
+	}
+
+	type result struct {
+		sp  *SignedPolicy
+		err error
+	}
+	results := make(chan result, 10)
+	for i := 0; i < cap(results); i++ {
+		go func() {
+			got, err := f.FetchWithRetry(context.Background())
+			results <- result{got, err}
+		}()
+	}
+	<-started
+	time.Sleep(50 * time.Millisecond) // wait for the other callers to join the ongoing fetch
+	close(release)
+
+	for i := 0; i < cap(results); i++ {
+		r := <-results
+		if r.sp != sp || r.err == nil || r.err.Error() != "dummy error" {
+			t.Errorf("singleflightFetcher.FetchWithRetry() = %v, %v, want %v, dummy error", r.sp, r.err, sp)
+		}
+	}
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Errorf("singleflightFetcher.FetchWithRetry() fetched %d times, want 1", got)
+	}
+}
+
 func Test_taggedPolicy_String(t *testing.T) {
 	type fields struct {
 		eTag       string
@@ -32,6 +32,10 @@ var (
 		WithPurgePeriod("1h"),
 		WithRetryDelay("1m"),
 		WithRetryAttempts(2),
+		WithMaxRetryDelay("5m"),
+		WithMaxStaleness("0"),
+		WithFetchConcurrency(8),
+		WithRefreshSpread("0"),
 		WithHTTPClient(http.DefaultClient),
 	}
 )
@@ -87,6 +91,25 @@ func WithExpiryMargin(d string) Option {
 	}
 }
 
+// WithMaxStaleness returns a MaxStaleness functional option.
+// The expired policy is still enforced within the duration if it cannot be refreshed, afterwards the requests to the domain are denied with ErrDomainExpired.
+func WithMaxStaleness(d string) Option {
+	return func(pol *policyd) error {
+		if d == "" {
+			return nil
+		}
+		ms, err := time.ParseDuration(d)
+		if err != nil {
+			return errors.Wrap(err, "invalid max staleness")
+		}
+		if ms < 0 {
+			return errors.New("invalid max staleness: negative duration")
+		}
+		pol.maxStaleness = ms
+		return nil
+	}
+}
+
 // WithRefreshPeriod returns a RefreshPeriod functional option
 func WithRefreshPeriod(d string) Option {
 	return func(pol *policyd) error {
@@ -143,6 +166,108 @@ func WithRetryAttempts(c int) Option {
 	}
 }
 
+// WithMaxRetryDelay returns a MaxRetryDelay functional option.
+// The retry delay is capped by it, and the retry is given up if the Retry-After from the server exceeds it.
+func WithMaxRetryDelay(d string) Option {
+	return func(pol *policyd) error {
+		if d == "" {
+			return nil
+		}
+		md, err := time.ParseDuration(d)
+		if err != nil {
+			return errors.Wrap(err, "invalid max retry delay")
+		}
+		if md <= 0 {
+			return errors.New("invalid max retry delay")
+		}
+		pol.maxRetryDelay = md
+		return nil
+	}
+}
+
+// WithFetchConcurrency returns a FetchConcurrency functional option.
+// It limits the number of the domains fetched concurrently.
+func WithFetchConcurrency(n int) Option {
+	return func(pol *policyd) error {
+		if n < 1 {
+			return errors.New("invalid fetch concurrency")
+		}
+		pol.fetchConcurrency = n
+		return nil
+	}
+}
+
+// WithRefreshSpread returns a RefreshSpread functional option.
+// The periodic fetches of the domains are delayed randomly within the duration, to avoid the burst of the requests to Athenz.
+func WithRefreshSpread(d string) Option {
+	return func(pol *policyd) error {
+		if d == "" {
+			return nil
+		}
+		rs, err := time.ParseDuration(d)
+		if err != nil {
+			return errors.Wrap(err, "invalid refresh spread")
+		}
+		if rs < 0 {
+			return errors.New("invalid refresh spread: negative duration")
+		}
+		pol.refreshSpread = rs
+		return nil
+	}
+}
+
+// WithBulkSource returns a BulkSource functional option.
+// The policies of the domains are fetched from the source at once, and each policy is verified independently.
+// The domains not in the source, or with the invalid policies, are fetched from ZTS.
+func WithBulkSource(s BulkSource) Option {
+	return func(pol *policyd) error {
+		pol.bulkSource = s
+		return nil
+	}
+}
+
+// WithShadowSource returns a ShadowSource functional option.
+// The shadow policies of the domains are fetched from the source and evaluated with the enforced policies, and the disagreements are logged.
+func WithShadowSource(s BulkSource) Option {
+	return func(pol *policyd) error {
+		pol.shadowSource = s
+		return nil
+	}
+}
+
+// WithDryRun returns a DryRun functional option.
+// The requests denied by the policy of any domain are allowed, and only reported to the DecisionHook and logged.
+func WithDryRun(b bool) Option {
+	return func(pol *policyd) error {
+		pol.dryRun = b
+		return nil
+	}
+}
+
+// WithDryRunDomains returns a DryRunDomains functional option.
+// The requests denied by the policy of the domains are allowed, and only reported to the DecisionHook and logged.
+func WithDryRunDomains(doms ...string) Option {
+	return func(pol *policyd) error {
+		if len(doms) == 0 {
+			return nil
+		}
+		pol.dryRunDomains = make(map[string]struct{}, len(doms))
+		for _, d := range doms {
+			pol.dryRunDomains[d] = struct{}{}
+		}
+		return nil
+	}
+}
+
+// WithDecisionHook returns a DecisionHook functional option.
+// The hook is called with the result of each policy check.
+func WithDecisionHook(h DecisionHook) Option {
+	return func(pol *policyd) error {
+		pol.decisionHook = h
+		return nil
+	}
+}
+
 // WithHTTPClient returns a HttpClient functional option
 func WithHTTPClient(c *http.Client) Option {
 	return func(pol *policyd) error {
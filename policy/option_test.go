@@ -16,6 +16,7 @@ limitations under the License.
 package policy
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"reflect"
@@ -347,6 +348,270 @@ func TestWithRefreshPeriod(t *testing.T) {
 	}
 }
 
+func TestWithBulkSource(t *testing.T) {
+	type args struct {
+		s BulkSource
+	}
+	tests := []struct {
+		name      string
+		args      args
+		checkFunc func(Option) error
+	}{
+		{
+			name: "set success",
+			args: args{
+				s: BulkSourceFunc(func(context.Context, []string) ([]*SignedPolicy, error) {
+					return nil, nil
+				}),
+			},
+			checkFunc: func(opt Option) error {
+				pol := &policyd{}
+				if err := opt(pol); err != nil {
+					return err
+				}
+				if pol.bulkSource == nil {
+					return fmt.Errorf("invalid param was set")
+				}
+				return nil
+			},
+		},
+		{
+			name: "empty value",
+			args: args{
+				s: nil,
+			},
+			checkFunc: func(opt Option) error {
+				pol := &policyd{}
+				if err := opt(pol); err != nil {
+					return err
+				}
+				if !reflect.DeepEqual(pol, &policyd{}) {
+					return fmt.Errorf("expected no changes, but got %v", pol)
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithBulkSource(tt.args.s)
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithBulkSource() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestWithShadowSource(t *testing.T) {
+	type args struct {
+		v BulkSource
+	}
+	tests := []struct {
+		name      string
+		args      args
+		checkFunc func(Option) error
+	}{
+		{
+			name: "set success",
+			args: args{
+				v: BulkSourceFunc(func(context.Context, []string) ([]*SignedPolicy, error) {
+					return nil, nil
+				}),
+			},
+			checkFunc: func(opt Option) error {
+				pol := &policyd{}
+				if err := opt(pol); err != nil {
+					return err
+				}
+				if pol.shadowSource == nil {
+					return fmt.Errorf("invalid param was set")
+				}
+				return nil
+			},
+		},
+		{
+			name: "empty value",
+			args: args{
+				v: nil,
+			},
+			checkFunc: func(opt Option) error {
+				pol := &policyd{}
+				if err := opt(pol); err != nil {
+					return err
+				}
+				if !reflect.DeepEqual(pol, &policyd{}) {
+					return fmt.Errorf("expected no changes, but got %v", pol)
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithShadowSource(tt.args.v)
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithShadowSource() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestWithDryRun(t *testing.T) {
+	type args struct {
+		v bool
+	}
+	tests := []struct {
+		name      string
+		args      args
+		checkFunc func(Option) error
+	}{
+		{
+			name: "set success",
+			args: args{
+				v: true,
+			},
+			checkFunc: func(opt Option) error {
+				pol := &policyd{}
+				if err := opt(pol); err != nil {
+					return err
+				}
+				if !pol.dryRun {
+					return fmt.Errorf("invalid param was set")
+				}
+				return nil
+			},
+		},
+		{
+			name: "empty value",
+			args: args{
+				v: false,
+			},
+			checkFunc: func(opt Option) error {
+				pol := &policyd{}
+				if err := opt(pol); err != nil {
+					return err
+				}
+				if !reflect.DeepEqual(pol, &policyd{}) {
+					return fmt.Errorf("expected no changes, but got %v", pol)
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithDryRun(tt.args.v)
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithDryRun() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestWithDryRunDomains(t *testing.T) {
+	type args struct {
+		v []string
+	}
+	tests := []struct {
+		name      string
+		args      args
+		checkFunc func(Option) error
+	}{
+		{
+			name: "set success",
+			args: args{
+				v: []string{"dom1", "dom2"},
+			},
+			checkFunc: func(opt Option) error {
+				pol := &policyd{}
+				if err := opt(pol); err != nil {
+					return err
+				}
+				if !reflect.DeepEqual(pol.dryRunDomains, map[string]struct{}{"dom1": {}, "dom2": {}}) {
+					return fmt.Errorf("invalid param was set")
+				}
+				return nil
+			},
+		},
+		{
+			name: "empty value",
+			args: args{
+				v: nil,
+			},
+			checkFunc: func(opt Option) error {
+				pol := &policyd{}
+				if err := opt(pol); err != nil {
+					return err
+				}
+				if !reflect.DeepEqual(pol, &policyd{}) {
+					return fmt.Errorf("expected no changes, but got %v", pol)
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithDryRunDomains(tt.args.v...)
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithDryRunDomains() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestWithDecisionHook(t *testing.T) {
+	type args struct {
+		v DecisionHook
+	}
+	tests := []struct {
+		name      string
+		args      args
+		checkFunc func(Option) error
+	}{
+		{
+			name: "set success",
+			args: args{
+				v: func(context.Context, Decision) {},
+			},
+			checkFunc: func(opt Option) error {
+				pol := &policyd{}
+				if err := opt(pol); err != nil {
+					return err
+				}
+				if pol.decisionHook == nil {
+					return fmt.Errorf("invalid param was set")
+				}
+				return nil
+			},
+		},
+		{
+			name: "empty value",
+			args: args{
+				v: nil,
+			},
+			checkFunc: func(opt Option) error {
+				pol := &policyd{}
+				if err := opt(pol); err != nil {
+					return err
+				}
+				if !reflect.DeepEqual(pol, &policyd{}) {
+					return fmt.Errorf("expected no changes, but got %v", pol)
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithDecisionHook(tt.args.v)
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithDecisionHook() error = %v", err)
+			}
+		})
+	}
+}
+
 func TestWithHTTPClient(t *testing.T) {
 	type args struct {
 		c *http.Client
@@ -530,6 +795,300 @@ func TestWithRetryDelay(t *testing.T) {
 	}
 }
 
+func TestWithMaxRetryDelay(t *testing.T) {
+	type args struct {
+		d string
+	}
+	tests := []struct {
+		name      string
+		args      args
+		checkFunc func(Option) error
+	}{
+		{
+			name: "set success",
+			args: args{
+				"10m",
+			},
+			checkFunc: func(opt Option) error {
+				pol := &policyd{}
+				if err := opt(pol); err != nil {
+					return err
+				}
+				if pol.maxRetryDelay != 10*time.Minute {
+					return fmt.Errorf("Error")
+				}
+
+				return nil
+			},
+		},
+		{
+			name: "invalid format",
+			args: args{
+				"dummy",
+			},
+			checkFunc: func(opt Option) error {
+				pol := &policyd{}
+				if err := opt(pol); err == nil {
+					return fmt.Errorf("expected error, but not return")
+				}
+
+				return nil
+			},
+		},
+		{
+			name: "zero delay",
+			args: args{
+				"0",
+			},
+			checkFunc: func(opt Option) error {
+				pol := &policyd{}
+				if err := opt(pol); err == nil {
+					return fmt.Errorf("expected error, but not return")
+				}
+
+				return nil
+			},
+		},
+		{
+			name: "empty value",
+			args: args{
+				"",
+			},
+			checkFunc: func(opt Option) error {
+				pol := &policyd{}
+				if err := opt(pol); err != nil {
+					return err
+				}
+				if !reflect.DeepEqual(pol, &policyd{}) {
+					return fmt.Errorf("expected no changes, but got %v", pol)
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithMaxRetryDelay(tt.args.d)
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithMaxRetryDelay() error= %v", err)
+			}
+		})
+	}
+}
+
+func TestWithMaxStaleness(t *testing.T) {
+	type args struct {
+		d string
+	}
+	tests := []struct {
+		name      string
+		args      args
+		checkFunc func(Option) error
+	}{
+		{
+			name: "set success",
+			args: args{
+				"6h",
+			},
+			checkFunc: func(opt Option) error {
+				pol := &policyd{}
+				if err := opt(pol); err != nil {
+					return err
+				}
+				if pol.maxStaleness != 6*time.Hour {
+					return fmt.Errorf("Error")
+				}
+
+				return nil
+			},
+		},
+		{
+			name: "invalid format",
+			args: args{
+				"dummy",
+			},
+			checkFunc: func(opt Option) error {
+				pol := &policyd{}
+				if err := opt(pol); err == nil {
+					return fmt.Errorf("expected error, but not return")
+				}
+
+				return nil
+			},
+		},
+		{
+			name: "negative duration",
+			args: args{
+				"-1h",
+			},
+			checkFunc: func(opt Option) error {
+				pol := &policyd{}
+				if err := opt(pol); err == nil {
+					return fmt.Errorf("expected error, but not return")
+				}
+
+				return nil
+			},
+		},
+		{
+			name: "empty value",
+			args: args{
+				"",
+			},
+			checkFunc: func(opt Option) error {
+				pol := &policyd{}
+				if err := opt(pol); err != nil {
+					return err
+				}
+				if !reflect.DeepEqual(pol, &policyd{}) {
+					return fmt.Errorf("expected no changes, but got %v", pol)
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithMaxStaleness(tt.args.d)
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithMaxStaleness() error= %v", err)
+			}
+		})
+	}
+}
+
+func TestWithFetchConcurrency(t *testing.T) {
+	type args struct {
+		n int
+	}
+	tests := []struct {
+		name      string
+		args      args
+		checkFunc func(Option) error
+	}{
+		{
+			name: "set success",
+			args: args{
+				16,
+			},
+			checkFunc: func(opt Option) error {
+				pol := &policyd{}
+				if err := opt(pol); err != nil {
+					return err
+				}
+				if pol.fetchConcurrency != 16 {
+					return fmt.Errorf("Error")
+				}
+
+				return nil
+			},
+		},
+		{
+			name: "invalid concurrency",
+			args: args{
+				0,
+			},
+			checkFunc: func(opt Option) error {
+				pol := &policyd{}
+				if err := opt(pol); err == nil {
+					return fmt.Errorf("expected error, but not return")
+				}
+
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithFetchConcurrency(tt.args.n)
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithFetchConcurrency() error= %v", err)
+			}
+		})
+	}
+}
+
+func TestWithRefreshSpread(t *testing.T) {
+	type args struct {
+		d string
+	}
+	tests := []struct {
+		name      string
+		args      args
+		checkFunc func(Option) error
+	}{
+		{
+			name: "set success",
+			args: args{
+				"5m",
+			},
+			checkFunc: func(opt Option) error {
+				pol := &policyd{}
+				if err := opt(pol); err != nil {
+					return err
+				}
+				if pol.refreshSpread != 5*time.Minute {
+					return fmt.Errorf("Error")
+				}
+
+				return nil
+			},
+		},
+		{
+			name: "invalid format",
+			args: args{
+				"dummy",
+			},
+			checkFunc: func(opt Option) error {
+				pol := &policyd{}
+				if err := opt(pol); err == nil {
+					return fmt.Errorf("expected error, but not return")
+				}
+
+				return nil
+			},
+		},
+		{
+			name: "negative duration",
+			args: args{
+				"-5m",
+			},
+			checkFunc: func(opt Option) error {
+				pol := &policyd{}
+				if err := opt(pol); err == nil {
+					return fmt.Errorf("expected error, but not return")
+				}
+
+				return nil
+			},
+		},
+		{
+			name: "empty value",
+			args: args{
+				"",
+			},
+			checkFunc: func(opt Option) error {
+				pol := &policyd{}
+				if err := opt(pol); err != nil {
+					return err
+				}
+				if !reflect.DeepEqual(pol, &policyd{}) {
+					return fmt.Errorf("expected no changes, but got %v", pol)
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithRefreshSpread(tt.args.d)
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithRefreshSpread() error= %v", err)
+			}
+		})
+	}
+}
+
 func TestWithRetryAttempts(t *testing.T) {
 	type args struct {
 		c int
@@ -0,0 +1,99 @@
+/*
+Copyright (C)  2018 Yahoo Japan Corporation Athenz team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/kpango/fastime"
+)
+
+// retryableError represents the fetch error which may succeed on retry, e.g. the network error or the 5xx response.
+// The other errors are fatal, e.g. the signature verification failure.
+type retryableError struct {
+	err error
+	// the delay requested by the Retry-After header, zero if not provided
+	retryAfter time.Duration
+}
+
+// retryable marks the error as retryable.
+func retryable(err error, retryAfter time.Duration) error {
+	return &retryableError{
+		err:        err,
+		retryAfter: retryAfter,
+	}
+}
+
+// Error returns the message of the original error.
+func (e *retryableError) Error() string {
+	return e.err.Error()
+}
+
+// Cause returns the original error for errors.Cause().
+func (e *retryableError) Cause() error {
+	return e.err
+}
+
+// Unwrap returns the original error for errors.Is().
+func (e *retryableError) Unwrap() error {
+	return e.err
+}
+
+// retryInfo returns true and the Retry-After delay if the error or any of its causes is retryable.
+func retryInfo(err error) (bool, time.Duration) {
+	for err != nil {
+		if re, ok := err.(*retryableError); ok {
+			return true, re.retryAfter
+		}
+		cause, ok := err.(interface{ Cause() error })
+		if !ok {
+			return false, 0
+		}
+		err = cause.Cause()
+	}
+	return false, 0
+}
+
+// isRetryableStatus returns true if the request may succeed on retry with the response status code.
+func isRetryableStatus(code int) bool {
+	return code >= http.StatusInternalServerError || code == http.StatusTooManyRequests || code == http.StatusRequestTimeout
+}
+
+// parseRetryAfter returns the delay of the Retry-After header on 429 and 503, in either the seconds or the HTTP date. It returns zero if not provided or invalid.
+func parseRetryAfter(res *http.Response) time.Duration {
+	if res.StatusCode != http.StatusTooManyRequests && res.StatusCode != http.StatusServiceUnavailable {
+		return 0
+	}
+	v := res.Header.Get("Retry-After")
+	if v == "" {
+		return 0
+	}
+	if sec, err := strconv.ParseInt(v, 10, 64); err == nil {
+		if sec < 0 {
+			return 0
+		}
+		return time.Duration(sec) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := t.Sub(fastime.Now()); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
@@ -0,0 +1,176 @@
+/*
+Copyright (C)  2018 Yahoo Japan Corporation Athenz team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/kpango/fastime"
+	"github.com/pkg/errors"
+)
+
+func Test_retryInfo(t *testing.T) {
+	type args struct {
+		err error
+	}
+	tests := []struct {
+		name           string
+		args           args
+		want           bool
+		wantRetryAfter time.Duration
+	}{
+		{
+			name: "retryable",
+			args: args{
+				err: retryable(ErrFetchPolicy, time.Second),
+			},
+			want:           true,
+			wantRetryAfter: time.Second,
+		},
+		{
+			name: "wrapped retryable",
+			args: args{
+				err: errors.Wrap(retryable(ErrFetchPolicy, 0), "wrapped"),
+			},
+			want: true,
+		},
+		{
+			name: "fatal",
+			args: args{
+				err: errors.Wrap(ErrFetchPolicy, "fatal"),
+			},
+			want: false,
+		},
+		{
+			name: "nil",
+			args: args{
+				err: nil,
+			},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotRetryAfter := retryInfo(tt.args.err)
+			if got != tt.want || gotRetryAfter != tt.wantRetryAfter {
+				t.Errorf("retryInfo() = %v, %v, want %v, %v", got, gotRetryAfter, tt.want, tt.wantRetryAfter)
+			}
+		})
+	}
+}
+
+func Test_retryableError_Cause(t *testing.T) {
+	err := errors.Wrap(retryable(errors.Wrap(ErrFetchPolicy, "fetch"), 0), "wrapped")
+	if errors.Cause(err) != ErrFetchPolicy {
+		t.Errorf("errors.Cause() = %v, want %v", errors.Cause(err), ErrFetchPolicy)
+	}
+	if err.Error() != "wrapped: fetch: Error fetching athenz policy" {
+		t.Errorf("retryableError.Error() = %v", err)
+	}
+}
+
+func Test_isRetryableStatus(t *testing.T) {
+	for code, want := range map[int]bool{
+		http.StatusInternalServerError: true,
+		http.StatusBadGateway:          true,
+		http.StatusServiceUnavailable:  true,
+		http.StatusTooManyRequests:     true,
+		http.StatusRequestTimeout:      true,
+		http.StatusBadRequest:          false,
+		http.StatusUnauthorized:        false,
+		http.StatusNotFound:            false,
+	} {
+		if got := isRetryableStatus(code); got != want {
+			t.Errorf("isRetryableStatus(%d) = %v, want %v", code, got, want)
+		}
+	}
+}
+
+func Test_parseRetryAfter(t *testing.T) {
+	type args struct {
+		status     int
+		retryAfter string
+	}
+	tests := []struct {
+		name string
+		args args
+		min  time.Duration
+		max  time.Duration
+	}{
+		{
+			name: "seconds on 429",
+			args: args{
+				status:     http.StatusTooManyRequests,
+				retryAfter: "120",
+			},
+			min: 2 * time.Minute,
+			max: 2 * time.Minute,
+		},
+		{
+			name: "HTTP date on 503",
+			args: args{
+				status:     http.StatusServiceUnavailable,
+				retryAfter: fastime.Now().Add(time.Hour).UTC().Format(http.TimeFormat),
+			},
+			min: 59 * time.Minute,
+			max: time.Hour,
+		},
+		{
+			name: "past HTTP date",
+			args: args{
+				status:     http.StatusServiceUnavailable,
+				retryAfter: fastime.Now().Add(-time.Hour).UTC().Format(http.TimeFormat),
+			},
+		},
+		{
+			name: "ignored on 500",
+			args: args{
+				status:     http.StatusInternalServerError,
+				retryAfter: "120",
+			},
+		},
+		{
+			name: "invalid",
+			args: args{
+				status:     http.StatusTooManyRequests,
+				retryAfter: "soon",
+			},
+		},
+		{
+			name: "not provided",
+			args: args{
+				status: http.StatusTooManyRequests,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := &http.Response{
+				StatusCode: tt.args.status,
+				Header:     http.Header{},
+			}
+			if tt.args.retryAfter != "" {
+				res.Header.Set("Retry-After", tt.args.retryAfter)
+			}
+			if got := parseRetryAfter(res); got < tt.min || got > tt.max {
+				t.Errorf("parseRetryAfter() = %v, want between %v and %v", got, tt.min, tt.max)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+/*
+Copyright (C)  2018 Yahoo Japan Corporation Athenz team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/kpango/fastime"
+	"github.com/kpango/glg"
+	"github.com/pkg/errors"
+)
+
+// DomainStatus represents the status of the policy enforced for a domain.
+type DomainStatus struct {
+	// Expires is the expiry of the enforced policy.
+	Expires time.Time
+	// Stale is true if the policy is expired but still enforced within the max staleness, since it cannot be refreshed.
+	Stale bool
+	// Expired is true if the policy is expired beyond the max staleness. The requests to the domain are denied with ErrDomainExpired.
+	Expired bool
+	// StaleChecks is the number of the policy checks on the stale policy.
+	StaleChecks uint64
+	// ExpiredChecks is the number of the policy checks denied with ErrDomainExpired.
+	ExpiredChecks uint64
+}
+
+// domainState holds the expiry of the policy enforced for a domain and the metrics. It is updated atomically.
+type domainState struct {
+	expires       int64 // UnixNano
+	staleChecks   uint64
+	expiredChecks uint64
+}
+
+// setDomainExpiry sets the expiry of the policy enforced for the domain.
+func (p *policyd) setDomainExpiry(domain string, expires time.Time) {
+	if v, ok := p.domainStatus.Get(domain); ok {
+		atomic.StoreInt64(&v.(*domainState).expires, expires.UnixNano())
+		return
+	}
+	p.domainStatus.Set(domain, &domainState{
+		expires: expires.UnixNano(),
+	})
+}
+
+// checkDomainExpiry returns ErrDomainExpired if the policy of the domain is expired beyond the max staleness.
+// It returns nil if the domain policy is not cached yet.
+func (p *policyd) checkDomainExpiry(domain string) error {
+	v, ok := p.domainStatus.Get(domain)
+	if !ok {
+		return nil
+	}
+	ds := v.(*domainState)
+	exp := time.Unix(0, atomic.LoadInt64(&ds.expires))
+	now := fastime.Now()
+	if now.Before(exp) {
+		return nil
+	}
+	if now.Before(exp.Add(p.maxStaleness)) {
+		atomic.AddUint64(&ds.staleChecks, 1)
+		glg.Debugf("check policy on the stale policy, domain: %s, expires: %s", domain, exp)
+		return nil
+	}
+	atomic.AddUint64(&ds.expiredChecks, 1)
+	return errors.Wrapf(ErrDomainExpired, "policy expired at %s", exp)
+}
+
+// GetDomainStatus returns the status of the policy enforced for each domain.
+func (p *policyd) GetDomainStatus(ctx context.Context) map[string]DomainStatus {
+	now := fastime.Now()
+	m := make(map[string]DomainStatus)
+	for domain, v := range p.domainStatus.ToRawMap(ctx) {
+		ds := v.(*domainState)
+		exp := time.Unix(0, atomic.LoadInt64(&ds.expires))
+		m[domain] = DomainStatus{
+			Expires:       exp,
+			Stale:         !now.Before(exp) && now.Before(exp.Add(p.maxStaleness)),
+			Expired:       !now.Before(exp.Add(p.maxStaleness)),
+			StaleChecks:   atomic.LoadUint64(&ds.staleChecks),
+			ExpiredChecks: atomic.LoadUint64(&ds.expiredChecks),
+		}
+	}
+	return m
+}
@@ -0,0 +1,216 @@
+/*
+Copyright (C)  2018 Yahoo Japan Corporation Athenz team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kpango/fastime"
+	"github.com/kpango/gache"
+	"github.com/pkg/errors"
+)
+
+func Test_policyd_setDomainExpiry(t *testing.T) {
+	expires := fastime.Now().Add(time.Hour)
+	tests := []struct {
+		name      string
+		ds        gache.Gache
+		checkFunc func(gache.Gache) error
+	}{
+		{
+			name: "set new domain",
+			ds:   gache.New(),
+			checkFunc: func(ds gache.Gache) error {
+				v, ok := ds.Get("dummyDom")
+				if !ok {
+					return errors.New("domain status not set")
+				}
+				if got := v.(*domainState).expires; got != expires.UnixNano() {
+					return fmt.Errorf("expires = %v, want %v", got, expires.UnixNano())
+				}
+				return nil
+			},
+		},
+		{
+			name: "update existing domain, metrics are kept",
+			ds: func() gache.Gache {
+				ds := gache.New()
+				ds.Set("dummyDom", &domainState{
+					expires:     fastime.Now().Add(-time.Hour).UnixNano(),
+					staleChecks: 3,
+				})
+				return ds
+			}(),
+			checkFunc: func(ds gache.Gache) error {
+				v, _ := ds.Get("dummyDom")
+				want := &domainState{
+					expires:     expires.UnixNano(),
+					staleChecks: 3,
+				}
+				if got := v.(*domainState); !reflect.DeepEqual(got, want) {
+					return fmt.Errorf("domain status = %+v, want %+v", got, want)
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &policyd{
+				domainStatus: tt.ds,
+			}
+			p.setDomainExpiry("dummyDom", expires)
+			if err := tt.checkFunc(tt.ds); err != nil {
+				t.Errorf("policyd.setDomainExpiry() error = %v", err)
+			}
+		})
+	}
+}
+
+func Test_policyd_checkDomainExpiry(t *testing.T) {
+	type fields struct {
+		domainStatus gache.Gache
+		maxStaleness time.Duration
+	}
+	type test struct {
+		name      string
+		fields    fields
+		domain    string
+		wantErr   error
+		wantState *domainState
+	}
+	tests := []test{
+		{
+			name: "unknown domain",
+			fields: fields{
+				domainStatus: gache.New(),
+			},
+			domain: "dummyDom",
+		},
+		func() (tt test) {
+			expires := fastime.Now().Add(time.Hour).UnixNano()
+			ds := gache.New()
+			ds.Set("dummyDom", &domainState{expires: expires})
+			tt.name = "policy not expired"
+			tt.fields = fields{
+				domainStatus: ds,
+			}
+			tt.domain = "dummyDom"
+			tt.wantState = &domainState{expires: expires}
+			return tt
+		}(),
+		func() (tt test) {
+			expires := fastime.Now().Add(-time.Minute).UnixNano()
+			ds := gache.New()
+			ds.Set("dummyDom", &domainState{expires: expires})
+			tt.name = "policy expired within max staleness"
+			tt.fields = fields{
+				domainStatus: ds,
+				maxStaleness: time.Hour,
+			}
+			tt.domain = "dummyDom"
+			tt.wantState = &domainState{expires: expires, staleChecks: 1}
+			return tt
+		}(),
+		func() (tt test) {
+			expires := fastime.Now().Add(-2 * time.Hour).UnixNano()
+			ds := gache.New()
+			ds.Set("dummyDom", &domainState{expires: expires})
+			tt.name = "policy expired beyond max staleness"
+			tt.fields = fields{
+				domainStatus: ds,
+				maxStaleness: time.Hour,
+			}
+			tt.domain = "dummyDom"
+			tt.wantErr = ErrDomainExpired
+			tt.wantState = &domainState{expires: expires, expiredChecks: 1}
+			return tt
+		}(),
+		func() (tt test) {
+			expires := fastime.Now().Add(-time.Second).UnixNano()
+			ds := gache.New()
+			ds.Set("dummyDom", &domainState{expires: expires})
+			tt.name = "policy expired without max staleness"
+			tt.fields = fields{
+				domainStatus: ds,
+			}
+			tt.domain = "dummyDom"
+			tt.wantErr = ErrDomainExpired
+			tt.wantState = &domainState{expires: expires, expiredChecks: 1}
+			return tt
+		}(),
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &policyd{
+				domainStatus: tt.fields.domainStatus,
+				maxStaleness: tt.fields.maxStaleness,
+			}
+			err := p.checkDomainExpiry(tt.domain)
+			if errors.Cause(err) != tt.wantErr {
+				t.Errorf("policyd.checkDomainExpiry() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantState == nil {
+				return
+			}
+			v, _ := p.domainStatus.Get(tt.domain)
+			if got := v.(*domainState); !reflect.DeepEqual(got, tt.wantState) {
+				t.Errorf("policyd.checkDomainExpiry() state = %+v, want %+v", got, tt.wantState)
+			}
+		})
+	}
+}
+
+func Test_policyd_GetDomainStatus(t *testing.T) {
+	now := fastime.Now()
+	fresh := now.Add(time.Hour)
+	stale := now.Add(-time.Minute)
+	expired := now.Add(-2 * time.Hour)
+	ds := gache.New()
+	ds.Set("fresh", &domainState{expires: fresh.UnixNano()})
+	ds.Set("stale", &domainState{expires: stale.UnixNano(), staleChecks: 2})
+	ds.Set("expired", &domainState{expires: expired.UnixNano(), staleChecks: 5, expiredChecks: 1})
+	p := &policyd{
+		domainStatus: ds,
+		maxStaleness: time.Hour,
+	}
+
+	want := map[string]DomainStatus{
+		"fresh": {
+			Expires: time.Unix(0, fresh.UnixNano()),
+		},
+		"stale": {
+			Expires:     time.Unix(0, stale.UnixNano()),
+			Stale:       true,
+			StaleChecks: 2,
+		},
+		"expired": {
+			Expires:       time.Unix(0, expired.UnixNano()),
+			Expired:       true,
+			StaleChecks:   5,
+			ExpiredChecks: 1,
+		},
+	}
+	if got := p.GetDomainStatus(context.Background()); !reflect.DeepEqual(got, want) {
+		t.Errorf("policyd.GetDomainStatus() = %+v, want %+v", got, want)
+	}
+}
@@ -23,13 +23,15 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
-	"sync"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/kpango/gache"
 	"github.com/kpango/glg"
 	"github.com/pkg/errors"
 	authcore "github.com/yahoo/athenz/libs/go/zmssvctoken"
+	"github.com/yahoojapan/athenz-authorizer/v5/internal/supervisor"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -48,18 +50,35 @@ type pubkeyd struct {
 
 	client *http.Client
 
+	// the sources of the public keys, the sys.auth domain is used if empty
+	sources []KeySource
+
 	eTagCache       gache.Gache
 	eTagExpiry      time.Duration
 	eTagPurgePeriod time.Duration
 
+	// the removed keys are still accepted during the grace period
+	keyGracePeriod time.Duration
+
+	// the configuration of each environment
+	envs map[AthenzEnv]EnvConfig
+	// the environments of each usage (EnvZMS or EnvZTS) in the order of lookup
+	usages map[AthenzEnv][]AthenzEnv
+
 	// cache
-	confCache *AthenzConfig
+	keyStores map[AthenzEnv]*keyStore
 }
 
-// AthenzConfig represent the cache of Athenz config.
-type AthenzConfig struct {
-	ZMSPubKeys *sync.Map //map[string]authcore.Verifier
-	ZTSPubKeys *sync.Map //map[string]authcore.Verifier
+// EnvConfig represents the configuration of an Athenz environment.
+type EnvConfig struct {
+	// AthenzURL is the URL of the Athenz server to fetch the public keys of the environment. If empty, the AthenzURL option is used.
+	AthenzURL string
+	// SysAuthDomain is the domain having the public keys. If empty, the SysAuthDomain option is used.
+	SysAuthDomain string
+	// Service is the service having the public keys in SysAuthDomain. If empty, the environment name is used.
+	Service string
+	// Usage is the environment (EnvZMS or EnvZTS) whose signatures are verified by the public keys. If empty, the environment name is used.
+	Usage AthenzEnv
 }
 
 type confCache struct {
@@ -84,9 +103,9 @@ const (
 // New represent the constructor of Pubkeyd
 func New(opts ...Option) (Daemon, error) {
 	c := &pubkeyd{
-		confCache: &AthenzConfig{
-			ZMSPubKeys: new(sync.Map),
-			ZTSPubKeys: new(sync.Map),
+		envs: map[AthenzEnv]EnvConfig{
+			EnvZMS: {},
+			EnvZTS: {},
 		},
 		eTagCache: gache.New(),
 	}
@@ -97,56 +116,44 @@ func New(opts ...Option) (Daemon, error) {
 		}
 	}
 
+	c.keyStores = make(map[AthenzEnv]*keyStore, len(c.envs))
+	c.usages = make(map[AthenzEnv][]AthenzEnv)
+	envs := make([]string, 0, len(c.envs))
+	for env := range c.envs {
+		c.keyStores[env] = newKeyStore(nil)
+		envs = append(envs, string(env))
+	}
+	// the environment of the same name as the usage first, then the others in the name order
+	sort.Strings(envs)
+	for _, env := range envs {
+		usage := c.envs[AthenzEnv(env)].usage(AthenzEnv(env))
+		if AthenzEnv(env) == usage {
+			c.usages[usage] = append([]AthenzEnv{usage}, c.usages[usage]...)
+			continue
+		}
+		c.usages[usage] = append(c.usages[usage], AthenzEnv(env))
+	}
+
 	return c, nil
 }
 
 // Start starts the pubkey daemon to retrive the public key periodically
 func (p *pubkeyd) Start(ctx context.Context) <-chan error {
-	glg.Info("Starting pubkey updater")
-
-	ech := make(chan error, 100)
-	fch := make(chan struct{}, 1)
-
-	go func() {
-		defer close(fch)
-		defer close(ech)
-
-		p.eTagCache.StartExpired(ctx, p.eTagPurgePeriod)
-		ticker := time.NewTicker(p.refreshPeriod)
-		for {
-			select {
-			case <-ctx.Done():
-				glg.Info("Stopping pubkey updater")
-				ticker.Stop()
-				ech <- ctx.Err()
-				return
-			case <-fch:
-				if err := p.Update(ctx); err != nil {
-					ech <- errors.Wrap(err, "error update pubkey")
-
-					time.Sleep(p.retryDelay)
-
-					select {
-					case fch <- struct{}{}:
-					default:
-						glg.Warn("failure queue already full")
-					}
-				}
-			case <-ticker.C:
-				if err := p.Update(ctx); err != nil {
-					ech <- errors.Wrap(err, "error update pubkey")
-
-					select {
-					case fch <- struct{}{}:
-					default:
-						glg.Warn("failure queue already full")
-					}
-				}
-			}
+	p.eTagCache.StartExpired(ctx, p.eTagPurgePeriod)
+	return supervisor.Run(ctx, supervisor.Config{
+		Name:     "pubkey",
+		Interval: supervisor.Every(p.refreshPeriod),
+		Backoff: supervisor.Backoff{
+			Initial: p.retryDelay,
+			Max:     p.refreshPeriod,
+			Jitter:  supervisor.DefaultJitter,
+		},
+	}, func(ctx context.Context) error {
+		if err := p.Update(ctx); err != nil {
+			return errors.Wrap(err, "error update pubkey")
 		}
-	}()
-
-	return ech
+		return nil
+	})
 }
 
 // Update updates and cache athenz public key data
@@ -154,11 +161,10 @@ func (p *pubkeyd) Update(ctx context.Context) error {
 	glg.Info("Updating athenz pubkey")
 	eg := errgroup.Group{}
 
-	// this function decode and create verifier obj and store to corresponding cache map
-	updConf := func(env AthenzEnv, cache *sync.Map) error {
-		cm := new(sync.Map)
+	// this function decode and create verifier obj and swap the key set of the corresponding cache
+	updConf := func(env AthenzEnv, cache *keyStore) error {
 		dec := new(authcore.YBase64)
-		pubKeys, upded, err := p.fetchPubKeyEntries(ctx, env)
+		pubKeys, upded, err := p.fetchPubKeys(ctx, env)
 		if err != nil {
 			glg.Errorf("Error updating athenz pubkey, env: %v, error: %v", env, err)
 			return errors.Wrap(err, "error fetch public key entries")
@@ -168,53 +174,62 @@ func (p *pubkeyd) Update(ctx context.Context) error {
 			return nil
 		}
 
-		for _, key := range pubKeys.PublicKeys {
+		keys := make(map[string]authcore.Verifier, len(pubKeys))
+		invalidKeyIDs := make([]string, 0)
+		for _, key := range pubKeys {
+			if _, ok := keys[key.ID]; ok {
+				glg.Warnf("duplicated key ID, skipped, env: %v, keyID: %v", env, key.ID)
+				continue
+			}
 			glg.Debugf("Decoding key, env: %v, keyID: %v", env, key.ID)
 			decKey, err := dec.DecodeString(key.Key)
 			if err != nil {
-				glg.Errorf("error decoding key, env: %v, error: %v", env, err)
-				return errors.Wrap(err, "error decoding key")
+				glg.Errorf("error decoding key, skipped, env: %v, keyID: %v, error: %v", env, key.ID, err)
+				invalidKeyIDs = append(invalidKeyIDs, key.ID)
+				continue
 			}
 			ver, err := authcore.NewVerifier(decKey)
 			if err != nil {
-				glg.Errorf("error initializing verifier, env: %v, error: %v", env, err)
-				return errors.Wrap(err, "error initializing verifier")
+				glg.Errorf("error initializing verifier, skipped, env: %v, keyID: %v, error: %v", env, key.ID, err)
+				invalidKeyIDs = append(invalidKeyIDs, key.ID)
+				continue
 			}
-			cm.Store(key.ID, ver)
+			keys[key.ID] = ver
 			glg.Debugf("Successfully decode key, env: %v, keyID: %v", env, key.ID)
 		}
-		cm.Range(func(key interface{}, val interface{}) bool {
-			cache.Store(key, val)
-			return true
-		})
-		cache.Range(func(key interface{}, val interface{}) bool {
-			_, ok := cm.Load(key)
-			if !ok {
-				cache.Delete(key)
-			}
-			return true
-		})
-
-		return nil
-	}
 
-	eg.Go(func() error {
-		glg.Info("Updating ZTS athenz pubkey")
-		if err := updConf(EnvZTS, p.confCache.ZTSPubKeys); err != nil {
-			return errors.Wrap(err, "Error updating ZTS athenz pubkey")
+		// keep the current key set if no key is valid, or no key is fetched, e.g. the key source is emptied by mistake
+		if len(invalidKeyIDs) != 0 && len(keys) == 0 {
+			return errors.Wrapf(ErrInvalidAthenzPubkey, "no valid key, invalid key IDs: %v", invalidKeyIDs)
 		}
-		glg.Info("Update ZTS athenz pubkey success")
-		return nil
-	})
+		if len(keys) == 0 {
+			// the environment not covered by any key source has no key from the start
+			if ks := cache.load(); ks == nil || len(ks.keys) == 0 {
+				glg.Warnf("no athenz pubkey fetched, skipped, env: %v", env)
+				return nil
+			}
+			return errors.Wrap(ErrInvalidAthenzPubkey, "no key fetched, the current keys are kept")
+		}
+		cache.swap(keys, p.keyGracePeriod)
 
-	eg.Go(func() error {
-		glg.Info("Updating ZMS athenz pubkey")
-		if err := updConf(EnvZMS, p.confCache.ZMSPubKeys); err != nil {
-			return errors.Wrap(err, "Error updating ZMS athenz pubkey")
+		if len(invalidKeyIDs) != 0 {
+			return errors.Wrapf(ErrInvalidAthenzPubkey, "invalid key IDs: %v", invalidKeyIDs)
 		}
-		glg.Info("Update ZMS athenz pubkey success")
 		return nil
-	})
+	}
+
+	for env, cache := range p.keyStores {
+		env, cache := env, cache
+		name := strings.ToUpper(string(env))
+		eg.Go(func() error {
+			glg.Infof("Updating %s athenz pubkey", name)
+			if err := updConf(env, cache); err != nil {
+				return errors.Wrapf(err, "Error updating %s athenz pubkey", name)
+			}
+			glg.Infof("Update %s athenz pubkey success", name)
+			return nil
+		})
+	}
 
 	if err := eg.Wait(); err != nil {
 		return errors.Wrap(err, "error when processing pubkey")
@@ -228,10 +243,43 @@ func (p *pubkeyd) GetProvider() Provider {
 	return p.getPubKey
 }
 
+// fetchPubKeys returns the public keys of the environment from the key sources, or from the sys.auth domain if no key source is set.
+// If there are duplicated key IDs, the key of the former source is used.
+func (p *pubkeyd) fetchPubKeys(ctx context.Context, env AthenzEnv) ([]*PublicKey, bool, error) {
+	if len(p.sources) == 0 {
+		sac, upded, err := p.fetchPubKeyEntries(ctx, env)
+		if err != nil || !upded {
+			return nil, upded, err
+		}
+		return sac.PublicKeys, true, nil
+	}
+
+	pubKeys := make([]*PublicKey, 0)
+	for i, src := range p.sources {
+		keys, err := src.Fetch(ctx, env)
+		if err != nil {
+			return nil, false, errors.Wrapf(err, "error fetch public keys from key source[%d]", i)
+		}
+		pubKeys = append(pubKeys, keys...)
+	}
+	return pubKeys, true, nil
+}
+
 func (p *pubkeyd) fetchPubKeyEntries(ctx context.Context, env AthenzEnv) (*SysAuthConfig, bool, error) {
 	glg.Info("Fetching public key entries")
 	// https://{athenz.io/zts/v1}/domain/sys.auth/service/zts
-	url := fmt.Sprintf("https://%s/domain/%s/service/%s", p.athenzURL, p.sysAuthDomain, env)
+	cfg := p.envs[env]
+	athenzURL, sysAuthDomain, service := p.athenzURL, p.sysAuthDomain, string(env)
+	if cfg.AthenzURL != "" {
+		athenzURL = cfg.AthenzURL
+	}
+	if cfg.SysAuthDomain != "" {
+		sysAuthDomain = cfg.SysAuthDomain
+	}
+	if cfg.Service != "" {
+		service = cfg.Service
+	}
+	url := fmt.Sprintf("https://%s/domain/%s/service/%s", athenzURL, sysAuthDomain, service)
 	glg.Debugf("Fetching public key from %s", url)
 
 	req, err := http.NewRequest(http.MethodGet, url, nil)
@@ -291,20 +339,31 @@ func (p *pubkeyd) fetchPubKeyEntries(ctx context.Context, env AthenzEnv) (*SysAu
 	return sac, true, nil
 }
 
+// getPubKey returns the verifier of the key ID from the environment, and the other environments having the same usage.
+// The unknown environment is regarded as EnvZMS.
 func (p *pubkeyd) getPubKey(env AthenzEnv, keyID string) authcore.Verifier {
-	if env == EnvZTS {
-		ver, ok := p.confCache.ZTSPubKeys.Load(keyID)
-		if !ok {
-			glg.Warnf("ZTS PubKey Load Failed keyID[%s]  getZTSPubKey %v", keyID, ver)
-			return nil
+	if _, ok := p.keyStores[env]; !ok {
+		env = EnvZMS
+	}
+	envs, ok := p.usages[env]
+	if !ok {
+		envs = []AthenzEnv{env}
+	}
+	for _, e := range envs {
+		if ks, ok := p.keyStores[e]; ok {
+			if ver := ks.get(keyID); ver != nil {
+				return ver
+			}
 		}
-		return ver.(authcore.Verifier)
 	}
+	glg.Warnf("%s PubKey Load Failed keyID[%s]", strings.ToUpper(string(env)), keyID)
+	return nil
+}
 
-	ver, ok := p.confCache.ZMSPubKeys.Load(keyID)
-	if !ok {
-		glg.Warnf("ZMS PubKey Load Failed keyID[%s]  getZMSPubKey %v", keyID, ver)
-		return nil
+// usage returns the usage of the environment.
+func (c EnvConfig) usage(env AthenzEnv) AthenzEnv {
+	if c.Usage != "" {
+		return c.Usage
 	}
-	return ver.(authcore.Verifier)
+	return env
 }
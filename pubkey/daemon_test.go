@@ -22,7 +22,6 @@ import (
 	"net/http/httptest"
 	"reflect"
 	"strings"
-	"sync"
 	"testing"
 	"time"
 
@@ -106,17 +105,26 @@ func TestNew(t *testing.T) {
 	}
 }
 
+// rangeKeys calls f for each key in the current key set of the keyStore.
+func rangeKeys(s *keyStore, f func(key interface{}, value interface{}) bool) {
+	for id, ver := range s.load().keys {
+		if !f(id, ver) {
+			return
+		}
+	}
+}
+
 func Test_pubkeyd_getPubKey(t *testing.T) {
 	c := &pubkeyd{
-		confCache: &AthenzConfig{
-			ZMSPubKeys: new(sync.Map),
-			ZTSPubKeys: new(sync.Map),
+		keyStores: map[AthenzEnv]*keyStore{
+			EnvZMS: newKeyStore(nil),
+			EnvZTS: newKeyStore(nil),
 		},
 	}
 	zmsVer := &VerifierMock{}
 	ztsVer := &VerifierMock{}
-	c.confCache.ZMSPubKeys.Store("0", zmsVer)
-	c.confCache.ZTSPubKeys.Store("0", ztsVer)
+	c.keyStores[EnvZMS].swap(map[string]authcore.Verifier{"0": zmsVer}, 0)
+	c.keyStores[EnvZTS].swap(map[string]authcore.Verifier{"0": ztsVer}, 0)
 	type args struct {
 		env   AthenzEnv
 		keyID string
@@ -180,7 +188,7 @@ func Test_pubkeyd_fetchPubKeyEntries(t *testing.T) {
 		athenzURL       string
 		sysAuthDomain   string
 		client          *http.Client
-		confCache       *AthenzConfig
+		keyStores       map[AthenzEnv]*keyStore
 	}
 	type args struct {
 		ctx context.Context
@@ -213,9 +221,9 @@ func Test_pubkeyd_fetchPubKeyEntries(t *testing.T) {
 					eTagCache:     gache.New(),
 					eTagExpiry:    time.Minute,
 					client:        srv.Client(),
-					confCache: &AthenzConfig{
-						ZMSPubKeys: new(sync.Map),
-						ZTSPubKeys: new(sync.Map),
+					keyStores: map[AthenzEnv]*keyStore{
+						EnvZMS: newKeyStore(nil),
+						EnvZTS: newKeyStore(nil),
 					},
 				},
 				args: args{
@@ -289,9 +297,9 @@ func Test_pubkeyd_fetchPubKeyEntries(t *testing.T) {
 					eTagCache:     ec,
 					eTagExpiry:    time.Minute,
 					client:        srv.Client(),
-					confCache: &AthenzConfig{
-						ZMSPubKeys: new(sync.Map),
-						ZTSPubKeys: new(sync.Map),
+					keyStores: map[AthenzEnv]*keyStore{
+						EnvZMS: newKeyStore(nil),
+						EnvZTS: newKeyStore(nil),
 					},
 				},
 				args: args{
@@ -351,9 +359,9 @@ func Test_pubkeyd_fetchPubKeyEntries(t *testing.T) {
 					eTagCache:     ec,
 					eTagExpiry:    time.Minute,
 					client:        srv.Client(),
-					confCache: &AthenzConfig{
-						ZMSPubKeys: new(sync.Map),
-						ZTSPubKeys: new(sync.Map),
+					keyStores: map[AthenzEnv]*keyStore{
+						EnvZMS: newKeyStore(nil),
+						EnvZTS: newKeyStore(nil),
 					},
 				},
 				args: args{
@@ -405,9 +413,9 @@ func Test_pubkeyd_fetchPubKeyEntries(t *testing.T) {
 					eTagCache:     gache.New(),
 					eTagExpiry:    time.Minute,
 					client:        srv.Client(),
-					confCache: &AthenzConfig{
-						ZMSPubKeys: new(sync.Map),
-						ZTSPubKeys: new(sync.Map),
+					keyStores: map[AthenzEnv]*keyStore{
+						EnvZMS: newKeyStore(nil),
+						EnvZTS: newKeyStore(nil),
 					},
 				},
 				args: args{
@@ -440,9 +448,9 @@ func Test_pubkeyd_fetchPubKeyEntries(t *testing.T) {
 					eTagCache:     gache.New(),
 					eTagExpiry:    time.Minute,
 					client:        srv.Client(),
-					confCache: &AthenzConfig{
-						ZMSPubKeys: new(sync.Map),
-						ZTSPubKeys: new(sync.Map),
+					keyStores: map[AthenzEnv]*keyStore{
+						EnvZMS: newKeyStore(nil),
+						EnvZTS: newKeyStore(nil),
 					},
 				},
 				args: args{
@@ -475,9 +483,9 @@ func Test_pubkeyd_fetchPubKeyEntries(t *testing.T) {
 					eTagCache:     gache.New(),
 					eTagExpiry:    time.Minute,
 					client:        srv.Client(),
-					confCache: &AthenzConfig{
-						ZMSPubKeys: new(sync.Map),
-						ZTSPubKeys: new(sync.Map),
+					keyStores: map[AthenzEnv]*keyStore{
+						EnvZMS: newKeyStore(nil),
+						EnvZTS: newKeyStore(nil),
 					},
 				},
 				args: args{
@@ -509,7 +517,7 @@ func Test_pubkeyd_fetchPubKeyEntries(t *testing.T) {
 				athenzURL:       tt.fields.athenzURL,
 				sysAuthDomain:   tt.fields.sysAuthDomain,
 				client:          tt.fields.client,
-				confCache:       tt.fields.confCache,
+				keyStores:       tt.fields.keyStores,
 			}
 			got, got1, err := c.fetchPubKeyEntries(tt.args.ctx, tt.args.env)
 
@@ -522,7 +530,7 @@ func Test_pubkeyd_fetchPubKeyEntries(t *testing.T) {
 
 func Test_pubkeyd_GetProvider(t *testing.T) {
 	c := &pubkeyd{
-		confCache: &AthenzConfig{},
+		keyStores: map[AthenzEnv]*keyStore{},
 	}
 	type test struct {
 		name string
@@ -554,7 +562,7 @@ func Test_pubkeyd_Update(t *testing.T) {
 		athenzURL       string
 		sysAuthDomain   string
 		client          *http.Client
-		confCache       *AthenzConfig
+		keyStores       map[AthenzEnv]*keyStore
 	}
 	type args struct {
 		ctx context.Context
@@ -600,9 +608,9 @@ func Test_pubkeyd_Update(t *testing.T) {
 					eTagCache:     gache.New(),
 					eTagExpiry:    time.Minute,
 					client:        srv.Client(),
-					confCache: &AthenzConfig{
-						ZMSPubKeys: new(sync.Map),
-						ZTSPubKeys: new(sync.Map),
+					keyStores: map[AthenzEnv]*keyStore{
+						EnvZMS: newKeyStore(nil),
+						EnvZTS: newKeyStore(nil),
 					},
 				},
 				args: args{
@@ -623,7 +631,7 @@ func Test_pubkeyd_Update(t *testing.T) {
 						}
 						return true
 					}
-					c.confCache.ZMSPubKeys.Range(checker)
+					rangeKeys(c.keyStores[EnvZMS], checker)
 					if ind != 2 {
 						return errors.Errorf("invalid length ZMSPubKeys. want: 2, result: %d", ind)
 					}
@@ -632,7 +640,7 @@ func Test_pubkeyd_Update(t *testing.T) {
 					}
 					err = nil
 					ind = 0
-					c.confCache.ZTSPubKeys.Range(checker)
+					rangeKeys(c.keyStores[EnvZTS], checker)
 					if ind != 1 {
 						return errors.Errorf("invalid length ZTSPubKeys. want: 1, result: %d", ind)
 					}
@@ -683,10 +691,8 @@ func Test_pubkeyd_Update(t *testing.T) {
 
 			zmsVer := &VerifierMock{}
 			ztsVer := &VerifierMock{}
-			zmsVM := new(sync.Map)
-			ztsVM := new(sync.Map)
-			zmsVM.Store("zms", zmsVer)
-			ztsVM.Store("zts", ztsVer)
+			zmsVM := newKeyStore(map[string]authcore.Verifier{"zms": zmsVer})
+			ztsVM := newKeyStore(map[string]authcore.Verifier{"zts": ztsVer})
 			return test{
 				name: "test use ETag cache",
 				fields: fields{
@@ -695,9 +701,9 @@ func Test_pubkeyd_Update(t *testing.T) {
 					eTagCache:     ec,
 					eTagExpiry:    time.Minute,
 					client:        srv.Client(),
-					confCache: &AthenzConfig{
-						ZMSPubKeys: zmsVM,
-						ZTSPubKeys: ztsVM,
+					keyStores: map[AthenzEnv]*keyStore{
+						EnvZMS: zmsVM,
+						EnvZTS: ztsVM,
 					},
 				},
 				args: args{
@@ -721,7 +727,7 @@ func Test_pubkeyd_Update(t *testing.T) {
 						}
 						return true
 					}
-					c.confCache.ZMSPubKeys.Range(checker)
+					rangeKeys(c.keyStores[EnvZMS], checker)
 					if ind != 1 {
 						return errors.Errorf("invalid length ZMSPubKeys. want: 1, result: %d", ind)
 					}
@@ -730,7 +736,7 @@ func Test_pubkeyd_Update(t *testing.T) {
 					}
 					err = nil
 					ind = 0
-					c.confCache.ZTSPubKeys.Range(checker)
+					rangeKeys(c.keyStores[EnvZTS], checker)
 					if ind != 1 {
 						return errors.Errorf("invalid length ZTSPubKeys. want: 1, result: %d", ind)
 					}
@@ -770,9 +776,9 @@ func Test_pubkeyd_Update(t *testing.T) {
 					eTagCache:     gache.New(),
 					eTagExpiry:    time.Minute,
 					client:        srv.Client(),
-					confCache: &AthenzConfig{
-						ZMSPubKeys: new(sync.Map),
-						ZTSPubKeys: new(sync.Map),
+					keyStores: map[AthenzEnv]*keyStore{
+						EnvZMS: newKeyStore(nil),
+						EnvZTS: newKeyStore(nil),
 					},
 				},
 				args: args{
@@ -821,16 +827,16 @@ func Test_pubkeyd_Update(t *testing.T) {
 					eTagCache:     gache.New(),
 					eTagExpiry:    time.Minute,
 					client:        srv.Client(),
-					confCache: &AthenzConfig{
-						ZMSPubKeys: new(sync.Map),
-						ZTSPubKeys: new(sync.Map),
+					keyStores: map[AthenzEnv]*keyStore{
+						EnvZMS: newKeyStore(nil),
+						EnvZTS: newKeyStore(nil),
 					},
 				},
 				args: args{
 					ctx: context.Background(),
 				},
 				checkFunc: func(c *pubkeyd, gotErr error) error {
-					wantErr := "error when processing pubkey: Error updating ZMS athenz pubkey: error decoding key: illegal base64 data at input byte 6"
+					wantErr := "error when processing pubkey: Error updating ZMS athenz pubkey: no valid key, invalid key IDs: [0]: Invalid athenz pubkey"
 					if gotErr.Error() != wantErr {
 						return errors.Wrap(gotErr, "unexpected error")
 					}
@@ -872,16 +878,16 @@ func Test_pubkeyd_Update(t *testing.T) {
 					eTagCache:     gache.New(),
 					eTagExpiry:    time.Minute,
 					client:        srv.Client(),
-					confCache: &AthenzConfig{
-						ZMSPubKeys: new(sync.Map),
-						ZTSPubKeys: new(sync.Map),
+					keyStores: map[AthenzEnv]*keyStore{
+						EnvZMS: newKeyStore(nil),
+						EnvZTS: newKeyStore(nil),
 					},
 				},
 				args: args{
 					ctx: context.Background(),
 				},
 				checkFunc: func(c *pubkeyd, gotErr error) error {
-					wantErr := "error when processing pubkey: Error updating ZTS athenz pubkey: error initializing verifier: Unable to load public key"
+					wantErr := "error when processing pubkey: Error updating ZTS athenz pubkey: no valid key, invalid key IDs: [0]: Invalid athenz pubkey"
 					if gotErr.Error() != wantErr {
 						return errors.Wrap(gotErr, "unexpected error")
 					}
@@ -889,6 +895,50 @@ func Test_pubkeyd_Update(t *testing.T) {
 				},
 			}
 		}(),
+		func() test {
+			handler := http.HandlerFunc(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Add("ETag", "dummyETag")
+				_, err := w.Write([]byte(`{"name":"dummyDom.zms","publicKeys":[{"key":"LS0tLS1CRUdJTiBQVUJMSUMgS0VZLS0tLS0KTUlHZk1BMEdDU3FHU0liM0RRRUJBUVVBQTRHTkFEQ0JpUUtCZ1FEVTU3VEVoWW5xUkRNM0R2UUM4ajNQSU1FeAp1M3JtYW9QakV6SnlRWTFrVm42MEE2cXJKTDJ1N3N2NHNTa1V5NjdJSUlhQ1VXNVp4aTRXUEdyazAvQm9oMDlGCkJWL1ZML0dMMTB6UmFvcDJXT3ZXRTlpSWNzKzJOK2pWTk1ycVhxZUNENFphK2dHdGdLTU5SMldiRlQvQlcra0wKUGlGeGg0U0NsVkZrdmI4Mm93SURBUUFCCi0tLS0tRU5EIFBVQkxJQyBLRVktLS0tLQ--","id":"0"},{"key":"ZHVtbXkga2V5Cg--","id":"1"}],"modified":"2017-01-23T02:20:09.331Z"}`))
+				if err != nil {
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+			}))
+			srv := httptest.NewTLSServer(handler)
+
+			return test{
+				name: "test invalid key skipped",
+				fields: fields{
+					athenzURL:     strings.Replace(srv.URL, "https://", "", 1),
+					sysAuthDomain: "dummyDom",
+					eTagCache:     gache.New(),
+					eTagExpiry:    time.Minute,
+					client:        srv.Client(),
+					keyStores: map[AthenzEnv]*keyStore{
+						EnvZMS: newKeyStore(nil),
+						EnvZTS: newKeyStore(nil),
+					},
+				},
+				args: args{
+					ctx: context.Background(),
+				},
+				checkFunc: func(c *pubkeyd, gotErr error) error {
+					if errors.Cause(gotErr) != ErrInvalidAthenzPubkey {
+						return errors.Wrap(gotErr, "unexpected error")
+					}
+					for _, ks := range []*keyStore{c.keyStores[EnvZMS], c.keyStores[EnvZTS]} {
+						if ks.get("0") == nil {
+							return errors.New("valid key not stored")
+						}
+						if ks.get("1") != nil {
+							return errors.New("invalid key stored")
+						}
+					}
+					return nil
+				},
+			}
+		}(),
 	}
 
 	for _, tt := range tests {
@@ -902,7 +952,7 @@ func Test_pubkeyd_Update(t *testing.T) {
 				athenzURL:       tt.fields.athenzURL,
 				sysAuthDomain:   tt.fields.sysAuthDomain,
 				client:          tt.fields.client,
-				confCache:       tt.fields.confCache,
+				keyStores:       tt.fields.keyStores,
 			}
 			err := c.Update(tt.args.ctx)
 			if err = tt.checkFunc(c, err); err != nil {
@@ -922,7 +972,7 @@ func Test_pubkeyd_Start(t *testing.T) {
 		athenzURL       string
 		sysAuthDomain   string
 		client          *http.Client
-		confCache       *AthenzConfig
+		keyStores       map[AthenzEnv]*keyStore
 	}
 	type args struct {
 		ctx context.Context
@@ -972,9 +1022,9 @@ func Test_pubkeyd_Start(t *testing.T) {
 					eTagExpiry:      time.Minute,
 					eTagPurgePeriod: time.Minute,
 					client:          srv.Client(),
-					confCache: &AthenzConfig{
-						ZMSPubKeys: new(sync.Map),
-						ZTSPubKeys: new(sync.Map),
+					keyStores: map[AthenzEnv]*keyStore{
+						EnvZMS: newKeyStore(nil),
+						EnvZTS: newKeyStore(nil),
 					},
 				},
 				args: args{
@@ -1007,8 +1057,8 @@ func Test_pubkeyd_Start(t *testing.T) {
 						}
 						return true
 					}
-					check := func(m *sync.Map, wc int) error {
-						m.Range(checker)
+					check := func(m *keyStore, wc int) error {
+						rangeKeys(m, checker)
 						if ind != wc {
 							return errors.Errorf("invalid length ZMSPubKeys. want: %d, result: %d", wc, ind)
 						}
@@ -1017,13 +1067,13 @@ func Test_pubkeyd_Start(t *testing.T) {
 						}
 						return nil
 					}
-					err = check(c.confCache.ZMSPubKeys, 0)
+					err = check(c.keyStores[EnvZMS], 0)
 					if err != nil {
 						return err
 					}
 					err = nil
 					ind = 0
-					err = check(c.confCache.ZTSPubKeys, 0)
+					err = check(c.keyStores[EnvZTS], 0)
 					if err != nil {
 						return err
 					}
@@ -1087,9 +1137,9 @@ func Test_pubkeyd_Start(t *testing.T) {
 					eTagExpiry:      time.Minute,
 					eTagPurgePeriod: time.Minute,
 					client:          srv.Client(),
-					confCache: &AthenzConfig{
-						ZMSPubKeys: new(sync.Map),
-						ZTSPubKeys: new(sync.Map),
+					keyStores: map[AthenzEnv]*keyStore{
+						EnvZMS: newKeyStore(nil),
+						EnvZTS: newKeyStore(nil),
 					},
 				},
 				args: args{
@@ -1157,9 +1207,9 @@ func Test_pubkeyd_Start(t *testing.T) {
 					eTagExpiry:      time.Minute,
 					eTagPurgePeriod: time.Minute,
 					client:          srv.Client(),
-					confCache: &AthenzConfig{
-						ZMSPubKeys: new(sync.Map),
-						ZTSPubKeys: new(sync.Map),
+					keyStores: map[AthenzEnv]*keyStore{
+						EnvZMS: newKeyStore(nil),
+						EnvZTS: newKeyStore(nil),
 					},
 				},
 				args: args{
@@ -1199,8 +1249,8 @@ func Test_pubkeyd_Start(t *testing.T) {
 						}
 						return true
 					}
-					check := func(m *sync.Map, wc int, env string) error {
-						m.Range(checker)
+					check := func(m *keyStore, wc int, env string) error {
+						rangeKeys(m, checker)
 						if ind != wc {
 							return errors.Errorf("invalid length %s PubKeys. want: %d, result: %d", env, wc, ind)
 						}
@@ -1209,13 +1259,13 @@ func Test_pubkeyd_Start(t *testing.T) {
 						}
 						return nil
 					}
-					err = check(c.confCache.ZMSPubKeys, 1, "ZMS")
+					err = check(c.keyStores[EnvZMS], 1, "ZMS")
 					if err != nil {
 						return err
 					}
 					err = nil
 					ind = 0
-					err = check(c.confCache.ZTSPubKeys, 1, "ZTS")
+					err = check(c.keyStores[EnvZTS], 1, "ZTS")
 					if err != nil {
 						return err
 					}
@@ -1238,7 +1288,7 @@ func Test_pubkeyd_Start(t *testing.T) {
 				athenzURL:       tt.fields.athenzURL,
 				sysAuthDomain:   tt.fields.sysAuthDomain,
 				client:          tt.fields.client,
-				confCache:       tt.fields.confCache,
+				keyStores:       tt.fields.keyStores,
 			}
 			ch := c.Start(tt.args.ctx)
 			if err := tt.checkFunc(c, ch); err != nil {
@@ -1247,3 +1297,104 @@ func Test_pubkeyd_Start(t *testing.T) {
 		})
 	}
 }
+
+func Test_pubkeyd_getPubKey_environments(t *testing.T) {
+	d, err := New(
+		WithEnvironment("zts-east", EnvConfig{Service: "zts", Usage: EnvZTS}),
+		WithEnvironment("zts-west", EnvConfig{Service: "zts", Usage: EnvZTS}),
+		WithEnvironment("standalone", EnvConfig{}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := d.(*pubkeyd)
+	ztsVer := &VerifierMock{}
+	eastVer := &VerifierMock{}
+	westVer := &VerifierMock{}
+	standaloneVer := &VerifierMock{}
+	c.keyStores[EnvZTS].swap(map[string]authcore.Verifier{"0": ztsVer}, 0)
+	c.keyStores["zts-east"].swap(map[string]authcore.Verifier{"0": eastVer, "1": eastVer}, 0)
+	c.keyStores["zts-west"].swap(map[string]authcore.Verifier{"1": westVer, "2": westVer}, 0)
+	c.keyStores["standalone"].swap(map[string]authcore.Verifier{"3": standaloneVer}, 0)
+
+	tests := []struct {
+		name  string
+		env   AthenzEnv
+		keyID string
+		want  authcore.Verifier
+	}{
+		{
+			name:  "key of the usage environment first",
+			env:   EnvZTS,
+			keyID: "0",
+			want:  ztsVer,
+		},
+		{
+			name:  "key of the other environments in the name order",
+			env:   EnvZTS,
+			keyID: "1",
+			want:  eastVer,
+		},
+		{
+			name:  "key of the other environment",
+			env:   EnvZTS,
+			keyID: "2",
+			want:  westVer,
+		},
+		{
+			name:  "key of the environment",
+			env:   "zts-west",
+			keyID: "1",
+			want:  westVer,
+		},
+		{
+			name:  "key of the other usage not found",
+			env:   EnvZMS,
+			keyID: "0",
+			want:  nil,
+		},
+		{
+			name:  "key of the standalone environment",
+			env:   "standalone",
+			keyID: "3",
+			want:  standaloneVer,
+		},
+		{
+			name:  "key of the standalone environment not used for ZTS",
+			env:   EnvZTS,
+			keyID: "3",
+			want:  nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := c.getPubKey(tt.env, tt.keyID); got != tt.want {
+				t.Errorf("getPubKey() = expect: %v	result: %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func Test_pubkeyd_fetchPubKeyEntries_environment(t *testing.T) {
+	var gotPath string
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		_, _ = w.Write([]byte(`{"name":"dummy","publicKeys":[]}`))
+	}))
+	defer srv.Close()
+
+	d, err := New(
+		WithAthenzURL("dummy.athenz.io"),
+		WithHTTPClient(srv.Client()),
+		WithEnvironment("zts-east", EnvConfig{AthenzURL: srv.URL, SysAuthDomain: "east.sys.auth", Service: "zts", Usage: EnvZTS}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := d.(*pubkeyd).fetchPubKeyEntries(context.Background(), "zts-east"); err != nil {
+		t.Fatalf("fetchPubKeyEntries() error = %v", err)
+	}
+	if want := "/domain/east.sys.auth/service/zts"; gotPath != want {
+		t.Errorf("fetchPubKeyEntries() path = %s, want %s", gotPath, want)
+	}
+}
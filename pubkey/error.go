@@ -24,4 +24,7 @@ var (
 
 	// ErrEmptyAthenzPubkey "Athenz pubkey not initialized"
 	ErrEmptyAthenzPubkey = errors.New("Athenz pubkey not initialized")
+
+	// ErrInvalidAthenzPubkey "Invalid athenz pubkey"
+	ErrInvalidAthenzPubkey = errors.New("Invalid athenz pubkey")
 )
@@ -0,0 +1,107 @@
+/*
+Copyright (C)  2018 Yahoo Japan Corporation Athenz team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pubkey
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kpango/fastime"
+	authcore "github.com/yahoo/athenz/libs/go/zmssvctoken"
+)
+
+// keySet represents an immutable set of the public keys. It must not be modified after stored into the keyStore.
+type keySet struct {
+	keys map[string]authcore.Verifier
+	// the keys removed from the Athenz server, which are still accepted until the end of the grace period
+	retired map[string]*retiredKey
+}
+
+type retiredKey struct {
+	ver    authcore.Verifier
+	expiry time.Time
+}
+
+// keyStore holds the key set of an Athenz environment. The whole key set is swapped atomically, so that the readers never see a partial key set.
+type keyStore struct {
+	v atomic.Value // *keySet
+	// serializes the writers
+	mu sync.Mutex
+}
+
+// newKeyStore returns a keyStore holding the keys.
+func newKeyStore(keys map[string]authcore.Verifier) *keyStore {
+	s := new(keyStore)
+	if keys == nil {
+		keys = make(map[string]authcore.Verifier)
+	}
+	s.v.Store(&keySet{
+		keys:    keys,
+		retired: make(map[string]*retiredKey),
+	})
+	return s
+}
+
+// load returns the current key set, or nil if not stored yet.
+func (s *keyStore) load() *keySet {
+	ks, _ := s.v.Load().(*keySet)
+	return ks
+}
+
+// get returns the verifier of the key ID, or nil if not found or the grace period of the removed key is over.
+func (s *keyStore) get(keyID string) authcore.Verifier {
+	ks := s.load()
+	if ks == nil {
+		return nil
+	}
+	if ver, ok := ks.keys[keyID]; ok {
+		return ver
+	}
+	if rk, ok := ks.retired[keyID]; ok && fastime.Now().Before(rk.expiry) {
+		return rk.ver
+	}
+	return nil
+}
+
+// swap replaces the key set with the keys. The keys removed from the current key set are kept for the grace period.
+func (s *keyStore) swap(keys map[string]authcore.Verifier, gracePeriod time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := fastime.Now()
+	next := &keySet{
+		keys:    keys,
+		retired: make(map[string]*retiredKey),
+	}
+	if cur := s.load(); cur != nil && gracePeriod > 0 {
+		for id, rk := range cur.retired {
+			if _, ok := keys[id]; !ok && now.Before(rk.expiry) {
+				next.retired[id] = rk
+			}
+		}
+		for id, ver := range cur.keys {
+			if _, ok := keys[id]; !ok {
+				next.retired[id] = &retiredKey{
+					ver:    ver,
+					expiry: now.Add(gracePeriod),
+				}
+			}
+		}
+	}
+	s.v.Store(next)
+}
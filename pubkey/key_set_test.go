@@ -0,0 +1,110 @@
+/*
+Copyright (C)  2018 Yahoo Japan Corporation Athenz team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pubkey
+
+import (
+	"testing"
+	"time"
+
+	authcore "github.com/yahoo/athenz/libs/go/zmssvctoken"
+)
+
+func Test_keyStore_swap(t *testing.T) {
+	ver0 := &VerifierMock{}
+	ver1 := &VerifierMock{}
+	ver2 := &VerifierMock{}
+	type want struct {
+		keyID string
+		ver   authcore.Verifier
+	}
+	tests := []struct {
+		name        string
+		store       func() *keyStore
+		keys        map[string]authcore.Verifier
+		gracePeriod time.Duration
+		wants       []want
+	}{
+		{
+			name: "removed key deleted without grace period",
+			store: func() *keyStore {
+				return newKeyStore(map[string]authcore.Verifier{"0": ver0, "1": ver1})
+			},
+			keys: map[string]authcore.Verifier{"1": ver1, "2": ver2},
+			wants: []want{
+				{keyID: "0", ver: nil},
+				{keyID: "1", ver: ver1},
+				{keyID: "2", ver: ver2},
+			},
+		},
+		{
+			name: "removed key kept during grace period",
+			store: func() *keyStore {
+				return newKeyStore(map[string]authcore.Verifier{"0": ver0, "1": ver1})
+			},
+			keys:        map[string]authcore.Verifier{"1": ver1, "2": ver2},
+			gracePeriod: time.Hour,
+			wants: []want{
+				{keyID: "0", ver: ver0},
+				{keyID: "1", ver: ver1},
+				{keyID: "2", ver: ver2},
+			},
+		},
+		{
+			name: "retired key expired",
+			store: func() *keyStore {
+				s := newKeyStore(map[string]authcore.Verifier{"1": ver1})
+				s.load().retired["0"] = &retiredKey{ver: ver0, expiry: time.Now().Add(-time.Second)}
+				return s
+			},
+			keys:        map[string]authcore.Verifier{"1": ver1},
+			gracePeriod: time.Hour,
+			wants: []want{
+				{keyID: "0", ver: nil},
+				{keyID: "1", ver: ver1},
+			},
+		},
+		{
+			name: "retired key re-added",
+			store: func() *keyStore {
+				s := newKeyStore(map[string]authcore.Verifier{"1": ver1})
+				s.load().retired["0"] = &retiredKey{ver: ver0, expiry: time.Now().Add(time.Hour)}
+				return s
+			},
+			keys:        map[string]authcore.Verifier{"0": ver2, "1": ver1},
+			gracePeriod: time.Hour,
+			wants: []want{
+				{keyID: "0", ver: ver2},
+				{keyID: "1", ver: ver1},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := tt.store()
+			old := s.load()
+			s.swap(tt.keys, tt.gracePeriod)
+			if s.load() == old {
+				t.Errorf("keyStore.swap() key set not replaced")
+			}
+			for _, w := range tt.wants {
+				if got := s.get(w.keyID); got != w.ver {
+					t.Errorf("keyStore.get(%s) = %v, want %v", w.keyID, got, w.ver)
+				}
+			}
+		})
+	}
+}
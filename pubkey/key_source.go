@@ -0,0 +1,151 @@
+/*
+Copyright (C)  2018 Yahoo Japan Corporation Athenz team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pubkey
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kpango/glg"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/pkg/errors"
+	authcore "github.com/yahoo/athenz/libs/go/zmssvctoken"
+)
+
+// KeySource represents a source of the Athenz public keys, used instead of the sys.auth domain of ZMS.
+type KeySource interface {
+	// Fetch returns all the public keys of the Athenz environment. The key of each entry is the YBase64 encoded PEM public key, same as the sys.auth domain.
+	// It returns an empty slice if the source has no key of the environment.
+	Fetch(ctx context.Context, env AthenzEnv) ([]*PublicKey, error)
+}
+
+// KeySourceFunc is an adapter to use the function as a KeySource.
+type KeySourceFunc func(ctx context.Context, env AthenzEnv) ([]*PublicKey, error)
+
+// Fetch calls f(ctx, env).
+func (f KeySourceFunc) Fetch(ctx context.Context, env AthenzEnv) ([]*PublicKey, error) {
+	return f(ctx, env)
+}
+
+// NewStaticKeySource returns a KeySource of the keys embedded in the config, e.g. the "zmsPublicKeys" and "ztsPublicKeys" in athenz.conf.
+func NewStaticKeySource(keys map[AthenzEnv][]*PublicKey) KeySource {
+	return KeySourceFunc(func(ctx context.Context, env AthenzEnv) ([]*PublicKey, error) {
+		return keys[env], nil
+	})
+}
+
+// NewDirKeySource returns a KeySource of the PEM files in the local directory.
+// The public key files are placed as "{dir}/{env}/{keyID}.pem", e.g. "/etc/athenz/keys/zts/0.pem".
+func NewDirKeySource(dir string) KeySource {
+	return KeySourceFunc(func(ctx context.Context, env AthenzEnv) ([]*PublicKey, error) {
+		envDir := filepath.Join(dir, string(env))
+		files, err := ioutil.ReadDir(envDir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return []*PublicKey{}, nil
+			}
+			return nil, errors.Wrap(err, "error reading public key directory")
+		}
+
+		enc := new(authcore.YBase64)
+		keys := make([]*PublicKey, 0, len(files))
+		for _, f := range files {
+			if f.IsDir() || filepath.Ext(f.Name()) != ".pem" {
+				continue
+			}
+			b, err := ioutil.ReadFile(filepath.Join(envDir, f.Name()))
+			if err != nil {
+				return nil, errors.Wrapf(err, "error reading public key file %s", f.Name())
+			}
+			keys = append(keys, &PublicKey{
+				ID:  strings.TrimSuffix(f.Name(), ".pem"),
+				Key: enc.EncodeToString(b),
+			})
+		}
+		return keys, nil
+	})
+}
+
+// NewJWKSKeySource returns a KeySource of the JWK Set of the environment, e.g. "https://athenz.io/zts/v1/oauth2/keys?rfc=true" of ZTS.
+// The keys of the other environments are empty. The environment without any key is skipped by Update, and the current keys are kept if the keys become empty.
+func NewJWKSKeySource(env AthenzEnv, url string, client *http.Client) KeySource {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return KeySourceFunc(func(ctx context.Context, e AthenzEnv) ([]*PublicKey, error) {
+		if e != env {
+			return []*PublicKey{}, nil
+		}
+		glg.Debugf("Fetching public key from %s", url)
+		set, err := fetchJWKS(ctx, client, url)
+		if err != nil {
+			return nil, errors.Wrap(ErrFetchAthenzPubkey, err.Error())
+		}
+
+		enc := new(authcore.YBase64)
+		keys := make([]*PublicKey, 0, len(set.Keys))
+		for _, key := range set.Keys {
+			var raw interface{}
+			if err := key.Raw(&raw); err != nil {
+				glg.Warnf("error materializing JWK, skipped, keyID: %s, error: %v", key.KeyID(), err)
+				continue
+			}
+			der, err := x509.MarshalPKIXPublicKey(raw)
+			if err != nil {
+				glg.Warnf("error marshaling JWK, skipped, keyID: %s, error: %v", key.KeyID(), err)
+				continue
+			}
+			keys = append(keys, &PublicKey{
+				ID:  key.KeyID(),
+				Key: enc.EncodeToString(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})),
+			})
+		}
+		return keys, nil
+	})
+}
+
+// fetchJWKS fetches the JWK Set of the URL, the request is canceled when ctx is done.
+func fetchJWKS(ctx context.Context, client *http.Client, url string) (*jwk.Set, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating get JWK Set request")
+	}
+	res, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, errors.Wrap(err, "error make http request")
+	}
+	defer func() {
+		// flush the body to reuse the connection
+		_, _ = io.Copy(ioutil.Discard, res.Body)
+		_ = res.Body.Close()
+	}()
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("http return status not OK, status: %d", res.StatusCode)
+	}
+	set, err := jwk.Parse(res.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing JWK Set")
+	}
+	return set, nil
+}
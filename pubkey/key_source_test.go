@@ -0,0 +1,288 @@
+/*
+Copyright (C)  2018 Yahoo Japan Corporation Athenz team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pubkey
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/pkg/errors"
+	authcore "github.com/yahoo/athenz/libs/go/zmssvctoken"
+)
+
+func newTestPublicKeyPEM(t *testing.T) (*ecdsa.PrivateKey, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+// checkKeys checks the key IDs of the keys and that every key can create a verifier.
+func checkKeys(t *testing.T, keys []*PublicKey, wantIDs ...string) {
+	if len(keys) != len(wantIDs) {
+		t.Errorf("got %d keys, want %d", len(keys), len(wantIDs))
+		return
+	}
+	dec := new(authcore.YBase64)
+	for i, key := range keys {
+		if key.ID != wantIDs[i] {
+			t.Errorf("key ID = %s, want %s", key.ID, wantIDs[i])
+		}
+		b, err := dec.DecodeString(key.Key)
+		if err != nil {
+			t.Errorf("error decoding key %s: %v", key.ID, err)
+			continue
+		}
+		if _, err := authcore.NewVerifier(b); err != nil {
+			t.Errorf("error initializing verifier %s: %v", key.ID, err)
+		}
+	}
+}
+
+func TestNewStaticKeySource(t *testing.T) {
+	_, pemKey := newTestPublicKeyPEM(t)
+	src := NewStaticKeySource(map[AthenzEnv][]*PublicKey{
+		EnvZTS: {
+			{ID: "0", Key: new(authcore.YBase64).EncodeToString(pemKey)},
+		},
+	})
+
+	keys, err := src.Fetch(context.Background(), EnvZTS)
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	checkKeys(t, keys, "0")
+
+	keys, err = src.Fetch(context.Background(), EnvZMS)
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	checkKeys(t, keys)
+}
+
+func TestNewDirKeySource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pubkey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	_, pemKey := newTestPublicKeyPEM(t)
+	if err := os.MkdirAll(filepath.Join(dir, "zts", "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, b := range map[string][]byte{
+		"0.pem":      pemKey,
+		"1.pem":      pemKey,
+		"README.txt": []byte("not a key"),
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, "zts", name), b, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	src := NewDirKeySource(dir)
+	keys, err := src.Fetch(context.Background(), EnvZTS)
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	checkKeys(t, keys, "0", "1")
+
+	// no directory of the environment
+	keys, err = src.Fetch(context.Background(), EnvZMS)
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	checkKeys(t, keys)
+}
+
+func TestNewJWKSKeySource(t *testing.T) {
+	priv, _ := newTestPublicKeyPEM(t)
+	key, err := jwk.New(&priv.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := key.Set(jwk.KeyIDKey, "0"); err != nil {
+		t.Fatal(err)
+	}
+	body, err := json.Marshal(map[string]interface{}{"keys": []jwk.Key{key}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/oauth2/keys" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(body)
+	}))
+	defer srv.Close()
+
+	src := NewJWKSKeySource(EnvZTS, srv.URL+"/oauth2/keys", srv.Client())
+	keys, err := src.Fetch(context.Background(), EnvZTS)
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	checkKeys(t, keys, "0")
+
+	// other environment
+	keys, err = src.Fetch(context.Background(), EnvZMS)
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	checkKeys(t, keys)
+
+	// fetch error
+	src = NewJWKSKeySource(EnvZTS, srv.URL+"/notfound", srv.Client())
+	if _, err := src.Fetch(context.Background(), EnvZTS); err == nil {
+		t.Errorf("Fetch() error = nil")
+	}
+
+	// canceled
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	src = NewJWKSKeySource(EnvZTS, srv.URL+"/oauth2/keys", srv.Client())
+	if _, err := src.Fetch(ctx, EnvZTS); err == nil {
+		t.Errorf("Fetch() error = nil with canceled context")
+	}
+}
+
+func Test_pubkeyd_fetchPubKeys(t *testing.T) {
+	_, pemKey := newTestPublicKeyPEM(t)
+	encKey := new(authcore.YBase64).EncodeToString(pemKey)
+	tests := []struct {
+		name    string
+		sources []KeySource
+		wantIDs []string
+		wantErr bool
+	}{
+		{
+			name: "keys of all sources",
+			sources: []KeySource{
+				NewStaticKeySource(map[AthenzEnv][]*PublicKey{EnvZTS: {{ID: "0", Key: encKey}}}),
+				NewStaticKeySource(map[AthenzEnv][]*PublicKey{EnvZTS: {{ID: "1", Key: encKey}}}),
+			},
+			wantIDs: []string{"0", "1"},
+		},
+		{
+			name: "source error",
+			sources: []KeySource{
+				NewStaticKeySource(map[AthenzEnv][]*PublicKey{EnvZTS: {{ID: "0", Key: encKey}}}),
+				KeySourceFunc(func(ctx context.Context, env AthenzEnv) ([]*PublicKey, error) {
+					return nil, ErrFetchAthenzPubkey
+				}),
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &pubkeyd{
+				sources: tt.sources,
+			}
+			keys, upded, err := p.fetchPubKeys(context.Background(), EnvZTS)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("pubkeyd.fetchPubKeys() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if !upded {
+				t.Errorf("pubkeyd.fetchPubKeys() updated = false")
+			}
+			checkKeys(t, keys, tt.wantIDs...)
+		})
+	}
+}
+
+func Test_pubkeyd_Update_withKeySources(t *testing.T) {
+	_, pemKey0 := newTestPublicKeyPEM(t)
+	_, pemKey1 := newTestPublicKeyPEM(t)
+	enc := new(authcore.YBase64)
+	p := &pubkeyd{
+		keyStores: map[AthenzEnv]*keyStore{
+			EnvZMS: newKeyStore(nil),
+			EnvZTS: newKeyStore(nil),
+		},
+		sources: []KeySource{
+			NewStaticKeySource(map[AthenzEnv][]*PublicKey{EnvZTS: {{ID: "0", Key: enc.EncodeToString(pemKey0)}}}),
+			NewStaticKeySource(map[AthenzEnv][]*PublicKey{EnvZTS: {{ID: "0", Key: enc.EncodeToString(pemKey1)}}, EnvZMS: {{ID: "1", Key: enc.EncodeToString(pemKey1)}}}),
+		},
+	}
+	if err := p.Update(context.Background()); err != nil {
+		t.Fatalf("pubkeyd.Update() error = %v", err)
+	}
+	if len(p.keyStores[EnvZTS].load().keys) != 1 || p.getPubKey(EnvZTS, "0") == nil {
+		t.Errorf("invalid ZTS keys: %v", p.keyStores[EnvZTS].load().keys)
+	}
+	if len(p.keyStores[EnvZMS].load().keys) != 1 || p.getPubKey(EnvZMS, "1") == nil {
+		t.Errorf("invalid ZMS keys: %v", p.keyStores[EnvZMS].load().keys)
+	}
+}
+
+func Test_pubkeyd_Update_emptyKeySource(t *testing.T) {
+	_, pemKey := newTestPublicKeyPEM(t)
+	enc := new(authcore.YBase64)
+	keys := map[AthenzEnv][]*PublicKey{EnvZTS: {{ID: "0", Key: enc.EncodeToString(pemKey)}}}
+	p := &pubkeyd{
+		keyStores: map[AthenzEnv]*keyStore{
+			EnvZMS: newKeyStore(nil),
+			EnvZTS: newKeyStore(nil),
+		},
+		sources: []KeySource{
+			KeySourceFunc(func(ctx context.Context, env AthenzEnv) ([]*PublicKey, error) {
+				return keys[env], nil
+			}),
+		},
+	}
+	// the environment not covered by the key sources is skipped
+	if err := p.Update(context.Background()); err != nil {
+		t.Fatalf("pubkeyd.Update() error = %v", err)
+	}
+	if p.getPubKey(EnvZTS, "0") == nil {
+		t.Errorf("pubkeyd.Update() ZTS key not found")
+	}
+
+	// the current key set is kept if no key is fetched
+	keys = map[AthenzEnv][]*PublicKey{}
+	err := p.Update(context.Background())
+	if errors.Cause(err) != ErrInvalidAthenzPubkey {
+		t.Errorf("pubkeyd.Update() error = %v, want %v", err, ErrInvalidAthenzPubkey)
+	}
+	if p.getPubKey(EnvZTS, "0") == nil {
+		t.Errorf("pubkeyd.Update() current key set not kept")
+	}
+}
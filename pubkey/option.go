@@ -31,6 +31,7 @@ var (
 		WithETagExpiry("168h"), // 1 week
 		WithETagPurgePeriod("84h"),
 		WithRetryDelay("1m"),
+		WithKeyGracePeriod("0"),
 		WithHTTPClient(&http.Client{}),
 	}
 )
@@ -125,6 +126,65 @@ func WithETagPurgePeriod(d string) Option {
 	}
 }
 
+// WithKeyGracePeriod returns a KeyGracePeriod functional option.
+// The public key removed from the Athenz server is still accepted during the grace period, so that the tokens signed by the rotated key can be verified.
+func WithKeyGracePeriod(t string) Option {
+	return func(p *pubkeyd) error {
+		if t == "" {
+			return nil
+		}
+
+		gp, err := time.ParseDuration(t)
+		if err != nil {
+			return errors.Wrap(err, "invalid key grace period")
+		}
+		p.keyGracePeriod = gp
+		return nil
+	}
+}
+
+// WithEnvironment returns an Environment functional option.
+// It adds the Athenz environment, or overrides the configuration of EnvZMS and EnvZTS,
+// e.g. WithEnvironment("zts-east", EnvConfig{AthenzURL: "zts-east.athenz.io/zts/v1", Service: "zts", Usage: EnvZTS})
+func WithEnvironment(env AthenzEnv, cfg EnvConfig) Option {
+	return func(p *pubkeyd) error {
+		if env == "" {
+			return errors.New("empty environment name")
+		}
+		switch cfg.Usage {
+		case "", EnvZMS, EnvZTS:
+		default:
+			return errors.Errorf("invalid usage of environment %s: %s", env, cfg.Usage)
+		}
+		if cfg.AthenzURL != "" {
+			u := urlutil.TrimHTTPScheme(cfg.AthenzURL)
+			if urlutil.HasScheme(u) {
+				return urlutil.ErrUnsupportedScheme
+			}
+			cfg.AthenzURL = u
+		}
+		if p.envs == nil {
+			p.envs = make(map[AthenzEnv]EnvConfig)
+		}
+		p.envs[env] = cfg
+		return nil
+	}
+}
+
+// WithKeySources returns a KeySources functional option.
+// The public keys are loaded from the key sources instead of the sys.auth domain, e.g. for the hosts that cannot reach ZMS.
+func WithKeySources(srcs ...KeySource) Option {
+	return func(p *pubkeyd) error {
+		for i, src := range srcs {
+			if src == nil {
+				return errors.Errorf("key source[%d] is nil", i)
+			}
+		}
+		p.sources = srcs
+		return nil
+	}
+}
+
 // WithHTTPClient returns a HTTPClient functional option
 func WithHTTPClient(cl *http.Client) Option {
 	return func(p *pubkeyd) error {
@@ -292,6 +292,78 @@ func TestWithRetryDelay(t *testing.T) {
 	}
 }
 
+func TestWithKeyGracePeriod(t *testing.T) {
+	type args struct {
+		time string
+	}
+	tests := []struct {
+		name      string
+		args      args
+		checkFunc func(Option) error
+	}{
+		{
+			name: "set keyGracePeriod expire time success",
+			args: args{
+				time: "2h",
+			},
+			checkFunc: func(got Option) error {
+				p := &pubkeyd{}
+				if err := got(p); err != nil {
+					return err
+				}
+
+				if p.keyGracePeriod != time.Duration(time.Hour*2) {
+					return fmt.Errorf("cannot set keyGracePeriod time")
+				}
+				return nil
+			},
+		},
+		{
+			name: "test set empty string",
+			args: args{
+				time: "",
+			},
+			checkFunc: func(got Option) error {
+				p := &pubkeyd{}
+				if err := got(p); err != nil {
+					return err
+				}
+				if !reflect.DeepEqual(p, &pubkeyd{}) {
+					return fmt.Errorf("expected no changes, but got %v", p)
+				}
+				return nil
+			},
+		},
+		{
+			name: "cannot parse string to time.Duration",
+			args: args{
+				time: "dummy",
+			},
+			checkFunc: func(got Option) error {
+				p := &pubkeyd{}
+				err := got(p)
+
+				if err == nil {
+					return fmt.Errorf("invalid keyGracePeriod time was set")
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithKeyGracePeriod(tt.args.time)
+			if got == nil {
+				t.Errorf("WithKeyGracePeriod() = nil")
+				return
+			}
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithKeyGracePeriod() = %v", err)
+			}
+		})
+	}
+}
+
 func TestWithETagPurgePeriod(t *testing.T) {
 	type args struct {
 		dur string
@@ -492,3 +564,152 @@ func TestWithHTTPClient(t *testing.T) {
 		})
 	}
 }
+
+func TestWithKeySources(t *testing.T) {
+	type args struct {
+		srcs []KeySource
+	}
+	tests := []struct {
+		name      string
+		args      args
+		checkFunc func(Option) error
+	}{
+		{
+			name: "set success",
+			args: args{
+				srcs: []KeySource{
+					NewStaticKeySource(nil),
+					NewDirKeySource("dummy"),
+				},
+			},
+			checkFunc: func(got Option) error {
+				p := &pubkeyd{}
+				if err := got(p); err != nil {
+					return err
+				}
+				if len(p.sources) != 2 {
+					return fmt.Errorf("cannot set sources")
+				}
+				return nil
+			},
+		},
+		{
+			name: "nil key source",
+			args: args{
+				srcs: []KeySource{nil},
+			},
+			checkFunc: func(got Option) error {
+				p := &pubkeyd{}
+				if err := got(p); err == nil {
+					return fmt.Errorf("nil key source was set")
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithKeySources(tt.args.srcs...)
+			if got == nil {
+				t.Errorf("WithKeySources() = nil")
+				return
+			}
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithKeySources() = %v", err)
+			}
+		})
+	}
+}
+
+func TestWithEnvironment(t *testing.T) {
+	type args struct {
+		env AthenzEnv
+		cfg EnvConfig
+	}
+	tests := []struct {
+		name      string
+		args      args
+		checkFunc func(Option) error
+	}{
+		{
+			name: "set success",
+			args: args{
+				env: "zts-east",
+				cfg: EnvConfig{
+					AthenzURL: "https://zts-east.athenz.io/zts/v1",
+					Service:   "zts",
+					Usage:     EnvZTS,
+				},
+			},
+			checkFunc: func(got Option) error {
+				p := &pubkeyd{}
+				if err := got(p); err != nil {
+					return err
+				}
+				want := EnvConfig{
+					AthenzURL: "zts-east.athenz.io/zts/v1",
+					Service:   "zts",
+					Usage:     EnvZTS,
+				}
+				if !reflect.DeepEqual(p.envs["zts-east"], want) {
+					return fmt.Errorf("cannot set environment, got: %v, want: %v", p.envs["zts-east"], want)
+				}
+				return nil
+			},
+		},
+		{
+			name: "empty environment name",
+			args: args{
+				env: "",
+			},
+			checkFunc: func(got Option) error {
+				if err := got(&pubkeyd{}); err == nil {
+					return fmt.Errorf("empty environment name was set")
+				}
+				return nil
+			},
+		},
+		{
+			name: "invalid usage",
+			args: args{
+				env: "zts-east",
+				cfg: EnvConfig{
+					Usage: "dummy",
+				},
+			},
+			checkFunc: func(got Option) error {
+				if err := got(&pubkeyd{}); err == nil {
+					return fmt.Errorf("invalid usage was set")
+				}
+				return nil
+			},
+		},
+		{
+			name: "unsupported scheme",
+			args: args{
+				env: "zts-east",
+				cfg: EnvConfig{
+					AthenzURL: "ftp://zts-east.athenz.io",
+				},
+			},
+			checkFunc: func(got Option) error {
+				if err := got(&pubkeyd{}); err != urlutil.ErrUnsupportedScheme {
+					return fmt.Errorf("unexpected error: %v", err)
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithEnvironment(tt.args.env, tt.args.cfg)
+			if got == nil {
+				t.Errorf("WithEnvironment() = nil")
+				return
+			}
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithEnvironment() = %v", err)
+			}
+		})
+	}
+}
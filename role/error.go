@@ -24,4 +24,10 @@ var (
 
 	// ErrRoleTokenExpired "Access denied due to expired RoleToken"
 	ErrRoleTokenExpired = errors.New("Access denied due to expired RoleToken")
+
+	// ErrRoleTokenNotYetValid "Access denied due to RoleToken issued in the future"
+	ErrRoleTokenNotYetValid = errors.New("Access denied due to RoleToken issued in the future")
+
+	// ErrRoleTokenLifetimeExceeded "Access denied due to RoleToken lifetime exceeding the maximum"
+	ErrRoleTokenLifetimeExceeded = errors.New("Access denied due to RoleToken lifetime exceeding the maximum")
 )
@@ -17,11 +17,16 @@ limitations under the License.
 package role
 
 import (
+	"time"
+
+	"github.com/pkg/errors"
 	"github.com/yahoojapan/athenz-authorizer/v5/pubkey"
 )
 
 var (
-	defaultOptions = []Option{}
+	defaultOptions = []Option{
+		WithClockSkew("5m"),
+	}
 )
 
 // Option represents a functional options pattern interface
@@ -34,3 +39,41 @@ func WithPubkeyProvider(pkp pubkey.Provider) Option {
 		return nil
 	}
 }
+
+// WithAuthorizedProxyUsers represents set authorizedProxyUsers functional option
+func WithAuthorizedProxyUsers(users []string) Option {
+	return func(r *rtp) error {
+		r.authorizedProxyUsers = users
+		return nil
+	}
+}
+
+// WithClockSkew represents set clockSkew functional option
+func WithClockSkew(t string) Option {
+	return func(r *rtp) error {
+		if t == "" {
+			return nil
+		}
+		cs, err := time.ParseDuration(t)
+		if err != nil {
+			return errors.Wrap(err, "invalid clock skew")
+		}
+		r.clockSkew = cs
+		return nil
+	}
+}
+
+// WithMaxLifetime represents set maxLifetime functional option
+func WithMaxLifetime(t string) Option {
+	return func(r *rtp) error {
+		if t == "" {
+			return nil
+		}
+		ml, err := time.ParseDuration(t)
+		if err != nil {
+			return errors.Wrap(err, "invalid max lifetime")
+		}
+		r.maxLifetime = ml
+		return nil
+	}
+}
@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"reflect"
 	"testing"
+	"time"
 
 	authcore "github.com/yahoo/athenz/libs/go/zmssvctoken"
 	"github.com/yahoojapan/athenz-authorizer/v5/pubkey"
@@ -82,3 +83,169 @@ func TestWithPubkeyProvider(t *testing.T) {
 		})
 	}
 }
+
+func TestWithAuthorizedProxyUsers(t *testing.T) {
+	type args struct {
+		users []string
+	}
+	tests := []struct {
+		name      string
+		args      args
+		checkFunc func(Option) error
+	}{
+		{
+			name: "set success",
+			args: args{
+				users: []string{"dummy.proxy"},
+			},
+			checkFunc: func(opt Option) error {
+				r := &rtp{}
+				if err := opt(r); err != nil {
+					return err
+				}
+				if !reflect.DeepEqual(r.authorizedProxyUsers, []string{"dummy.proxy"}) {
+					return fmt.Errorf("Error")
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithAuthorizedProxyUsers(tt.args.users)
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithAuthorizedProxyUsers() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestWithClockSkew(t *testing.T) {
+	type args struct {
+		t string
+	}
+	tests := []struct {
+		name      string
+		args      args
+		checkFunc func(Option) error
+	}{
+		{
+			name: "set success",
+			args: args{
+				t: "1h",
+			},
+			checkFunc: func(opt Option) error {
+				r := &rtp{}
+				if err := opt(r); err != nil {
+					return err
+				}
+				if r.clockSkew != time.Hour {
+					return fmt.Errorf("Error")
+				}
+				return nil
+			},
+		},
+		{
+			name: "empty value",
+			args: args{
+				t: "",
+			},
+			checkFunc: func(opt Option) error {
+				r := &rtp{}
+				if err := opt(r); err != nil {
+					return err
+				}
+				if !reflect.DeepEqual(r, &rtp{}) {
+					return fmt.Errorf("expected no changes, but got %v", r)
+				}
+				return nil
+			},
+		},
+		{
+			name: "invalid format",
+			args: args{
+				t: "invalid",
+			},
+			checkFunc: func(opt Option) error {
+				r := &rtp{}
+				if err := opt(r); err == nil {
+					return fmt.Errorf("expected error, but not return")
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithClockSkew(tt.args.t)
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithClockSkew() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestWithMaxLifetime(t *testing.T) {
+	type args struct {
+		t string
+	}
+	tests := []struct {
+		name      string
+		args      args
+		checkFunc func(Option) error
+	}{
+		{
+			name: "set success",
+			args: args{
+				t: "1h",
+			},
+			checkFunc: func(opt Option) error {
+				r := &rtp{}
+				if err := opt(r); err != nil {
+					return err
+				}
+				if r.maxLifetime != time.Hour {
+					return fmt.Errorf("Error")
+				}
+				return nil
+			},
+		},
+		{
+			name: "empty value",
+			args: args{
+				t: "",
+			},
+			checkFunc: func(opt Option) error {
+				r := &rtp{}
+				if err := opt(r); err != nil {
+					return err
+				}
+				if !reflect.DeepEqual(r, &rtp{}) {
+					return fmt.Errorf("expected no changes, but got %v", r)
+				}
+				return nil
+			},
+		},
+		{
+			name: "invalid format",
+			args: args{
+				t: "invalid",
+			},
+			checkFunc: func(opt Option) error {
+				r := &rtp{}
+				if err := opt(r); err == nil {
+					return fmt.Errorf("expected error, but not return")
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithMaxLifetime(tt.args.t)
+			if err := tt.checkFunc(got); err != nil {
+				t.Errorf("WithMaxLifetime() error = %v", err)
+			}
+		})
+	}
+}
@@ -18,7 +18,9 @@ package role
 
 import (
 	"strings"
+	"time"
 
+	"github.com/kpango/fastime"
 	"github.com/pkg/errors"
 	"github.com/yahoojapan/athenz-authorizer/v5/pubkey"
 )
@@ -30,6 +32,12 @@ type Processor interface {
 
 type rtp struct {
 	pkp pubkey.Provider
+	// The proxy users accepted for the tokens. Empty means the proxy user is not checked.
+	authorizedProxyUsers []string
+	// The clock skew allowed when validating the issue time (t).
+	clockSkew time.Duration
+	// The maximum lifetime (e - t) of the tokens. 0 means the lifetime is not checked.
+	maxLifetime time.Duration
 }
 
 // New returns the Role instance.
@@ -81,12 +89,45 @@ func (r *rtp) parseToken(tok string) (*Token, error) {
 }
 
 func (r *rtp) validate(rt *Token) error {
+	if err := rt.validateRequiredFields(); err != nil {
+		return err
+	}
+	if err := r.validateProxyUser(rt); err != nil {
+		return err
+	}
 	if rt.Expired() {
 		return errors.Wrapf(ErrRoleTokenExpired, "token expired")
 	}
+	if err := r.validateIssueTime(rt); err != nil {
+		return err
+	}
 	ver := r.pkp(pubkey.EnvZTS, rt.KeyID)
 	if ver == nil {
 		return errors.Wrapf(ErrRoleTokenInvalid, "invalid role token key ID %s", rt.KeyID)
 	}
 	return ver.Verify(rt.UnsignedToken, rt.Signature)
 }
+
+func (r *rtp) validateIssueTime(rt *Token) error {
+	if rt.TimeStamp.After(fastime.Now().Add(r.clockSkew)) {
+		return errors.Wrapf(ErrRoleTokenNotYetValid, "token issued at %v", rt.TimeStamp)
+	}
+	if r.maxLifetime > 0 {
+		if lifetime := rt.ExpiryTime.Sub(rt.TimeStamp); lifetime > r.maxLifetime {
+			return errors.Wrapf(ErrRoleTokenLifetimeExceeded, "token lifetime %v, max %v", lifetime, r.maxLifetime)
+		}
+	}
+	return nil
+}
+
+func (r *rtp) validateProxyUser(rt *Token) error {
+	if rt.ProxyUser == "" || len(r.authorizedProxyUsers) == 0 {
+		return nil
+	}
+	for _, v := range r.authorizedProxyUsers {
+		if v == rt.ProxyUser {
+			return nil
+		}
+	}
+	return errors.Wrapf(ErrRoleTokenInvalid, "proxy user %s is not authorized", rt.ProxyUser)
+}
@@ -23,6 +23,7 @@ import (
 	"time"
 
 	"github.com/kpango/fastime"
+	"github.com/pkg/errors"
 	authcore "github.com/yahoo/athenz/libs/go/zmssvctoken"
 	"github.com/yahoojapan/athenz-authorizer/v5/pubkey"
 )
@@ -46,10 +47,34 @@ func TestNew(t *testing.T) {
 				opts: nil,
 			},
 			want: &rtp{
-				nil,
+				clockSkew: 5 * time.Minute,
 			},
 			wantErr: false,
 		},
+		{
+			name: "new success, use option",
+			args: args{
+				opts: []Option{
+					WithClockSkew("1m"),
+					WithMaxLifetime("720h"),
+				},
+			},
+			want: &rtp{
+				clockSkew:   time.Minute,
+				maxLifetime: 720 * time.Hour,
+			},
+			wantErr: false,
+		},
+		{
+			name: "new fail, option is error",
+			args: args{
+				opts: []Option{
+					WithMaxLifetime("invalid-duration"),
+				},
+			},
+			want:    nil,
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -102,6 +127,10 @@ func Test_rtp_ParseAndValidateRoleToken(t *testing.T) {
 				Signature:     "dummysignature",
 				Principal:     "takumats.tenant.test",
 				TimeStamp:     time.Unix(1550463321, 0),
+				Version:       "Z1",
+				Host:          "dummyhost",
+				Salt:          "e55ee6ddc3e3c27c",
+				IP:            "172.16.168.25",
 			},
 		},
 		{
@@ -150,6 +179,10 @@ func Test_rtp_ParseAndValidateRoleToken(t *testing.T) {
 				Signature:     "dummysignature;d=dummy1;r=users2",
 				Principal:     "takumats.tenant.test",
 				TimeStamp:     time.Unix(1550463321, 0),
+				Version:       "Z1",
+				Host:          "dummyhost",
+				Salt:          "e55ee6ddc3e3c27c",
+				IP:            "172.16.168.25",
 			},
 		},
 		{
@@ -175,6 +208,10 @@ func Test_rtp_ParseAndValidateRoleToken(t *testing.T) {
 				Signature:     "dummysignature;s=dummysignature2",
 				Principal:     "takumats.tenant.test",
 				TimeStamp:     time.Unix(1550463321, 0),
+				Version:       "Z1",
+				Host:          "dummyhost",
+				Salt:          "e55ee6ddc3e3c27c",
+				IP:            "172.16.168.25",
 			},
 		},
 	}
@@ -223,6 +260,10 @@ func Test_rtp_parseToken(t *testing.T) {
 				Signature:     "dummysignature",
 				Principal:     "takumats.tenant.test",
 				TimeStamp:     time.Unix(1550463321, 0),
+				Version:       "Z1",
+				Host:          "dummyhost",
+				Salt:          "e55ee6ddc3e3c27c",
+				IP:            "172.16.168.25",
 			},
 		},
 		{
@@ -266,16 +307,40 @@ func Test_rtp_parseToken(t *testing.T) {
 
 func Test_rtp_validate(t *testing.T) {
 	type fields struct {
-		pkp pubkey.Provider
+		pkp                  pubkey.Provider
+		authorizedProxyUsers []string
+		clockSkew            time.Duration
+		maxLifetime          time.Duration
 	}
 	type args struct {
 		rt *Token
 	}
+	newToken := func(expiry time.Time) *Token {
+		return &Token{
+			Version:    "Z1",
+			Domain:     "dummy.sidecartest",
+			Roles:      []string{"users"},
+			Principal:  "takumats.tenant.test",
+			Salt:       "e55ee6ddc3e3c27c",
+			TimeStamp:  fastime.Now(),
+			ExpiryTime: expiry,
+			KeyID:      "0",
+			Signature:  "dummysignature",
+		}
+	}
+	verifierSuccess := func(pubkey.AthenzEnv, string) authcore.Verifier {
+		return VerifierMock{
+			VerifyFunc: func(string, string) error {
+				return nil
+			},
+		}
+	}
 	tests := []struct {
-		name    string
-		fields  fields
-		args    args
-		wantErr bool
+		name         string
+		fields       fields
+		args         args
+		wantErr      bool
+		wantErrCause error
 	}{
 		{
 			name: "validate success",
@@ -289,9 +354,7 @@ func Test_rtp_validate(t *testing.T) {
 				},
 			},
 			args: args{
-				&Token{
-					ExpiryTime: fastime.Now().Add(time.Hour),
-				},
+				newToken(fastime.Now().Add(time.Hour)),
 			},
 		},
 		{
@@ -306,11 +369,10 @@ func Test_rtp_validate(t *testing.T) {
 				},
 			},
 			args: args{
-				&Token{
-					ExpiryTime: fastime.Now().Add(-1 * time.Hour),
-				},
+				newToken(fastime.Now().Add(-1 * time.Hour)),
 			},
-			wantErr: true,
+			wantErr:      true,
+			wantErrCause: ErrRoleTokenExpired,
 		},
 		{
 			name: "validate error",
@@ -324,9 +386,7 @@ func Test_rtp_validate(t *testing.T) {
 				},
 			},
 			args: args{
-				&Token{
-					ExpiryTime: fastime.Now().Add(time.Hour),
-				},
+				newToken(fastime.Now().Add(time.Hour)),
 			},
 			wantErr: true,
 		},
@@ -338,20 +398,157 @@ func Test_rtp_validate(t *testing.T) {
 				},
 			},
 			args: args{
-				&Token{
-					ExpiryTime: fastime.Now().Add(time.Hour),
-				},
+				newToken(fastime.Now().Add(time.Hour)),
+			},
+			wantErr: true,
+		},
+		{
+			name: "required field missing",
+			fields: fields{
+				pkp: verifierSuccess,
+			},
+			args: args{
+				func() *Token {
+					rt := newToken(fastime.Now().Add(time.Hour))
+					rt.Principal = ""
+					return rt
+				}(),
+			},
+			wantErr: true,
+		},
+		{
+			name: "empty role",
+			fields: fields{
+				pkp: verifierSuccess,
+			},
+			args: args{
+				func() *Token {
+					rt := newToken(fastime.Now().Add(time.Hour))
+					rt.Roles = []string{"users", ""}
+					return rt
+				}(),
+			},
+			wantErr: true,
+		},
+		{
+			name: "proxy user not checked",
+			fields: fields{
+				pkp: verifierSuccess,
+			},
+			args: args{
+				func() *Token {
+					rt := newToken(fastime.Now().Add(time.Hour))
+					rt.ProxyUser = "dummy.proxy"
+					return rt
+				}(),
+			},
+		},
+		{
+			name: "authorized proxy user",
+			fields: fields{
+				pkp:                  verifierSuccess,
+				authorizedProxyUsers: []string{"dummy.proxy"},
+			},
+			args: args{
+				func() *Token {
+					rt := newToken(fastime.Now().Add(time.Hour))
+					rt.ProxyUser = "dummy.proxy"
+					return rt
+				}(),
+			},
+		},
+		{
+			name: "unauthorized proxy user",
+			fields: fields{
+				pkp:                  verifierSuccess,
+				authorizedProxyUsers: []string{"dummy.proxy"},
+			},
+			args: args{
+				func() *Token {
+					rt := newToken(fastime.Now().Add(time.Hour))
+					rt.ProxyUser = "dummy.other"
+					return rt
+				}(),
 			},
 			wantErr: true,
 		},
+		{
+			name: "issued in the future within clock skew",
+			fields: fields{
+				pkp:       verifierSuccess,
+				clockSkew: 5 * time.Minute,
+			},
+			args: args{
+				func() *Token {
+					rt := newToken(fastime.Now().Add(time.Hour))
+					rt.TimeStamp = fastime.Now().Add(time.Minute)
+					return rt
+				}(),
+			},
+		},
+		{
+			name: "issued in the future beyond clock skew",
+			fields: fields{
+				pkp:       verifierSuccess,
+				clockSkew: 5 * time.Minute,
+			},
+			args: args{
+				func() *Token {
+					rt := newToken(fastime.Now().Add(time.Hour))
+					rt.TimeStamp = fastime.Now().Add(10 * time.Minute)
+					return rt
+				}(),
+			},
+			wantErr:      true,
+			wantErrCause: ErrRoleTokenNotYetValid,
+		},
+		{
+			name: "lifetime within max lifetime",
+			fields: fields{
+				pkp:         verifierSuccess,
+				maxLifetime: 2 * time.Hour,
+			},
+			args: args{
+				newToken(fastime.Now().Add(time.Hour)),
+			},
+		},
+		{
+			name: "lifetime exceeds max lifetime",
+			fields: fields{
+				pkp:         verifierSuccess,
+				maxLifetime: 2 * time.Hour,
+			},
+			args: args{
+				newToken(fastime.Now().Add(365 * 24 * time.Hour)),
+			},
+			wantErr:      true,
+			wantErrCause: ErrRoleTokenLifetimeExceeded,
+		},
+		{
+			name: "lifetime not checked",
+			fields: fields{
+				pkp: verifierSuccess,
+			},
+			args: args{
+				newToken(fastime.Now().Add(365 * 24 * time.Hour)),
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			r := &rtp{
-				pkp: tt.fields.pkp,
+				pkp:                  tt.fields.pkp,
+				authorizedProxyUsers: tt.fields.authorizedProxyUsers,
+				clockSkew:            tt.fields.clockSkew,
+				maxLifetime:          tt.fields.maxLifetime,
 			}
-			if err := r.validate(tt.args.rt); (err != nil) != tt.wantErr {
+			err := r.validate(tt.args.rt)
+			if (err != nil) != tt.wantErr {
 				t.Errorf("rtp.validate() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErrCause != nil && errors.Cause(err) != tt.wantErrCause {
+				t.Errorf("rtp.validate() error = %v, wantErrCause %v", err, tt.wantErrCause)
 			}
 		})
 	}
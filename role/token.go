@@ -17,6 +17,7 @@ limitations under the License.
 package role
 
 import (
+	"net"
 	"strconv"
 	"strings"
 	"time"
@@ -27,18 +28,21 @@ import (
 
 // Token represents role token data.
 type Token struct {
-	// Version    string   // required
-	Domain    string   // required
-	Roles     []string // required
-	Principal string   // required
-	// Host       string
-	// Salt       string    // required
+	Version    string   // required
+	Domain     string   // required
+	Roles      []string // required
+	Principal  string   // required
+	Host       string
+	Salt       string    // required
 	TimeStamp  time.Time // required
 	ExpiryTime time.Time // required
 	KeyID      string    // required
-	// IP         string
-	// ProxyUser  string
-	Signature string // required
+	KeyService string
+	IP         string
+	ProxyUser  string
+	Signature  string // required
+	// DomainCompleteRoleSet is true if the token has all the roles of the principal in the domain.
+	DomainCompleteRoleSet bool
 
 	UnsignedToken string
 }
@@ -46,8 +50,10 @@ type Token struct {
 // SetParams sets the value for corresponding key data.
 func (r *Token) SetParams(key, value string) error {
 	switch key {
-	// case "a":
-	// r.Salt = value
+	case "a":
+		r.Salt = value
+	case "c":
+		r.DomainCompleteRoleSet = value == "1"
 	case "d":
 		r.Domain = value
 	case "e":
@@ -56,10 +62,10 @@ func (r *Token) SetParams(key, value string) error {
 			return errors.Wrap(err, "invalid expiry time")
 		}
 		r.ExpiryTime = time.Unix(i, 0)
-	// case "h":
-	// r.Host = value
-	// case "i":
-	// r.IP = value
+	case "h":
+		r.Host = value
+	case "i":
+		r.IP = value
 	case "k":
 		r.KeyID = value
 	case "p":
@@ -74,15 +80,79 @@ func (r *Token) SetParams(key, value string) error {
 			return errors.Wrap(err, "invalid timestamp")
 		}
 		r.TimeStamp = time.Unix(i, 0)
-		// case "proxy":
-		// r.ProxyUser = value
-		// case "v":
-		// r.Version = value
+	case "proxy":
+		r.ProxyUser = value
+	case "v":
+		r.Version = value
+	case "z":
+		r.KeyService = value
+	default:
+		return errors.Wrapf(ErrRoleTokenInvalid, "unknown key %s", key)
 	}
 
 	return nil
 }
 
+// validateRequiredFields returns error if any of the required fields is missing.
+func (r *Token) validateRequiredFields() error {
+	var missing []string
+	if r.Version == "" {
+		missing = append(missing, "v")
+	}
+	if r.Domain == "" {
+		missing = append(missing, "d")
+	}
+	if len(r.Roles) == 0 {
+		missing = append(missing, "r")
+	}
+	for _, role := range r.Roles {
+		if role == "" {
+			missing = append(missing, "r")
+			break
+		}
+	}
+	if r.Principal == "" {
+		missing = append(missing, "p")
+	}
+	if r.Salt == "" {
+		missing = append(missing, "a")
+	}
+	if r.TimeStamp.IsZero() {
+		missing = append(missing, "t")
+	}
+	if r.ExpiryTime.IsZero() {
+		missing = append(missing, "e")
+	}
+	if r.KeyID == "" {
+		missing = append(missing, "k")
+	}
+	if r.Signature == "" {
+		missing = append(missing, "s")
+	}
+	if len(missing) != 0 {
+		return errors.Wrapf(ErrRoleTokenInvalid, "missing required fields %v", missing)
+	}
+	return nil
+}
+
+// ValidateIP returns error if the role token is issued for the IP address other than remoteAddr.
+// remoteAddr can be in the form of "host:port", e.g. http.Request.RemoteAddr. The role token without IP address is not restricted.
+func (r *Token) ValidateIP(remoteAddr string) error {
+	if r.IP == "" {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	tokIP := net.ParseIP(r.IP)
+	remoteIP := net.ParseIP(host)
+	if tokIP == nil || remoteIP == nil || !tokIP.Equal(remoteIP) {
+		return errors.Wrapf(ErrRoleTokenInvalid, "IP address mismatch, token: %s, remote: %s", r.IP, remoteAddr)
+	}
+	return nil
+}
+
 // Expired returns if the role token is expired or not.
 func (r *Token) Expired() bool {
 	return fastime.Now().After(r.ExpiryTime)
@@ -238,6 +238,148 @@ func TestToken_SetParams(t *testing.T) {
 				return nil
 			},
 		},
+		{
+			name:   "set param v success",
+			fields: fields{},
+			args: args{
+				key:   "v",
+				value: "Z1",
+			},
+			checkFunc: func(got *Token) error {
+				expected := &Token{
+					Version: "Z1",
+				}
+
+				if !reflect.DeepEqual(got, expected) {
+					return fmt.Errorf("error")
+				}
+
+				return nil
+			},
+		},
+		{
+			name:   "set param a success",
+			fields: fields{},
+			args: args{
+				key:   "a",
+				value: "dummya",
+			},
+			checkFunc: func(got *Token) error {
+				expected := &Token{
+					Salt: "dummya",
+				}
+
+				if !reflect.DeepEqual(got, expected) {
+					return fmt.Errorf("error")
+				}
+
+				return nil
+			},
+		},
+		{
+			name:   "set param h success",
+			fields: fields{},
+			args: args{
+				key:   "h",
+				value: "dummyh",
+			},
+			checkFunc: func(got *Token) error {
+				expected := &Token{
+					Host: "dummyh",
+				}
+
+				if !reflect.DeepEqual(got, expected) {
+					return fmt.Errorf("error")
+				}
+
+				return nil
+			},
+		},
+		{
+			name:   "set param i success",
+			fields: fields{},
+			args: args{
+				key:   "i",
+				value: "172.16.168.25",
+			},
+			checkFunc: func(got *Token) error {
+				expected := &Token{
+					IP: "172.16.168.25",
+				}
+
+				if !reflect.DeepEqual(got, expected) {
+					return fmt.Errorf("error")
+				}
+
+				return nil
+			},
+		},
+		{
+			name:   "set param proxy success",
+			fields: fields{},
+			args: args{
+				key:   "proxy",
+				value: "dummyproxy",
+			},
+			checkFunc: func(got *Token) error {
+				expected := &Token{
+					ProxyUser: "dummyproxy",
+				}
+
+				if !reflect.DeepEqual(got, expected) {
+					return fmt.Errorf("error")
+				}
+
+				return nil
+			},
+		},
+		{
+			name:   "set param z success",
+			fields: fields{},
+			args: args{
+				key:   "z",
+				value: "dummyz",
+			},
+			checkFunc: func(got *Token) error {
+				expected := &Token{
+					KeyService: "dummyz",
+				}
+
+				if !reflect.DeepEqual(got, expected) {
+					return fmt.Errorf("error")
+				}
+
+				return nil
+			},
+		},
+		{
+			name:   "set param c success",
+			fields: fields{},
+			args: args{
+				key:   "c",
+				value: "1",
+			},
+			checkFunc: func(got *Token) error {
+				expected := &Token{
+					DomainCompleteRoleSet: true,
+				}
+
+				if !reflect.DeepEqual(got, expected) {
+					return fmt.Errorf("error")
+				}
+
+				return nil
+			},
+		},
+		{
+			name:   "set unknown param fail",
+			fields: fields{},
+			args: args{
+				key:   "x",
+				value: "dummyx",
+			},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -307,3 +449,178 @@ func TestToken_Expired(t *testing.T) {
 		})
 	}
 }
+
+func TestToken_validateRequiredFields(t *testing.T) {
+	newToken := func() *Token {
+		return &Token{
+			Version:    "Z1",
+			Domain:     "dummy.sidecartest",
+			Roles:      []string{"users"},
+			Principal:  "takumats.tenant.test",
+			Salt:       "e55ee6ddc3e3c27c",
+			TimeStamp:  time.Unix(1550463321, 0),
+			ExpiryTime: time.Unix(9999999999, 0),
+			KeyID:      "0",
+			Signature:  "dummysignature",
+		}
+	}
+	tests := []struct {
+		name    string
+		token   *Token
+		wantErr bool
+	}{
+		{
+			name:  "all required fields",
+			token: newToken(),
+		},
+		{
+			name: "version missing",
+			token: func() *Token {
+				rt := newToken()
+				rt.Version = ""
+				return rt
+			}(),
+			wantErr: true,
+		},
+		{
+			name: "domain missing",
+			token: func() *Token {
+				rt := newToken()
+				rt.Domain = ""
+				return rt
+			}(),
+			wantErr: true,
+		},
+		{
+			name: "roles missing",
+			token: func() *Token {
+				rt := newToken()
+				rt.Roles = nil
+				return rt
+			}(),
+			wantErr: true,
+		},
+		{
+			name: "principal missing",
+			token: func() *Token {
+				rt := newToken()
+				rt.Principal = ""
+				return rt
+			}(),
+			wantErr: true,
+		},
+		{
+			name: "salt missing",
+			token: func() *Token {
+				rt := newToken()
+				rt.Salt = ""
+				return rt
+			}(),
+			wantErr: true,
+		},
+		{
+			name: "timestamp missing",
+			token: func() *Token {
+				rt := newToken()
+				rt.TimeStamp = time.Time{}
+				return rt
+			}(),
+			wantErr: true,
+		},
+		{
+			name: "key ID missing",
+			token: func() *Token {
+				rt := newToken()
+				rt.KeyID = ""
+				return rt
+			}(),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.token.validateRequiredFields(); (err != nil) != tt.wantErr {
+				t.Errorf("Token.validateRequiredFields() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestToken_ValidateIP(t *testing.T) {
+	type fields struct {
+		IP string
+	}
+	type args struct {
+		remoteAddr string
+	}
+	tests := []struct {
+		name    string
+		fields  fields
+		args    args
+		wantErr bool
+	}{
+		{
+			name: "token without IP",
+			args: args{
+				remoteAddr: "192.168.0.1:12345",
+			},
+		},
+		{
+			name: "IP match",
+			fields: fields{
+				IP: "172.16.168.25",
+			},
+			args: args{
+				remoteAddr: "172.16.168.25:12345",
+			},
+		},
+		{
+			name: "IP match without port",
+			fields: fields{
+				IP: "172.16.168.25",
+			},
+			args: args{
+				remoteAddr: "172.16.168.25",
+			},
+		},
+		{
+			name: "IPv6 match",
+			fields: fields{
+				IP: "2001:db8::1",
+			},
+			args: args{
+				remoteAddr: "[2001:db8:0::1]:12345",
+			},
+		},
+		{
+			name: "IP mismatch",
+			fields: fields{
+				IP: "172.16.168.25",
+			},
+			args: args{
+				remoteAddr: "172.16.168.26:12345",
+			},
+			wantErr: true,
+		},
+		{
+			name: "empty remote address",
+			fields: fields{
+				IP: "172.16.168.25",
+			},
+			args: args{
+				remoteAddr: "",
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Token{
+				IP: tt.fields.IP,
+			}
+			if err := r.ValidateIP(tt.args.remoteAddr); (err != nil) != tt.wantErr {
+				t.Errorf("Token.ValidateIP() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
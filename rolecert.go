@@ -0,0 +1,200 @@
+/*
+Copyright (C)  2018 Yahoo Japan Corporation Athenz team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package authorizerd
+
+import (
+	"crypto/x509"
+	"net/url"
+	"strings"
+
+	"github.com/kpango/fastime"
+	"github.com/pkg/errors"
+)
+
+const (
+	// roleCertCNDelimiter is the delimiter between the domain and the role name in the subject CN or the email SAN, e.g. "domain:role.name"
+	roleCertCNDelimiter = ":role."
+	// athenzPrincipalURIPrefix is the prefix of the principal URI, e.g. "athenz://principal/domain.service"
+	athenzPrincipalURIPrefix = "athenz://principal/"
+	// spiffeScheme is the scheme of the SPIFFE ID, e.g. "spiffe://domain/ra/role" and "spiffe://domain/sa/service"
+	spiffeScheme = "spiffe"
+	// spiffeRolePath is the path prefix of the role in the SPIFFE ID
+	spiffeRolePath = "/ra/"
+	// spiffeServicePath is the path prefix of the service in the SPIFFE ID
+	spiffeServicePath = "/sa/"
+)
+
+// roleCert represents the roles and the principal encoded in the role certificates.
+type roleCert struct {
+	// name of the principal, e.g. "domain.service"
+	name string
+	// domains in the order of appearance
+	domains     []string
+	domainRoles map[string][]string
+	issueTime   int64
+	expiryTime  int64
+}
+
+// parseRoleCert extracts the roles and the principal from the leaf role certificate (peerCerts[0]).
+// The roles are encoded in the URI with roleCertURIPrefix, the SPIFFE ID "spiffe://domain/ra/role", the subject CN or the email SAN "domain:role.name".
+// The principal is encoded in the URI "athenz://principal/domain.service" or the SPIFFE ID "spiffe://domain/sa/service".
+// The other certificates are sent by the client without the proof of the private key, so they are never trusted for the roles.
+func (a *authority) parseRoleCert(peerCerts []*x509.Certificate) *roleCert {
+	rc := &roleCert{
+		domainRoles: make(map[string][]string),
+	}
+	if len(peerCerts) == 0 {
+		return rc
+	}
+	drcheck := make(map[string]struct{})
+	addRole := func(domain, roleName string) {
+		if domain == "" || roleName == "" {
+			return
+		}
+		// duplicated role check
+		key := domain + roleCertCNDelimiter + roleName
+		if _, ok := drcheck[key]; ok {
+			return
+		}
+		drcheck[key] = struct{}{}
+		if _, ok := rc.domainRoles[domain]; !ok {
+			rc.domains = append(rc.domains, domain)
+		}
+		rc.domainRoles[domain] = append(rc.domainRoles[domain], roleName)
+	}
+
+	cert := peerCerts[0]
+	for _, uri := range cert.URIs {
+		if a.roleCertURIPrefix != "" && strings.HasPrefix(uri.String(), a.roleCertURIPrefix) {
+			dr := strings.SplitN(strings.TrimPrefix(uri.String(), a.roleCertURIPrefix), "/", 2) // domain/role
+			if len(dr) == 2 {
+				addRole(dr[0], dr[1])
+			}
+			continue
+		}
+		if uri.Scheme == spiffeScheme && strings.HasPrefix(uri.Path, spiffeRolePath) {
+			addRole(uri.Host, strings.TrimPrefix(uri.Path, spiffeRolePath))
+		}
+	}
+	addRole(splitRoleName(cert.Subject.CommonName))
+	for _, email := range cert.EmailAddresses {
+		if at := strings.LastIndex(email, "@"); at >= 0 {
+			email = email[:at]
+		}
+		addRole(splitRoleName(email))
+	}
+
+	rc.name = principalName(cert.URIs)
+	rc.issueTime = cert.NotBefore.Unix()
+	rc.expiryTime = cert.NotAfter.Unix()
+	return rc
+}
+
+// principal returns the principal of the domain. If the domain is empty, the first domain is used.
+func (rc *roleCert) principal(domain string) Principal {
+	if domain == "" && len(rc.domains) != 0 {
+		domain = rc.domains[0]
+	}
+	return &principal{
+		name:       rc.name,
+		roles:      rc.domainRoles[domain],
+		domain:     domain,
+		issueTime:  rc.issueTime,
+		expiryTime: rc.expiryTime,
+	}
+}
+
+// splitRoleName splits "domain:role.name" into the domain and the role name. It returns empty strings if not matched.
+func splitRoleName(s string) (string, string) {
+	dr := strings.SplitN(s, roleCertCNDelimiter, 2)
+	if len(dr) != 2 {
+		return "", ""
+	}
+	return dr[0], dr[1]
+}
+
+// principalName returns the principal name in the URIs, or an empty string if not found.
+func principalName(uris []*url.URL) string {
+	for _, uri := range uris {
+		if strings.HasPrefix(uri.String(), athenzPrincipalURIPrefix) {
+			return strings.TrimPrefix(uri.String(), athenzPrincipalURIPrefix)
+		}
+	}
+	for _, uri := range uris {
+		if uri.Scheme == spiffeScheme && strings.HasPrefix(uri.Path, spiffeServicePath) {
+			if service := strings.TrimPrefix(uri.Path, spiffeServicePath); service != "" && uri.Host != "" {
+				return uri.Host + "." + service
+			}
+		}
+	}
+	return ""
+}
+
+// validateRoleCert validates the leaf role certificate (peerCerts[0]) by the issuer DN, the validity period and the chain to the trusted CA.
+// The leaf is the only certificate the roles are extracted from (see parseRoleCert), the other certificates are used only to build the chain.
+// Each validation is skipped if not configured.
+func (a *authority) validateRoleCert(peerCerts []*x509.Certificate) error {
+	if len(peerCerts) == 0 {
+		return errors.New("empty role certificate")
+	}
+	cert := peerCerts[0]
+
+	if len(a.roleCertIssuers) != 0 {
+		issuer := cert.Issuer.String()
+		matched := false
+		for _, i := range a.roleCertIssuers {
+			if i == issuer {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return errors.Wrapf(ErrCertMismatchIssuer, "issuer: %s", issuer)
+		}
+	}
+
+	now := fastime.Now()
+	if a.roleCertVerifyExpiry {
+		if now.Before(cert.NotBefore) {
+			return errors.Wrapf(ErrCertNotYetValid, "not before: %s", cert.NotBefore)
+		}
+		if now.After(cert.NotAfter) {
+			return errors.Wrapf(ErrCertExpired, "not after: %s", cert.NotAfter)
+		}
+	}
+
+	if a.roleCertCAPool != nil {
+		intermediates := x509.NewCertPool()
+		for _, c := range peerCerts[1:] {
+			intermediates.AddCert(c)
+		}
+		_, err := cert.Verify(x509.VerifyOptions{
+			Roots:         a.roleCertCAPool,
+			Intermediates: intermediates,
+			CurrentTime:   now,
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		})
+		if err != nil {
+			if ie, ok := err.(x509.CertificateInvalidError); ok && ie.Reason == x509.Expired {
+				return errors.Wrap(ErrCertExpired, err.Error())
+			}
+			return errors.Wrap(ErrCertUntrusted, err.Error())
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,468 @@
+/*
+Copyright (C)  2018 Yahoo Japan Corporation Athenz team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package authorizerd
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kpango/gache"
+	"github.com/pkg/errors"
+	"github.com/yahoojapan/athenz-authorizer/v5/access"
+	"github.com/yahoojapan/athenz-authorizer/v5/policy"
+)
+
+// newTestCert returns a certificate signed by the parent (self-signed if parent is nil) and its private key.
+func newTestCert(t *testing.T, cn string, isCA bool, notBefore, notAfter time.Time, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: cn, Organization: []string{"Athenz"}},
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		IsCA:                  isCA,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	if parent == nil {
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+func Test_authority_validateRoleCert(t *testing.T) {
+	now := time.Now()
+	ca, caKey := newTestCert(t, "Athenz CA", true, now.Add(-time.Hour), now.Add(time.Hour), nil, nil)
+	intermediate, intermediateKey := newTestCert(t, "Athenz Intermediate CA", true, now.Add(-time.Hour), now.Add(time.Hour), ca, caKey)
+	leaf, _ := newTestCert(t, "coretech.service", false, now.Add(-time.Hour), now.Add(time.Hour), intermediate, intermediateKey)
+	expired, _ := newTestCert(t, "coretech.service", false, now.Add(-2*time.Hour), now.Add(-time.Hour), ca, caKey)
+	notYetValid, _ := newTestCert(t, "coretech.service", false, now.Add(time.Hour), now.Add(2*time.Hour), ca, caKey)
+	otherCA, otherCAKey := newTestCert(t, "Other CA", true, now.Add(-time.Hour), now.Add(time.Hour), nil, nil)
+	untrusted, _ := newTestCert(t, "coretech.service", false, now.Add(-time.Hour), now.Add(time.Hour), otherCA, otherCAKey)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+
+	type fields struct {
+		roleCertCAPool       *x509.CertPool
+		roleCertVerifyExpiry bool
+		roleCertIssuers      []string
+	}
+	tests := []struct {
+		name      string
+		fields    fields
+		peerCerts []*x509.Certificate
+		wantErr   error
+	}{
+		{
+			name:      "no validation configured",
+			peerCerts: []*x509.Certificate{expired},
+		},
+		{
+			name:      "empty role certificate",
+			peerCerts: []*x509.Certificate{},
+			wantErr:   errors.New("empty role certificate"),
+		},
+		{
+			name: "issuer matched",
+			fields: fields{
+				roleCertIssuers: []string{"CN=dummy", "CN=Athenz Intermediate CA,O=Athenz"},
+			},
+			peerCerts: []*x509.Certificate{leaf},
+		},
+		{
+			name: "issuer mismatched",
+			fields: fields{
+				roleCertIssuers: []string{"CN=Athenz CA,O=Athenz"},
+			},
+			peerCerts: []*x509.Certificate{leaf},
+			wantErr:   ErrCertMismatchIssuer,
+		},
+		{
+			name: "expired",
+			fields: fields{
+				roleCertVerifyExpiry: true,
+			},
+			peerCerts: []*x509.Certificate{expired},
+			wantErr:   ErrCertExpired,
+		},
+		{
+			name: "not yet valid",
+			fields: fields{
+				roleCertVerifyExpiry: true,
+			},
+			peerCerts: []*x509.Certificate{notYetValid},
+			wantErr:   ErrCertNotYetValid,
+		},
+		{
+			name: "chain verified with intermediate",
+			fields: fields{
+				roleCertCAPool: pool,
+			},
+			peerCerts: []*x509.Certificate{leaf, intermediate},
+		},
+		{
+			name: "untrusted leaf with trusted certificates appended",
+			fields: fields{
+				roleCertCAPool: pool,
+			},
+			peerCerts: []*x509.Certificate{untrusted, leaf, intermediate},
+			wantErr:   ErrCertUntrusted,
+		},
+		{
+			name: "chain without intermediate",
+			fields: fields{
+				roleCertCAPool: pool,
+			},
+			peerCerts: []*x509.Certificate{leaf},
+			wantErr:   ErrCertUntrusted,
+		},
+		{
+			name: "chain of untrusted CA",
+			fields: fields{
+				roleCertCAPool: pool,
+			},
+			peerCerts: []*x509.Certificate{untrusted, otherCA},
+			wantErr:   ErrCertUntrusted,
+		},
+		{
+			name: "chain of expired certificate",
+			fields: fields{
+				roleCertCAPool: pool,
+			},
+			peerCerts: []*x509.Certificate{expired},
+			wantErr:   ErrCertExpired,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &authority{
+				roleCertCAPool:       tt.fields.roleCertCAPool,
+				roleCertVerifyExpiry: tt.fields.roleCertVerifyExpiry,
+				roleCertIssuers:      tt.fields.roleCertIssuers,
+			}
+			err := a.validateRoleCert(tt.peerCerts)
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Errorf("authority.validateRoleCert() error = %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Errorf("authority.validateRoleCert() error = nil, want %v", tt.wantErr)
+				return
+			}
+			if cause := errors.Cause(err); cause != tt.wantErr && cause.Error() != tt.wantErr.Error() {
+				t.Errorf("authority.validateRoleCert() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_authority_parseRoleCert(t *testing.T) {
+	mustParseURLs := func(rawurls ...string) []*url.URL {
+		uris := make([]*url.URL, 0, len(rawurls))
+		for _, raw := range rawurls {
+			u, err := url.Parse(raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			uris = append(uris, u)
+		}
+		return uris
+	}
+	notBefore := time.Unix(1500000000, 0)
+	notAfter := time.Unix(1600000000, 0)
+
+	tests := []struct {
+		name      string
+		peerCerts []*x509.Certificate
+		want      *roleCert
+	}{
+		{
+			name: "roles in the athenz role URI and principal in the athenz principal URI",
+			peerCerts: []*x509.Certificate{
+				{
+					URIs:      mustParseURLs("athenz://role/coretech/readers", "athenz://role/coretech/writers", "athenz://principal/coretech.api"),
+					NotBefore: notBefore,
+					NotAfter:  notAfter,
+				},
+			},
+			want: &roleCert{
+				name:    "coretech.api",
+				domains: []string{"coretech"},
+				domainRoles: map[string][]string{
+					"coretech": {"readers", "writers"},
+				},
+				issueTime:  notBefore.Unix(),
+				expiryTime: notAfter.Unix(),
+			},
+		},
+		{
+			name: "roles in the subject CN and the email SAN",
+			peerCerts: []*x509.Certificate{
+				{
+					Subject:        pkix.Name{CommonName: "coretech:role.readers"},
+					EmailAddresses: []string{"sports:role.writers@zts.athenz.io", "coretech:role.readers@zts.athenz.io", "coretech.api@zts.athenz.io"},
+				},
+			},
+			want: &roleCert{
+				domains: []string{"coretech", "sports"},
+				domainRoles: map[string][]string{
+					"coretech": {"readers"},
+					"sports":   {"writers"},
+				},
+				issueTime:  time.Time{}.Unix(),
+				expiryTime: time.Time{}.Unix(),
+			},
+		},
+		{
+			name: "role and principal in the SPIFFE ID",
+			peerCerts: []*x509.Certificate{
+				{
+					URIs: mustParseURLs("spiffe://coretech/ra/readers", "spiffe://sports/sa/api"),
+				},
+			},
+			want: &roleCert{
+				name:    "sports.api",
+				domains: []string{"coretech"},
+				domainRoles: map[string][]string{
+					"coretech": {"readers"},
+				},
+				issueTime:  time.Time{}.Unix(),
+				expiryTime: time.Time{}.Unix(),
+			},
+		},
+		{
+			name: "athenz principal URI preferred over SPIFFE ID",
+			peerCerts: []*x509.Certificate{
+				{
+					URIs: mustParseURLs("spiffe://sports/sa/api", "athenz://principal/coretech.api"),
+				},
+			},
+			want: &roleCert{
+				name:        "coretech.api",
+				domainRoles: map[string][]string{},
+				issueTime:   time.Time{}.Unix(),
+				expiryTime:  time.Time{}.Unix(),
+			},
+		},
+		{
+			name: "roles and principal only in the leaf certificate",
+			peerCerts: []*x509.Certificate{
+				{
+					Subject: pkix.Name{CommonName: "coretech:role.readers"},
+				},
+				{
+					Subject:        pkix.Name{CommonName: "victim:role.admin"},
+					EmailAddresses: []string{"victim:role.admin2@zts.athenz.io"},
+					URIs:           mustParseURLs("athenz://role/victim/admin3", "spiffe://victim/ra/admin4", "athenz://principal/intermediate.ca"),
+				},
+			},
+			want: &roleCert{
+				domains: []string{"coretech"},
+				domainRoles: map[string][]string{
+					"coretech": {"readers"},
+				},
+				issueTime:  time.Time{}.Unix(),
+				expiryTime: time.Time{}.Unix(),
+			},
+		},
+		{
+			name:      "no certificate",
+			peerCerts: []*x509.Certificate{},
+			want: &roleCert{
+				domainRoles: map[string][]string{},
+			},
+		},
+		{
+			name: "invalid encoded roles",
+			peerCerts: []*x509.Certificate{
+				{
+					Subject:        pkix.Name{CommonName: "coretech.api"},
+					EmailAddresses: []string{":role.readers@zts.athenz.io", "coretech:role.@zts.athenz.io"},
+					URIs:           mustParseURLs("athenz://role/coretech", "spiffe://coretech/ra/", "spiffe://coretech/sa/"),
+				},
+			},
+			want: &roleCert{
+				domainRoles: map[string][]string{},
+				issueTime:   time.Time{}.Unix(),
+				expiryTime:  time.Time{}.Unix(),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &authority{
+				roleCertURIPrefix: "athenz://role/",
+			}
+			if got := a.parseRoleCert(tt.peerCerts); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("authority.parseRoleCert() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_authority_AuthorizeRoleCert_forgedCertificate(t *testing.T) {
+	now := time.Now()
+	ca, caKey := newTestCert(t, "Athenz CA", true, now.Add(-time.Hour), now.Add(time.Hour), nil, nil)
+	leaf, _ := newTestCert(t, "coretech:role.readers", false, now.Add(-time.Hour), now.Add(time.Hour), ca, caKey)
+	// appended by the client to the TLS chain, not signed by the trusted CA
+	forged, _ := newTestCert(t, "victim:role.admin", false, now.Add(-time.Hour), now.Add(time.Hour), nil, nil)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+
+	var checked []string
+	a := &authority{
+		roleCertURIPrefix: "athenz://role/",
+		roleCertCAPool:    pool,
+		policyd: &PolicydMock{
+			CheckPolicyFunc: func(ctx context.Context, domain string, roles []string, action, resource string) error {
+				checked = append(checked, domain)
+				if domain == "victim" {
+					return nil
+				}
+				return policy.ErrNoMatch
+			},
+		},
+	}
+	p, err := a.AuthorizeRoleCert(context.Background(), []*x509.Certificate{leaf, forged}, "act", "res")
+	if err == nil {
+		t.Errorf("authority.AuthorizeRoleCert() principal = %v, want error", p)
+	}
+	if !reflect.DeepEqual(checked, []string{"coretech"}) {
+		t.Errorf("authority.AuthorizeRoleCert() checked domains = %v, want %v", checked, []string{"coretech"})
+	}
+}
+
+func Test_roleCert_principal(t *testing.T) {
+	rc := &roleCert{
+		name:    "coretech.api",
+		domains: []string{"coretech", "sports"},
+		domainRoles: map[string][]string{
+			"coretech": {"readers"},
+			"sports":   {"writers"},
+		},
+		issueTime:  1500000000,
+		expiryTime: 1600000000,
+	}
+	tests := []struct {
+		name   string
+		domain string
+		want   Principal
+	}{
+		{
+			name:   "specified domain",
+			domain: "sports",
+			want: &principal{
+				name:       "coretech.api",
+				roles:      []string{"writers"},
+				domain:     "sports",
+				issueTime:  1500000000,
+				expiryTime: 1600000000,
+			},
+		},
+		{
+			name:   "first domain if empty",
+			domain: "",
+			want: &principal{
+				name:       "coretech.api",
+				roles:      []string{"readers"},
+				domain:     "coretech",
+				issueTime:  1500000000,
+				expiryTime: 1600000000,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rc.principal(tt.domain); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("roleCert.principal() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_authority_initAuthorizers_clientCertWithoutRoles(t *testing.T) {
+	now := time.Now()
+	cert, _ := newTestCert(t, "domain.service", false, now.Add(-time.Hour), now.Add(time.Hour), nil, nil)
+	a := &authority{
+		enableRoleCert:    true,
+		accessTokenParam:  AccessTokenParam{enable: true},
+		combinationPolicy: FirstPresent,
+		accessProcessor: &AccessProcessorMock{
+			atc: &access.OAuth2AccessTokenClaim{
+				Scope: []string{"role"},
+				BaseClaim: access.BaseClaim{
+					StandardClaims: access.StandardClaims{
+						Audience: "domain",
+						Subject:  "domain.service",
+					},
+				},
+			},
+		},
+		policyd: &PolicydMock{
+			CheckPolicyFunc: func(ctx context.Context, domain string, roles []string, action, resource string) error {
+				if domain != "domain" || len(roles) != 1 || roles[0] != "role" {
+					return errors.New("domain/roles mismatch")
+				}
+				return nil
+			},
+		},
+		cache:    gache.New(),
+		cacheExp: time.Minute,
+	}
+	if err := a.initAuthorizers(); err != nil {
+		t.Fatalf("authority.initAuthorizers() error = %v", err)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "https://athenz.io/", nil)
+	r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+	r.Header.Set("Authorization", "Bearer dummyTok")
+	p, err := a.Authorize(r, "dummyAct", "dummyRes")
+	if err != nil {
+		t.Fatalf("authority.Authorize() error = %v", err)
+	}
+	if p.Name() != "domain.service" || p.Domain() != "domain" {
+		t.Errorf("authority.Authorize() = %+v, want the principal of the access token", p)
+	}
+}